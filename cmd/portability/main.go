@@ -29,19 +29,23 @@ func main() {
 		exportCmd := flag.NewFlagSet("export", flag.ExitOnError)
 		outFile := exportCmd.String("o", "export.json", "output file path")
 		includeDeleted := exportCmd.Bool("include-deleted", false, "include soft-deleted records")
+		expandVariables := exportCmd.Bool("expand-variables", false, "replace {{var.key}} references in page content with their values")
+		includeComments := exportCmd.Bool("include-comments", false, "include comment threads on pages")
+		space := exportCmd.String("space", "default", "name of the space to export")
 		exportCmd.Parse(os.Args[2:])
-		runExport(*outFile, *includeDeleted)
+		runExport(*outFile, *space, portability.ExportOptions{IncludeDeleted: *includeDeleted, ExpandVariables: *expandVariables, IncludeComments: *includeComments})
 
 	case "import":
 		importCmd := flag.NewFlagSet("import", flag.ExitOnError)
 		inFile := importCmd.String("i", "", "input file path (required)")
 		dryRun := importCmd.Bool("dry-run", false, "validate without writing to database")
+		space := importCmd.String("space", "default", "name of the space to import into")
 		importCmd.Parse(os.Args[2:])
 		if *inFile == "" {
 			fmt.Fprintf(os.Stderr, "Error: -i flag is required\n")
 			os.Exit(1)
 		}
-		runImport(*inFile, *dryRun)
+		runImport(*inFile, *space, *dryRun)
 
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown subcommand: %s\nUsage: %s <export|import> [flags]\n", subcommand, os.Args[0])
@@ -62,12 +66,22 @@ func connectDB(ctx context.Context) *pgxpool.Pool {
 	return pool
 }
 
-func runExport(outFile string, includeDeleted bool) {
+// spaceID looks up the id of the space with the given name.
+func spaceID(ctx context.Context, pool *pgxpool.Pool, name string) string {
+	var id string
+	if err := pool.QueryRow(ctx, `SELECT id FROM spaces WHERE name = $1`, name).Scan(&id); err != nil {
+		slog.Error("unknown space", "space", name, "error", err)
+		os.Exit(1)
+	}
+	return id
+}
+
+func runExport(outFile, space string, opts portability.ExportOptions) {
 	ctx := context.Background()
 	pool := connectDB(ctx)
 	defer pool.Close()
 
-	bundle, err := portability.Export(ctx, pool, includeDeleted)
+	bundle, err := portability.Export(ctx, pool, spaceID(ctx, pool, space), opts)
 	if err != nil {
 		slog.Error("export failed", "error", err)
 		os.Exit(1)
@@ -87,7 +101,7 @@ func runExport(outFile string, includeDeleted bool) {
 	slog.Info("export complete", "file", outFile, "size_bytes", len(data))
 }
 
-func runImport(inFile string, dryRun bool) {
+func runImport(inFile, space string, dryRun bool) {
 	ctx := context.Background()
 	pool := connectDB(ctx)
 	defer pool.Close()
@@ -124,7 +138,7 @@ func runImport(inFile string, dryRun bool) {
 		return
 	}
 
-	if err := portability.Import(ctx, pool, &bundle, false); err != nil {
+	if err := portability.Import(ctx, pool, spaceID(ctx, pool, space), &bundle, false); err != nil {
 		slog.Error("import failed", "error", err)
 		os.Exit(1)
 	}
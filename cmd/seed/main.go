@@ -88,8 +88,8 @@ func main() {
 	}
 	slog.Info("migrations applied")
 
-	// Ensure site_settings row exists
-	_, err = pool.Exec(ctx, `INSERT INTO site_settings (singleton) VALUES (TRUE) ON CONFLICT DO NOTHING`)
+	// Everything is seeded into the default space
+	_, err = pool.Exec(ctx, `INSERT INTO site_settings (space_id) VALUES ($1) ON CONFLICT (space_id) DO NOTHING`, db.DefaultSpaceID)
 	if err != nil {
 		slog.Error("failed to ensure site_settings", "error", err)
 		os.Exit(1)
@@ -97,10 +97,10 @@ func main() {
 
 	// Ensure default roles exist
 	_, err = pool.Exec(ctx,
-		`INSERT INTO roles (name, description) VALUES
-			('admin', 'Full access to all features'),
-			('editor', 'Can edit content')
-		 ON CONFLICT (name) DO NOTHING`)
+		`INSERT INTO roles (space_id, name, description) VALUES
+			($1, 'admin', 'Full access to all features'),
+			($1, 'editor', 'Can edit content')
+		 ON CONFLICT (space_id, name) DO NOTHING`, db.DefaultSpaceID)
 	if err != nil {
 		slog.Error("failed to ensure default roles", "error", err)
 		os.Exit(1)
@@ -122,6 +122,10 @@ func main() {
 			slog.Error("failed to create admin user", "error", err)
 			os.Exit(1)
 		}
+		if err := queries.AddSpaceMember(ctx, user.ID); err != nil {
+			slog.Error("failed to add admin user to space", "error", err)
+			os.Exit(1)
+		}
 		if err := queries.AssignRole(ctx, user.ID, "admin"); err != nil {
 			slog.Error("failed to assign admin role", "error", err)
 			os.Exit(1)
@@ -146,10 +150,10 @@ func main() {
 	// Upsert sections (id is auto-generated UUID, name is the slug)
 	for _, s := range sections {
 		_, err := pool.Exec(ctx,
-			`INSERT INTO sections (name, title, description, sort_order)
-			 VALUES ($1, $2, $3, $4)
-			 ON CONFLICT (name) WHERE deleted = false DO UPDATE SET title=$2, description=$3, sort_order=$4, updated_at=now()`,
-			s.Name, s.Title, s.Description, s.SortOrder)
+			`INSERT INTO sections (space_id, name, title, description, sort_order)
+			 VALUES ($5, $1, $2, $3, $4)
+			 ON CONFLICT (space_id, name) WHERE deleted = false DO UPDATE SET title=$2, description=$3, sort_order=$4, updated_at=now()`,
+			s.Name, s.Title, s.Description, s.SortOrder, db.DefaultSpaceID)
 		if err != nil {
 			slog.Error("failed to upsert section", "section", s.Name, "error", err)
 			os.Exit(1)
@@ -161,7 +165,7 @@ func main() {
 	totalPages := 0
 	for _, s := range sections {
 		var sectionID string
-		err = pool.QueryRow(ctx, `SELECT id FROM sections WHERE name = $1`, s.Name).Scan(&sectionID)
+		err = pool.QueryRow(ctx, `SELECT id FROM sections WHERE space_id = $2 AND name = $1 AND deleted = false`, s.Name, db.DefaultSpaceID).Scan(&sectionID)
 		if err != nil {
 			slog.Error("failed to find section", "section", s.Name, "error", err)
 			os.Exit(1)
@@ -173,6 +177,16 @@ func main() {
 			os.Exit(1)
 		}
 
+		// _index.md holds the section's landing page
+		if data, err := fs.ReadFile(contentFS, s.Name+"/_index.md"); err == nil {
+			_, body := parseFrontMatter(data)
+			_, err = pool.Exec(ctx, `UPDATE sections SET landing_md = $1 WHERE id = $2`, strings.TrimSpace(string(body)), sectionID)
+			if err != nil {
+				slog.Error("failed to set section landing page", "section", s.Name, "error", err)
+				os.Exit(1)
+			}
+		}
+
 		var filenames []string
 		for _, e := range entries {
 			name := e.Name()
@@ -278,17 +292,17 @@ func main() {
 
 		// Look up section UUID by name
 		var sectionID string
-		err = pool.QueryRow(ctx, `SELECT id FROM sections WHERE name = $1`, sectionName).Scan(&sectionID)
+		err = pool.QueryRow(ctx, `SELECT id FROM sections WHERE space_id = $2 AND name = $1 AND deleted = false`, sectionName, db.DefaultSpaceID).Scan(&sectionID)
 		if err != nil {
 			slog.Error("failed to find section for image", "filename", name, "section_name", sectionName, "error", err)
 			continue
 		}
 
 		_, err = pool.Exec(ctx,
-			`INSERT INTO images (filename, content_type, data, section_id)
-			 VALUES ($1, $2, $3, $4)
-			 ON CONFLICT (filename) DO UPDATE SET content_type=$2, data=$3, section_id=$4`,
-			name, contentType, data, sectionID)
+			`INSERT INTO images (space_id, filename, content_type, data, section_id)
+			 VALUES ($5, $1, $2, $3, $4)
+			 ON CONFLICT (space_id, filename) DO UPDATE SET content_type=$2, data=$3, section_id=$4`,
+			name, contentType, data, sectionID, db.DefaultSpaceID)
 		if err != nil {
 			slog.Error("failed to upsert image", "filename", name, "error", err)
 			os.Exit(1)
@@ -305,8 +319,8 @@ func main() {
 	}
 	for secName, role := range sectionRoles {
 		_, err := pool.Exec(ctx,
-			`UPDATE sections SET required_role = $2 WHERE name = $1`,
-			secName, role)
+			`UPDATE sections SET required_role = $2 WHERE space_id = $3 AND name = $1`,
+			secName, role, db.DefaultSpaceID)
 		if err != nil {
 			slog.Error("failed to set required_role", "section", secName, "error", err)
 			os.Exit(1)
@@ -322,8 +336,8 @@ func main() {
 	}
 	for _, r := range partnerRoles {
 		_, err := pool.Exec(ctx,
-			`INSERT INTO roles (name, description) VALUES ($1, $2) ON CONFLICT (name) DO NOTHING`,
-			r.Name, r.Desc)
+			`INSERT INTO roles (space_id, name, description) VALUES ($3, $1, $2) ON CONFLICT (space_id, name) DO NOTHING`,
+			r.Name, r.Desc, db.DefaultSpaceID)
 		if err != nil {
 			slog.Error("failed to upsert role", "role", r.Name, "error", err)
 			os.Exit(1)
@@ -345,6 +359,10 @@ func main() {
 			slog.Error("failed to create editor user", "error", err)
 			os.Exit(1)
 		}
+		if err := queries.AddSpaceMember(ctx, u.ID); err != nil {
+			slog.Error("failed to add editor user to space", "error", err)
+			os.Exit(1)
+		}
 		if err := queries.AssignRole(ctx, u.ID, "editor"); err != nil {
 			slog.Error("failed to assign editor role", "error", err)
 			os.Exit(1)
@@ -15,6 +15,7 @@ import (
 	"docgen/config"
 	"docgen/handlers"
 	"docgen/internal/db"
+	"docgen/internal/markdown"
 
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/pgx/v5"
@@ -64,6 +65,8 @@ func main() {
 		"templates_dir", config.TemplatesDir(),
 		"content_dir", config.ContentDir(),
 		"static_dir", config.StaticDir(),
+		"diagram_mermaid_cmd", config.DiagramMermaidCmd(),
+		"diagram_plantuml_cmd", config.DiagramPlantUMLCmd(),
 		"smtp_host", config.SMTPHost(),
 		"smtp_port", config.SMTPPort(),
 		"smtp_user", config.SMTPUser(),
@@ -112,18 +115,20 @@ func main() {
 		slog.Info("migrations applied")
 	}
 
-	// Ensure site_settings row exists
-	if _, err := pool.Exec(ctx, `INSERT INTO site_settings (singleton) VALUES (TRUE) ON CONFLICT DO NOTHING`); err != nil {
+	// Ensure the default space's site_settings row exists
+	if _, err := pool.Exec(ctx, `INSERT INTO site_settings (space_id) VALUES ($1) ON CONFLICT (space_id) DO NOTHING`, db.DefaultSpaceID); err != nil {
 		slog.Error("failed to ensure site_settings", "error", err)
 		os.Exit(1)
 	}
 
-	// Ensure default roles exist
+	// Ensure default roles exist in every space
 	if _, err := pool.Exec(ctx,
-		`INSERT INTO roles (name, description) VALUES
+		`INSERT INTO roles (space_id, name, description)
+		 SELECT s.id, r.name, r.description FROM spaces s CROSS JOIN (VALUES
 			('admin', 'Full access to all features'),
-			('editor', 'Can edit content')
-		 ON CONFLICT (name) DO NOTHING`); err != nil {
+			('editor', 'Can edit content'),
+			('reviewer', 'Can review and approve page changes')) AS r(name, description)
+		 ON CONFLICT (space_id, name) DO NOTHING`); err != nil {
 		slog.Error("failed to ensure default roles", "error", err)
 		os.Exit(1)
 	}
@@ -134,25 +139,43 @@ func main() {
 
 	h := &handlers.Handlers{
 		DB:             &db.Queries{Pool: pool},
+		StaticFS:       staticFS,
 		DefaultFavicon: defaultFavicon,
 	}
 	h.InitFaviconVersion(ctx)
 
+	// Server-side diagram rendering (optional, falls back to client-side)
+	diagrams := handlers.NewDiagramRenderer(h.DB, config.DiagramMermaidCmd(), config.DiagramPlantUMLCmd())
+	if len(diagrams.Commands) > 0 {
+		markdown.SetDiagramRenderer(diagrams.Render)
+	}
+
+	// User interface translations
+	ui, err := handlers.LoadUICatalog(docgen.ResolveFS(config.LocalesDir(), docgen.EmbeddedLocales()))
+	if err != nil {
+		slog.Error("failed to load message catalogs", "error", err)
+		os.Exit(1)
+	}
+	h.UI = ui
+
 	// Parse templates with custom functions (includes faviconVersion from h)
+	// once per user interface language
 	templatesFS := docgen.ResolveFS(config.TemplatesDir(), docgen.EmbeddedTemplates())
 	funcMap := template.FuncMap{
 		"formatBytes": handlers.FormatBytes,
+		"themeColor":  handlers.ThemeColor,
 	}
 	for k, v := range h.FaviconVersionFunc() {
 		funcMap[k] = v
 	}
-	tmpl, err := template.New("").Funcs(funcMap).ParseFS(templatesFS, "*.html")
-	if err != nil {
+	for k, v := range h.StaticAssetFunc() {
+		funcMap[k] = v
+	}
+	h.FuncMap = funcMap
+	if err := h.ParseTemplates(templatesFS); err != nil {
 		slog.Error("failed to parse templates", "error", err)
 		os.Exit(1)
 	}
-	h.Tmpl = tmpl
-	h.FuncMap = funcMap
 
 	// Enable template hot-reload when using local templates directory
 	if _, err := os.Stat(config.TemplatesDir()); err == nil {
@@ -171,6 +194,48 @@ func main() {
 		}
 	}()
 
+	// Scheduled publishing goroutine. Safe on multiple replicas: each run
+	// takes an advisory lock and is skipped if another replica holds it.
+	go func() {
+		ticker := time.NewTicker(1 * time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			res, err := h.ApplySchedules(context.Background())
+			if err != nil {
+				slog.Error("scheduled publishing failed", "error", err)
+				continue
+			}
+			if len(res.PagesPublished)+len(res.PagesUnpublished) > 0 || res.SectionsPublished > 0 {
+				slog.Info("scheduled publishing applied", "pages_published", len(res.PagesPublished),
+					"pages_unpublished", len(res.PagesUnpublished), "sections_published", res.SectionsPublished)
+			}
+		}
+	}()
+
+	// Daily digest goroutine. Each user's digest goes out once a day at
+	// most; the hourly check keeps the delay after that short.
+	go func() {
+		ticker := time.NewTicker(1 * time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			h.SendDigests(context.Background())
+		}
+	}()
+
+	// View analytics are written in batches in the background
+	h.Analytics = handlers.NewAnalyticsRecorder(h.DB)
+	go h.Analytics.Run(context.Background())
+
+	// Webhook deliveries are queued in the database and sent in the
+	// background; several replicas can share the queue.
+	h.Webhooks = handlers.NewWebhookSender(h.DB)
+	go h.Webhooks.Run(context.Background())
+
+	// Notification emails are queued in the database as well, so that
+	// requests do not wait for the SMTP server.
+	h.Mail = handlers.NewMailSender(h.DB)
+	go h.Mail.Run(context.Background())
+
 	// Routes
 	mux := http.NewServeMux()
 	mux.HandleFunc("GET /favicon", h.Favicon)
@@ -196,6 +261,7 @@ func main() {
 	mux.HandleFunc("POST /rows/{id}/delete", h.RequireEditor(h.DeleteRow))
 	mux.HandleFunc("POST /preview", h.RequireEditor(h.StartPreview))
 	mux.HandleFunc("POST /preview/stop", h.StopPreview)
+	mux.HandleFunc("POST /locale", h.SetLocale)
 	mux.HandleFunc("POST /api/reorder", h.RequireEditor(h.Reorder))
 	mux.HandleFunc("POST /api/{section}/reorder-pages", h.RequireEditor(h.ReorderPages))
 	mux.HandleFunc("GET /sections/{section}/edit", h.RequireEditor(h.EditSectionForm))
@@ -203,6 +269,50 @@ func main() {
 	mux.HandleFunc("POST /sections/{section}", h.RequireEditor(h.UpdateSection))
 	mux.HandleFunc("GET /sections/{section}/pages/new", h.RequireEditor(h.NewPageForm))
 	mux.HandleFunc("POST /sections/{section}/pages/new", h.RequireEditor(h.CreatePage))
+	// Content library routes
+	mux.HandleFunc("GET /snippets", h.RequireEditor(h.Snippets))
+	mux.HandleFunc("GET /snippets/new", h.RequireEditor(h.NewSnippetForm))
+	mux.HandleFunc("POST /snippets", h.RequireEditor(h.CreateSnippet))
+	mux.HandleFunc("GET /snippets/{id}/edit", h.RequireEditor(h.EditSnippetForm))
+	mux.HandleFunc("POST /snippets/{id}", h.RequireEditor(h.UpdateSnippet))
+	mux.HandleFunc("POST /snippets/{id}/delete", h.RequireEditor(h.DeleteSnippet))
+	mux.HandleFunc("GET /page-templates", h.RequireEditor(h.PageTemplates))
+	mux.HandleFunc("GET /page-templates/new", h.RequireEditor(h.NewPageTemplateForm))
+	mux.HandleFunc("POST /page-templates", h.RequireEditor(h.CreatePageTemplate))
+	mux.HandleFunc("GET /page-templates/{id}/edit", h.RequireEditor(h.EditPageTemplateForm))
+	mux.HandleFunc("POST /page-templates/{id}", h.RequireEditor(h.UpdatePageTemplate))
+	mux.HandleFunc("POST /page-templates/{id}/delete", h.RequireEditor(h.DeletePageTemplate))
+	// Review routes
+	mux.HandleFunc("GET /reviews", h.RequireReviewer(h.Reviews))
+	mux.HandleFunc("GET /reviews/{id}", h.RequireReviewer(h.Review))
+	mux.HandleFunc("POST /reviews/{id}/approve", h.RequireReviewer(h.ApproveReview))
+	mux.HandleFunc("POST /reviews/{id}/request-changes", h.RequireReviewer(h.RequestReviewChanges))
+	mux.HandleFunc("POST /reviews/{id}/comment", h.RequireReviewer(h.CommentReview))
+	mux.HandleFunc("POST /reviews/{id}/publish", h.RequireEditor(h.PublishReview))
+	// Comment routes
+	mux.HandleFunc("POST /comments/{id}/reply", h.ReplyCommentThread)
+	mux.HandleFunc("POST /comments/{id}/resolve", h.ResolveCommentThread)
+	mux.HandleFunc("POST /comments/{id}/reopen", h.ReopenCommentThread)
+	// Subscription routes
+	mux.HandleFunc("POST /sections/{section}/subscribe", h.WatchSection)
+	mux.HandleFunc("POST /sections/{section}/unsubscribe", h.UnwatchSection)
+	mux.HandleFunc("GET /notifications", h.Notifications)
+	mux.HandleFunc("POST /notifications", h.UpdateNotifications)
+	mux.HandleFunc("GET /preferences", h.Preferences)
+	mux.HandleFunc("POST /preferences", h.UpdatePreferences)
+	mux.HandleFunc("POST /notifications/{id}/delete", h.DeleteSubscription)
+	mux.HandleFunc("GET /unsubscribe", h.UnsubscribePage)
+	mux.HandleFunc("POST /unsubscribe", h.Unsubscribe)
+	// Recent changes routes
+	mux.HandleFunc("GET /changes", h.Changes)
+	mux.HandleFunc("GET /changes.atom", h.ChangesAtom)
+	mux.HandleFunc("GET /changes.rss", h.ChangesRSS)
+	mux.HandleFunc("GET /changes.json", h.ChangesJSON)
+	mux.HandleFunc("POST /changes/feed-token", h.ResetFeedToken)
+	mux.HandleFunc("GET /sections/{section}/changes", h.Changes)
+	mux.HandleFunc("GET /sections/{section}/changes.atom", h.ChangesAtom)
+	mux.HandleFunc("GET /sections/{section}/changes.rss", h.ChangesRSS)
+	mux.HandleFunc("GET /sections/{section}/changes.json", h.ChangesJSON)
 	// Admin routes
 	mux.HandleFunc("GET /admin/{$}", h.RequireAdmin(h.AdminIndex))
 	mux.HandleFunc("GET /admin/users", h.RequireAdmin(h.AdminUsers))
@@ -211,26 +321,80 @@ func main() {
 	mux.HandleFunc("GET /admin/users/{id}/edit", h.RequireAdmin(h.AdminEditUserForm))
 	mux.HandleFunc("POST /admin/users/{id}/update", h.RequireAdmin(h.AdminUpdateUser))
 	mux.HandleFunc("POST /admin/users/{id}/reset-password", h.RequireAdmin(h.AdminSendResetPassword))
+	mux.HandleFunc("POST /admin/users/{id}/remove", h.RequireAdmin(h.AdminRemoveSpaceMember))
 	mux.HandleFunc("GET /admin/roles", h.RequireAdmin(h.AdminRoles))
 	mux.HandleFunc("GET /admin/roles/new", h.RequireAdmin(h.AdminNewRoleForm))
 	mux.HandleFunc("POST /admin/roles", h.RequireAdmin(h.AdminCreateRole))
 	mux.HandleFunc("GET /admin/roles/{id}/edit", h.RequireAdmin(h.AdminEditRoleForm))
 	mux.HandleFunc("POST /admin/roles/{id}/update", h.RequireAdmin(h.AdminUpdateRole))
 	mux.HandleFunc("GET /admin/images", h.RequireAdmin(h.AdminImages))
+	mux.HandleFunc("GET /admin/variables", h.RequireAdmin(h.AdminVariables))
+	mux.HandleFunc("GET /admin/variables/new", h.RequireAdmin(h.AdminNewVariableForm))
+	mux.HandleFunc("POST /admin/variables", h.RequireAdmin(h.AdminCreateVariable))
+	mux.HandleFunc("GET /admin/variables/{id}/edit", h.RequireAdmin(h.AdminEditVariableForm))
+	mux.HandleFunc("POST /admin/variables/{id}/update", h.RequireAdmin(h.AdminUpdateVariable))
+	mux.HandleFunc("POST /admin/variables/{id}/delete", h.RequireAdmin(h.AdminDeleteVariable))
+	mux.HandleFunc("GET /admin/feedback", h.RequireAdmin(h.AdminFeedback))
+	mux.HandleFunc("GET /admin/analytics", h.RequireAdmin(h.AdminAnalytics))
+	mux.HandleFunc("GET /admin/webhooks", h.RequireAdmin(h.AdminWebhooks))
+	mux.HandleFunc("GET /admin/webhooks/new", h.RequireAdmin(h.AdminNewWebhookForm))
+	mux.HandleFunc("POST /admin/webhooks", h.RequireAdmin(h.AdminCreateWebhook))
+	mux.HandleFunc("GET /admin/webhooks/{id}/edit", h.RequireAdmin(h.AdminEditWebhookForm))
+	mux.HandleFunc("POST /admin/webhooks/{id}/update", h.RequireAdmin(h.AdminUpdateWebhook))
+	mux.HandleFunc("POST /admin/webhooks/{id}/rotate-secret", h.RequireAdmin(h.AdminRotateWebhookSecret))
+	mux.HandleFunc("POST /admin/webhooks/{id}/test", h.RequireAdmin(h.AdminTestWebhook))
+	mux.HandleFunc("POST /admin/webhooks/{id}/delete", h.RequireAdmin(h.AdminDeleteWebhook))
+	mux.HandleFunc("POST /admin/webhooks/deliveries/{id}/retry", h.RequireAdmin(h.AdminRetryWebhookDelivery))
+	mux.HandleFunc("GET /admin/themes", h.RequireAdmin(h.AdminThemes))
+	mux.HandleFunc("GET /admin/themes/new", h.RequireAdmin(h.AdminNewThemeForm))
+	mux.HandleFunc("POST /admin/themes", h.RequireAdmin(h.AdminCreateTheme))
+	mux.HandleFunc("GET /admin/themes/{id}/edit", h.RequireAdmin(h.AdminEditThemeForm))
+	mux.HandleFunc("POST /admin/themes/{id}/update", h.RequireAdmin(h.AdminUpdateTheme))
+	mux.HandleFunc("POST /admin/themes/{id}/delete", h.RequireAdmin(h.AdminDeleteTheme))
+	mux.HandleFunc("GET /admin/versions", h.RequireAdmin(h.AdminVersions))
+	mux.HandleFunc("POST /admin/versions", h.RequireAdmin(h.AdminBranchVersion))
+	mux.HandleFunc("POST /admin/versions/{id}/freeze", h.RequireAdmin(h.AdminFreezeVersion))
+	mux.HandleFunc("POST /admin/versions/{id}/delete", h.RequireAdmin(h.AdminDeleteVersion))
 	mux.HandleFunc("GET /admin/data", h.RequireAdmin(h.AdminDataPage))
 	mux.HandleFunc("GET /admin/data/export", h.RequireAdmin(h.AdminExport))
 	mux.HandleFunc("POST /admin/data/import", h.RequireAdmin(h.AdminImport))
+	mux.HandleFunc("GET /admin/spaces", h.RequireAdmin(h.AdminSpaces))
+	mux.HandleFunc("GET /admin/spaces/new", h.RequireAdmin(h.AdminNewSpaceForm))
+	mux.HandleFunc("POST /admin/spaces", h.RequireAdmin(h.AdminCreateSpace))
+	mux.HandleFunc("GET /admin/spaces/{id}/edit", h.RequireAdmin(h.AdminEditSpaceForm))
+	mux.HandleFunc("POST /admin/spaces/{id}/update", h.RequireAdmin(h.AdminUpdateSpace))
+	mux.HandleFunc("POST /admin/spaces/{id}/delete", h.RequireAdmin(h.AdminDeleteSpace))
 
 	mux.HandleFunc("GET /{section}/{slug}/edit", h.RequireEditor(h.EditPage))
-	mux.HandleFunc("POST /{section}/{slug}/preview", h.PreviewPage)
+	mux.HandleFunc("POST /{section}/{slug}/preview", h.RequireEditor(h.PreviewPage))
 	mux.HandleFunc("POST /{section}/{slug}/delete", h.RequireEditor(h.DeletePage))
+	mux.HandleFunc("POST /{section}/{slug}/discard-draft", h.RequireEditor(h.DiscardDraft))
+	mux.HandleFunc("POST /{section}/{slug}/comments", h.CreateCommentThread)
+	mux.HandleFunc("POST /{section}/{slug}/feedback", h.SubmitFeedback)
+	mux.HandleFunc("POST /{section}/{slug}/subscribe", h.WatchPage)
+	mux.HandleFunc("POST /{section}/{slug}/unsubscribe", h.UnwatchPage)
+	mux.HandleFunc("GET /{section}/{slug}/translate", h.RequireEditor(h.EditTranslation))
+	mux.HandleFunc("POST /{section}/{slug}/translate", h.RequireEditor(h.SaveTranslation))
+	mux.HandleFunc("POST /{section}/{slug}/delete-translation", h.RequireEditor(h.DeleteTranslation))
 	mux.HandleFunc("POST /{section}/{slug}", h.RequireEditor(h.SavePage))
 	mux.HandleFunc("GET /{section}/{slug}", h.Page)
 	mux.HandleFunc("GET /{section}/{$}", h.Section)
 
+	// Documentation versions; "latest" redirects to the pages above.
+	mux.HandleFunc("GET /{version}/{section}/{slug}/edit", h.RequireEditor(h.EditVersionPage))
+	mux.HandleFunc("POST /{version}/{section}/{slug}", h.RequireEditor(h.SaveVersionPage))
+	mux.HandleFunc("GET /{version}/{section}/{slug}", h.VersionPage)
+	mux.HandleFunc("GET /{version}/{section}/{$}", h.VersionSection)
+
+	// Static assets get their own mux: "/static/{path...}" would conflict
+	// with "/{section}/{slug}/edit" on paths like /static/js/edit.
+	root := http.NewServeMux()
+	root.HandleFunc("GET /static/{path...}", h.Static)
+	root.Handle("/", mux)
+
 	addr := ":" + config.Port()
 	slog.Info("HTTP server started", "addr", addr)
-	if err := http.ListenAndServe(addr, h.RequireAuth(mux)); err != nil {
+	if err := http.ListenAndServe(addr, h.ResolveSpace(h.RequireAuth(root))); err != nil {
 		slog.Error("server failed", "error", err)
 		os.Exit(1)
 	}
@@ -0,0 +1,63 @@
+// Command webhook-receiver is a local endpoint for testing webhooks. It logs
+// every delivery it receives and, given the webhook's secret, verifies its
+// signature.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"docgen/internal/webhook"
+)
+
+func main() {
+	addr := flag.String("addr", ":9000", "listen address")
+	secret := flag.String("secret", "", "webhook secret used to verify signatures (optional)")
+	status := flag.Int("status", http.StatusNoContent, "HTTP status to answer with, e.g. 500 to exercise retries")
+	flag.Parse()
+
+	http.HandleFunc("POST /", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "read failed", http.StatusBadRequest)
+			return
+		}
+
+		attrs := []any{
+			"event", r.Header.Get(webhook.HeaderEvent),
+			"delivery", r.Header.Get(webhook.HeaderDelivery),
+			"path", r.URL.Path,
+		}
+		if *secret != "" {
+			err := webhook.Verify(*secret, r.Header.Get(webhook.HeaderTimestamp), r.Header.Get(webhook.HeaderSignature), body, 5*time.Minute)
+			if err != nil {
+				slog.Warn("rejected delivery", append(attrs, "error", err)...)
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			attrs = append(attrs, "signature", "valid")
+		}
+		slog.Info("received delivery", attrs...)
+
+		var pretty bytes.Buffer
+		if json.Indent(&pretty, body, "", "  ") == nil {
+			fmt.Println(pretty.String())
+		} else {
+			fmt.Println(string(body))
+		}
+		w.WriteHeader(*status)
+	})
+
+	slog.Info("webhook receiver listening", "addr", *addr, "verify", *secret != "", "status", *status)
+	if err := http.ListenAndServe(*addr, nil); err != nil {
+		slog.Error("server failed", "error", err)
+		os.Exit(1)
+	}
+}
@@ -73,6 +73,10 @@ func StaticDir() string {
 	return env("STATIC_DIR", "static")
 }
 
+func LocalesDir() string {
+	return env("LOCALES_DIR", "locales")
+}
+
 func SMTPHost() string {
 	return env("SMTP_HOST", "localhost")
 }
@@ -101,6 +105,19 @@ func BaseURL() string {
 	return env("BASE_URL", "http://localhost:8080")
 }
 
+// DiagramMermaidCmd is the command used to render mermaid diagrams to SVG
+// server-side, e.g. "mmdc -i - -o - -e svg". It reads the diagram source on
+// stdin and must write SVG to stdout. Empty disables Mermaid rendering.
+func DiagramMermaidCmd() string {
+	return env("DIAGRAM_MERMAID_CMD", "")
+}
+
+// DiagramPlantUMLCmd is the command used to render PlantUML diagrams to SVG,
+// e.g. "plantuml -tsvg -pipe". Empty disables PlantUML rendering.
+func DiagramPlantUMLCmd() string {
+	return env("DIAGRAM_PLANTUML_CMD", "")
+}
+
 func LogLevel() string {
 	return env("LOG_LEVEL", "info")
 }
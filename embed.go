@@ -18,6 +18,9 @@ var contentFS embed.FS
 //go:embed static
 var staticFS embed.FS
 
+//go:embed locales/*.json
+var localesFS embed.FS
+
 // ResolveFS returns os.DirFS(localDir) if localDir exists on disk,
 // otherwise returns the embedded filesystem. This lets dev mode use
 // local files (live editing) while production uses the embedded copy.
@@ -55,3 +58,10 @@ func EmbeddedStatic() fs.FS {
 	sub, _ := fs.Sub(staticFS, "static")
 	return sub
 }
+
+// EmbeddedLocales returns the embedded user interface message catalogs,
+// rooted at the "locales" subdirectory.
+func EmbeddedLocales() fs.FS {
+	sub, _ := fs.Sub(localesFS, "locales")
+	return sub
+}
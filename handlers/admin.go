@@ -45,6 +45,10 @@ type AdminUserFormData struct {
 	AllRoles  []db.Role
 	IsNew     bool
 	ResetSent bool
+	// ProfileLocked is set for users who are also members of other spaces;
+	// see spaceMember.
+	ProfileLocked bool
+	IsSelf        bool
 }
 
 type AdminRolesData struct {
@@ -75,6 +79,12 @@ func adminNav(active string) []AdminNavItem {
 		{Title: "Users", Path: "/admin/users", IsActive: active == "users"},
 		{Title: "Roles", Path: "/admin/roles", IsActive: active == "roles"},
 		{Title: "Images", Path: "/admin/images", IsActive: active == "images"},
+		{Title: "Variables", Path: "/admin/variables", IsActive: active == "variables"},
+		{Title: "Feedback", Path: "/admin/feedback", IsActive: active == "feedback"},
+		{Title: "Analytics", Path: "/admin/analytics", IsActive: active == "analytics"},
+		{Title: "Themes", Path: "/admin/themes", IsActive: active == "themes"},
+		{Title: "Webhooks", Path: "/admin/webhooks", IsActive: active == "webhooks"},
+		{Title: "Versions", Path: "/admin/versions", IsActive: active == "versions"},
 		{Title: "Export/Import", Path: "/admin/data", IsActive: active == "data"},
 	}
 }
@@ -103,10 +113,14 @@ func (h *Handlers) RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
 
 func (h *Handlers) adminData(r *http.Request, active string) AdminData {
 	title, _, themeCSS := h.siteSettings(r.Context())
+	nav := adminNav(active)
+	if db.SpaceFrom(r.Context()).IsDefault() {
+		nav = append(nav, AdminNavItem{Title: "Spaces", Path: "/admin/spaces", IsActive: active == "spaces"})
+	}
 	return AdminData{
 		SiteTitle:     title,
 		ThemeCSS:      themeCSS,
-		NavItems:      adminNav(active),
+		NavItems:      nav,
 		UserFirstname: userFirstname(r.Context()),
 		IsEditor:      true,
 	}
@@ -117,7 +131,7 @@ func (h *Handlers) AdminIndex(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/admin/users", http.StatusFound)
 }
 
-// AdminUsers lists all users.
+// AdminUsers lists the members of the current space.
 func (h *Handlers) AdminUsers(w http.ResponseWriter, r *http.Request) {
 	users, err := h.DB.ListUsers(r.Context())
 	if err != nil {
@@ -131,7 +145,7 @@ func (h *Handlers) AdminUsers(w http.ResponseWriter, r *http.Request) {
 		Users:     users,
 	}
 
-	if err := h.tmpl().ExecuteTemplate(w, "admin-users.html", data); err != nil {
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "admin-users.html", data); err != nil {
 		slog.Error("AdminUsers template", "error", err)
 	}
 }
@@ -146,7 +160,7 @@ func (h *Handlers) AdminNewUserForm(w http.ResponseWriter, r *http.Request) {
 		IsNew:     true,
 	}
 
-	if err := h.tmpl().ExecuteTemplate(w, "admin-user-form.html", data); err != nil {
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "admin-user-form.html", data); err != nil {
 		slog.Error("AdminNewUserForm template", "error", err)
 	}
 }
@@ -163,6 +177,14 @@ func (h *Handlers) AdminCreateUser(w http.ResponseWriter, r *http.Request) {
 	company := r.FormValue("company")
 	email := r.FormValue("email")
 	password := r.FormValue("password")
+	roleNames := r.Form["roles"]
+
+	// Users are shared between spaces: an existing user is added to this
+	// space instead, keeping their profile and password.
+	if existing, err := h.DB.GetUserByEmail(r.Context(), email); err == nil {
+		h.addSpaceMember(w, r, existing, roleNames)
+		return
+	}
 
 	if firstname == "" || lastname == "" || email == "" || password == "" {
 		http.Error(w, "firstname, lastname, email, and password are required", http.StatusBadRequest)
@@ -187,9 +209,13 @@ func (h *Handlers) AdminCreateUser(w http.ResponseWriter, r *http.Request) {
 		slog.Error("AdminCreateUser", "error", err)
 		return
 	}
+	if err := h.DB.AddSpaceMember(r.Context(), user.ID); err != nil {
+		h.serverError(w, r)
+		slog.Error("AdminCreateUser member", "error", err)
+		return
+	}
 
 	// Assign roles
-	roleNames := r.Form["roles"]
 	if len(roleNames) > 0 {
 		if err := h.DB.SetUserRoles(r.Context(), user.ID, roleNames); err != nil {
 			slog.Error("AdminCreateUser roles", "error", err)
@@ -203,6 +229,15 @@ func (h *Handlers) AdminCreateUser(w http.ResponseWriter, r *http.Request) {
 		slog.Error("AdminCreateUser history", "error", err)
 	}
 
+	h.emitEvent(r.Context(), "user.created", map[string]any{
+		"id":        user.ID,
+		"email":     user.Email,
+		"firstname": user.Firstname,
+		"lastname":  user.Lastname,
+		"company":   user.Company,
+		"roles":     roleNames,
+	})
+
 	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
 }
 
@@ -210,7 +245,7 @@ func (h *Handlers) AdminCreateUser(w http.ResponseWriter, r *http.Request) {
 func (h *Handlers) AdminEditUserForm(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 
-	user, err := h.DB.GetUserByID(r.Context(), id)
+	user, locked, err := h.spaceMember(r.Context(), id)
 	if err != nil {
 		h.notFound(w, r)
 		return
@@ -220,15 +255,17 @@ func (h *Handlers) AdminEditUserForm(w http.ResponseWriter, r *http.Request) {
 	allRoles, _ := h.DB.ListAllRoles(r.Context())
 
 	data := AdminUserFormData{
-		AdminData: h.adminData(r, "users"),
-		FormUser:  user,
-		UserRoles: userRoles,
-		AllRoles:  allRoles,
-		IsNew:     false,
-		ResetSent: r.URL.Query().Get("reset_sent") == "1",
+		AdminData:     h.adminData(r, "users"),
+		FormUser:      user,
+		UserRoles:     userRoles,
+		AllRoles:      allRoles,
+		IsNew:         false,
+		ResetSent:     r.URL.Query().Get("reset_sent") == "1",
+		ProfileLocked: locked,
+		IsSelf:        id == userID(r.Context()),
 	}
 
-	if err := h.tmpl().ExecuteTemplate(w, "admin-user-form.html", data); err != nil {
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "admin-user-form.html", data); err != nil {
 		slog.Error("AdminEditUserForm template", "error", err)
 	}
 }
@@ -242,10 +279,19 @@ func (h *Handlers) AdminUpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	current, locked, err := h.spaceMember(r.Context(), id)
+	if err != nil {
+		h.notFound(w, r)
+		return
+	}
+
 	firstname := r.FormValue("firstname")
 	lastname := r.FormValue("lastname")
 	company := r.FormValue("company")
 	email := r.FormValue("email")
+	if locked {
+		firstname, lastname, company, email = current.Firstname, current.Lastname, current.Company, current.Email
+	}
 
 	if firstname == "" || lastname == "" || email == "" {
 		http.Error(w, "firstname, lastname, and email are required", http.StatusBadRequest)
@@ -253,6 +299,9 @@ func (h *Handlers) AdminUpdateUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	password := r.FormValue("password")
+	if locked {
+		password = ""
+	}
 	if password != "" && len(password) < 8 {
 		http.Error(w, "password must be at least 8 characters", http.StatusBadRequest)
 		return
@@ -313,7 +362,7 @@ func (h *Handlers) AdminRoles(w http.ResponseWriter, r *http.Request) {
 		Roles:     roles,
 	}
 
-	if err := h.tmpl().ExecuteTemplate(w, "admin-roles.html", data); err != nil {
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "admin-roles.html", data); err != nil {
 		slog.Error("AdminRoles template", "error", err)
 	}
 }
@@ -325,7 +374,7 @@ func (h *Handlers) AdminNewRoleForm(w http.ResponseWriter, r *http.Request) {
 		IsNew:     true,
 	}
 
-	if err := h.tmpl().ExecuteTemplate(w, "admin-role-form.html", data); err != nil {
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "admin-role-form.html", data); err != nil {
 		slog.Error("AdminNewRoleForm template", "error", err)
 	}
 }
@@ -377,7 +426,7 @@ func (h *Handlers) AdminEditRoleForm(w http.ResponseWriter, r *http.Request) {
 		IsNew:     false,
 	}
 
-	if err := h.tmpl().ExecuteTemplate(w, "admin-role-form.html", data); err != nil {
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "admin-role-form.html", data); err != nil {
 		slog.Error("AdminEditRoleForm template", "error", err)
 	}
 }
@@ -419,8 +468,8 @@ func (h *Handlers) AdminUpdateRole(w http.ResponseWriter, r *http.Request) {
 func (h *Handlers) AdminSendResetPassword(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 
-	user, err := h.DB.GetUserByID(r.Context(), id)
-	if err != nil {
+	user, locked, err := h.spaceMember(r.Context(), id)
+	if err != nil || locked {
 		h.notFound(w, r)
 		return
 	}
@@ -444,7 +493,7 @@ func (h *Handlers) AdminSendResetPassword(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	resetURL := config.BaseURL() + "/reset-password?token=" + token
+	resetURL := siteURL(r.Context()) + "/reset-password?token=" + token
 
 	settings, _ := h.DB.GetSiteSettings(r.Context())
 	siteTitle := settings.SiteTitle
@@ -481,7 +530,7 @@ func (h *Handlers) AdminImages(w http.ResponseWriter, r *http.Request) {
 		Error:     r.URL.Query().Get("error"),
 	}
 
-	if err := h.tmpl().ExecuteTemplate(w, "admin-images.html", data); err != nil {
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "admin-images.html", data); err != nil {
 		slog.Error("AdminImages template", "error", err)
 	}
 }
@@ -494,34 +543,43 @@ func (h *Handlers) AdminDataPage(w http.ResponseWriter, r *http.Request) {
 		Error:     r.URL.Query().Get("error"),
 	}
 
-	if err := h.tmpl().ExecuteTemplate(w, "admin-data.html", data); err != nil {
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "admin-data.html", data); err != nil {
 		slog.Error("AdminDataPage template", "error", err)
 	}
 }
 
-// AdminExport exports the database as a JSON file download.
+// AdminExport exports the current space as a JSON file download.
 func (h *Handlers) AdminExport(w http.ResponseWriter, r *http.Request) {
-	bundle, err := portability.Export(r.Context(), h.DB.Pool, false)
+	opts := portability.ExportOptions{
+		ExpandVariables: r.URL.Query().Get("expand_variables") == "on",
+		IncludeComments: r.URL.Query().Get("include_comments") == "on",
+	}
+	space := db.SpaceFrom(r.Context())
+	bundle, err := portability.Export(r.Context(), h.DB.Pool, space.ID, opts)
 	if err != nil {
 		slog.Error("AdminExport", "error", err)
-		http.Redirect(w, r, "/admin/data?error="+url.QueryEscape("Export failed: "+err.Error()), http.StatusSeeOther)
+		http.Redirect(w, r, "/admin/data?error="+url.QueryEscape(h.t(r.Context(), "Export failed: %s", err)), http.StatusSeeOther)
 		return
 	}
 
 	data, err := json.MarshalIndent(bundle, "", "  ")
 	if err != nil {
 		slog.Error("AdminExport marshal", "error", err)
-		http.Redirect(w, r, "/admin/data?error="+url.QueryEscape("Export failed: "+err.Error()), http.StatusSeeOther)
+		http.Redirect(w, r, "/admin/data?error="+url.QueryEscape(h.t(r.Context(), "Export failed: %s", err)), http.StatusSeeOther)
 		return
 	}
 
 	filename := fmt.Sprintf("export-%s.json", time.Now().UTC().Format("20060102-150405"))
+	if !space.IsDefault() {
+		filename = fmt.Sprintf("export-%s-%s.json", space.Name, time.Now().UTC().Format("20060102-150405"))
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
 	w.Write(data)
 }
 
-// AdminImport handles the file upload and imports the JSON bundle.
+// AdminImport handles the file upload and imports the JSON bundle into the
+// current space.
 func (h *Handlers) AdminImport(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseMultipartForm(64 << 20); err != nil {
 		http.Redirect(w, r, "/admin/data?error="+url.QueryEscape("Invalid form data"), http.StatusSeeOther)
@@ -543,21 +601,28 @@ func (h *Handlers) AdminImport(w http.ResponseWriter, r *http.Request) {
 
 	var bundle portability.ExportBundle
 	if err := json.Unmarshal(data, &bundle); err != nil {
-		http.Redirect(w, r, "/admin/data?error="+url.QueryEscape("Invalid JSON: "+err.Error()), http.StatusSeeOther)
+		http.Redirect(w, r, "/admin/data?error="+url.QueryEscape(h.t(r.Context(), "Invalid JSON: %s", err)), http.StatusSeeOther)
 		return
 	}
 
 	if err := portability.Validate(&bundle); err != nil {
-		http.Redirect(w, r, "/admin/data?error="+url.QueryEscape("Validation failed: "+err.Error()), http.StatusSeeOther)
+		http.Redirect(w, r, "/admin/data?error="+url.QueryEscape(h.t(r.Context(), "Validation failed: %s", err)), http.StatusSeeOther)
 		return
 	}
 
 	clean := r.FormValue("clean_import") == "on"
-	if err := portability.Import(r.Context(), h.DB.Pool, &bundle, clean); err != nil {
-		http.Redirect(w, r, "/admin/data?error="+url.QueryEscape("Import failed: "+err.Error()), http.StatusSeeOther)
+	if err := portability.Import(r.Context(), h.DB.Pool, db.SpaceFrom(r.Context()).ID, &bundle, clean); err != nil {
+		http.Redirect(w, r, "/admin/data?error="+url.QueryEscape(h.t(r.Context(), "Import failed: %s", err)), http.StatusSeeOther)
 		return
 	}
 
+	h.emitEvent(r.Context(), "import.completed", map[string]any{
+		"clean":    clean,
+		"sections": len(bundle.Sections),
+		"pages":    len(bundle.Pages),
+		"images":   len(bundle.Images),
+	})
+
 	http.Redirect(w, r, "/admin/data?success=Import+completed+successfully", http.StatusSeeOther)
 }
 
@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"docgen/internal/db"
+)
+
+const (
+	analyticsQueueSize = 4096
+	analyticsBatchSize = 1000
+)
+
+// AnalyticsRecorder counts page views and section views in memory
+// and writes them to the database in batches from Run, so that recording a
+// view never waits on the database. Visitors are stored only as hashes salted
+// with a per-day salt. Events not yet written are lost if the process exits.
+type AnalyticsRecorder struct {
+	DB       *db.Queries
+	Interval time.Duration
+	events   chan analyticsEvent
+	salts    map[time.Time]string
+}
+
+type analyticsEvent struct {
+	kind     string
+	spaceID  string
+	targetID string
+	userID   string
+	at       time.Time
+}
+
+type viewKey struct {
+	kind     string
+	spaceID  string
+	day      time.Time
+	targetID string
+	userID   string
+}
+
+// NewAnalyticsRecorder returns a recorder that flushes every 30 seconds, or
+// sooner when a batch fills up.
+func NewAnalyticsRecorder(q *db.Queries) *AnalyticsRecorder {
+	return &AnalyticsRecorder{
+		DB:       q,
+		Interval: 30 * time.Second,
+		events:   make(chan analyticsEvent, analyticsQueueSize),
+		salts:    make(map[time.Time]string),
+	}
+}
+
+// PageView records a view of a page in a space by a user.
+func (a *AnalyticsRecorder) PageView(spaceID, pageID, userID string) {
+	a.record(analyticsEvent{kind: "page", spaceID: spaceID, targetID: pageID, userID: userID})
+}
+
+// SectionView records a visit to a section's own URL by a user.
+func (a *AnalyticsRecorder) SectionView(spaceID, sectionID, userID string) {
+	a.record(analyticsEvent{kind: "section", spaceID: spaceID, targetID: sectionID, userID: userID})
+}
+
+func (a *AnalyticsRecorder) record(e analyticsEvent) {
+	if a == nil {
+		return
+	}
+	e.at = time.Now()
+	select {
+	case a.events <- e:
+	default:
+		slog.Warn("analytics queue full, dropping event", "kind", e.kind)
+	}
+}
+
+// Run collects events and writes them until ctx is cancelled.
+func (a *AnalyticsRecorder) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.Interval)
+	defer ticker.Stop()
+
+	views := make(map[viewKey]int)
+	pending := 0
+	flush := func(ctx context.Context) {
+		if pending == 0 {
+			return
+		}
+		if err := a.flush(ctx, views); err != nil {
+			slog.Error("analytics flush failed", "events", pending, "error", err)
+		}
+		views = make(map[viewKey]int)
+		pending = 0
+	}
+
+	for {
+		select {
+		case e := <-a.events:
+			views[viewKey{e.kind, e.spaceID, analyticsDay(e.at), e.targetID, e.userID}]++
+			pending++
+			if pending >= analyticsBatchSize {
+				flush(ctx)
+			}
+		case <-ticker.C:
+			flush(ctx)
+		case <-ctx.Done():
+			flush(context.Background())
+			return
+		}
+	}
+}
+
+// flush aggregates the collected views per target and role and writes them.
+// Roles are those the user has in the space of the view.
+func (a *AnalyticsRecorder) flush(ctx context.Context, views map[viewKey]int) error {
+	type roleKey struct{ spaceID, userID string }
+	roles := make(map[roleKey]string)
+	type countKey struct {
+		kind     string
+		day      time.Time
+		targetID string
+		role     string
+	}
+	counts := make(map[countKey]*db.ViewCount)
+	for k, n := range views {
+		rk := roleKey{k.spaceID, k.userID}
+		role, ok := roles[rk]
+		if !ok {
+			names, err := a.DB.GetUserRoles(db.WithSpace(ctx, db.Space{ID: k.spaceID}), k.userID)
+			if err != nil {
+				return err
+			}
+			role = strings.Join(names, ",")
+			roles[rk] = role
+		}
+		salt, err := a.salt(ctx, k.day)
+		if err != nil {
+			return err
+		}
+		ck := countKey{k.kind, k.day, k.targetID, role}
+		c := counts[ck]
+		if c == nil {
+			c = &db.ViewCount{Day: k.day, TargetID: k.targetID, Role: role}
+			counts[ck] = c
+		}
+		c.Views += n
+		c.Visitors = append(c.Visitors, visitorHash(salt, k.userID))
+	}
+
+	var pages, sections []db.ViewCount
+	for k, c := range counts {
+		if k.kind == "page" {
+			pages = append(pages, *c)
+		} else {
+			sections = append(sections, *c)
+		}
+	}
+	// Salts of past days are pruned along with the data.
+	for day := range a.salts {
+		if day.Before(analyticsDay(time.Now()).AddDate(0, 0, -1)) {
+			delete(a.salts, day)
+		}
+	}
+
+	return a.DB.RecordAnalytics(ctx, pages, sections)
+}
+
+// salt returns the visitor hash salt of a day, shared by all replicas.
+func (a *AnalyticsRecorder) salt(ctx context.Context, day time.Time) (string, error) {
+	if s, ok := a.salts[day]; ok {
+		return s, nil
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	s, err := a.DB.AnalyticsSalt(ctx, day, hex.EncodeToString(b))
+	if err != nil {
+		return "", err
+	}
+	a.salts[day] = s
+	return s, nil
+}
+
+func visitorHash(salt, userID string) string {
+	sum := sha256.Sum256([]byte(salt + ":" + userID))
+	return hex.EncodeToString(sum[:])
+}
+
+// analyticsDay returns the UTC day of t.
+func analyticsDay(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// recordView counts a page or section view. Views in preview mode are not
+// counted: they are editors looking at the site as someone else.
+func (h *Handlers) recordView(ctx context.Context, kind, targetID string) {
+	if inPreviewMode(ctx) {
+		return
+	}
+	uid := userID(ctx)
+	spaceID := db.SpaceFrom(ctx).ID
+	switch kind {
+	case "page":
+		h.Analytics.PageView(spaceID, targetID, uid)
+	case "section":
+		h.Analytics.SectionView(spaceID, targetID, uid)
+	}
+}
+
+// TrendDay is one bar of the daily views chart. Height is a percentage of the
+// busiest day.
+type TrendDay struct {
+	Day      time.Time
+	Views    int
+	Visitors int
+	Height   int
+}
+
+type AdminAnalyticsData struct {
+	AdminData
+	Days       int
+	DayOptions []int
+	TotalViews int
+	Trend      []TrendDay
+	TopPages   []db.PageViewStats
+	Sections   []db.SectionViewStats
+	Roles      []db.RoleViews
+	ZeroViews  []db.PageViewStats
+}
+
+// AdminAnalytics shows page view statistics for the last 7, 30 or 90 days.
+func (h *Handlers) AdminAnalytics(w http.ResponseWriter, r *http.Request) {
+	days, _ := strconv.Atoi(r.URL.Query().Get("days"))
+	if days != 7 && days != 90 {
+		days = 30
+	}
+	today := analyticsDay(time.Now())
+	since := today.AddDate(0, 0, 1-days)
+	ctx := r.Context()
+
+	data := AdminAnalyticsData{
+		AdminData:  h.adminData(r, "analytics"),
+		Days:       days,
+		DayOptions: []int{7, 30, 90},
+	}
+
+	daily, err := h.DB.ListDailyViews(ctx, since)
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("AdminAnalytics daily", "error", err)
+		return
+	}
+	byDay := make(map[time.Time]db.DailyViews)
+	peak := 0
+	for _, d := range daily {
+		byDay[analyticsDay(d.Day)] = d
+		peak = max(peak, d.Views)
+		data.TotalViews += d.Views
+	}
+	for day := since; !day.After(today); day = day.AddDate(0, 0, 1) {
+		d := byDay[day]
+		t := TrendDay{Day: day, Views: d.Views, Visitors: d.Visitors}
+		if peak > 0 {
+			t.Height = d.Views * 100 / peak
+		}
+		data.Trend = append(data.Trend, t)
+	}
+
+	if data.TopPages, err = h.DB.ListTopPages(ctx, since, 20); err != nil {
+		h.serverError(w, r)
+		slog.Error("AdminAnalytics top pages", "error", err)
+		return
+	}
+	if data.Sections, err = h.DB.ListSectionViewStats(ctx, since); err != nil {
+		h.serverError(w, r)
+		slog.Error("AdminAnalytics sections", "error", err)
+		return
+	}
+	if data.Roles, err = h.DB.ListRoleViews(ctx, since); err != nil {
+		h.serverError(w, r)
+		slog.Error("AdminAnalytics roles", "error", err)
+		return
+	}
+	if data.ZeroViews, err = h.DB.ListZeroViewPages(ctx, since); err != nil {
+		h.serverError(w, r)
+		slog.Error("AdminAnalytics zero views", "error", err)
+		return
+	}
+
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "admin-analytics.html", data); err != nil {
+		slog.Error("AdminAnalytics template", "error", err)
+	}
+}
@@ -26,6 +26,7 @@ type contextKey string
 
 const userContextKey contextKey = "user"
 const previewRolesContextKey contextKey = "preview_roles"
+const previewDraftsContextKey contextKey = "preview_drafts"
 const sessionTokenContextKey contextKey = "session_token"
 
 const (
@@ -211,6 +212,16 @@ func inPreviewMode(ctx context.Context) bool {
 	return PreviewRolesFromContext(ctx) != nil
 }
 
+// showDrafts reports whether unpublished pages and drafts should be shown:
+// to editors, and in preview mode when the preview was started with drafts.
+func (h *Handlers) showDrafts(ctx context.Context) bool {
+	if inPreviewMode(ctx) {
+		drafts, _ := ctx.Value(previewDraftsContextKey).(bool)
+		return drafts
+	}
+	return h.isEditor(ctx)
+}
+
 // sessionTokenFromContext returns the session token stored in context.
 func sessionTokenFromContext(ctx context.Context) string {
 	s, _ := ctx.Value(sessionTokenContextKey).(string)
@@ -240,7 +251,7 @@ func (h *Handlers) LoginPage(w http.ResponseWriter, r *http.Request) {
 		SiteTitle: title,
 		ThemeCSS:  themeCSS,
 	}
-	if err := h.tmpl().ExecuteTemplate(w, "login.html", data); err != nil {
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "login.html", data); err != nil {
 		slog.Error("LoginPage template", "error", err)
 	}
 }
@@ -356,7 +367,7 @@ func (h *Handlers) renderLoginError(w http.ResponseWriter, r *http.Request, msg
 		data.ChallengeToken = c.Token
 	}
 	w.WriteHeader(http.StatusUnauthorized)
-	if err := h.tmpl().ExecuteTemplate(w, "login.html", data); err != nil {
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "login.html", data); err != nil {
 		slog.Error("renderLoginError template", "error", err)
 	}
 }
@@ -387,7 +398,7 @@ func (h *Handlers) ResetPasswordPage(w http.ResponseWriter, r *http.Request) {
 		ThemeCSS:  themeCSS,
 		Token:     token,
 	}
-	if err := h.tmpl().ExecuteTemplate(w, "reset-password.html", data); err != nil {
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "reset-password.html", data); err != nil {
 		slog.Error("ResetPasswordPage template", "error", err)
 	}
 }
@@ -414,7 +425,7 @@ func (h *Handlers) ResetPassword(w http.ResponseWriter, r *http.Request) {
 		data := ResetPasswordData{SiteTitle: title, ThemeCSS: themeCSS, Token: token,
 			Error: "This reset link has expired or is invalid"}
 		w.WriteHeader(http.StatusBadRequest)
-		h.tmpl().ExecuteTemplate(w, "reset-password.html", data)
+		h.tmpl(r.Context()).ExecuteTemplate(w, "reset-password.html", data)
 		return
 	}
 
@@ -422,7 +433,7 @@ func (h *Handlers) ResetPassword(w http.ResponseWriter, r *http.Request) {
 		data := ResetPasswordData{SiteTitle: title, ThemeCSS: themeCSS, Token: token,
 			Error: "Password must be at least 8 characters"}
 		w.WriteHeader(http.StatusBadRequest)
-		h.tmpl().ExecuteTemplate(w, "reset-password.html", data)
+		h.tmpl(r.Context()).ExecuteTemplate(w, "reset-password.html", data)
 		return
 	}
 
@@ -430,7 +441,7 @@ func (h *Handlers) ResetPassword(w http.ResponseWriter, r *http.Request) {
 		data := ResetPasswordData{SiteTitle: title, ThemeCSS: themeCSS, Token: token,
 			Error: "Passwords do not match"}
 		w.WriteHeader(http.StatusBadRequest)
-		h.tmpl().ExecuteTemplate(w, "reset-password.html", data)
+		h.tmpl(r.Context()).ExecuteTemplate(w, "reset-password.html", data)
 		return
 	}
 
@@ -453,7 +464,7 @@ func (h *Handlers) ResetPassword(w http.ResponseWriter, r *http.Request) {
 	}
 
 	data := ResetPasswordData{SiteTitle: title, ThemeCSS: themeCSS, Success: true}
-	if err := h.tmpl().ExecuteTemplate(w, "reset-password.html", data); err != nil {
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "reset-password.html", data); err != nil {
 		slog.Error("ResetPassword template", "error", err)
 	}
 }
@@ -540,14 +551,31 @@ func (h *Handlers) isEditor(ctx context.Context) bool {
 }
 
 // RequireAuth wraps an http.Handler and enforces authentication on all routes
-// except /login.
+// except /login, /reset-password and static assets. Change feeds also accept
+// a feed token.
 func (h *Handlers) RequireAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == "/login" || r.URL.Path == "/reset-password" {
+		if r.URL.Path == "/login" || r.URL.Path == "/reset-password" || r.URL.Path == "/unsubscribe" || strings.HasPrefix(r.URL.Path, "/static/") {
 			next.ServeHTTP(w, r)
 			return
 		}
 
+		// Feed readers cannot log in; the token in a feed URL stands in
+		// for the session of the user it was issued to.
+		if token := r.URL.Query().Get("token"); token != "" && isFeedRequest(r) {
+			user, err := h.DB.GetUserByFeedToken(r.Context(), token)
+			if err != nil {
+				http.Error(w, "invalid feed token", http.StatusUnauthorized)
+				return
+			}
+			if member, err := h.DB.IsSpaceMember(r.Context(), db.SpaceFrom(r.Context()).ID, user.ID); err != nil || !member {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userContextKey, &user)))
+			return
+		}
+
 		cookie, err := r.Cookie(sessionCookieName)
 		if err != nil {
 			http.Redirect(w, r, "/login", http.StatusSeeOther)
@@ -578,6 +606,22 @@ func (h *Handlers) RequireAuth(next http.Handler) http.Handler {
 		ctx = context.WithValue(ctx, sessionTokenContextKey, session.Token)
 		if session.PreviewRoles != nil {
 			ctx = context.WithValue(ctx, previewRolesContextKey, *session.PreviewRoles)
+			ctx = context.WithValue(ctx, previewDraftsContextKey, session.PreviewDrafts)
+		}
+
+		// Sessions are shared between spaces, but only members may see a
+		// space. Logging out still works everywhere.
+		if r.URL.Path != "/logout" {
+			member, err := h.DB.IsSpaceMember(ctx, db.SpaceFrom(ctx).ID, user.ID)
+			if err != nil {
+				slog.Error("RequireAuth space membership", "error", err)
+				h.serverError(w, r.WithContext(ctx))
+				return
+			}
+			if !member {
+				h.forbidden(w, r.WithContext(ctx))
+				return
+			}
 		}
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"docgen"
+	"docgen/internal/db"
+	"docgen/internal/dbtest"
+)
+
+// newTestHandlers returns handlers on the test database that render errors
+// with a template showing only the error title.
+func newTestHandlers(t *testing.T) *Handlers {
+	t.Helper()
+	q := dbtest.Open(t)
+	ui, err := LoadUICatalog(docgen.EmbeddedLocales())
+	if err != nil {
+		t.Fatalf("LoadUICatalog: %v", err)
+	}
+	return &Handlers{
+		DB:   q,
+		UI:   ui,
+		Tmpl: template.Must(template.New("error.html").Parse(`{{.Title}}`)),
+	}
+}
+
+// serveAuth sends r through RequireAuth to a handler that reports the
+// authenticated user's id.
+func serveAuth(h *Handlers, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(userID(r.Context())))
+	})).ServeHTTP(w, r)
+	return w
+}
+
+func TestRequireAuthFeedToken(t *testing.T) {
+	h := newTestHandlers(t)
+	ctx := context.Background()
+
+	member := dbtest.User(t, h.DB)
+	if err := h.DB.AddSpaceMember(ctx, member.ID); err != nil {
+		t.Fatalf("AddSpaceMember: %v", err)
+	}
+	outsider := dbtest.User(t, h.DB)
+	other, err := h.DB.CreateSpace(ctx, dbtest.Name("space-"), "Other", "", outsider.ID)
+	if err != nil {
+		t.Fatalf("CreateSpace: %v", err)
+	}
+
+	token := func(u db.User) string {
+		t.Helper()
+		tok, err := h.DB.GetFeedToken(ctx, u.ID)
+		if err != nil {
+			t.Fatalf("GetFeedToken: %v", err)
+		}
+		return tok
+	}
+	memberToken, outsiderToken := token(member), token(outsider)
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		space    *db.Space
+		wantCode int
+		wantUser string
+	}{
+		{"site feed", http.MethodGet, "/changes.atom?token=" + memberToken, nil, http.StatusOK, member.ID},
+		{"section feed", http.MethodGet, "/sections/guide/changes.rss?token=" + memberToken, nil, http.StatusOK, member.ID},
+		{"HEAD", http.MethodHead, "/changes.json?token=" + memberToken, nil, http.StatusOK, member.ID},
+		{"unknown token", http.MethodGet, "/changes.atom?token=00000000-0000-0000-0000-000000000000", nil, http.StatusUnauthorized, ""},
+		{"not a member", http.MethodGet, "/changes.atom?token=" + outsiderToken, nil, http.StatusForbidden, ""},
+		{"member of another space", http.MethodGet, "/changes.atom?token=" + outsiderToken, &other, http.StatusOK, outsider.ID},
+		{"not a member of that space", http.MethodGet, "/changes.atom?token=" + memberToken, &other, http.StatusForbidden, ""},
+		{"POST", http.MethodPost, "/changes.atom?token=" + memberToken, nil, http.StatusSeeOther, ""},
+		{"changes page", http.MethodGet, "/changes?token=" + memberToken, nil, http.StatusSeeOther, ""},
+		{"page named like a feed", http.MethodGet, "/sections/guide/pages/changes.json?token=" + memberToken, nil, http.StatusSeeOther, ""},
+		{"edit form", http.MethodGet, "/sections/guide/intro/edit?token=" + memberToken, nil, http.StatusSeeOther, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, nil)
+			if tt.space != nil {
+				r = r.WithContext(db.WithSpace(r.Context(), *tt.space))
+			}
+			w := serveAuth(h, r)
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantCode)
+			}
+			if tt.wantCode == http.StatusSeeOther {
+				if loc := w.Header().Get("Location"); loc != "/login" {
+					t.Errorf("redirected to %q, want /login", loc)
+				}
+			}
+			if tt.wantUser != "" && tt.method != http.MethodHead && w.Body.String() != tt.wantUser {
+				t.Errorf("user = %q, want %q", w.Body.String(), tt.wantUser)
+			}
+		})
+	}
+}
+
+func TestRequireAuthSpaceMembership(t *testing.T) {
+	h := newTestHandlers(t)
+	ctx := context.Background()
+
+	member := dbtest.User(t, h.DB)
+	if err := h.DB.AddSpaceMember(ctx, member.ID); err != nil {
+		t.Fatalf("AddSpaceMember: %v", err)
+	}
+	outsider := dbtest.User(t, h.DB)
+	other, err := h.DB.CreateSpace(ctx, dbtest.Name("space-"), "Other", "", outsider.ID)
+	if err != nil {
+		t.Fatalf("CreateSpace: %v", err)
+	}
+
+	session := func(u db.User) string {
+		t.Helper()
+		token := dbtest.Name("session-")
+		if _, err := h.DB.CreateSession(ctx, u.ID, token, time.Now().Add(time.Hour)); err != nil {
+			t.Fatalf("CreateSession: %v", err)
+		}
+		return token
+	}
+	memberSession, outsiderSession := session(member), session(outsider)
+
+	tests := []struct {
+		name     string
+		path     string
+		session  string
+		space    *db.Space
+		wantCode int
+	}{
+		{"member", "/", memberSession, nil, http.StatusOK},
+		{"not a member", "/", outsiderSession, nil, http.StatusForbidden},
+		{"member of another space", "/", outsiderSession, &other, http.StatusOK},
+		{"not a member of that space", "/sections/guide", memberSession, &other, http.StatusForbidden},
+		{"logout without membership", "/logout", outsiderSession, nil, http.StatusOK},
+		{"login page", "/login", "", nil, http.StatusOK},
+		{"no session", "/", "", nil, http.StatusSeeOther},
+		{"unknown session", "/", "expired", nil, http.StatusSeeOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.session != "" {
+				r.AddCookie(&http.Cookie{Name: sessionCookieName, Value: tt.session})
+			}
+			if tt.space != nil {
+				r = r.WithContext(db.WithSpace(r.Context(), *tt.space))
+			}
+			w := serveAuth(h, r)
+			if w.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,403 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"docgen/internal/db"
+)
+
+const (
+	maxChangeSummary = 200
+	maxChanges       = 100
+	maxFeedEntries   = 50
+	// maxSectionHistory is how many revisions the section form lists.
+	maxSectionHistory = 20
+)
+
+type ChangesData struct {
+	AdminData
+	Section   db.Section
+	Changes   []db.PageChange
+	FeedPath  string
+	FeedToken string
+	// PageSlug is set when the changes of a single page are listed.
+	PageSlug string
+}
+
+// changeSummary reads the editor's change summary from the form. required
+// is set for sections that make one mandatory.
+func (h *Handlers) changeSummary(r *http.Request, required bool) (string, error) {
+	summary := strings.TrimSpace(r.FormValue("summary"))
+	if required && summary == "" {
+		return "", errors.New(h.t(r.Context(), "This section requires a change summary"))
+	}
+	if utf8.RuneCountInString(summary) > maxChangeSummary {
+		return "", errors.New(h.t(r.Context(), "The change summary must be at most %d characters", maxChangeSummary))
+	}
+	return summary, nil
+}
+
+// changeSections returns the sections whose changes the current user may
+// see.
+func (h *Handlers) changeSections(ctx context.Context) ([]db.Section, error) {
+	sections, err := h.DB.ListSections(ctx, h.showDrafts(ctx))
+	if err != nil {
+		return nil, err
+	}
+	var visible []db.Section
+	for _, s := range sections {
+		if h.canAccessSection(ctx, s.RequiredRole) {
+			visible = append(visible, s)
+		}
+	}
+	return visible, nil
+}
+
+// loadChanges returns the sections the current user may see and the latest
+// changes of the section named in the path or, without one, of all of them.
+// In a section, the page query parameter narrows the changes to one page.
+// It writes the error response itself and returns ok false on failure.
+func (h *Handlers) loadChanges(w http.ResponseWriter, r *http.Request, limit int) (sections []db.Section, section db.Section, changes []db.PageChange, ok bool) {
+	ctx := r.Context()
+	name := r.PathValue("section")
+
+	sections, err := h.changeSections(ctx)
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("loadChanges sections", "error", err)
+		return nil, section, nil, false
+	}
+
+	var ids []string
+	for _, s := range sections {
+		if name == "" {
+			ids = append(ids, s.ID)
+		} else if s.Name == name {
+			section = s
+			ids = append(ids, s.ID)
+		}
+	}
+	if name != "" && len(ids) == 0 {
+		// Tell sections the user cannot see apart from missing ones, as
+		// Section does.
+		if s, err := h.DB.GetSectionByName(ctx, name); err == nil && h.sectionVisible(ctx, s) {
+			h.forbidden(w, r)
+		} else {
+			h.notFound(w, r)
+		}
+		return nil, section, nil, false
+	}
+
+	var slug string
+	if name != "" {
+		slug = r.URL.Query().Get("page")
+	}
+	changes, err = h.DB.ListPageChanges(ctx, ids, slug, h.showDrafts(ctx), limit)
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("loadChanges", "error", err)
+		return nil, section, nil, false
+	}
+	return sections, section, changes, true
+}
+
+// Changes lists the latest page changes across the site or in one section.
+func (h *Handlers) Changes(w http.ResponseWriter, r *http.Request) {
+	sections, section, changes, ok := h.loadChanges(w, r, maxChanges)
+	if !ok {
+		return
+	}
+
+	token, err := h.DB.GetFeedToken(r.Context(), userID(r.Context()))
+	if err != nil {
+		slog.Error("Changes feed token", "error", err)
+	}
+
+	data := ChangesData{
+		AdminData: h.adminData(r, ""),
+		Section:   section,
+		Changes:   changes,
+		FeedPath:  "/changes",
+		FeedToken: token,
+	}
+	if section.ID != "" {
+		data.PageSlug = r.URL.Query().Get("page")
+		data.FeedPath = "/sections/" + section.Name + "/changes"
+	}
+	data.NavItems = []AdminNavItem{{Title: "All sections", Path: "/changes", IsActive: section.ID == ""}}
+	for _, s := range sections {
+		data.NavItems = append(data.NavItems, AdminNavItem{
+			Title:    s.Title,
+			Path:     "/sections/" + s.Name + "/changes",
+			IsActive: s.ID == section.ID,
+		})
+	}
+	data.IsEditor = h.isEditor(r.Context())
+
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "changes.html", data); err != nil {
+		slog.Error("Changes template", "error", err)
+	}
+}
+
+// ResetFeedToken replaces the current user's feed token, revoking the feed
+// URLs they have handed out.
+func (h *Handlers) ResetFeedToken(w http.ResponseWriter, r *http.Request) {
+	if err := h.DB.ResetFeedToken(r.Context(), userID(r.Context())); err != nil {
+		h.serverError(w, r)
+		slog.Error("ResetFeedToken", "error", err)
+		return
+	}
+
+	target := "/changes"
+	if back := r.FormValue("from"); strings.HasPrefix(back, "/sections/") && strings.HasSuffix(back, "/changes") {
+		target = back
+	}
+	http.Redirect(w, r, target+"#feeds", http.StatusSeeOther)
+}
+
+// feedPath matches the paths of the change feeds: /changes.atom and the
+// like for the whole site, and /sections/{section}/changes.atom for one
+// section.
+var feedPath = regexp.MustCompile(`^(/sections/[^/]+)?/changes\.(atom|rss|json)$`)
+
+// isFeedRequest reports whether r reads one of the change feeds, which feed
+// readers fetch with a token instead of a session. Feed tokens end up in
+// reader configurations and logs, so they are good for nothing else.
+func isFeedRequest(r *http.Request) bool {
+	return (r.Method == http.MethodGet || r.Method == http.MethodHead) && feedPath.MatchString(r.URL.Path)
+}
+
+// changeFeed holds what the three feed formats have in common.
+type changeFeed struct {
+	Title   string
+	Link    string
+	Updated time.Time
+	Changes []db.PageChange
+}
+
+func (h *Handlers) loadChangeFeed(w http.ResponseWriter, r *http.Request) (changeFeed, bool) {
+	_, section, changes, ok := h.loadChanges(w, r, maxFeedEntries)
+	if !ok {
+		return changeFeed{}, false
+	}
+	siteTitle, _, _ := h.siteSettings(r.Context())
+	feed := changeFeed{
+		Title:   siteTitle + ": Recent changes",
+		Link:    siteURL(r.Context()) + "/changes",
+		Updated: time.Now(),
+		Changes: changes,
+	}
+	if section.ID != "" {
+		feed.Title = siteTitle + ": Recent changes in " + section.Title
+		feed.Link = siteURL(r.Context()) + "/sections/" + section.Name + "/changes"
+	}
+	if len(changes) > 0 {
+		feed.Updated = changes[0].ChangedAt
+	}
+	return feed, true
+}
+
+func changeURL(ctx context.Context, c db.PageChange) string {
+	return siteURL(ctx) + "/" + c.SectionName + "/" + c.Slug
+}
+
+// changeText describes a change in a feed: the editor's summary if they
+// gave one.
+func changeText(c db.PageChange) string {
+	switch {
+	case c.Summary != "":
+		return c.Summary
+	case c.Version == 1:
+		return "Page created"
+	default:
+		return fmt.Sprintf("Updated to version %d", c.Version)
+	}
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	Title   string     `xml:"title"`
+	ID      string     `xml:"id"`
+	Updated string     `xml:"updated"`
+	Link    atomLink   `xml:"link"`
+	Author  atomAuthor `xml:"author"`
+	Summary string     `xml:"summary"`
+}
+
+// ChangesAtom serves the recent changes as an Atom feed.
+func (h *Handlers) ChangesAtom(w http.ResponseWriter, r *http.Request) {
+	feed, ok := h.loadChangeFeed(w, r)
+	if !ok {
+		return
+	}
+
+	out := atomFeed{
+		Title:   feed.Title,
+		ID:      feed.Link,
+		Updated: feed.Updated.UTC().Format(time.RFC3339),
+		Link:    atomLink{Href: feed.Link},
+	}
+	for _, c := range feed.Changes {
+		author := c.AuthorName
+		if author == "" {
+			author = "Unknown"
+		}
+		out.Entries = append(out.Entries, atomEntry{
+			Title:   fmt.Sprintf("%s (%s)", c.Title, c.SectionTitle),
+			ID:      "urn:uuid:" + c.ID,
+			Updated: c.ChangedAt.UTC().Format(time.RFC3339),
+			Link:    atomLink{Href: changeURL(r.Context(), c)},
+			Author:  atomAuthor{Name: author},
+			Summary: changeText(c),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	if err := xml.NewEncoder(w).Encode(out); err != nil {
+		slog.Error("ChangesAtom", "error", err)
+	}
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Creator     string  `xml:"http://purl.org/dc/elements/1.1/ creator,omitempty"`
+	Description string  `xml:"description"`
+}
+
+// ChangesRSS serves the recent changes as an RSS 2.0 feed.
+func (h *Handlers) ChangesRSS(w http.ResponseWriter, r *http.Request) {
+	feed, ok := h.loadChangeFeed(w, r)
+	if !ok {
+		return
+	}
+
+	out := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:         feed.Title,
+			Link:          feed.Link,
+			Description:   feed.Title,
+			LastBuildDate: feed.Updated.UTC().Format(time.RFC1123Z),
+		},
+	}
+	for _, c := range feed.Changes {
+		out.Channel.Items = append(out.Channel.Items, rssItem{
+			Title:       fmt.Sprintf("%s (%s)", c.Title, c.SectionTitle),
+			Link:        changeURL(r.Context(), c),
+			GUID:        rssGUID{Value: "urn:uuid:" + c.ID},
+			PubDate:     c.ChangedAt.UTC().Format(time.RFC1123Z),
+			Creator:     c.AuthorName,
+			Description: changeText(c),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	if err := xml.NewEncoder(w).Encode(out); err != nil {
+		slog.Error("ChangesRSS", "error", err)
+	}
+}
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedItem struct {
+	ID           string           `json:"id"`
+	URL          string           `json:"url"`
+	Title        string           `json:"title"`
+	ContentText  string           `json:"content_text"`
+	DateModified time.Time        `json:"date_modified"`
+	Authors      []jsonFeedAuthor `json:"authors,omitempty"`
+	Tags         []string         `json:"tags,omitempty"`
+}
+
+// ChangesJSON serves the recent changes as a JSON Feed.
+func (h *Handlers) ChangesJSON(w http.ResponseWriter, r *http.Request) {
+	feed, ok := h.loadChangeFeed(w, r)
+	if !ok {
+		return
+	}
+
+	out := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       feed.Title,
+		HomePageURL: feed.Link,
+		Items:       []jsonFeedItem{},
+	}
+	for _, c := range feed.Changes {
+		item := jsonFeedItem{
+			ID:           c.ID,
+			URL:          changeURL(r.Context(), c),
+			Title:        c.Title,
+			ContentText:  changeText(c),
+			DateModified: c.ChangedAt.UTC(),
+			Tags:         []string{c.SectionTitle},
+		}
+		if c.AuthorName != "" {
+			item.Authors = []jsonFeedAuthor{{Name: c.AuthorName}}
+		}
+		out.Items = append(out.Items, item)
+	}
+
+	w.Header().Set("Content-Type", "application/feed+json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(out); err != nil {
+		slog.Error("ChangesJSON", "error", err)
+	}
+}
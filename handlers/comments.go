@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"docgen/internal/db"
+)
+
+// maxAnchorLen caps the quoted passage stored with a thread, in runes.
+const maxAnchorLen = 300
+
+// mentionRe matches @mentions, which use the user's email address:
+// "@jane@example.com".
+var mentionRe = regexp.MustCompile(`@([A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,})`)
+
+// CommentView is a comment with its body rendered for display.
+type CommentView struct {
+	db.PageComment
+	BodyHTML template.HTML
+}
+
+type ThreadView struct {
+	db.CommentThread
+	Comments   []CommentView
+	CanResolve bool
+}
+
+// renderCommentBody escapes a comment and highlights its mentions. Comments
+// are plain text; line breaks are kept.
+func renderCommentBody(body string) template.HTML {
+	s := html.EscapeString(body)
+	s = mentionRe.ReplaceAllString(s, `<span class="mention">@$1</span>`)
+	s = strings.ReplaceAll(s, "\n", "<br>")
+	return template.HTML(s)
+}
+
+// mentionedEmails returns the distinct email addresses mentioned in body,
+// lower-cased.
+func mentionedEmails(body string) []string {
+	seen := map[string]bool{}
+	var emails []string
+	for _, m := range mentionRe.FindAllStringSubmatch(body, -1) {
+		email := strings.ToLower(m[1])
+		if !seen[email] {
+			seen[email] = true
+			emails = append(emails, email)
+		}
+	}
+	return emails
+}
+
+// pageThreads loads the comment threads of a page with their comments.
+func (h *Handlers) pageThreads(ctx context.Context, pageID string) ([]ThreadView, error) {
+	threads, err := h.DB.ListCommentThreads(ctx, pageID)
+	if err != nil {
+		return nil, err
+	}
+	comments, err := h.DB.ListPageComments(ctx, pageID)
+	if err != nil {
+		return nil, err
+	}
+
+	byThread := map[string][]CommentView{}
+	for _, c := range comments {
+		byThread[c.ThreadID] = append(byThread[c.ThreadID], CommentView{PageComment: c, BodyHTML: renderCommentBody(c.Body)})
+	}
+
+	isEditor := h.isEditor(ctx)
+	uid := userID(ctx)
+	views := make([]ThreadView, len(threads))
+	for i, t := range threads {
+		views[i] = ThreadView{
+			CommentThread: t,
+			Comments:      byThread[t.ID],
+			CanResolve:    isEditor || t.CreatedBy == uid,
+		}
+	}
+	return views, nil
+}
+
+// notifyComment queues emails to the users mentioned in a comment and the
+// given watchers, so that posting a comment does not wait for the SMTP
+// server. Only users who can read the section are notified, and never the
+// author of the comment.
+func (h *Handlers) notifyComment(ctx context.Context, requiredRole, pageTitle, link, body string, watchers []db.User) {
+	seen := map[string]bool{userID(ctx): true}
+
+	var mentioned []db.User
+	if emails := mentionedEmails(body); len(emails) > 0 {
+		users, err := h.DB.GetUsersByEmail(ctx, emails)
+		if err != nil {
+			slog.Error("notifyComment mentions", "error", err)
+		}
+		for _, u := range users {
+			if !seen[u.ID] && h.userCanAccessSection(ctx, u.ID, requiredRole) {
+				seen[u.ID] = true
+				mentioned = append(mentioned, u)
+			}
+		}
+	}
+
+	var others []db.User
+	for _, u := range watchers {
+		if !seen[u.ID] && h.userCanAccessSection(ctx, u.ID, requiredRole) {
+			seen[u.ID] = true
+			others = append(others, u)
+		}
+	}
+
+	author := userFirstname(ctx)
+	h.notifyUsers(ctx, mentioned, "You were mentioned on "+pageTitle,
+		fmt.Sprintf("%s mentioned you in a comment on \"%s\":\r\n\r\n%s\r\n\r\n%s\r\n", author, pageTitle, body, link))
+	h.notifyUsers(ctx, others, "New comment on "+pageTitle,
+		fmt.Sprintf("%s commented on \"%s\":\r\n\r\n%s\r\n\r\n%s\r\n", author, pageTitle, body, link))
+}
+
+func threadLink(ctx context.Context, t db.CommentThread) string {
+	return siteURL(ctx) + "/" + t.SectionName + "/" + t.Slug + "#thread-" + t.ID
+}
+
+// threadRedirect returns where to go after acting on a thread: back to the
+// editor when the action came from there, otherwise to the thread on the page.
+func threadRedirect(r *http.Request, t db.CommentThread) string {
+	if r.URL.Query().Get("from") == "edit" {
+		return "/" + t.SectionName + "/" + t.Slug + "/edit"
+	}
+	return "/" + t.SectionName + "/" + t.Slug + "#thread-" + t.ID
+}
+
+// loadThread fetches the thread in the path and checks that the current user
+// can see its page.
+func (h *Handlers) loadThread(w http.ResponseWriter, r *http.Request) (db.CommentThread, bool) {
+	thread, err := h.DB.GetCommentThread(r.Context(), r.PathValue("id"))
+	if err != nil {
+		h.notFound(w, r)
+		return thread, false
+	}
+	section, err := h.DB.GetSectionByName(r.Context(), thread.SectionName)
+	if err != nil || !h.sectionVisible(r.Context(), section) {
+		h.notFound(w, r)
+		return thread, false
+	}
+	if !h.canAccessSection(r.Context(), section.RequiredRole) {
+		h.forbidden(w, r)
+		return thread, false
+	}
+	if _, err := h.DB.GetPage(r.Context(), section.ID, thread.Slug, h.showDrafts(r.Context())); err != nil {
+		h.notFound(w, r)
+		return thread, false
+	}
+	return thread, true
+}
+
+// CreateCommentThread starts a discussion on a page, optionally anchored to
+// a highlighted passage.
+func (h *Handlers) CreateCommentThread(w http.ResponseWriter, r *http.Request) {
+	sectionName := r.PathValue("section")
+	slug := r.PathValue("slug")
+
+	section, err := h.DB.GetSectionByName(r.Context(), sectionName)
+	if err != nil || !h.sectionVisible(r.Context(), section) {
+		h.notFound(w, r)
+		return
+	}
+
+	if !h.canAccessSection(r.Context(), section.RequiredRole) {
+		h.forbidden(w, r)
+		return
+	}
+
+	page, err := h.DB.GetPage(r.Context(), section.ID, slug, h.showDrafts(r.Context()))
+	if err != nil {
+		h.notFound(w, r)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	body := strings.TrimSpace(r.FormValue("body"))
+	if body == "" {
+		http.Redirect(w, r, "/"+section.Name+"/"+page.Slug+"?error="+url.QueryEscape("Comment is empty")+"#comments", http.StatusSeeOther)
+		return
+	}
+
+	var anchorText *string
+	if a := strings.Join(strings.Fields(r.FormValue("anchor_text")), " "); a != "" {
+		if runes := []rune(a); len(runes) > maxAnchorLen {
+			a = string(runes[:maxAnchorLen])
+		}
+		anchorText = &a
+	}
+
+	id, err := h.DB.CreateCommentThread(r.Context(), page.ID, anchorText, userID(r.Context()), body)
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("CreateCommentThread", "error", err)
+		return
+	}
+
+	// Editors look after the content, so they hear about new questions.
+	editors, err := h.DB.ListUsersWithRole(r.Context(), "editor")
+	if err != nil {
+		slog.Error("CreateCommentThread editors", "error", err)
+	}
+	h.notifyComment(r.Context(), section.RequiredRole, page.Title,
+		siteURL(r.Context())+"/"+section.Name+"/"+page.Slug+"#thread-"+id, body, editors)
+
+	http.Redirect(w, r, "/"+section.Name+"/"+page.Slug+"#thread-"+id, http.StatusSeeOther)
+}
+
+// ReplyCommentThread adds a reply to a thread. Replying to a resolved thread
+// reopens it.
+func (h *Handlers) ReplyCommentThread(w http.ResponseWriter, r *http.Request) {
+	thread, ok := h.loadThread(w, r)
+	if !ok {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	body := strings.TrimSpace(r.FormValue("body"))
+	if body == "" {
+		http.Redirect(w, r, threadRedirect(r, thread), http.StatusSeeOther)
+		return
+	}
+
+	if err := h.DB.CreatePageComment(r.Context(), thread.ID, userID(r.Context()), body); err != nil {
+		h.serverError(w, r)
+		slog.Error("ReplyCommentThread", "error", err)
+		return
+	}
+	if thread.Resolved {
+		if err := h.DB.SetCommentThreadResolved(r.Context(), thread.ID, false, userID(r.Context())); err != nil {
+			slog.Error("ReplyCommentThread reopen", "error", err)
+		}
+	}
+
+	participants, err := h.DB.ListCommentThreadParticipants(r.Context(), thread.ID)
+	if err != nil {
+		slog.Error("ReplyCommentThread participants", "error", err)
+	}
+	h.notifyComment(r.Context(), thread.RequiredRole, thread.PageTitle, threadLink(r.Context(), thread), body, participants)
+
+	http.Redirect(w, r, threadRedirect(r, thread), http.StatusSeeOther)
+}
+
+// ResolveCommentThread marks a thread as resolved.
+func (h *Handlers) ResolveCommentThread(w http.ResponseWriter, r *http.Request) {
+	h.setThreadResolved(w, r, true)
+}
+
+// ReopenCommentThread reopens a resolved thread.
+func (h *Handlers) ReopenCommentThread(w http.ResponseWriter, r *http.Request) {
+	h.setThreadResolved(w, r, false)
+}
+
+// setThreadResolved resolves or reopens a thread. Only editors and the user
+// who started the thread may do so.
+func (h *Handlers) setThreadResolved(w http.ResponseWriter, r *http.Request, resolved bool) {
+	thread, ok := h.loadThread(w, r)
+	if !ok {
+		return
+	}
+
+	uid := userID(r.Context())
+	if !h.isEditor(r.Context()) && thread.CreatedBy != uid {
+		h.forbidden(w, r)
+		return
+	}
+
+	if err := h.DB.SetCommentThreadResolved(r.Context(), thread.ID, resolved, uid); err != nil {
+		h.serverError(w, r)
+		slog.Error("setThreadResolved", "error", err)
+		return
+	}
+
+	if thread.CreatedBy != "" && thread.CreatedBy != uid {
+		if u, err := h.DB.GetUserByID(r.Context(), thread.CreatedBy); err == nil {
+			verb := "resolved"
+			if !resolved {
+				verb = "reopened"
+			}
+			h.notifyUsers(r.Context(), []db.User{u}, "Comment "+verb+": "+thread.PageTitle,
+				fmt.Sprintf("%s %s your comment thread on \"%s\".\r\n\r\n%s\r\n",
+					userFirstname(r.Context()), verb, thread.PageTitle, threadLink(r.Context(), thread)))
+		} else {
+			slog.Error("setThreadResolved creator", "error", err)
+		}
+	}
+
+	http.Redirect(w, r, threadRedirect(r, thread), http.StatusSeeOther)
+}
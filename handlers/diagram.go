@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"docgen/internal/db"
+)
+
+// DiagramRenderer renders diagram blocks to SVG with external commands
+// (mermaid-cli, plantuml) and caches the output in the images table under a
+// filename derived from the diagram source, so each diagram is rendered once.
+type DiagramRenderer struct {
+	DB       *db.Queries
+	Commands map[string]string // language -> command line
+	Timeout  time.Duration
+
+	known sync.Map // filename -> struct{}, images known to exist
+}
+
+// NewDiagramRenderer returns a renderer for the languages that have a
+// non-empty command configured.
+func NewDiagramRenderer(q *db.Queries, mermaidCmd, plantumlCmd string) *DiagramRenderer {
+	cmds := make(map[string]string)
+	if mermaidCmd != "" {
+		cmds["mermaid"] = mermaidCmd
+	}
+	if plantumlCmd != "" {
+		cmds["plantuml"] = plantumlCmd
+	}
+	return &DiagramRenderer{DB: q, Commands: cmds, Timeout: 20 * time.Second}
+}
+
+// diagramFilename returns the cache filename for a diagram's source.
+func diagramFilename(lang string, source []byte) string {
+	sum := sha256.Sum256(append([]byte(lang+"\n"), source...))
+	return fmt.Sprintf("diagram-%s.svg", hex.EncodeToString(sum[:16]))
+}
+
+// Render implements markdown.DiagramFunc.
+func (d *DiagramRenderer) Render(lang string, source []byte) (string, bool) {
+	cmdline, ok := d.Commands[lang]
+	if !ok {
+		return "", false
+	}
+	filename := diagramFilename(lang, source)
+	url := "/images/" + filename
+	if _, ok := d.known.Load(filename); ok {
+		return url, true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), d.Timeout)
+	defer cancel()
+
+	exists, err := d.DB.ImageExists(ctx, filename)
+	if err != nil {
+		slog.Error("diagram cache lookup", "filename", filename, "error", err)
+		return "", false
+	}
+	if !exists {
+		svg, err := runDiagramCommand(ctx, cmdline, source)
+		if err != nil {
+			slog.Error("diagram render", "lang", lang, "error", err)
+			return "", false
+		}
+		if err := d.DB.CreateGeneratedImage(ctx, filename, "image/svg+xml", svg); err != nil {
+			slog.Error("diagram cache store", "filename", filename, "error", err)
+			return "", false
+		}
+		slog.Info("diagram rendered", "lang", lang, "filename", filename)
+	}
+	d.known.Store(filename, struct{}{})
+	return url, true
+}
+
+func runDiagramCommand(ctx context.Context, cmdline string, source []byte) ([]byte, error) {
+	args := strings.Fields(cmdline)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(source)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	if !bytes.Contains(stdout.Bytes(), []byte("<svg")) {
+		return nil, fmt.Errorf("%s: output is not SVG", args[0])
+	}
+	return stdout.Bytes(), nil
+}
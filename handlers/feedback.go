@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"docgen/internal/db"
+)
+
+type AdminFeedbackData struct {
+	AdminData
+	Pages []db.PageFeedbackStats
+}
+
+// SubmitFeedback records the current user's "Was this page helpful?" vote.
+func (h *Handlers) SubmitFeedback(w http.ResponseWriter, r *http.Request) {
+	sectionName := r.PathValue("section")
+	slug := r.PathValue("slug")
+
+	section, err := h.DB.GetSectionByName(r.Context(), sectionName)
+	if err != nil || !h.sectionVisible(r.Context(), section) {
+		h.notFound(w, r)
+		return
+	}
+
+	if !h.canAccessSection(r.Context(), section.RequiredRole) {
+		h.forbidden(w, r)
+		return
+	}
+
+	page, err := h.DB.GetPage(r.Context(), section.ID, slug, h.showDrafts(r.Context()))
+	if err != nil {
+		h.notFound(w, r)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	var helpful bool
+	switch r.FormValue("helpful") {
+	case "yes":
+		helpful = true
+	case "no":
+		helpful = false
+	default:
+		http.Error(w, "invalid feedback", http.StatusBadRequest)
+		return
+	}
+
+	comment := strings.TrimSpace(r.FormValue("comment"))
+	if err := h.DB.SetPageFeedback(r.Context(), page.ID, userID(r.Context()), helpful, comment); err != nil {
+		h.serverError(w, r)
+		slog.Error("SubmitFeedback", "error", err)
+		return
+	}
+
+	http.Redirect(w, r, "/"+section.Name+"/"+page.Slug+"#feedback", http.StatusSeeOther)
+}
+
+// AdminFeedback ranks pages by negative feedback, with the comments left on
+// each.
+func (h *Handlers) AdminFeedback(w http.ResponseWriter, r *http.Request) {
+	pages, err := h.DB.ListPageFeedbackStats(r.Context())
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("AdminFeedback", "error", err)
+		return
+	}
+
+	comments, err := h.DB.ListFeedbackComments(r.Context())
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("AdminFeedback comments", "error", err)
+		return
+	}
+
+	byPage := make(map[string][]db.PageFeedback)
+	for _, c := range comments {
+		byPage[c.PageID] = append(byPage[c.PageID], c)
+	}
+	for i := range pages {
+		pages[i].Comments = byPage[pages[i].PageID]
+	}
+
+	data := AdminFeedbackData{
+		AdminData: h.adminData(r, "feedback"),
+		Pages:     pages,
+	}
+
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "admin-feedback.html", data); err != nil {
+		slog.Error("AdminFeedback template", "error", err)
+	}
+}
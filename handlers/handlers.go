@@ -14,7 +14,9 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"path/filepath"
 	"regexp"
@@ -23,6 +25,7 @@ import (
 	"docgen/internal/db"
 	"docgen/internal/markdown"
 
+	"golang.org/x/text/language"
 	"golang.org/x/text/unicode/norm"
 )
 
@@ -38,6 +41,9 @@ type TemplateSection struct {
 	Disabled     bool
 	RowID        *string
 	IsEditor     bool
+	Unpublished  bool
+	// HasLanding is set for sections with a landing page.
+	HasLanding bool
 }
 
 type TemplateRow struct {
@@ -48,13 +54,19 @@ type TemplateRow struct {
 }
 
 type TemplatePage struct {
-	Title      string
-	Slug       string
-	Content    template.HTML
-	IsActive   bool
-	Children   []TemplatePage
-	IsChild    bool
-	ParentSlug string
+	Title       string
+	Slug        string
+	Content     template.HTML
+	IsActive    bool
+	Children    []TemplatePage
+	IsChild     bool
+	ParentSlug  string
+	Unpublished bool
+	Scheduled   bool
+	HasDraft    bool
+	// Excerpt is the start of the page's text, for the cards on the
+	// section's landing page.
+	Excerpt string
 }
 
 type SiteData struct {
@@ -69,6 +81,54 @@ type SiteData struct {
 	IsEditor      bool
 	PreviewMode   bool
 	PreviewRoles  string
+	Threads       []ThreadView
+	CommentError  string
+	Feedback      *db.PageFeedback
+	Watching      bool
+	// WatchingSection is set when the user watches the whole section.
+	WatchingSection bool
+	// DocVersion names the documentation version shown; empty for the
+	// latest documentation.
+	DocVersion    string
+	VersionFrozen bool
+	Versions      []VersionLink
+	// Locale is the language the reader asked for and Locales the language
+	// switcher entries, empty for single-language sites.
+	Locale            string
+	Locales           []LocaleLink
+	LocaleName        string
+	DefaultLocaleName string
+	// TranslationMissing is set when the page is shown in the default
+	// language because it has no translation into Locale.
+	TranslationMissing bool
+	// TranslationOutdated is set for editors when the page changed after it
+	// was translated into Locale.
+	TranslationOutdated bool
+	NavLinks            []db.NavLink
+	// Landing is set on a section's landing page, which shows the section's
+	// landing Markdown as Current.Content and cards for Pages.
+	Landing bool
+	// Nav is the page's parent for the breadcrumbs and its neighbours in
+	// reading order.
+	Nav PageNav
+	// Meta is when and by whom the page was last updated, nil in sections
+	// that hide it.
+	Meta *PageMeta
+}
+
+// PageNav locates a page in its section: its parent, if it is a child
+// page, and the pages before and after it in reading order.
+type PageNav struct {
+	Parent *TemplatePage
+	Prev   *TemplatePage
+	Next   *TemplatePage
+}
+
+// PageMeta describes a page's last change for the footer below it.
+type PageMeta struct {
+	UpdatedAt   time.Time
+	AuthorName  string
+	HistoryPath string
 }
 
 type EditData struct {
@@ -86,6 +146,21 @@ type EditData struct {
 	UserFirstname string
 	IsEditor      bool
 	Error         string
+	Templates     []db.PageTemplate
+	TemplateID    string
+	TemplateVars  []string
+	Published     bool
+	HasDraft      bool
+	// RequiredApprovals > 0 means changes must be reviewed before publishing.
+	RequiredApprovals int
+	ReviewID          string
+	PublishAt         string
+	UnpublishAt       string
+	RequireSummary    bool
+	// Threads holds the open comment threads only.
+	Threads      []ThreadView
+	Feedback     db.FeedbackSummary
+	Translations []TranslationStatus
 }
 
 type EditSectionData struct {
@@ -103,6 +178,16 @@ type EditSectionData struct {
 	Roles         []db.Role
 	RequiredRole  string
 	Pages         []TemplatePage
+	// RequiredApprovals is the number of reviewer approvals needed to publish.
+	RequiredApprovals int
+	PublishAt         string
+	UnpublishAt       string
+	// RequireSummary can only be changed by admins.
+	RequireSummary bool
+	IsAdmin        bool
+	History        []db.SectionChange
+	LandingMD      string
+	ShowPageMeta   bool
 }
 
 type HomeData struct {
@@ -117,6 +202,7 @@ type HomeData struct {
 	UserLastname      string
 	IsEditor          bool
 	IsAdmin           bool
+	ShowReviewsBtn    bool
 	Roles             []db.Role
 	Rows              []TemplateRow
 	UngroupedSections []TemplateSection
@@ -127,6 +213,10 @@ type HomeData struct {
 	ShowPreviewBtn    bool
 	PreviewAllRoles   []db.Role
 	PreviewUsers      []db.UserWithRoles
+	Spaces            []SpaceLink
+	NavLinks          []db.NavLink
+	FooterColumns     []db.FooterColumn
+	Blocks            HomeBlocks
 }
 
 type RowFormData struct {
@@ -152,9 +242,25 @@ type EditHomeData struct {
 	Footer        string
 	Theme         string
 	AccentColor   string
+	Font          string
+	Fonts         []FontOption
+	CustomThemes  []db.CustomTheme
+	CustomCSS     string
+	DefaultLocale string
+	Locales       string
+	UILanguage    string
+	UILanguages   []UILanguageOption
+	// NavLinks and FooterLinks are the header and footer links in the
+	// format parseNavLinks and parseFooterColumns read.
+	NavLinks      string
+	FooterLinks   string
+	HomeIntro     string
+	HomePopular   bool
+	HomeRecent    bool
 	Version       int
 	UserFirstname string
 	IsEditor      bool
+	IsAdmin       bool
 	HasFavicon    bool
 }
 
@@ -208,8 +314,17 @@ type Handlers struct {
 	Tmpl           *template.Template
 	TemplatesFS    fs.FS
 	FuncMap        template.FuncMap
+	UI             *UICatalog
+	StaticFS       fs.FS
 	DefaultFavicon []byte
+	Analytics      *AnalyticsRecorder
+	Webhooks       *WebhookSender
+	Mail           *MailSender
 	faviconV       atomic.Int64
+	// staticHashes caches the content hashes of static files by name.
+	staticHashes sync.Map
+	// tmpls holds the template set of each user interface language.
+	tmpls map[string]*template.Template
 }
 
 // FaviconVersionFunc returns a template.FuncMap with a "faviconVersion"
@@ -233,18 +348,23 @@ func (h *Handlers) bumpFaviconVersion() {
 	h.faviconV.Add(1)
 }
 
-// tmpl returns the template set. When TemplatesFS is set (dev mode),
-// it re-parses templates from disk on every call so edits appear without
-// a server restart. Otherwise it returns the cached Tmpl.
-func (h *Handlers) tmpl() *template.Template {
+// tmpl returns the template set in the current user's interface language.
+// When TemplatesFS is set (dev mode), it re-parses templates from disk on
+// every call so edits appear without a server restart. Otherwise it returns
+// the cached set.
+func (h *Handlers) tmpl(ctx context.Context) *template.Template {
+	lang := h.uiLanguage(ctx)
 	if h.TemplatesFS != nil {
-		t, err := template.New("").Funcs(h.FuncMap).ParseFS(h.TemplatesFS, "*.html")
+		t, err := h.parseTemplates(h.TemplatesFS, lang)
 		if err != nil {
 			slog.Error("tmpl re-parse", "error", err)
 			return h.Tmpl
 		}
 		return t
 	}
+	if t, ok := h.tmpls[lang]; ok {
+		return t
+	}
 	return h.Tmpl
 }
 
@@ -266,7 +386,7 @@ func (h *Handlers) renderError(w http.ResponseWriter, r *http.Request, code int,
 		Title:     title,
 		Message:   message,
 	}
-	if err := h.tmpl().ExecuteTemplate(w, "error.html", data); err != nil {
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "error.html", data); err != nil {
 		slog.Error("renderError template", "error", err)
 		http.Error(w, title, code)
 	}
@@ -289,7 +409,45 @@ func (h *Handlers) serverError(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handlers) siteSettings(ctx context.Context) (string, string, template.HTML) {
 	settings, _ := h.DB.GetSiteSettings(ctx)
-	return settings.SiteTitle, settings.Badge, ThemeCSS(settings.Theme, settings.AccentColor)
+	return settings.SiteTitle, settings.Badge, h.themeCSS(ctx, settings)
+}
+
+// themeCSS returns the <style> blocks for the current user's theme followed
+// by the site's custom CSS. Users who have not picked a theme or accent
+// color, or picked a custom theme this space does not have, get the site's.
+func (h *Handlers) themeCSS(ctx context.Context, settings db.SiteSettings) template.HTML {
+	theme, accent := settings.Theme, settings.AccentColor
+	if u := UserFromContext(ctx); u != nil {
+		userTheme, userAccent, err := h.DB.GetUserTheme(ctx, u.ID)
+		if err != nil {
+			slog.Error("themeCSS user theme", "error", err)
+		}
+		if userTheme != "" {
+			theme = userTheme
+		}
+		if userAccent != "" {
+			accent = userAccent
+		}
+	}
+	css, ok := h.namedThemeCSS(ctx, theme, accent, settings.Font)
+	if !ok {
+		css, _ = h.namedThemeCSS(ctx, settings.Theme, accent, settings.Font)
+	}
+	return css + CustomCSS(settings.CustomCSS)
+}
+
+// namedThemeCSS returns the <style> block for a built-in or custom theme.
+// Built-in themes use the given font; custom themes bring their own. It
+// reports false if there is no such theme.
+func (h *Handlers) namedThemeCSS(ctx context.Context, theme, accent, font string) (template.HTML, bool) {
+	if ValidTheme(theme) {
+		return ThemeCSS(theme, accent, font), true
+	}
+	t, err := h.DB.GetCustomThemeByName(ctx, theme)
+	if err != nil {
+		return ThemeCSS("midnight", accent, font), false
+	}
+	return CustomThemeCSS(t.Vars, t.Font), true
 }
 
 func userFirstname(ctx context.Context) string {
@@ -306,6 +464,23 @@ func userID(ctx context.Context) string {
 	return ""
 }
 
+// renderMarkdown expands snippet includes and {{var.key}} variables in page
+// markdown, renders it to HTML and rewrites image paths from static/images/
+// to /images/. sectionID selects the variable overrides to apply.
+func (h *Handlers) renderMarkdown(ctx context.Context, sectionID, contentMD string) (template.HTML, error) {
+	contentMD = markdown.ExpandSnippets(contentMD, h.snippetLookup(ctx))
+	vars, err := h.DB.GetVariableValues(ctx, sectionID)
+	if err != nil {
+		slog.Error("renderMarkdown variables", "error", err)
+	}
+	contentMD = markdown.ExpandVariables(contentMD, vars)
+	htmlBytes, err := markdown.Render([]byte(contentMD))
+	if err != nil {
+		return "", err
+	}
+	return template.HTML(strings.ReplaceAll(string(htmlBytes), "static/images/", "/images/")), nil
+}
+
 // buildPageTree converts a flat list of pages into a tree with one level of nesting.
 // Top-level pages (parent_slug == nil) are returned in order, with their children nested.
 func buildPageTree(pages []db.Page, activeSlug string) []TemplatePage {
@@ -320,21 +495,28 @@ func buildPageTree(pages []db.Page, activeSlug string) []TemplatePage {
 		}
 	}
 
+	now := time.Now()
 	var result []TemplatePage
 	for _, p := range topLevel {
 		tp := TemplatePage{
-			Title:    p.Title,
-			Slug:     p.Slug,
-			IsActive: p.Slug == activeSlug,
+			Title:       p.Title,
+			Slug:        p.Slug,
+			IsActive:    p.Slug == activeSlug,
+			Unpublished: !p.Live(now),
+			Scheduled:   p.PublishAt != nil && p.PublishAt.After(now),
+			HasDraft:    p.HasDraft(),
 		}
 		if kids, ok := childrenMap[p.Slug]; ok {
 			for _, c := range kids {
 				tp.Children = append(tp.Children, TemplatePage{
-					Title:      c.Title,
-					Slug:       c.Slug,
-					IsActive:   c.Slug == activeSlug,
-					IsChild:    true,
-					ParentSlug: p.Slug,
+					Title:       c.Title,
+					Slug:        c.Slug,
+					IsActive:    c.Slug == activeSlug,
+					IsChild:     true,
+					ParentSlug:  p.Slug,
+					Unpublished: !c.Live(now),
+					Scheduled:   c.PublishAt != nil && c.PublishAt.After(now),
+					HasDraft:    c.HasDraft(),
 				})
 			}
 		}
@@ -343,8 +525,62 @@ func buildPageTree(pages []db.Page, activeSlug string) []TemplatePage {
 	return result
 }
 
+// flattenPageTree lists the pages of a tree built by buildPageTree in
+// reading order: each top-level page followed by its children.
+func flattenPageTree(tree []TemplatePage) []TemplatePage {
+	var pages []TemplatePage
+	for _, p := range tree {
+		pages = append(pages, p)
+		pages = append(pages, p.Children...)
+	}
+	return pages
+}
+
+// pageNav locates the page with the given slug in tree.
+func pageNav(tree []TemplatePage, slug string) PageNav {
+	pages := flattenPageTree(tree)
+	var nav PageNav
+	for i, p := range pages {
+		if p.Slug != slug {
+			continue
+		}
+		if i > 0 {
+			nav.Prev = &pages[i-1]
+		}
+		if i+1 < len(pages) {
+			nav.Next = &pages[i+1]
+		}
+		for j := range tree {
+			if p.IsChild && tree[j].Slug == p.ParentSlug {
+				nav.Parent = &tree[j]
+				break
+			}
+		}
+		break
+	}
+	return nav
+}
+
+// pageMeta returns the footer describing page's last change, or nil if
+// the section hides it.
+func (h *Handlers) pageMeta(ctx context.Context, section db.Section, page db.Page) *PageMeta {
+	if !section.ShowPageMeta {
+		return nil
+	}
+	meta := &PageMeta{
+		UpdatedAt:   page.UpdatedAt,
+		HistoryPath: "/sections/" + section.Name + "/changes?page=" + url.QueryEscape(page.Slug),
+	}
+	if page.ChangedBy != nil {
+		if u, err := h.DB.GetUserByID(ctx, *page.ChangedBy); err == nil {
+			meta.AuthorName = strings.TrimSpace(u.Firstname + " " + u.Lastname)
+		}
+	}
+	return meta
+}
+
 func (h *Handlers) Home(w http.ResponseWriter, r *http.Request) {
-	sections, err := h.DB.ListSections(r.Context())
+	sections, err := h.DB.ListSections(r.Context(), h.showDrafts(r.Context()))
 	if err != nil {
 		h.serverError(w, r)
 		slog.Error("Home", "error", err)
@@ -355,6 +591,7 @@ func (h *Handlers) Home(w http.ResponseWriter, r *http.Request) {
 
 	isEditor := h.isEditor(r.Context())
 
+	now := time.Now()
 	var tplSections []TemplateSection
 	for _, s := range sections {
 		disabled := !h.canAccessSection(r.Context(), s.RequiredRole)
@@ -369,6 +606,7 @@ func (h *Handlers) Home(w http.ResponseWriter, r *http.Request) {
 			Disabled:     disabled,
 			RowID:        s.RowID,
 			IsEditor:     isEditor,
+			Unpublished:  !s.Live(now),
 		})
 	}
 
@@ -429,7 +667,7 @@ func (h *Handlers) Home(w http.ResponseWriter, r *http.Request) {
 
 	data := HomeData{
 		SiteTitle:         settings.SiteTitle,
-		ThemeCSS:          ThemeCSS(settings.Theme, settings.AccentColor),
+		ThemeCSS:          h.themeCSS(r.Context(), settings),
 		Sections:          tplSections,
 		Badge:             settings.Badge,
 		Heading:           settings.Heading,
@@ -439,11 +677,16 @@ func (h *Handlers) Home(w http.ResponseWriter, r *http.Request) {
 		UserLastname:      u.Lastname,
 		IsEditor:          isEditor,
 		IsAdmin:           h.isAdmin(r.Context()),
+		ShowReviewsBtn:    h.isReviewer(r.Context()) || isEditor,
 		Rows:              tplRows,
 		UngroupedSections: ungrouped,
 		HasRows:           hasRows,
 		PreviewMode:       previewing,
 		PreviewRoles:      previewRolesStr,
+		Spaces:            h.spaceLinks(r.Context()),
+		NavLinks:          settings.NavLinks,
+		FooterColumns:     settings.FooterColumns,
+		Blocks:            h.homeBlocks(r.Context(), settings.SiteLayout),
 	}
 
 	// Populate modal data for preview button (only when real editor and not in preview)
@@ -457,7 +700,7 @@ func (h *Handlers) Home(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if err := h.tmpl().ExecuteTemplate(w, "home.html", data); err != nil {
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "home.html", data); err != nil {
 		slog.Error("Home template", "error", err)
 	}
 }
@@ -471,12 +714,24 @@ func (h *Handlers) Section(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !h.sectionVisible(r.Context(), section) {
+		h.notFound(w, r)
+		return
+	}
+
 	if !h.canAccessSection(r.Context(), section.RequiredRole) {
 		h.forbidden(w, r)
 		return
 	}
 
-	first, err := h.DB.GetFirstPage(r.Context(), section.ID)
+	h.recordView(r.Context(), "section", section.ID)
+
+	if strings.TrimSpace(section.LandingMD) != "" {
+		h.sectionLanding(w, r, section)
+		return
+	}
+
+	first, err := h.DB.GetFirstPage(r.Context(), section.ID, h.showDrafts(r.Context()))
 	if err != nil {
 		// Section exists but has no pages — show empty state
 		title, badge, themeCSS := h.siteSettings(r.Context())
@@ -507,7 +762,7 @@ func (h *Handlers) Section(w http.ResponseWriter, r *http.Request) {
 			PreviewMode:   previewing,
 			PreviewRoles:  previewRolesStr,
 		}
-		if err := h.tmpl().ExecuteTemplate(w, "empty-section.html", data); err != nil {
+		if err := h.tmpl(r.Context()).ExecuteTemplate(w, "empty-section.html", data); err != nil {
 			slog.Error("Section empty template", "error", err)
 		}
 		return
@@ -516,6 +771,81 @@ func (h *Handlers) Section(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, fmt.Sprintf("/%s/%s", section.Name, first.Slug), http.StatusFound)
 }
 
+// landingExcerptLength is the length of the page excerpts on section
+// landing pages, in characters.
+const landingExcerptLength = 160
+
+// sectionLanding renders a section's landing Markdown followed by a card
+// for each of its top-level pages.
+func (h *Handlers) sectionLanding(w http.ResponseWriter, r *http.Request, section db.Section) {
+	ctx := r.Context()
+	allPages, err := h.DB.ListPagesBySection(ctx, section.ID, h.showDrafts(ctx))
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("sectionLanding pages", "error", err)
+		return
+	}
+	content, err := h.renderMarkdown(ctx, section.ID, section.LandingMD)
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("sectionLanding render", "error", err)
+		return
+	}
+
+	vars, err := h.DB.GetVariableValues(ctx, section.ID)
+	if err != nil {
+		slog.Error("sectionLanding variables", "error", err)
+	}
+	excerpts := make(map[string]string, len(allPages))
+	for _, p := range allPages {
+		excerpts[p.Slug] = markdown.Excerpt(markdown.ExpandVariables(p.ContentMD, vars), landingExcerptLength)
+	}
+	navPages := buildPageTree(allPages, "")
+	for i := range navPages {
+		navPages[i].Excerpt = excerpts[navPages[i].Slug]
+	}
+
+	settings, _ := h.DB.GetSiteSettings(ctx)
+	previewing := inPreviewMode(ctx)
+	var previewRolesStr string
+	if previewing {
+		previewRolesStr = strings.Join(PreviewRolesFromContext(ctx), ", ")
+		if previewRolesStr == "" {
+			previewRolesStr = "(no custom roles)"
+		}
+	}
+	data := SiteData{
+		SiteTitle: settings.SiteTitle,
+		Badge:     settings.Badge,
+		ThemeCSS:  h.themeCSS(ctx, settings),
+		Pages:     navPages,
+		Current: TemplatePage{
+			Title:   section.Title,
+			Content: content,
+		},
+		Section: TemplateSection{
+			ID:          section.ID,
+			Name:        section.Name,
+			Title:       section.Title,
+			Description: section.Description,
+			Icon:        section.Icon,
+			BasePath:    "/" + section.Name + "/",
+			HasLanding:  true,
+		},
+		HomePath:      "/",
+		UserFirstname: userFirstname(ctx),
+		IsEditor:      h.isEditor(ctx),
+		PreviewMode:   previewing,
+		PreviewRoles:  previewRolesStr,
+		NavLinks:      settings.NavLinks,
+		Landing:       true,
+	}
+
+	if err := h.tmpl(ctx).ExecuteTemplate(w, "page.html", data); err != nil {
+		slog.Error("sectionLanding template", "error", err)
+	}
+}
+
 func (h *Handlers) Page(w http.ResponseWriter, r *http.Request) {
 	sectionName := r.PathValue("section")
 	slug := r.PathValue("slug")
@@ -526,33 +856,53 @@ func (h *Handlers) Page(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !h.sectionVisible(r.Context(), section) {
+		h.notFound(w, r)
+		return
+	}
+
 	if !h.canAccessSection(r.Context(), section.RequiredRole) {
 		h.forbidden(w, r)
 		return
 	}
 
-	page, err := h.DB.GetPage(r.Context(), section.ID, slug)
+	showDrafts := h.showDrafts(r.Context())
+
+	page, err := h.DB.GetPage(r.Context(), section.ID, slug, showDrafts)
 	if err != nil {
 		h.notFound(w, r)
 		return
 	}
 
-	allPages, err := h.DB.ListPagesBySection(r.Context(), section.ID)
+	allPages, err := h.DB.ListPagesBySection(r.Context(), section.ID, showDrafts)
 	if err != nil {
 		h.serverError(w, r)
 		slog.Error("Page", "error", err)
 		return
 	}
 
-	htmlBytes, err := markdown.Render([]byte(page.ContentMD))
+	settings, _ := h.DB.GetSiteSettings(r.Context())
+	locale := requestLocale(r, settings)
+	translation := h.translatePage(r.Context(), settings, locale, page, allPages)
+
+	title, contentMD := page.Title, page.ContentMD
+	if showDrafts && page.HasDraft() {
+		contentMD = *page.DraftContentMD
+		if page.DraftTitle != nil {
+			title = *page.DraftTitle
+		}
+	} else if translation != nil {
+		title, contentMD = translation.Title, translation.ContentMD
+	}
+
+	content, err := h.renderMarkdown(r.Context(), section.ID, contentMD)
 	if err != nil {
 		h.serverError(w, r)
 		slog.Error("Page render", "error", err)
 		return
 	}
 
-	// Rewrite image paths from static/images/ to /images/
-	htmlStr := strings.ReplaceAll(string(htmlBytes), "static/images/", "/images/")
+	h.recordView(r.Context(), "page", page.ID)
 
 	navPages := buildPageTree(allPages, slug)
 
@@ -572,24 +922,49 @@ func (h *Handlers) Page(w http.ResponseWriter, r *http.Request) {
 		ThemeCSS:  pageThemeCSS,
 		Pages:     navPages,
 		Current: TemplatePage{
-			Title:   page.Title,
-			Slug:    page.Slug,
-			Content: template.HTML(htmlStr),
+			Title:       title,
+			Slug:        page.Slug,
+			Content:     content,
+			Unpublished: !page.Live(time.Now()),
+			Scheduled:   page.PublishAt != nil && page.PublishAt.After(time.Now()),
+			HasDraft:    showDrafts && page.HasDraft(),
 		},
 		Section: TemplateSection{
-			ID:       section.ID,
-			Name:     section.Name,
-			Title:    section.Title,
-			BasePath: "/" + section.Name + "/",
+			ID:         section.ID,
+			Name:       section.Name,
+			Title:      section.Title,
+			BasePath:   "/" + section.Name + "/",
+			HasLanding: section.LandingMD != "",
 		},
 		HomePath:      "/",
 		UserFirstname: userFirstname(r.Context()),
 		IsEditor:      h.isEditor(r.Context()),
 		PreviewMode:   previewing,
 		PreviewRoles:  previewRolesStr,
+		CommentError:  r.URL.Query().Get("error"),
+		NavLinks:      settings.NavLinks,
+		Nav:           pageNav(navPages, page.Slug),
+		Meta:          h.pageMeta(r.Context(), section, page),
+	}
+	if data.Threads, err = h.pageThreads(r.Context(), page.ID); err != nil {
+		slog.Error("Page comments", "error", err)
+	}
+	if fb, err := h.DB.GetPageFeedback(r.Context(), page.ID, userID(r.Context())); err == nil {
+		data.Feedback = &fb
+	}
+	if data.Watching, data.WatchingSection, err = h.DB.GetSubscriptionState(r.Context(), userID(r.Context()), page.ID, section.ID); err != nil {
+		slog.Error("Page subscriptions", "error", err)
+	}
+	data.Versions = h.versionLinks(r.Context(), section, page.Slug, "")
+	if data.Locales = localeLinks(settings, locale); data.Locales != nil {
+		data.Locale = locale
+		data.LocaleName = localeName(locale)
+		data.DefaultLocaleName = localeName(settings.DefaultLocale)
+		data.TranslationMissing = locale != settings.DefaultLocale && translation == nil
+		data.TranslationOutdated = data.IsEditor && translation != nil && translation.Outdated(page.Version)
 	}
 
-	if err := h.tmpl().ExecuteTemplate(w, "page.html", data); err != nil {
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "page.html", data); err != nil {
 		slog.Error("Page template", "error", err)
 	}
 }
@@ -604,19 +979,28 @@ func (h *Handlers) EditPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	page, err := h.DB.GetPage(r.Context(), section.ID, slug)
+	page, err := h.DB.GetPage(r.Context(), section.ID, slug, true)
 	if err != nil {
 		h.notFound(w, r)
 		return
 	}
 
-	allPages, err := h.DB.ListPagesBySection(r.Context(), section.ID)
+	allPages, err := h.DB.ListPagesBySection(r.Context(), section.ID, true)
 	if err != nil {
 		h.serverError(w, r)
 		slog.Error("EditPage", "error", err)
 		return
 	}
 
+	// Continue editing the draft if there is one.
+	title, contentMD := page.Title, page.ContentMD
+	if page.HasDraft() {
+		contentMD = *page.DraftContentMD
+		if page.DraftTitle != nil {
+			title = *page.DraftTitle
+		}
+	}
+
 	navPages := buildPageTree(allPages, slug)
 
 	imageMetas, err := h.DB.ListImageMetasBySection(r.Context(), section.ID)
@@ -636,17 +1020,40 @@ func (h *Handlers) EditPage(w http.ResponseWriter, r *http.Request) {
 			Title:    section.Title,
 			BasePath: "/" + section.Name + "/",
 		},
-		HomePath:      "/",
-		PageTitle:     page.Title,
-		ContentMD:     page.ContentMD,
-		Slug:          page.Slug,
-		Version:       page.Version,
-		Images:        imageMetas,
-		UserFirstname: userFirstname(r.Context()),
-		Error:         r.URL.Query().Get("error"),
+		HomePath:          "/",
+		PageTitle:         title,
+		ContentMD:         contentMD,
+		Slug:              page.Slug,
+		Version:           page.Version,
+		Published:         page.Published,
+		HasDraft:          page.HasDraft(),
+		Images:            imageMetas,
+		UserFirstname:     userFirstname(r.Context()),
+		Error:             r.URL.Query().Get("error"),
+		RequiredApprovals: section.RequiredApprovals,
+		PublishAt:         formatScheduleTime(page.PublishAt),
+		UnpublishAt:       formatScheduleTime(page.UnpublishAt),
+		RequireSummary:    section.RequireSummary,
+	}
+	if review, err := h.DB.GetActivePageReview(r.Context(), page.ID); err == nil {
+		data.ReviewID = review.ID
+	}
+	threads, err := h.pageThreads(r.Context(), page.ID)
+	if err != nil {
+		slog.Error("EditPage comments", "error", err)
+	}
+	for _, t := range threads {
+		if !t.Resolved {
+			data.Threads = append(data.Threads, t)
+		}
+	}
+	if data.Feedback, err = h.DB.GetFeedbackSummary(r.Context(), page.ID); err != nil {
+		slog.Error("EditPage feedback", "error", err)
 	}
+	settings, _ := h.DB.GetSiteSettings(r.Context())
+	data.Translations = h.translationStatuses(r.Context(), settings, page)
 
-	if err := h.tmpl().ExecuteTemplate(w, "edit.html", data); err != nil {
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "edit.html", data); err != nil {
 		slog.Error("EditPage template", "error", err)
 	}
 }
@@ -674,18 +1081,121 @@ func (h *Handlers) SavePage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	page, err := h.DB.GetPage(r.Context(), section.ID, slug, true)
+	if err != nil {
+		h.notFound(w, r)
+		return
+	}
+
+	summary, err := h.changeSummary(r, section.RequireSummary)
+	if err != nil {
+		http.Redirect(w, r, fmt.Sprintf("/%s/%s/edit?error=%s", section.Name, slug, url.QueryEscape(err.Error())), http.StatusSeeOther)
+		return
+	}
+
+	publishAt, unpublishAt, err := parseSchedule(r)
+	if err != nil {
+		http.Redirect(w, r, fmt.Sprintf("/%s/%s/edit?error=%s", section.Name, slug, url.QueryEscape(err.Error())), http.StatusSeeOther)
+		return
+	}
+
+	// A scheduled publish would bypass review, so only pages that are
+	// already published can be scheduled in sections requiring approval.
+	if publishAt != nil && !page.Published && section.RequiredApprovals > 0 {
+		msg := "This section requires approval; submit the page for review before scheduling it"
+		http.Redirect(w, r, fmt.Sprintf("/%s/%s/edit?error=%s", section.Name, slug, url.QueryEscape(msg)), http.StatusSeeOther)
+		return
+	}
+
+	if r.FormValue("action") == "review" {
+		h.submitReview(w, r, section, page, title, contentMD, summary, publishAt, unpublishAt)
+		return
+	}
+
+	if r.FormValue("action") != "draft" && section.RequiredApprovals > 0 {
+		msg := "This section requires approval; submit the change for review"
+		http.Redirect(w, r, fmt.Sprintf("/%s/%s/edit?error=%s", section.Name, slug, url.QueryEscape(msg)), http.StatusSeeOther)
+		return
+	}
+
 	changedBy := userID(r.Context())
-	updated, err := h.DB.UpdatePage(r.Context(), section.ID, slug, title, contentMD, changedBy)
+
+	// Changes to a published page can be staged as a draft. An unpublished
+	// page has no published revision to protect, so it is saved in place.
+	if r.FormValue("action") == "draft" && page.Published {
+		if err := h.DB.SavePageDraft(r.Context(), section.ID, slug, title, contentMD); err != nil {
+			h.serverError(w, r)
+			slog.Error("SavePage draft", "error", err)
+			return
+		}
+		if err := h.DB.SetPageSchedule(r.Context(), section.ID, slug, publishAt, unpublishAt); err != nil {
+			h.serverError(w, r)
+			slog.Error("SavePage schedule", "error", err)
+			return
+		}
+		rescheduled := page
+		rescheduled.PublishAt, rescheduled.UnpublishAt = publishAt, unpublishAt
+		h.emitPageVisibility(r.Context(), section, page, rescheduled)
+		http.Redirect(w, r, fmt.Sprintf("/%s/%s", section.Name, slug), http.StatusSeeOther)
+		return
+	}
+
+	var updated db.Page
+	if r.FormValue("action") == "draft" {
+		updated, err = h.DB.UpdatePage(r.Context(), section.ID, slug, title, contentMD, changedBy)
+	} else {
+		updated, err = h.DB.PublishPage(r.Context(), section.ID, slug, title, contentMD, changedBy)
+	}
 	if err != nil {
 		h.serverError(w, r)
 		slog.Error("SavePage", "error", err)
 		return
 	}
+	if err := h.DB.SetPageSchedule(r.Context(), section.ID, slug, publishAt, unpublishAt); err != nil {
+		h.serverError(w, r)
+		slog.Error("SavePage schedule", "error", err)
+		return
+	}
+	updated.PublishAt, updated.UnpublishAt = publishAt, unpublishAt
 
-	if err := h.DB.SavePageHistory(r.Context(), updated, changedBy); err != nil {
+	if err := h.DB.SavePageHistory(r.Context(), updated, changedBy, summary); err != nil {
 		slog.Error("SavePage history", "error", err)
 	}
 
+	// Saves to a page readers cannot see yet stay internal.
+	if updated.Live(time.Now()) {
+		h.emitEvent(r.Context(), "page.updated", pageEvent(r.Context(), section, updated))
+	}
+	h.emitPageVisibility(r.Context(), section, page, updated)
+
+	if updated.Published {
+		fromVersion := 0
+		if page.Published {
+			fromVersion = page.Version
+		}
+		h.notifySubscribers(r.Context(), section, updated, fromVersion)
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/%s/%s", section.Name, slug), http.StatusSeeOther)
+}
+
+// DiscardDraft drops the staged changes of a published page.
+func (h *Handlers) DiscardDraft(w http.ResponseWriter, r *http.Request) {
+	sectionName := r.PathValue("section")
+	slug := r.PathValue("slug")
+
+	section, err := h.DB.GetSectionByName(r.Context(), sectionName)
+	if err != nil {
+		h.notFound(w, r)
+		return
+	}
+
+	if err := h.DB.DiscardPageDraft(r.Context(), section.ID, slug); err != nil {
+		h.serverError(w, r)
+		slog.Error("DiscardDraft", "error", err)
+		return
+	}
+
 	http.Redirect(w, r, fmt.Sprintf("/%s/%s", section.Name, slug), http.StatusSeeOther)
 }
 
@@ -697,17 +1207,21 @@ func (h *Handlers) PreviewPage(w http.ResponseWriter, r *http.Request) {
 
 	contentMD := r.FormValue("content_md")
 
-	htmlBytes, err := markdown.Render([]byte(contentMD))
+	// Unknown sections still preview, with global variables only.
+	var sectionID string
+	if section, err := h.DB.GetSectionByName(r.Context(), r.PathValue("section")); err == nil {
+		sectionID = section.ID
+	}
+
+	content, err := h.renderMarkdown(r.Context(), sectionID, contentMD)
 	if err != nil {
 		h.serverError(w, r)
 		slog.Error("PreviewPage", "error", err)
 		return
 	}
 
-	htmlStr := strings.ReplaceAll(string(htmlBytes), "static/images/", "/images/")
-
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	w.Write([]byte(htmlStr))
+	w.Write([]byte(content))
 }
 
 func (h *Handlers) Image(w http.ResponseWriter, r *http.Request) {
@@ -760,15 +1274,27 @@ func (h *Handlers) UploadImage(w http.ResponseWriter, r *http.Request) {
 	filename := sanitizeFilename(header.Filename)
 	sectionID := r.FormValue("section_id")
 
+	// Upsert: update if filename already exists, otherwise create
+	existing, err := h.DB.GetImage(r.Context(), filename)
+	exists := err == nil
+	if exists {
+		sectionID = existing.SectionID
+	}
+	summary, err := h.imageChangeSummary(r, sectionID)
+	if err != nil {
+		imageRedirect(w, r, err.Error())
+		return
+	}
+
 	changedBy := userID(r.Context())
 
-	// Upsert: update if filename already exists, otherwise create
 	var img db.Image
-	_, err = h.DB.GetImage(r.Context(), filename)
-	if err == nil {
+	event := "image.updated"
+	if exists {
 		img, err = h.DB.UpdateImage(r.Context(), filename, contentType, data, changedBy)
 	} else {
 		img, err = h.DB.CreateImage(r.Context(), filename, contentType, data, sectionID, changedBy)
+		event = "image.created"
 	}
 	if err != nil {
 		h.serverError(w, r)
@@ -776,10 +1302,12 @@ func (h *Handlers) UploadImage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.DB.SaveImageHistory(r.Context(), img, changedBy); err != nil {
+	if err := h.DB.SaveImageHistory(r.Context(), img, changedBy, summary); err != nil {
 		slog.Error("UploadImage history", "error", err)
 	}
 
+	h.emitEvent(r.Context(), event, imageEvent(r.Context(), img))
+
 	redirect := r.URL.Query().Get("redirect")
 	if redirect == "" {
 		redirect = "/"
@@ -814,6 +1342,17 @@ func (h *Handlers) UpdateImageHandler(w http.ResponseWriter, r *http.Request) {
 		contentType = "application/octet-stream"
 	}
 
+	existing, err := h.DB.GetImage(r.Context(), filename)
+	if err != nil {
+		h.notFound(w, r)
+		return
+	}
+	summary, err := h.imageChangeSummary(r, existing.SectionID)
+	if err != nil {
+		imageRedirect(w, r, err.Error())
+		return
+	}
+
 	changedBy := userID(r.Context())
 	img, err := h.DB.UpdateImage(r.Context(), filename, contentType, data, changedBy)
 	if err != nil {
@@ -822,10 +1361,12 @@ func (h *Handlers) UpdateImageHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.DB.SaveImageHistory(r.Context(), img, changedBy); err != nil {
+	if err := h.DB.SaveImageHistory(r.Context(), img, changedBy, summary); err != nil {
 		slog.Error("UpdateImage history", "error", err)
 	}
 
+	h.emitEvent(r.Context(), "image.updated", imageEvent(r.Context(), img))
+
 	redirect := r.URL.Query().Get("redirect")
 	if redirect == "" {
 		redirect = "/"
@@ -833,6 +1374,31 @@ func (h *Handlers) UpdateImageHandler(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, redirect+"#images", http.StatusSeeOther)
 }
 
+// imageChangeSummary reads the change summary for an image in the given
+// section, which may be empty for images not tied to one.
+func (h *Handlers) imageChangeSummary(r *http.Request, sectionID string) (string, error) {
+	required := false
+	if sectionID != "" {
+		if section, err := h.DB.GetSection(r.Context(), sectionID); err == nil {
+			required = section.RequireSummary
+		}
+	}
+	return h.changeSummary(r, required)
+}
+
+// imageRedirect sends the editor back to the image list with an error.
+func imageRedirect(w http.ResponseWriter, r *http.Request, msg string) {
+	redirect := r.URL.Query().Get("redirect")
+	if redirect == "" {
+		redirect = "/"
+	}
+	sep := "?"
+	if strings.Contains(redirect, "?") {
+		sep = "&"
+	}
+	http.Redirect(w, r, redirect+sep+"error="+url.QueryEscape(msg)+"#images", http.StatusSeeOther)
+}
+
 func (h *Handlers) NewPageForm(w http.ResponseWriter, r *http.Request) {
 	sectionName := r.PathValue("section")
 
@@ -842,7 +1408,7 @@ func (h *Handlers) NewPageForm(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	allPages, err := h.DB.ListPagesBySection(r.Context(), section.ID)
+	allPages, err := h.DB.ListPagesBySection(r.Context(), section.ID, true)
 	if err != nil {
 		h.serverError(w, r)
 		slog.Error("NewPageForm", "error", err)
@@ -851,6 +1417,11 @@ func (h *Handlers) NewPageForm(w http.ResponseWriter, r *http.Request) {
 
 	navPages := buildPageTree(allPages, "")
 
+	templates, err := h.DB.ListPageTemplatesForSection(r.Context(), section.ID)
+	if err != nil {
+		slog.Error("NewPageForm templates", "error", err)
+	}
+
 	npTitle, npBadge, npThemeCSS := h.siteSettings(r.Context())
 	data := EditData{
 		SiteTitle: npTitle,
@@ -863,11 +1434,23 @@ func (h *Handlers) NewPageForm(w http.ResponseWriter, r *http.Request) {
 			Title:    section.Title,
 			BasePath: "/" + section.Name + "/",
 		},
-		HomePath:      "/",
-		UserFirstname: userFirstname(r.Context()),
+		HomePath:          "/",
+		UserFirstname:     userFirstname(r.Context()),
+		Templates:         templates,
+		RequiredApprovals: section.RequiredApprovals,
+	}
+
+	if id := r.URL.Query().Get("template"); id != "" {
+		for _, t := range templates {
+			if t.ID == id {
+				data.TemplateID = t.ID
+				data.ContentMD = t.ContentMD
+				data.TemplateVars = templateVariables(t.ContentMD)
+			}
+		}
 	}
 
-	if err := h.tmpl().ExecuteTemplate(w, "new-page.html", data); err != nil {
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "new-page.html", data); err != nil {
 		slog.Error("NewPageForm template", "error", err)
 	}
 }
@@ -894,9 +1477,17 @@ func (h *Handlers) CreatePage(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "slug and title are required", http.StatusBadRequest)
 		return
 	}
+	if reservedSlugs[slug] {
+		http.Error(w, fmt.Sprintf("%q is reserved and cannot be used as a slug", slug), http.StatusBadRequest)
+		return
+	}
+
+	if r.FormValue("template_id") != "" {
+		contentMD = fillPageTemplate(contentMD, pageTemplateValues(r, section, slug, title))
+	}
 
 	// Auto-calculate sort_order
-	pages, err := h.DB.ListPagesBySection(r.Context(), section.ID)
+	pages, err := h.DB.ListPagesBySection(r.Context(), section.ID, true)
 	if err != nil {
 		h.serverError(w, r)
 		slog.Error("CreatePage list", "error", err)
@@ -904,18 +1495,27 @@ func (h *Handlers) CreatePage(w http.ResponseWriter, r *http.Request) {
 	}
 	sortOrder := len(pages)
 
+	// "Save as draft" keeps a brand-new page unpublished until it is ready.
+	// Sections that require approval never publish new pages directly.
+	published := r.FormValue("action") != "draft" && section.RequiredApprovals == 0
+
 	changedBy := userID(r.Context())
-	page, err := h.DB.CreatePage(r.Context(), section.ID, slug, title, contentMD, sortOrder, changedBy)
+	page, err := h.DB.CreatePage(r.Context(), section.ID, slug, title, contentMD, sortOrder, published, changedBy)
 	if err != nil {
 		h.serverError(w, r)
 		slog.Error("CreatePage", "error", err)
 		return
 	}
 
-	if err := h.DB.SavePageHistory(r.Context(), page, changedBy); err != nil {
+	if err := h.DB.SavePageHistory(r.Context(), page, changedBy, ""); err != nil {
 		slog.Error("CreatePage history", "error", err)
 	}
 
+	// A page created as a draft is reported once it is published.
+	if page.Published {
+		h.emitEvent(r.Context(), "page.created", pageEvent(r.Context(), section, page))
+	}
+
 	http.Redirect(w, r, fmt.Sprintf("/%s/%s", section.Name, slug), http.StatusSeeOther)
 }
 
@@ -930,7 +1530,7 @@ func (h *Handlers) NewSectionForm(w http.ResponseWriter, r *http.Request) {
 		RowIDParam:    r.URL.Query().Get("row_id"),
 	}
 
-	if err := h.tmpl().ExecuteTemplate(w, "new-section.html", data); err != nil {
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "new-section.html", data); err != nil {
 		slog.Error("NewSectionForm template", "error", err)
 	}
 }
@@ -963,7 +1563,7 @@ func (h *Handlers) CreateSection(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Auto-calculate sort_order from count of existing sections
-	sections, err := h.DB.ListSections(r.Context())
+	sections, err := h.DB.ListSections(r.Context(), true)
 	if err != nil {
 		h.serverError(w, r)
 		slog.Error("CreateSection list", "error", err)
@@ -979,10 +1579,12 @@ func (h *Handlers) CreateSection(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.DB.SaveSectionHistory(r.Context(), section, changedBy); err != nil {
+	if err := h.DB.SaveSectionHistory(r.Context(), section, changedBy, ""); err != nil {
 		slog.Error("CreateSection history", "error", err)
 	}
 
+	h.emitEvent(r.Context(), "section.created", sectionEvent(r.Context(), section))
+
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
@@ -997,27 +1599,37 @@ func (h *Handlers) EditSectionForm(w http.ResponseWriter, r *http.Request) {
 
 	roles, _ := h.DB.ListRoles(r.Context())
 
-	allPages, _ := h.DB.ListPagesBySection(r.Context(), section.ID)
+	allPages, _ := h.DB.ListPagesBySection(r.Context(), section.ID, true)
 	tplPages := buildPageTree(allPages, "")
 
 	esTitle, _, esThemeCSS := h.siteSettings(r.Context())
 	data := EditSectionData{
-		SiteTitle:     esTitle,
-		ThemeCSS:      esThemeCSS,
-		HomePath:      "/",
-		SectionID:     section.ID,
-		SectionName:   section.Name,
-		Title:         section.Title,
-		Description:   section.Description,
-		Icon:          section.Icon,
-		Version:       section.Version,
-		UserFirstname: userFirstname(r.Context()),
-		Roles:         roles,
-		RequiredRole:  section.RequiredRole,
-		Pages:         tplPages,
+		SiteTitle:         esTitle,
+		ThemeCSS:          esThemeCSS,
+		HomePath:          "/",
+		SectionID:         section.ID,
+		SectionName:       section.Name,
+		Title:             section.Title,
+		Description:       section.Description,
+		Icon:              section.Icon,
+		Version:           section.Version,
+		UserFirstname:     userFirstname(r.Context()),
+		Roles:             roles,
+		RequiredRole:      section.RequiredRole,
+		RequiredApprovals: section.RequiredApprovals,
+		PublishAt:         formatScheduleTime(section.PublishAt),
+		UnpublishAt:       formatScheduleTime(section.UnpublishAt),
+		Pages:             tplPages,
+		RequireSummary:    section.RequireSummary,
+		IsAdmin:           h.isAdmin(r.Context()),
+		LandingMD:         section.LandingMD,
+		ShowPageMeta:      section.ShowPageMeta,
+	}
+	if data.History, err = h.DB.ListSectionHistory(r.Context(), section.ID, maxSectionHistory); err != nil {
+		slog.Error("EditSectionForm history", "error", err)
 	}
 
-	if err := h.tmpl().ExecuteTemplate(w, "edit-section.html", data); err != nil {
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "edit-section.html", data); err != nil {
 		slog.Error("EditSectionForm template", "error", err)
 	}
 }
@@ -1040,6 +1652,9 @@ func (h *Handlers) UpdateSection(w http.ResponseWriter, r *http.Request) {
 	description := r.FormValue("description")
 	icon := r.FormValue("icon")
 	requiredRole := r.FormValue("required_role")
+	requiredApprovals, _ := strconv.Atoi(r.FormValue("required_approvals"))
+	landingMD := strings.TrimSpace(r.FormValue("landing_md"))
+	showPageMeta := r.FormValue("show_page_meta") == "on"
 
 	if title == "" {
 		http.Error(w, "title is required", http.StatusBadRequest)
@@ -1050,18 +1665,42 @@ func (h *Handlers) UpdateSection(w http.ResponseWriter, r *http.Request) {
 		icon = "document"
 	}
 
+	if requiredApprovals < 0 {
+		requiredApprovals = 0
+	}
+
+	publishAt, unpublishAt, err := parseSchedule(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	summary, err := h.changeSummary(r, section.RequireSummary)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Only admins decide whether a section requires change summaries.
+	requireSummary := section.RequireSummary
+	if h.isAdmin(r.Context()) {
+		requireSummary = r.FormValue("require_summary") == "on"
+	}
+
 	changedBy := userID(r.Context())
-	updated, err := h.DB.UpdateSection(r.Context(), section.ID, title, description, icon, requiredRole, changedBy)
+	updated, err := h.DB.UpdateSection(r.Context(), section.ID, title, description, icon, requiredRole, requiredApprovals, publishAt, unpublishAt, requireSummary, landingMD, showPageMeta, changedBy)
 	if err != nil {
 		h.serverError(w, r)
 		slog.Error("UpdateSection", "error", err)
 		return
 	}
 
-	if err := h.DB.SaveSectionHistory(r.Context(), updated, changedBy); err != nil {
+	if err := h.DB.SaveSectionHistory(r.Context(), updated, changedBy, summary); err != nil {
 		slog.Error("UpdateSection history", "error", err)
 	}
 
+	h.emitEvent(r.Context(), "section.updated", sectionEvent(r.Context(), updated))
+
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
@@ -1081,6 +1720,8 @@ func (h *Handlers) DeleteSection(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.emitEvent(r.Context(), "section.deleted", sectionEvent(r.Context(), section))
+
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
@@ -1094,7 +1735,7 @@ func (h *Handlers) DeletePage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = h.DB.GetPage(r.Context(), section.ID, slug)
+	page, err := h.DB.GetPage(r.Context(), section.ID, slug, true)
 	if err != nil {
 		h.notFound(w, r)
 		return
@@ -1113,6 +1754,9 @@ func (h *Handlers) DeletePage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	page.ContentMD = ""
+	h.emitEvent(r.Context(), "page.deleted", pageEvent(r.Context(), section, page))
+
 	http.Redirect(w, r, "/"+section.Name+"/", http.StatusSeeOther)
 }
 
@@ -1125,6 +1769,8 @@ func (h *Handlers) DeleteImage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.emitEvent(r.Context(), "image.deleted", webhookImage{Filename: filename, URL: imageURL(r.Context(), filename)})
+
 	redirect := r.URL.Query().Get("redirect")
 	if redirect == "" {
 		redirect = "/"
@@ -1160,7 +1806,7 @@ func (h *Handlers) RenameImage(w http.ResponseWriter, r *http.Request) {
 		if strings.Contains(redirect, "?") {
 			sep = "&"
 		}
-		http.Redirect(w, r, redirect+sep+"error="+url.QueryEscape(fmt.Sprintf("An image with the filename %q already exists.", newFilename))+"#images", http.StatusSeeOther)
+		http.Redirect(w, r, redirect+sep+"error="+url.QueryEscape(h.t(r.Context(), "An image with the filename \"%s\" already exists.", newFilename))+"#images", http.StatusSeeOther)
 		return
 	}
 
@@ -1172,16 +1818,21 @@ func (h *Handlers) RenameImage(w http.ResponseWriter, r *http.Request) {
 		h.notFound(w, r)
 		return
 	}
-	if err := h.DB.SaveImageHistory(r.Context(), oldImg, changedBy); err != nil {
+	if err := h.DB.SaveImageHistory(r.Context(), oldImg, changedBy, ""); err != nil {
 		slog.Error("RenameImage history", "error", err)
 	}
 
-	if _, err := h.DB.RenameImage(r.Context(), oldFilename, newFilename, changedBy); err != nil {
+	renamed, err := h.DB.RenameImage(r.Context(), oldFilename, newFilename, changedBy)
+	if err != nil {
 		h.serverError(w, r)
 		slog.Error("RenameImage", "error", err)
 		return
 	}
 
+	ev := imageEvent(r.Context(), renamed)
+	ev.PreviousFilename = oldFilename
+	h.emitEvent(r.Context(), "image.updated", ev)
+
 	redirect := r.URL.Query().Get("redirect")
 	if redirect == "" {
 		redirect = "/"
@@ -1191,10 +1842,14 @@ func (h *Handlers) RenameImage(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handlers) EditHomeForm(w http.ResponseWriter, r *http.Request) {
 	settings, _ := h.DB.GetSiteSettings(r.Context())
+	customThemes, err := h.DB.ListCustomThemes(r.Context())
+	if err != nil {
+		slog.Error("EditHomeForm custom themes", "error", err)
+	}
 
 	data := EditHomeData{
 		SiteTitle:     settings.SiteTitle,
-		ThemeCSS:      ThemeCSS(settings.Theme, settings.AccentColor),
+		ThemeCSS:      h.themeCSS(r.Context(), settings),
 		HomePath:      "/",
 		Badge:         settings.Badge,
 		Heading:       settings.Heading,
@@ -1202,12 +1857,26 @@ func (h *Handlers) EditHomeForm(w http.ResponseWriter, r *http.Request) {
 		Footer:        settings.Footer,
 		Theme:         settings.Theme,
 		AccentColor:   settings.AccentColor,
+		Font:          settings.Font,
+		Fonts:         fontOptions(),
+		CustomThemes:  customThemes,
+		CustomCSS:     settings.CustomCSS,
+		DefaultLocale: settings.DefaultLocale,
+		Locales:       strings.Join(settings.Locales, ", "),
+		UILanguage:    settings.UILanguage,
+		UILanguages:   h.uiLanguageOptions(),
+		NavLinks:      formatNavLinks(settings.NavLinks),
+		FooterLinks:   formatFooterColumns(settings.FooterColumns),
+		HomeIntro:     settings.HomeIntro,
+		HomePopular:   settings.HasHomeBlock(db.HomeBlockPopular),
+		HomeRecent:    settings.HasHomeBlock(db.HomeBlockRecent),
 		Version:       settings.Version,
 		UserFirstname: userFirstname(r.Context()),
+		IsAdmin:       h.isAdmin(r.Context()),
 		HasFavicon:    settings.HasFavicon,
 	}
 
-	if err := h.tmpl().ExecuteTemplate(w, "edit-home.html", data); err != nil {
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "edit-home.html", data); err != nil {
 		slog.Error("EditHomeForm template", "error", err)
 	}
 }
@@ -1232,14 +1901,58 @@ func (h *Handlers) UpdateHome(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !ValidTheme(theme) {
-		theme = "midnight"
+		if _, err := h.DB.GetCustomThemeByName(r.Context(), theme); err != nil {
+			theme = "midnight"
+		}
 	}
 	if !ValidAccent(accentColor) {
 		accentColor = "blue"
 	}
+	font := r.FormValue("font")
+	if !ValidFont(font) {
+		font = fontNames[0]
+	}
+
+	defaultLocale := "en"
+	if tag, err := language.Parse(r.FormValue("default_locale")); err == nil {
+		defaultLocale = tag.String()
+	}
+	locales, err := parseLocales(r.FormValue("locales"), defaultLocale)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	uiLanguage := r.FormValue("ui_language")
+	if !h.UI.Has(uiLanguage) {
+		uiLanguage = defaultUILanguage
+	}
+
+	// Custom CSS applies to every page, so only admins may change it.
+	current, _ := h.DB.GetSiteSettings(r.Context())
+	customCSS := current.CustomCSS
+	if h.isAdmin(r.Context()) {
+		customCSS = strings.TrimSpace(r.FormValue("custom_css"))
+		if !ValidCustomCSS(customCSS) {
+			http.Error(w, `custom CSS must not contain "</"`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	layout := db.SiteLayout{
+		HomeIntro:  strings.TrimSpace(r.FormValue("home_intro")),
+		HomeBlocks: parseHomeBlocks(r.Form["home_blocks"]),
+	}
+	if layout.NavLinks, err = parseNavLinks(r.FormValue("nav_links")); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if layout.FooterColumns, err = parseFooterColumns(r.FormValue("footer_links")); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	changedBy := userID(r.Context())
-	settings, err := h.DB.UpdateSiteSettings(r.Context(), siteTitle, badge, heading, description, footer, theme, accentColor, changedBy)
+	settings, err := h.DB.UpdateSiteSettings(r.Context(), siteTitle, badge, heading, description, footer, theme, accentColor, font, defaultLocale, locales, uiLanguage, customCSS, layout, changedBy)
 	if err != nil {
 		h.serverError(w, r)
 		slog.Error("UpdateHome", "error", err)
@@ -1289,7 +2002,7 @@ func (h *Handlers) NewRowForm(w http.ResponseWriter, r *http.Request) {
 		UserFirstname: userFirstname(r.Context()),
 		IsNew:         true,
 	}
-	if err := h.tmpl().ExecuteTemplate(w, "row-form.html", data); err != nil {
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "row-form.html", data); err != nil {
 		slog.Error("NewRowForm template", "error", err)
 	}
 }
@@ -1352,7 +2065,7 @@ func (h *Handlers) EditRowForm(w http.ResponseWriter, r *http.Request) {
 		UserFirstname: userFirstname(r.Context()),
 		IsNew:         false,
 	}
-	if err := h.tmpl().ExecuteTemplate(w, "row-form.html", data); err != nil {
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "row-form.html", data); err != nil {
 		slog.Error("EditRowForm template", "error", err)
 	}
 }
@@ -1503,7 +2216,8 @@ func (h *Handlers) StartPreview(w http.ResponseWriter, r *http.Request) {
 		roles = strings.Join(r.Form["roles"], ",")
 	}
 
-	if err := h.DB.SetSessionPreviewRoles(r.Context(), token, roles); err != nil {
+	drafts := r.FormValue("drafts") == "on"
+	if err := h.DB.SetSessionPreviewRoles(r.Context(), token, roles, drafts); err != nil {
 		slog.Error("StartPreview SetSessionPreviewRoles", "error", err)
 		h.serverError(w, r)
 		return
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/message/catalog"
+)
+
+// defaultUILanguage is the language the user interface strings are written
+// in. The strings are the message keys, so it needs no catalog.
+const defaultUILanguage = "en"
+
+// UICatalog holds the translations of the user interface strings. Each
+// language has a JSON file named after its tag, e.g. de.json, mapping the
+// English strings to their translations.
+type UICatalog struct {
+	builder   *catalog.Builder
+	languages []string
+}
+
+// LoadUICatalog reads the message catalogs in fsys.
+func LoadUICatalog(fsys fs.FS) (*UICatalog, error) {
+	c := &UICatalog{
+		builder:   catalog.NewBuilder(catalog.Fallback(language.English)),
+		languages: []string{defaultUILanguage},
+	}
+	names, err := fs.Glob(fsys, "*.json")
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		tag, err := language.Parse(strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		for key, msg := range messages {
+			if err := c.builder.SetString(tag, key, msg); err != nil {
+				return nil, fmt.Errorf("%s: %q: %w", name, key, err)
+			}
+		}
+		c.languages = append(c.languages, tag.String())
+	}
+	return c, nil
+}
+
+// Languages returns the user interface languages, English first.
+func (c *UICatalog) Languages() []string {
+	if c == nil {
+		return []string{defaultUILanguage}
+	}
+	return c.languages
+}
+
+// Has reports whether lang is one of the user interface languages.
+func (c *UICatalog) Has(lang string) bool {
+	return slices.Contains(c.Languages(), lang)
+}
+
+// FuncMap returns the template functions for lang: "t" translates a user
+// interface string and formats any further arguments into it like
+// fmt.Sprintf, showing strings without a translation in English; "uiLang"
+// returns lang, for the lang attribute.
+func (c *UICatalog) FuncMap(lang string) template.FuncMap {
+	p := c.printer(lang)
+	return template.FuncMap{
+		"t": func(key string, args ...any) string {
+			return translate(p, key, args...)
+		},
+		"uiLang": func() string {
+			return lang
+		},
+	}
+}
+
+func (c *UICatalog) printer(lang string) *message.Printer {
+	if c == nil {
+		return message.NewPrinter(language.English)
+	}
+	return message.NewPrinter(language.Make(lang), message.Catalog(c.builder))
+}
+
+// translate translates key and formats args into it. Messages are also
+// passed through it that are not keys and may contain a stray %, such as
+// ones that quote user input or were translated by the handler already;
+// without args they are returned as they are.
+func translate(p *message.Printer, key string, args ...any) string {
+	if len(args) == 0 && strings.Contains(key, "%") {
+		return key
+	}
+	return p.Sprintf(key, args...)
+}
+
+// t translates a user interface string into the current user's language,
+// like the "t" template function. Handlers use it for messages with
+// arguments and for the errors of helpers such as changeSummary.
+func (h *Handlers) t(ctx context.Context, key string, args ...any) string {
+	return translate(h.UI.printer(h.uiLanguage(ctx)), key, args...)
+}
+
+// UILanguageOption is an entry of a user interface language select.
+type UILanguageOption struct {
+	Code string
+	Name string
+}
+
+// uiLanguageOptions lists the user interface languages by their own names.
+func (h *Handlers) uiLanguageOptions() []UILanguageOption {
+	var options []UILanguageOption
+	for _, l := range h.UI.Languages() {
+		options = append(options, UILanguageOption{Code: l, Name: localeName(l)})
+	}
+	return options
+}
+
+// uiLanguage returns the user interface language for the current user: the
+// one they picked, else the site's.
+func (h *Handlers) uiLanguage(ctx context.Context) string {
+	lang, err := h.DB.GetUILanguage(ctx, userID(ctx))
+	if err != nil {
+		slog.Error("uiLanguage", "error", err)
+		return defaultUILanguage
+	}
+	if !h.UI.Has(lang) {
+		return defaultUILanguage
+	}
+	return lang
+}
+
+// ParseTemplates parses the templates in fsys once per user interface
+// language, each with the "t" function of its language, on top of FuncMap.
+func (h *Handlers) ParseTemplates(fsys fs.FS) error {
+	h.tmpls = make(map[string]*template.Template)
+	for _, lang := range h.UI.Languages() {
+		t, err := h.parseTemplates(fsys, lang)
+		if err != nil {
+			return err
+		}
+		h.tmpls[lang] = t
+	}
+	h.Tmpl = h.tmpls[defaultUILanguage]
+	return nil
+}
+
+func (h *Handlers) parseTemplates(fsys fs.FS, lang string) (*template.Template, error) {
+	funcs := maps.Clone(h.FuncMap)
+	if funcs == nil {
+		funcs = template.FuncMap{}
+	}
+	maps.Copy(funcs, h.UI.FuncMap(lang))
+	return template.New("").Funcs(funcs).ParseFS(fsys, "*.html")
+}
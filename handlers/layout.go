@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"docgen/internal/db"
+)
+
+const (
+	maxNavLinks      = 8
+	maxFooterColumns = 4
+	maxFooterLinks   = 10
+	maxLinkLabel     = 60
+	// homeBlockPages is how many pages the popular and recently updated
+	// blocks on the home page list.
+	homeBlockPages = 6
+	// popularPagesDays is how far back the popular pages block counts
+	// views.
+	popularPagesDays = 30
+)
+
+// linkLine matches a link in the header and footer link fields, written as
+// in Markdown: [Status page](https://status.example.com).
+var linkLine = regexp.MustCompile(`^\[([^\]]+)\]\(([^()\s]+)\)$`)
+
+// validLinkURL reports whether a header or footer link may point at u:
+// a path on this site, or an http, https or mailto URL.
+func validLinkURL(u string) bool {
+	if strings.HasPrefix(u, "/") {
+		return !strings.HasPrefix(u, "//")
+	}
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+	switch parsed.Scheme {
+	case "http", "https":
+		return parsed.Host != ""
+	case "mailto":
+		return parsed.Opaque != ""
+	}
+	return false
+}
+
+// parseLink parses one line of the header or footer link fields.
+func parseLink(line string) (db.NavLink, error) {
+	m := linkLine.FindStringSubmatch(line)
+	if m == nil {
+		return db.NavLink{}, fmt.Errorf("%q is not a link; write links as [Label](https://example.com)", line)
+	}
+	label := strings.TrimSpace(m[1])
+	if label == "" || utf8.RuneCountInString(label) > maxLinkLabel {
+		return db.NavLink{}, fmt.Errorf("link labels must be 1 to %d characters", maxLinkLabel)
+	}
+	if !validLinkURL(m[2]) {
+		return db.NavLink{}, fmt.Errorf("link %q must point to a path starting with / or an http, https or mailto URL", label)
+	}
+	return db.NavLink{Label: label, URL: m[2]}, nil
+}
+
+// parseNavLinks parses the header links field: one link per line, blank
+// lines ignored.
+func parseNavLinks(s string) ([]db.NavLink, error) {
+	links := []db.NavLink{}
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		link, err := parseLink(line)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+	if len(links) > maxNavLinks {
+		return nil, fmt.Errorf("at most %d header links are allowed", maxNavLinks)
+	}
+	return links, nil
+}
+
+// formatNavLinks is the inverse of parseNavLinks.
+func formatNavLinks(links []db.NavLink) string {
+	var b strings.Builder
+	for _, l := range links {
+		fmt.Fprintf(&b, "[%s](%s)\n", l.Label, l.URL)
+	}
+	return b.String()
+}
+
+// parseFooterColumns parses the footer links field: a "## Title" line
+// starts a column, and the links below it, one per line, fill it.
+func parseFooterColumns(s string) ([]db.FooterColumn, error) {
+	columns := []db.FooterColumn{}
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if title, ok := strings.CutPrefix(line, "##"); ok {
+			title = strings.TrimSpace(title)
+			if title == "" || utf8.RuneCountInString(title) > maxLinkLabel {
+				return nil, fmt.Errorf("footer column titles must be 1 to %d characters", maxLinkLabel)
+			}
+			columns = append(columns, db.FooterColumn{Title: title, Links: []db.NavLink{}})
+			continue
+		}
+		if len(columns) == 0 {
+			return nil, fmt.Errorf(`footer links must follow a "## Column title" line`)
+		}
+		link, err := parseLink(line)
+		if err != nil {
+			return nil, err
+		}
+		col := &columns[len(columns)-1]
+		col.Links = append(col.Links, link)
+		if len(col.Links) > maxFooterLinks {
+			return nil, fmt.Errorf("footer columns can have at most %d links", maxFooterLinks)
+		}
+	}
+	if len(columns) > maxFooterColumns {
+		return nil, fmt.Errorf("at most %d footer columns are allowed", maxFooterColumns)
+	}
+	return columns, nil
+}
+
+// formatFooterColumns is the inverse of parseFooterColumns.
+func formatFooterColumns(columns []db.FooterColumn) string {
+	var b strings.Builder
+	for i, c := range columns {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "## %s\n", c.Title)
+		b.WriteString(formatNavLinks(c.Links))
+	}
+	return b.String()
+}
+
+// parseHomeBlocks returns the known generated home page blocks among the
+// checked form values, in a fixed order.
+func parseHomeBlocks(values []string) []string {
+	blocks := []string{}
+	for _, b := range []string{db.HomeBlockPopular, db.HomeBlockRecent} {
+		if slices.Contains(values, b) {
+			blocks = append(blocks, b)
+		}
+	}
+	return blocks
+}
+
+// HomeBlocks is the content of the optional home page blocks.
+type HomeBlocks struct {
+	Intro        template.HTML
+	ShowPopular  bool
+	PopularPages []db.PageViewStats
+	ShowRecent   bool
+	RecentPages  []db.PageChange
+}
+
+// homeBlocks loads the home page blocks the site has enabled, listing only
+// pages in sections the current user may see.
+func (h *Handlers) homeBlocks(ctx context.Context, layout db.SiteLayout) HomeBlocks {
+	var blocks HomeBlocks
+	if strings.TrimSpace(layout.HomeIntro) != "" {
+		intro, err := h.renderMarkdown(ctx, "", layout.HomeIntro)
+		if err != nil {
+			slog.Error("homeBlocks intro", "error", err)
+		}
+		blocks.Intro = intro
+	}
+	blocks.ShowPopular = layout.HasHomeBlock(db.HomeBlockPopular)
+	blocks.ShowRecent = layout.HasHomeBlock(db.HomeBlockRecent)
+	if !blocks.ShowPopular && !blocks.ShowRecent {
+		return blocks
+	}
+
+	sections, err := h.changeSections(ctx)
+	if err != nil {
+		slog.Error("homeBlocks sections", "error", err)
+		return blocks
+	}
+	var ids []string
+	for _, s := range sections {
+		ids = append(ids, s.ID)
+	}
+	if blocks.ShowPopular {
+		since := analyticsDay(time.Now()).AddDate(0, 0, 1-popularPagesDays)
+		blocks.PopularPages, err = h.DB.ListPopularPages(ctx, ids, h.showDrafts(ctx), since, homeBlockPages)
+		if err != nil {
+			slog.Error("homeBlocks popular", "error", err)
+		}
+	}
+	if blocks.ShowRecent {
+		blocks.RecentPages, err = h.DB.ListRecentlyUpdatedPages(ctx, ids, h.showDrafts(ctx), homeBlockPages)
+		if err != nil {
+			slog.Error("homeBlocks recent", "error", err)
+		}
+	}
+	return blocks
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"docgen/internal/db"
+)
+
+const (
+	mailBatchSize   = 20
+	mailLease       = 5 * time.Minute
+	mailMaxAttempts = 8
+)
+
+// MailSender sends queued notification emails from Run, so that requests
+// never wait for the SMTP server. The queue lives in the database, so
+// emails survive restarts and several replicas can send from it at once.
+// Failed emails are retried with exponential backoff until mailMaxAttempts
+// is reached.
+type MailSender struct {
+	DB       *db.Queries
+	Interval time.Duration
+	wake     chan struct{}
+}
+
+// NewMailSender returns a sender that polls the queue every 30 seconds, or
+// sooner when notified of a new email.
+func NewMailSender(q *db.Queries) *MailSender {
+	return &MailSender{
+		DB:       q,
+		Interval: 30 * time.Second,
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Notify wakes the sender up to send newly queued emails.
+func (s *MailSender) Notify() {
+	if s == nil {
+		return
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run sends due emails until ctx is cancelled.
+func (s *MailSender) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.wake:
+		case <-ctx.Done():
+			return
+		}
+		s.sendDue(ctx)
+	}
+}
+
+func (s *MailSender) sendDue(ctx context.Context) {
+	for {
+		mails, err := s.DB.ClaimMail(ctx, mailBatchSize, mailLease)
+		if err != nil {
+			slog.Error("mail claim failed", "error", err)
+			return
+		}
+		for _, m := range mails {
+			s.send(ctx, m)
+		}
+		if len(mails) < mailBatchSize {
+			return
+		}
+	}
+}
+
+// send makes one attempt to send an email and removes it from the queue
+// unless it is to be retried.
+func (s *MailSender) send(ctx context.Context, m db.QueuedMail) {
+	err := sendEmail(m.Recipient, m.Subject, m.Body)
+	if err == nil || m.Attempts+1 >= mailMaxAttempts {
+		if err != nil {
+			slog.Warn("email failed", "to", m.Recipient, "attempts", m.Attempts+1, "error", err)
+		}
+		if err := s.DB.DeleteMail(ctx, m.ID); err != nil {
+			slog.Error("mail delete", "error", err)
+		}
+		return
+	}
+	if err := s.DB.RetryMail(ctx, m.ID, err.Error(), time.Now().Add(webhookBackoff(m.Attempts+1))); err != nil {
+		slog.Error("mail retry", "error", err)
+	}
+}
+
+// queueEmail queues an email for the MailSender. Failures are logged; the
+// change it reports has already been saved.
+func (h *Handlers) queueEmail(ctx context.Context, to, subject, body string) {
+	if err := h.DB.EnqueueMail(ctx, to, subject, body); err != nil {
+		slog.Error("queueEmail", "to", to, "error", err)
+		return
+	}
+	h.Mail.Notify()
+}
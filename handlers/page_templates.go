@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"docgen/internal/db"
+)
+
+type PageTemplatesData struct {
+	AdminData
+	Templates []db.PageTemplate
+}
+
+type PageTemplateFormData struct {
+	AdminData
+	Template  db.PageTemplate
+	SectionID string
+	Sections  []db.Section
+	IsNew     bool
+	Error     string
+}
+
+// templatePlaceholder matches {{name}} placeholders in a page template. Names
+// containing a dot are left alone so that other {{...}} syntax passes through.
+var templatePlaceholder = regexp.MustCompile(`\{\{\s*([A-Za-z][A-Za-z0-9_]*)\s*\}\}`)
+
+// builtinTemplateVars are filled in automatically when a page is created and
+// are never prompted for.
+var builtinTemplateVars = map[string]bool{
+	"title":   true,
+	"slug":    true,
+	"section": true,
+	"date":    true,
+	"author":  true,
+}
+
+// templateVariables returns the placeholders in contentMD that the editor
+// has to fill in, in order of first appearance.
+func templateVariables(contentMD string) []string {
+	var vars []string
+	seen := map[string]bool{}
+	for _, m := range templatePlaceholder.FindAllStringSubmatch(contentMD, -1) {
+		name := m[1]
+		if builtinTemplateVars[name] || seen[name] {
+			continue
+		}
+		seen[name] = true
+		vars = append(vars, name)
+	}
+	return vars
+}
+
+// fillPageTemplate replaces the placeholders that have a value. Unknown
+// placeholders are kept as written.
+func fillPageTemplate(contentMD string, values map[string]string) string {
+	return templatePlaceholder.ReplaceAllStringFunc(contentMD, func(ref string) string {
+		name := templatePlaceholder.FindStringSubmatch(ref)[1]
+		if v, ok := values[name]; ok {
+			return v
+		}
+		return ref
+	})
+}
+
+// pageTemplateValues collects the placeholder values submitted with the new
+// page form: var_<name> fields plus the built-in variables.
+func pageTemplateValues(r *http.Request, section db.Section, slug, title string) map[string]string {
+	values := map[string]string{}
+	for key := range r.PostForm {
+		if name, ok := strings.CutPrefix(key, "var_"); ok {
+			if v := strings.TrimSpace(r.PostForm.Get(key)); v != "" {
+				values[name] = v
+			}
+		}
+	}
+	values["title"] = title
+	values["slug"] = slug
+	values["section"] = section.Title
+	values["date"] = time.Now().Format("2006-01-02")
+	values["author"] = userFirstname(r.Context())
+	return values
+}
+
+// PageTemplates lists all page templates.
+func (h *Handlers) PageTemplates(w http.ResponseWriter, r *http.Request) {
+	templates, err := h.DB.ListPageTemplates(r.Context())
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("PageTemplates", "error", err)
+		return
+	}
+
+	data := PageTemplatesData{
+		AdminData: h.libraryData(r, "page-templates"),
+		Templates: templates,
+	}
+
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "page-templates.html", data); err != nil {
+		slog.Error("PageTemplates template", "error", err)
+	}
+}
+
+// NewPageTemplateForm renders the create page template form.
+func (h *Handlers) NewPageTemplateForm(w http.ResponseWriter, r *http.Request) {
+	sections, err := h.DB.ListSections(r.Context(), true)
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("NewPageTemplateForm", "error", err)
+		return
+	}
+
+	data := PageTemplateFormData{
+		AdminData: h.libraryData(r, "page-templates"),
+		Sections:  sections,
+		IsNew:     true,
+		Error:     r.URL.Query().Get("error"),
+	}
+
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "page-template-form.html", data); err != nil {
+		slog.Error("NewPageTemplateForm template", "error", err)
+	}
+}
+
+// CreatePageTemplate handles the create page template form submission.
+func (h *Handlers) CreatePageTemplate(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	description := r.FormValue("description")
+	sectionIDStr := r.FormValue("section_id")
+	contentMD := r.FormValue("content_md")
+
+	var sectionID *string
+	if sectionIDStr != "" {
+		sectionID = &sectionIDStr
+	}
+
+	if msg := h.checkPageTemplateName(r.Context(), name, ""); msg != "" {
+		http.Redirect(w, r, "/page-templates/new?error="+url.QueryEscape(msg), http.StatusSeeOther)
+		return
+	}
+
+	if err := h.DB.CreatePageTemplate(r.Context(), name, description, sectionID, contentMD, userID(r.Context())); err != nil {
+		h.serverError(w, r)
+		slog.Error("CreatePageTemplate", "error", err)
+		return
+	}
+
+	http.Redirect(w, r, "/page-templates", http.StatusSeeOther)
+}
+
+// EditPageTemplateForm renders the edit page template form.
+func (h *Handlers) EditPageTemplateForm(w http.ResponseWriter, r *http.Request) {
+	tpl, err := h.DB.GetPageTemplate(r.Context(), r.PathValue("id"))
+	if err != nil {
+		h.notFound(w, r)
+		return
+	}
+
+	sections, err := h.DB.ListSections(r.Context(), true)
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("EditPageTemplateForm", "error", err)
+		return
+	}
+
+	data := PageTemplateFormData{
+		AdminData: h.libraryData(r, "page-templates"),
+		Template:  tpl,
+		Sections:  sections,
+		Error:     r.URL.Query().Get("error"),
+	}
+	if tpl.SectionID != nil {
+		data.SectionID = *tpl.SectionID
+	}
+
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "page-template-form.html", data); err != nil {
+		slog.Error("EditPageTemplateForm template", "error", err)
+	}
+}
+
+// UpdatePageTemplate handles the edit page template form submission.
+func (h *Handlers) UpdatePageTemplate(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	description := r.FormValue("description")
+	sectionIDStr := r.FormValue("section_id")
+	contentMD := r.FormValue("content_md")
+
+	var sectionID *string
+	if sectionIDStr != "" {
+		sectionID = &sectionIDStr
+	}
+
+	if msg := h.checkPageTemplateName(r.Context(), name, id); msg != "" {
+		http.Redirect(w, r, "/page-templates/"+id+"/edit?error="+url.QueryEscape(msg), http.StatusSeeOther)
+		return
+	}
+
+	if err := h.DB.UpdatePageTemplate(r.Context(), id, name, description, sectionID, contentMD, userID(r.Context())); err != nil {
+		h.serverError(w, r)
+		slog.Error("UpdatePageTemplate", "error", err)
+		return
+	}
+
+	http.Redirect(w, r, "/page-templates", http.StatusSeeOther)
+}
+
+// DeletePageTemplate soft-deletes a page template.
+func (h *Handlers) DeletePageTemplate(w http.ResponseWriter, r *http.Request) {
+	if err := h.DB.SoftDeletePageTemplate(r.Context(), r.PathValue("id"), userID(r.Context())); err != nil {
+		h.serverError(w, r)
+		slog.Error("DeletePageTemplate", "error", err)
+		return
+	}
+
+	http.Redirect(w, r, "/page-templates", http.StatusSeeOther)
+}
+
+// checkPageTemplateName returns a user-facing error message, or "" if the
+// name is usable. selfID is the template being edited.
+func (h *Handlers) checkPageTemplateName(ctx context.Context, name, selfID string) string {
+	if name == "" {
+		return "Name is required"
+	}
+	if existing, err := h.DB.GetPageTemplateByName(ctx, name); err == nil && existing.ID != selfID {
+		return h.t(ctx, "A template named \"%s\" already exists", name)
+	}
+	return ""
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"context"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+type PreferencesData struct {
+	AdminData
+	// UILanguage is the user's interface language, empty for the site's.
+	UILanguage         string
+	SiteUILanguageName string
+	Languages          []UILanguageOption
+	// Theme and AccentColor are the user's, empty for the site's.
+	Theme       string
+	AccentColor string
+	// SiteThemeName is the label of the site's theme, or "auto".
+	SiteThemeName string
+	SiteAccent    string
+	Themes        []ThemeOption
+	Accents       []AccentOption
+	Saved         bool
+}
+
+// ThemeOption is an entry of the theme picker on the preferences page.
+type ThemeOption struct {
+	Name  string
+	Label string
+	// Auto is set for the theme that follows the device's light or dark
+	// mode; its label is translated.
+	Auto bool
+	// Swatches are the theme's sidebar, content and text colors.
+	Swatches []template.CSS
+}
+
+// AccentOption is an entry of the accent color picker on the preferences
+// page.
+type AccentOption struct {
+	Name   string
+	Swatch template.CSS
+}
+
+// themeOptions lists the built-in themes, the automatic one and the custom
+// themes of the current space.
+func (h *Handlers) themeOptions(ctx context.Context) []ThemeOption {
+	swatches := func(vars map[string]string) []template.CSS {
+		return []template.CSS{ThemeColor(vars, "bg-sidebar"), ThemeColor(vars, "bg-content"), ThemeColor(vars, "text-primary")}
+	}
+	builtin := func(name string) map[string]string {
+		base, _ := ThemeVarsFor(name, "blue")
+		vars := make(map[string]string, len(base))
+		for _, v := range base {
+			vars[v.Name] = v.Value
+		}
+		return vars
+	}
+
+	var options []ThemeOption
+	for _, name := range themeNames {
+		options = append(options, ThemeOption{Name: name, Label: themeLabel(name), Swatches: swatches(builtin(name))})
+	}
+	light, dark := builtin("daylight"), builtin("midnight")
+	options = append(options, ThemeOption{Name: AutoTheme, Auto: true, Swatches: []template.CSS{
+		ThemeColor(light, "bg-content"), ThemeColor(light, "text-primary"),
+		ThemeColor(dark, "bg-content"), ThemeColor(dark, "text-primary"),
+	}})
+
+	customThemes, err := h.DB.ListCustomThemes(ctx)
+	if err != nil {
+		slog.Error("themeOptions", "error", err)
+	}
+	for _, t := range customThemes {
+		options = append(options, ThemeOption{Name: t.Name, Label: t.Label, Swatches: swatches(t.Vars)})
+	}
+	return options
+}
+
+func accentOptions() []AccentOption {
+	var options []AccentOption
+	for _, name := range accentNames {
+		options = append(options, AccentOption{Name: name, Swatch: template.CSS(accents[name].Accent1)})
+	}
+	return options
+}
+
+// themeLabel returns the name of a built-in theme as shown to users.
+func themeLabel(name string) string {
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// Preferences shows the current user's preferences.
+func (h *Handlers) Preferences(w http.ResponseWriter, r *http.Request) {
+	lang, err := h.DB.GetUserUILanguage(r.Context(), userID(r.Context()))
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("Preferences", "error", err)
+		return
+	}
+	theme, accent, err := h.DB.GetUserTheme(r.Context(), userID(r.Context()))
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("Preferences theme", "error", err)
+		return
+	}
+	settings, _ := h.DB.GetSiteSettings(r.Context())
+
+	themeOptions := h.themeOptions(r.Context())
+	siteThemeName := settings.Theme
+	for _, o := range themeOptions {
+		if o.Name == settings.Theme && !o.Auto {
+			siteThemeName = o.Label
+		}
+	}
+
+	data := PreferencesData{
+		AdminData:          h.adminData(r, "preferences"),
+		UILanguage:         lang,
+		SiteUILanguageName: localeName(settings.UILanguage),
+		Languages:          h.uiLanguageOptions(),
+		Theme:              theme,
+		AccentColor:        accent,
+		SiteThemeName:      siteThemeName,
+		SiteAccent:         settings.AccentColor,
+		Themes:             themeOptions,
+		Accents:            accentOptions(),
+		Saved:              r.URL.Query().Get("saved") == "1",
+	}
+	data.NavItems = notificationsNav("preferences")
+	data.IsEditor = h.isEditor(r.Context())
+
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "preferences.html", data); err != nil {
+		slog.Error("Preferences template", "error", err)
+	}
+}
+
+// UpdatePreferences saves the current user's preferences.
+func (h *Handlers) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	lang := r.FormValue("ui_language")
+	if lang != "" && !h.UI.Has(lang) {
+		http.Error(w, "invalid language", http.StatusBadRequest)
+		return
+	}
+
+	theme := r.FormValue("theme")
+	if theme != "" && !ValidTheme(theme) {
+		if _, err := h.DB.GetCustomThemeByName(r.Context(), theme); err != nil {
+			http.Error(w, "invalid theme", http.StatusBadRequest)
+			return
+		}
+	}
+	accent := r.FormValue("accent_color")
+	if accent != "" && !ValidAccent(accent) {
+		http.Error(w, "invalid accent color", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.DB.SetUserUILanguage(r.Context(), userID(r.Context()), lang); err != nil {
+		h.serverError(w, r)
+		slog.Error("UpdatePreferences", "error", err)
+		return
+	}
+	if err := h.DB.SetUserTheme(r.Context(), userID(r.Context()), theme, accent); err != nil {
+		h.serverError(w, r)
+		slog.Error("UpdatePreferences theme", "error", err)
+		return
+	}
+
+	http.Redirect(w, r, "/preferences?saved=1", http.StatusSeeOther)
+}
@@ -0,0 +1,515 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"docgen/internal/db"
+	"docgen/internal/diff"
+)
+
+type ReviewsData struct {
+	AdminData
+	Reviews []db.PageReview
+}
+
+// ReviewLine is a diff line together with the inline comments on it.
+type ReviewLine struct {
+	diff.Line
+	Comments []db.ReviewComment
+}
+
+type ReviewData struct {
+	AdminData
+	Review       db.PageReview
+	TitleChanged bool
+	Lines        []ReviewLine
+	Inserted     int
+	Deleted      int
+	Votes        []db.ReviewVote
+	Comments     []db.ReviewComment
+	Required     int
+	CanVote      bool
+	CanPublish   bool
+	MyDecision   string
+	Error        string
+}
+
+func reviewNav(active string) []AdminNavItem {
+	return []AdminNavItem{
+		{Title: "Inbox", Path: "/reviews", IsActive: active == "inbox"},
+	}
+}
+
+func (h *Handlers) reviewData(r *http.Request, active string) AdminData {
+	data := h.adminData(r, active)
+	data.NavItems = reviewNav(active)
+	data.IsEditor = h.isEditor(r.Context())
+	return data
+}
+
+// isReviewer reports whether the current user may approve page changes.
+func (h *Handlers) isReviewer(ctx context.Context) bool {
+	if inPreviewMode(ctx) {
+		return false
+	}
+	u := UserFromContext(ctx)
+	if u == nil {
+		return false
+	}
+	isAdmin, _ := h.DB.HasRole(ctx, u.ID, "admin")
+	if isAdmin {
+		return true
+	}
+	isRev, _ := h.DB.HasRole(ctx, u.ID, "reviewer")
+	return isRev
+}
+
+// RequireReviewer wraps an http.HandlerFunc and returns 403 unless the user
+// has the "reviewer", "editor" or "admin" role. Editors can follow and
+// discuss their submissions; only reviewers can vote.
+func (h *Handlers) RequireReviewer(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.isReviewer(r.Context()) || h.isEditor(r.Context()) {
+			next(w, r)
+			return
+		}
+		if inPreviewMode(r.Context()) {
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+			return
+		}
+		h.forbidden(w, r)
+	}
+}
+
+// reviewStatus derives the status of an active review from its votes. Any
+// outstanding request for changes blocks approval.
+func reviewStatus(votes []db.ReviewVote, required int) string {
+	approvals := 0
+	for _, v := range votes {
+		if v.Decision == "request_changes" {
+			return db.ReviewChangesRequested
+		}
+		approvals++
+	}
+	if approvals >= max(required, 1) {
+		return db.ReviewApproved
+	}
+	return db.ReviewOpen
+}
+
+// userCanAccessSection is canAccessSection for a user other than the
+// current one; it is used to decide who may be notified about a section.
+func (h *Handlers) userCanAccessSection(ctx context.Context, userID, requiredRole string) bool {
+	if requiredRole == "" {
+		return true
+	}
+	if isAdmin, _ := h.DB.HasRole(ctx, userID, "admin"); isAdmin {
+		return true
+	}
+	has, _ := h.DB.HasRole(ctx, userID, requiredRole)
+	return has
+}
+
+// notifyUsers queues a notification email to each user; the MailSender
+// sends them in the background.
+func (h *Handlers) notifyUsers(ctx context.Context, to []db.User, subject, body string) {
+	subject = h.notificationSubject(ctx, subject)
+	for _, u := range to {
+		h.queueEmail(ctx, u.Email, subject, notificationBody(u, body))
+	}
+}
+
+// notificationSubject prefixes the subject of a notification email with the
+// title of the current space.
+func (h *Handlers) notificationSubject(ctx context.Context, subject string) string {
+	settings, _ := h.DB.GetSiteSettings(ctx)
+	return fmt.Sprintf("[%s] %s", settings.SiteTitle, subject)
+}
+
+func notificationBody(u db.User, body string) string {
+	return fmt.Sprintf("Hello %s,\r\n\r\n%s", u.Firstname, body)
+}
+
+// notifySubmitter emails the author of a review unless they caused the update.
+func (h *Handlers) notifySubmitter(ctx context.Context, review db.PageReview, subject, body string) {
+	if review.SubmittedBy == "" || review.SubmittedBy == userID(ctx) {
+		return
+	}
+	u, err := h.DB.GetUserByID(ctx, review.SubmittedBy)
+	if err != nil {
+		slog.Error("notifySubmitter", "error", err)
+		return
+	}
+	h.notifyUsers(ctx, []db.User{u}, subject, body)
+}
+
+// submitReview stages the change like a draft and opens a review for it.
+// The page's schedule is stored once the review has been opened.
+func (h *Handlers) submitReview(w http.ResponseWriter, r *http.Request, section db.Section, page db.Page, title, contentMD, summary string, publishAt, unpublishAt *time.Time) {
+	changedBy := userID(r.Context())
+
+	// The diff is taken against the published revision; an unpublished page
+	// has none, so the whole content is proposed.
+	var baseTitle, baseContentMD string
+	if page.Published {
+		baseTitle, baseContentMD = page.Title, page.ContentMD
+		if err := h.DB.SavePageDraft(r.Context(), section.ID, page.Slug, title, contentMD); err != nil {
+			h.serverError(w, r)
+			slog.Error("submitReview draft", "error", err)
+			return
+		}
+	} else {
+		updated, err := h.DB.UpdatePage(r.Context(), section.ID, page.Slug, title, contentMD, changedBy)
+		if err != nil {
+			h.serverError(w, r)
+			slog.Error("submitReview", "error", err)
+			return
+		}
+		if err := h.DB.SavePageHistory(r.Context(), updated, changedBy, summary); err != nil {
+			slog.Error("submitReview history", "error", err)
+		}
+	}
+
+	id, err := h.DB.CreatePageReview(r.Context(), page.ID, title, contentMD, baseTitle, baseContentMD, summary, changedBy)
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("submitReview create", "error", err)
+		return
+	}
+	if err := h.DB.SetPageSchedule(r.Context(), section.ID, page.Slug, publishAt, unpublishAt); err != nil {
+		h.serverError(w, r)
+		slog.Error("submitReview schedule", "error", err)
+		return
+	}
+
+	reviewers, err := h.DB.ListUsersWithRole(r.Context(), "reviewer")
+	if err != nil {
+		slog.Error("submitReview reviewers", "error", err)
+	}
+	var to []db.User
+	for _, u := range reviewers {
+		if u.ID != changedBy && h.userCanAccessSection(r.Context(), u.ID, section.RequiredRole) {
+			to = append(to, u)
+		}
+	}
+	h.notifyUsers(r.Context(), to, "Review requested: "+title,
+		fmt.Sprintf("%s submitted a change to \"%s\" in %s for review.\r\n\r\n"+
+			"Review it here:\r\n%s\r\n",
+			userFirstname(r.Context()), title, section.Title, siteURL(r.Context())+"/reviews/"+id))
+
+	http.Redirect(w, r, "/reviews/"+id, http.StatusSeeOther)
+}
+
+// Reviews lists the active reviews the user can see.
+func (h *Handlers) Reviews(w http.ResponseWriter, r *http.Request) {
+	all, err := h.DB.ListActivePageReviews(r.Context())
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("Reviews", "error", err)
+		return
+	}
+
+	var reviews []db.PageReview
+	for _, rv := range all {
+		if h.canAccessSection(r.Context(), rv.RequiredRole) {
+			reviews = append(reviews, rv)
+		}
+	}
+
+	data := ReviewsData{
+		AdminData: h.reviewData(r, "inbox"),
+		Reviews:   reviews,
+	}
+
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "reviews.html", data); err != nil {
+		slog.Error("Reviews template", "error", err)
+	}
+}
+
+// loadReview fetches the review in the path and checks section access.
+func (h *Handlers) loadReview(w http.ResponseWriter, r *http.Request) (db.PageReview, bool) {
+	review, err := h.DB.GetPageReview(r.Context(), r.PathValue("id"))
+	if err != nil {
+		h.notFound(w, r)
+		return review, false
+	}
+	if !h.canAccessSection(r.Context(), review.RequiredRole) {
+		h.forbidden(w, r)
+		return review, false
+	}
+	return review, true
+}
+
+// Review shows the diff of a review with its votes and comments.
+func (h *Handlers) Review(w http.ResponseWriter, r *http.Request) {
+	review, ok := h.loadReview(w, r)
+	if !ok {
+		return
+	}
+
+	votes, err := h.DB.ListReviewVotes(r.Context(), review.ID)
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("Review votes", "error", err)
+		return
+	}
+	comments, err := h.DB.ListReviewComments(r.Context(), review.ID)
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("Review comments", "error", err)
+		return
+	}
+
+	// Attach inline comments to their diff line; the rest are general.
+	inline := map[[2]int][]db.ReviewComment{}
+	var general []db.ReviewComment
+	for _, c := range comments {
+		if c.OldLine == nil && c.NewLine == nil {
+			general = append(general, c)
+			continue
+		}
+		var key [2]int
+		if c.OldLine != nil {
+			key[0] = *c.OldLine
+		}
+		if c.NewLine != nil {
+			key[1] = *c.NewLine
+		}
+		inline[key] = append(inline[key], c)
+	}
+
+	diffLines := diff.Lines(review.BaseContentMD, review.ContentMD)
+	lines := make([]ReviewLine, len(diffLines))
+	for i, l := range diffLines {
+		lines[i] = ReviewLine{Line: l, Comments: inline[[2]int{l.OldNum, l.NewNum}]}
+	}
+	inserted, deleted := diff.Stats(diffLines)
+
+	me := userID(r.Context())
+	var myDecision string
+	for _, v := range votes {
+		if v.ReviewerID == me {
+			myDecision = v.Decision
+		}
+	}
+
+	data := ReviewData{
+		AdminData:    h.reviewData(r, "inbox"),
+		Review:       review,
+		TitleChanged: review.BaseTitle != review.Title,
+		Lines:        lines,
+		Inserted:     inserted,
+		Deleted:      deleted,
+		Votes:        votes,
+		Comments:     general,
+		Required:     max(review.RequiredApprovals, 1),
+		CanVote:      review.IsActive() && review.SubmittedBy != me && h.isReviewer(r.Context()),
+		CanPublish:   review.Status == db.ReviewApproved && h.isEditor(r.Context()),
+		MyDecision:   myDecision,
+		Error:        r.URL.Query().Get("error"),
+	}
+
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "review.html", data); err != nil {
+		slog.Error("Review template", "error", err)
+	}
+}
+
+// ApproveReview records an approval.
+func (h *Handlers) ApproveReview(w http.ResponseWriter, r *http.Request) {
+	h.voteReview(w, r, "approve")
+}
+
+// RequestReviewChanges records a request for changes.
+func (h *Handlers) RequestReviewChanges(w http.ResponseWriter, r *http.Request) {
+	h.voteReview(w, r, "request_changes")
+}
+
+func (h *Handlers) voteReview(w http.ResponseWriter, r *http.Request, decision string) {
+	review, ok := h.loadReview(w, r)
+	if !ok {
+		return
+	}
+	if !h.isReviewer(r.Context()) {
+		h.forbidden(w, r)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	me := userID(r.Context())
+	if !review.IsActive() {
+		http.Redirect(w, r, "/reviews/"+review.ID+"?error="+url.QueryEscape("This review is closed"), http.StatusSeeOther)
+		return
+	}
+	if review.SubmittedBy == me {
+		http.Redirect(w, r, "/reviews/"+review.ID+"?error="+url.QueryEscape("You cannot review your own change"), http.StatusSeeOther)
+		return
+	}
+
+	if err := h.DB.SetReviewVote(r.Context(), review.ID, me, decision); err != nil {
+		h.serverError(w, r)
+		slog.Error("voteReview", "error", err)
+		return
+	}
+
+	// A vote may carry a comment explaining it.
+	if body := strings.TrimSpace(r.FormValue("body")); body != "" {
+		if err := h.DB.CreateReviewComment(r.Context(), review.ID, me, nil, nil, body); err != nil {
+			slog.Error("voteReview comment", "error", err)
+		}
+	}
+
+	votes, err := h.DB.ListReviewVotes(r.Context(), review.ID)
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("voteReview votes", "error", err)
+		return
+	}
+	status := reviewStatus(votes, review.RequiredApprovals)
+	if err := h.DB.SetPageReviewStatus(r.Context(), review.ID, status); err != nil {
+		h.serverError(w, r)
+		slog.Error("voteReview status", "error", err)
+		return
+	}
+
+	verb := "approved"
+	if decision == "request_changes" {
+		verb = "requested changes to"
+	}
+	subject := "Review update: " + review.Title
+	if status == db.ReviewApproved {
+		subject = "Approved: " + review.Title
+	}
+	h.notifySubmitter(r.Context(), review, subject,
+		fmt.Sprintf("%s %s your change to \"%s\".\r\n\r\n%s\r\n",
+			userFirstname(r.Context()), verb, review.Title, siteURL(r.Context())+"/reviews/"+review.ID))
+
+	http.Redirect(w, r, "/reviews/"+review.ID, http.StatusSeeOther)
+}
+
+// CommentReview adds a comment, optionally attached to a line of the diff.
+func (h *Handlers) CommentReview(w http.ResponseWriter, r *http.Request) {
+	review, ok := h.loadReview(w, r)
+	if !ok {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	body := strings.TrimSpace(r.FormValue("body"))
+	if body == "" {
+		http.Redirect(w, r, "/reviews/"+review.ID+"?error="+url.QueryEscape("Comment is empty"), http.StatusSeeOther)
+		return
+	}
+
+	var oldLine, newLine *int
+	if n, err := strconv.Atoi(r.FormValue("old_line")); err == nil && n > 0 {
+		oldLine = &n
+	}
+	if n, err := strconv.Atoi(r.FormValue("new_line")); err == nil && n > 0 {
+		newLine = &n
+	}
+
+	if err := h.DB.CreateReviewComment(r.Context(), review.ID, userID(r.Context()), oldLine, newLine, body); err != nil {
+		h.serverError(w, r)
+		slog.Error("CommentReview", "error", err)
+		return
+	}
+
+	h.notifySubmitter(r.Context(), review, "New comment: "+review.Title,
+		fmt.Sprintf("%s commented on your change to \"%s\":\r\n\r\n%s\r\n\r\n%s\r\n",
+			userFirstname(r.Context()), review.Title, body, siteURL(r.Context())+"/reviews/"+review.ID))
+
+	http.Redirect(w, r, "/reviews/"+review.ID, http.StatusSeeOther)
+}
+
+// PublishReview publishes an approved change.
+func (h *Handlers) PublishReview(w http.ResponseWriter, r *http.Request) {
+	review, ok := h.loadReview(w, r)
+	if !ok {
+		return
+	}
+
+	fail := func(msg string) {
+		http.Redirect(w, r, "/reviews/"+review.ID+"?error="+url.QueryEscape(msg), http.StatusSeeOther)
+	}
+
+	// Re-derive the status: the section's requirement may have changed
+	// since the last vote.
+	votes, err := h.DB.ListReviewVotes(r.Context(), review.ID)
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("PublishReview votes", "error", err)
+		return
+	}
+	if !review.IsActive() || reviewStatus(votes, review.RequiredApprovals) != db.ReviewApproved {
+		fail("This change has not been approved")
+		return
+	}
+
+	page, err := h.DB.GetPage(r.Context(), review.SectionID, review.Slug, true)
+	if err != nil {
+		h.notFound(w, r)
+		return
+	}
+
+	// Only publish what was reviewed: edits made after submission need a
+	// new review.
+	pendingTitle, pendingContentMD := page.Title, page.ContentMD
+	if page.HasDraft() {
+		pendingContentMD = *page.DraftContentMD
+		if page.DraftTitle != nil {
+			pendingTitle = *page.DraftTitle
+		}
+	} else if page.Published {
+		fail("The draft of this page was discarded")
+		return
+	}
+	if pendingTitle != review.Title || pendingContentMD != review.ContentMD {
+		fail("The page has changed since it was submitted; submit it for review again")
+		return
+	}
+
+	changedBy := userID(r.Context())
+	updated, err := h.DB.PublishPage(r.Context(), review.SectionID, review.Slug, review.Title, review.ContentMD, changedBy)
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("PublishReview", "error", err)
+		return
+	}
+
+	if err := h.DB.SavePageHistory(r.Context(), updated, changedBy, review.Summary); err != nil {
+		slog.Error("PublishReview history", "error", err)
+	}
+
+	if err := h.DB.SetPageReviewStatus(r.Context(), review.ID, db.ReviewPublished); err != nil {
+		slog.Error("PublishReview status", "error", err)
+	}
+
+	if section, err := h.DB.GetSectionByName(r.Context(), review.SectionName); err == nil {
+		fromVersion := 0
+		if page.Published {
+			fromVersion = page.Version
+		}
+		h.notifySubscribers(r.Context(), section, updated, fromVersion)
+		if updated.Live(time.Now()) {
+			h.emitEvent(r.Context(), "page.updated", pageEvent(r.Context(), section, updated))
+		}
+		h.emitPageVisibility(r.Context(), section, page, updated)
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/%s/%s", review.SectionName, review.Slug), http.StatusSeeOther)
+}
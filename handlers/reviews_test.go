@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"testing"
+
+	"docgen/internal/db"
+)
+
+func TestReviewStatus(t *testing.T) {
+	approve := db.ReviewVote{ReviewerID: "a", Decision: "approve"}
+	approve2 := db.ReviewVote{ReviewerID: "b", Decision: "approve"}
+	changes := db.ReviewVote{ReviewerID: "c", Decision: "request_changes"}
+
+	tests := []struct {
+		name     string
+		votes    []db.ReviewVote
+		required int
+		want     string
+	}{
+		{"no votes", nil, 1, db.ReviewOpen},
+		{"one approval of one", []db.ReviewVote{approve}, 1, db.ReviewApproved},
+		{"one approval of two", []db.ReviewVote{approve}, 2, db.ReviewOpen},
+		{"two approvals of two", []db.ReviewVote{approve, approve2}, 2, db.ReviewApproved},
+		{"more approvals than required", []db.ReviewVote{approve, approve2}, 1, db.ReviewApproved},
+		{"requirement dropped to zero still needs an approval", nil, 0, db.ReviewOpen},
+		{"requirement dropped to zero", []db.ReviewVote{approve}, 0, db.ReviewApproved},
+		{"changes requested", []db.ReviewVote{changes}, 1, db.ReviewChangesRequested},
+		{"changes requested block approvals", []db.ReviewVote{approve, approve2, changes}, 2, db.ReviewChangesRequested},
+		{"changes requested before approvals", []db.ReviewVote{changes, approve, approve2}, 1, db.ReviewChangesRequested},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := reviewStatus(tt.votes, tt.required); got != tt.want {
+				t.Errorf("reviewStatus(%d votes, %d) = %q, want %q", len(tt.votes), tt.required, got, tt.want)
+			}
+		})
+	}
+}
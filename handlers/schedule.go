@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"docgen/internal/db"
+)
+
+// scheduleLayout is the format of <input type="datetime-local"> values.
+// Schedule times are entered and shown in the server's local time zone.
+const scheduleLayout = "2006-01-02T15:04"
+
+// parseSchedule reads the publish_at and unpublish_at form fields. Empty
+// fields clear the corresponding time.
+func parseSchedule(r *http.Request) (publishAt, unpublishAt *time.Time, err error) {
+	if publishAt, err = parseScheduleTime(r.FormValue("publish_at")); err != nil {
+		return nil, nil, err
+	}
+	if unpublishAt, err = parseScheduleTime(r.FormValue("unpublish_at")); err != nil {
+		return nil, nil, err
+	}
+	if publishAt != nil && unpublishAt != nil && !unpublishAt.After(*publishAt) {
+		return nil, nil, errors.New("unpublish time must be after publish time")
+	}
+	return publishAt, unpublishAt, nil
+}
+
+func parseScheduleTime(v string) (*time.Time, error) {
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.ParseInLocation(scheduleLayout, v, time.Local)
+	if err != nil {
+		return nil, errors.New("invalid schedule time")
+	}
+	return &t, nil
+}
+
+func formatScheduleTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.In(time.Local).Format(scheduleLayout)
+}
+
+// sectionVisible reports whether a section can be shown: it is within its
+// schedule, or the viewer sees unpublished content.
+func (h *Handlers) sectionVisible(ctx context.Context, section db.Section) bool {
+	return section.Live(time.Now()) || h.showDrafts(ctx)
+}
+
+// ApplySchedules makes passed page schedules permanent and reports the
+// changes. A page that went live is reported like a manual publish, with a
+// page.updated event and a notification to its subscribers, plus a
+// page.published event; a page that was taken down gets page.unpublished.
+func (h *Handlers) ApplySchedules(ctx context.Context) (db.ScheduleResult, error) {
+	res, err := h.DB.ApplySchedules(ctx)
+	if err != nil {
+		return res, err
+	}
+	for _, p := range res.PagesPublished {
+		if pctx, section, ok := h.scheduledPageContext(ctx, p); ok {
+			h.emitEvent(pctx, "page.updated", pageEvent(pctx, section, p.Page))
+			h.emitEvent(pctx, "page.published", pageEvent(pctx, section, p.Page))
+			// The page was hidden until now, so readers are shown all of it.
+			h.notifySubscribers(pctx, section, p.Page, 0)
+		}
+	}
+	for _, p := range res.PagesUnpublished {
+		if pctx, section, ok := h.scheduledPageContext(ctx, p); ok {
+			h.emitEvent(pctx, "page.unpublished", pageEvent(pctx, section, p.Page))
+		}
+	}
+	return res, nil
+}
+
+// scheduledPageContext returns a context for reporting a scheduled change:
+// scoped to the page's space and acting as its last editor, who set the
+// schedule.
+func (h *Handlers) scheduledPageContext(ctx context.Context, p db.ScheduledPage) (context.Context, db.Section, bool) {
+	space, err := h.DB.GetSpace(ctx, p.SpaceID)
+	if err != nil {
+		slog.Error("ApplySchedules space", "page", p.ID, "error", err)
+		return nil, db.Section{}, false
+	}
+	ctx = db.WithSpace(ctx, space)
+	if p.ChangedBy != nil {
+		if u, err := h.DB.GetUserByID(ctx, *p.ChangedBy); err == nil {
+			ctx = context.WithValue(ctx, userContextKey, &u)
+		}
+	}
+	section, err := h.DB.GetSection(ctx, p.SectionID)
+	if err != nil {
+		slog.Error("ApplySchedules section", "page", p.ID, "error", err)
+		return nil, db.Section{}, false
+	}
+	return ctx, section, true
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+
+	"docgen/internal/db"
+	"docgen/internal/markdown"
+)
+
+type SnippetsData struct {
+	AdminData
+	Snippets []db.Snippet
+}
+
+type SnippetFormData struct {
+	AdminData
+	Snippet        db.Snippet
+	IsNew          bool
+	Error          string
+	UsedByPages    []db.SnippetUsage
+	UsedBySnippets []db.Snippet
+}
+
+var validSnippetName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// libraryNav lists the editor-managed content library pages.
+func libraryNav(active string) []AdminNavItem {
+	return []AdminNavItem{
+		{Title: "Snippets", Path: "/snippets", IsActive: active == "snippets"},
+		{Title: "Page Templates", Path: "/page-templates", IsActive: active == "page-templates"},
+	}
+}
+
+func (h *Handlers) libraryData(r *http.Request, active string) AdminData {
+	data := h.adminData(r, active)
+	data.NavItems = libraryNav(active)
+	return data
+}
+
+// snippetLookup resolves snippet includes against the database.
+func (h *Handlers) snippetLookup(ctx context.Context) markdown.SnippetLookup {
+	return func(name string) (string, bool) {
+		s, err := h.DB.GetSnippetByName(ctx, name)
+		if err != nil {
+			return "", false
+		}
+		return s.ContentMD, true
+	}
+}
+
+// Snippets lists all snippets.
+func (h *Handlers) Snippets(w http.ResponseWriter, r *http.Request) {
+	snippets, err := h.DB.ListSnippets(r.Context())
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("Snippets", "error", err)
+		return
+	}
+
+	data := SnippetsData{
+		AdminData: h.libraryData(r, "snippets"),
+		Snippets:  snippets,
+	}
+
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "snippets.html", data); err != nil {
+		slog.Error("Snippets template", "error", err)
+	}
+}
+
+// NewSnippetForm renders the create snippet form.
+func (h *Handlers) NewSnippetForm(w http.ResponseWriter, r *http.Request) {
+	data := SnippetFormData{
+		AdminData: h.libraryData(r, "snippets"),
+		IsNew:     true,
+		Error:     r.URL.Query().Get("error"),
+	}
+
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "snippet-form.html", data); err != nil {
+		slog.Error("NewSnippetForm template", "error", err)
+	}
+}
+
+// CreateSnippet handles the create snippet form submission.
+func (h *Handlers) CreateSnippet(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	name := r.FormValue("name")
+	description := r.FormValue("description")
+	contentMD := r.FormValue("content_md")
+
+	if msg := h.checkSnippetName(r.Context(), name, ""); msg != "" {
+		http.Redirect(w, r, "/snippets/new?error="+url.QueryEscape(msg), http.StatusSeeOther)
+		return
+	}
+
+	changedBy := userID(r.Context())
+	snippet, err := h.DB.CreateSnippet(r.Context(), name, description, contentMD, changedBy)
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("CreateSnippet", "error", err)
+		return
+	}
+
+	if err := h.DB.SaveSnippetHistory(r.Context(), snippet, changedBy); err != nil {
+		slog.Error("CreateSnippet history", "error", err)
+	}
+
+	http.Redirect(w, r, "/snippets", http.StatusSeeOther)
+}
+
+// EditSnippetForm renders the edit snippet form together with the pages and
+// snippets that include it.
+func (h *Handlers) EditSnippetForm(w http.ResponseWriter, r *http.Request) {
+	snippet, err := h.DB.GetSnippet(r.Context(), r.PathValue("id"))
+	if err != nil {
+		h.notFound(w, r)
+		return
+	}
+
+	usedByPages, err := h.DB.ListPagesUsingSnippet(r.Context(), snippet.Name)
+	if err != nil {
+		slog.Error("EditSnippetForm pages", "error", err)
+	}
+	usedBySnippets, err := h.DB.ListSnippetsUsingSnippet(r.Context(), snippet.Name)
+	if err != nil {
+		slog.Error("EditSnippetForm snippets", "error", err)
+	}
+
+	data := SnippetFormData{
+		AdminData:      h.libraryData(r, "snippets"),
+		Snippet:        snippet,
+		Error:          r.URL.Query().Get("error"),
+		UsedByPages:    usedByPages,
+		UsedBySnippets: usedBySnippets,
+	}
+
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "snippet-form.html", data); err != nil {
+		slog.Error("EditSnippetForm template", "error", err)
+	}
+}
+
+// UpdateSnippet handles the edit snippet form submission.
+func (h *Handlers) UpdateSnippet(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	name := r.FormValue("name")
+	description := r.FormValue("description")
+	contentMD := r.FormValue("content_md")
+
+	if msg := h.checkSnippetName(r.Context(), name, id); msg != "" {
+		http.Redirect(w, r, "/snippets/"+id+"/edit?error="+url.QueryEscape(msg), http.StatusSeeOther)
+		return
+	}
+
+	changedBy := userID(r.Context())
+	snippet, err := h.DB.UpdateSnippet(r.Context(), id, name, description, contentMD, changedBy)
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("UpdateSnippet", "error", err)
+		return
+	}
+
+	if err := h.DB.SaveSnippetHistory(r.Context(), snippet, changedBy); err != nil {
+		slog.Error("UpdateSnippet history", "error", err)
+	}
+
+	http.Redirect(w, r, "/snippets/"+id+"/edit", http.StatusSeeOther)
+}
+
+// DeleteSnippet soft-deletes a snippet.
+func (h *Handlers) DeleteSnippet(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	changedBy := userID(r.Context())
+
+	if err := h.DB.SoftDeleteSnippet(r.Context(), id, changedBy); err != nil {
+		h.serverError(w, r)
+		slog.Error("DeleteSnippet", "error", err)
+		return
+	}
+
+	http.Redirect(w, r, "/snippets", http.StatusSeeOther)
+}
+
+// checkSnippetName validates a snippet name and returns a user-facing error
+// message, or "" if the name is usable. selfID is the snippet being edited.
+func (h *Handlers) checkSnippetName(ctx context.Context, name, selfID string) string {
+	if !validSnippetName.MatchString(name) {
+		return "Name must use lowercase letters, digits, hyphens and underscores"
+	}
+	if existing, err := h.DB.GetSnippetByName(ctx, name); err == nil && existing.ID != selfID {
+		return h.t(ctx, "A snippet named \"%s\" already exists", name)
+	}
+	return ""
+}
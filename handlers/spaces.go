@@ -0,0 +1,524 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"docgen/config"
+	"docgen/internal/db"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// spacePrefix is the path prefix under which every space other than the
+// default one is reachable, e.g. /s/handbook/.
+const spacePrefix = "/s/"
+
+var spaceNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// spaceURL returns the absolute URL of a space's home page, without a
+// trailing slash. Spaces with a host are served at its root, using the
+// scheme of BASE_URL.
+func spaceURL(s db.Space) string {
+	base := strings.TrimRight(config.BaseURL(), "/")
+	if s.Host != "" {
+		scheme := "http"
+		if u, err := url.Parse(base); err == nil && u.Scheme != "" {
+			scheme = u.Scheme
+		}
+		return scheme + "://" + s.Host
+	}
+	if s.IsDefault() {
+		return base
+	}
+	return base + spacePrefix + s.Name
+}
+
+// siteURL returns the absolute URL of the space the request is for. Links in
+// emails, feeds and webhook payloads are built from it.
+func siteURL(ctx context.Context) string {
+	return spaceURL(db.SpaceFrom(ctx))
+}
+
+// baseHost returns the host name of BASE_URL, which always serves the
+// default space.
+func baseHost() string {
+	u, err := url.Parse(config.BaseURL())
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// ResolveSpace wraps an http.Handler and determines the space a request is
+// for. A space whose host matches the Host header is served at the root;
+// otherwise /s/<name>/ selects the space and the prefix is stripped, with
+// root-relative links in redirects and HTML responses rewritten to keep it.
+// Everything else belongs to the default space.
+func (h *Handlers) ResolveSpace(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if hn, _, err := net.SplitHostPort(host); err == nil {
+			host = hn
+		}
+		host = strings.ToLower(host)
+		if host != "" && host != baseHost() {
+			space, err := h.DB.GetSpaceByHost(r.Context(), host)
+			if err == nil {
+				next.ServeHTTP(w, r.WithContext(db.WithSpace(r.Context(), space)))
+				return
+			}
+			if !errors.Is(err, pgx.ErrNoRows) {
+				slog.Error("ResolveSpace host", "error", err)
+				h.serverError(w, r)
+				return
+			}
+		}
+
+		if !strings.HasPrefix(r.URL.Path, spacePrefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		name, rest, hasSlash := strings.Cut(strings.TrimPrefix(r.URL.Path, spacePrefix), "/")
+		space, err := h.DB.GetSpaceByName(r.Context(), name)
+		if err != nil || space.IsDefault() {
+			if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+				slog.Error("ResolveSpace prefix", "error", err)
+				h.serverError(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		prefix := spacePrefix + space.Name
+		if !hasSlash {
+			target := prefix + "/"
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+			return
+		}
+
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = "/" + rest
+		r2.URL.RawPath = ""
+		r2 = r2.WithContext(db.WithSpace(r.Context(), space))
+
+		pw := &prefixWriter{ResponseWriter: w, prefix: prefix}
+		next.ServeHTTP(pw, r2)
+		pw.finish()
+	})
+}
+
+// prefixWriter adds a space's path prefix to the root-relative URLs in the
+// Location header and in HTML bodies, so that templates and handlers can
+// keep linking to "/…" whichever space they serve.
+type prefixWriter struct {
+	http.ResponseWriter
+	prefix  string
+	status  int
+	started bool
+	html    bool
+	buf     bytes.Buffer
+}
+
+func (pw *prefixWriter) WriteHeader(code int) {
+	if pw.status != 0 {
+		return
+	}
+	pw.status = code
+	if loc := pw.Header().Get("Location"); strings.HasPrefix(loc, "/") && !strings.HasPrefix(loc, "//") {
+		pw.Header().Set("Location", pw.prefix+loc)
+	}
+}
+
+func (pw *prefixWriter) Write(p []byte) (int, error) {
+	if pw.status == 0 {
+		pw.WriteHeader(http.StatusOK)
+	}
+	if !pw.started {
+		pw.start(p)
+	}
+	if pw.html {
+		return pw.buf.Write(p)
+	}
+	return pw.ResponseWriter.Write(p)
+}
+
+// start decides on the first write whether the body is buffered for
+// rewriting, and otherwise sends the header.
+func (pw *prefixWriter) start(p []byte) {
+	pw.started = true
+	ct := pw.Header().Get("Content-Type")
+	if ct == "" {
+		ct = http.DetectContentType(p)
+		pw.Header().Set("Content-Type", ct)
+	}
+	if strings.HasPrefix(ct, "text/html") {
+		pw.html = true
+		pw.Header().Del("Content-Length")
+		return
+	}
+	pw.ResponseWriter.WriteHeader(pw.status)
+}
+
+// finish sends the response if nothing has been written to the client yet.
+func (pw *prefixWriter) finish() {
+	switch {
+	case pw.html:
+		body := rewriteLinks(pw.buf.Bytes(), pw.prefix)
+		pw.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		pw.ResponseWriter.WriteHeader(pw.status)
+		pw.ResponseWriter.Write(body)
+	case !pw.started && pw.status != 0:
+		pw.ResponseWriter.WriteHeader(pw.status)
+	}
+}
+
+// linkAttr matches the start of a root-relative URL in the attributes and
+// CSS that templates use for links, up to and excluding the leading slash.
+var linkAttr = regexp.MustCompile(`(?i)(?:\s(?:href|src|action|formaction|data-url)=["']?|url\(["']?)/`)
+
+// rewriteLinks inserts prefix before the root-relative URLs in an HTML body.
+// Protocol-relative URLs and static assets, which are shared by all spaces,
+// are left alone.
+func rewriteLinks(body []byte, prefix string) []byte {
+	var out bytes.Buffer
+	out.Grow(len(body) + len(body)/20)
+	last := 0
+	for _, m := range linkAttr.FindAllIndex(body, -1) {
+		slash := m[1] - 1
+		rest := body[slash:]
+		if bytes.HasPrefix(rest, []byte("//")) || bytes.HasPrefix(rest, []byte("/static/")) {
+			continue
+		}
+		out.Write(body[last:slash])
+		out.WriteString(prefix)
+		last = slash
+	}
+	out.Write(body[last:])
+	return out.Bytes()
+}
+
+// spaceMember returns a member of the current space. A user's profile is
+// shared by all their spaces, so outside the default space it is locked when
+// the user also belongs to other spaces.
+func (h *Handlers) spaceMember(ctx context.Context, id string) (db.User, bool, error) {
+	member, err := h.DB.IsSpaceMember(ctx, db.SpaceFrom(ctx).ID, id)
+	if err != nil {
+		return db.User{}, false, err
+	}
+	if !member {
+		return db.User{}, false, pgx.ErrNoRows
+	}
+	user, err := h.DB.GetUserByID(ctx, id)
+	if err != nil {
+		return db.User{}, false, err
+	}
+	if db.SpaceFrom(ctx).IsDefault() {
+		return user, false, nil
+	}
+	n, err := h.DB.CountUserSpaces(ctx, id)
+	if err != nil {
+		return db.User{}, false, err
+	}
+	return user, n > 1, nil
+}
+
+// addSpaceMember adds an existing user to the current space with the given
+// roles, as the result of the create user form.
+func (h *Handlers) addSpaceMember(w http.ResponseWriter, r *http.Request, user db.User, roleNames []string) {
+	if err := h.DB.AddSpaceMember(r.Context(), user.ID); err != nil {
+		h.serverError(w, r)
+		slog.Error("AdminCreateUser member", "error", err)
+		return
+	}
+	if err := h.DB.SetUserRoles(r.Context(), user.ID, roleNames); err != nil {
+		h.serverError(w, r)
+		slog.Error("AdminCreateUser roles", "error", err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+// AdminRemoveSpaceMember removes a user and their roles from the current
+// space. The account itself and the user's other spaces are kept.
+func (h *Handlers) AdminRemoveSpaceMember(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == userID(r.Context()) {
+		http.Error(w, "you cannot remove yourself", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.DB.RemoveSpaceMember(r.Context(), id); err != nil {
+		h.serverError(w, r)
+		slog.Error("AdminRemoveSpaceMember", "error", err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+// SpaceLink is an entry of the space switcher.
+type SpaceLink struct {
+	Title   string
+	URL     string
+	Current bool
+}
+
+// spaceLinks returns the space switcher entries for the current user, or nil
+// if they belong to a single space.
+func (h *Handlers) spaceLinks(ctx context.Context) []SpaceLink {
+	spaces, err := h.DB.ListUserSpaces(ctx, userID(ctx))
+	if err != nil {
+		slog.Error("spaceLinks", "error", err)
+		return nil
+	}
+	if len(spaces) < 2 {
+		return nil
+	}
+	current := db.SpaceFrom(ctx).ID
+	links := make([]SpaceLink, len(spaces))
+	for i, s := range spaces {
+		links[i] = SpaceLink{Title: s.Title, URL: spaceURL(s) + "/", Current: s.ID == current}
+	}
+	return links
+}
+
+// --- Admin: spaces ---
+
+type AdminSpacesData struct {
+	AdminData
+	Spaces []AdminSpace
+}
+
+// AdminSpace is a space with the URL it is served at.
+type AdminSpace struct {
+	db.Space
+	URL string
+}
+
+type AdminSpaceFormData struct {
+	AdminData
+	Space AdminSpace
+	IsNew bool
+	Error string
+}
+
+// requireDefaultSpace reports whether the request is for the default space,
+// rendering a 404 otherwise. Spaces are managed from the default space only.
+func (h *Handlers) requireDefaultSpace(w http.ResponseWriter, r *http.Request) bool {
+	if !db.SpaceFrom(r.Context()).IsDefault() {
+		h.notFound(w, r)
+		return false
+	}
+	return true
+}
+
+// AdminSpaces lists all spaces.
+func (h *Handlers) AdminSpaces(w http.ResponseWriter, r *http.Request) {
+	if !h.requireDefaultSpace(w, r) {
+		return
+	}
+
+	spaces, err := h.DB.ListSpaces(r.Context())
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("AdminSpaces", "error", err)
+		return
+	}
+
+	data := AdminSpacesData{AdminData: h.adminData(r, "spaces")}
+	for _, s := range spaces {
+		data.Spaces = append(data.Spaces, AdminSpace{Space: s, URL: spaceURL(s) + "/"})
+	}
+
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "admin-spaces.html", data); err != nil {
+		slog.Error("AdminSpaces template", "error", err)
+	}
+}
+
+// AdminNewSpaceForm renders the create space form.
+func (h *Handlers) AdminNewSpaceForm(w http.ResponseWriter, r *http.Request) {
+	if !h.requireDefaultSpace(w, r) {
+		return
+	}
+
+	q := r.URL.Query()
+	data := AdminSpaceFormData{
+		AdminData: h.adminData(r, "spaces"),
+		Space:     AdminSpace{Space: db.Space{Name: q.Get("name"), Title: q.Get("title"), Host: q.Get("host")}},
+		IsNew:     true,
+		Error:     q.Get("error"),
+	}
+
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "admin-space-form.html", data); err != nil {
+		slog.Error("AdminNewSpaceForm template", "error", err)
+	}
+}
+
+// validSpaceHost returns a user-facing error message if host cannot be used
+// for a space.
+func validSpaceHost(host string) string {
+	if host == "" {
+		return ""
+	}
+	if strings.ContainsAny(host, "/:") || strings.TrimSpace(host) != host {
+		return "Host must be a plain host name without scheme, port or path"
+	}
+	if strings.EqualFold(host, baseHost()) {
+		return "Host must differ from the host of BASE_URL"
+	}
+	return ""
+}
+
+// AdminCreateSpace handles the create space form submission. The new space
+// starts empty, with the current user as its admin.
+func (h *Handlers) AdminCreateSpace(w http.ResponseWriter, r *http.Request) {
+	if !h.requireDefaultSpace(w, r) {
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.ToLower(strings.TrimSpace(r.FormValue("name")))
+	title := strings.TrimSpace(r.FormValue("title"))
+	host := strings.ToLower(strings.TrimSpace(r.FormValue("host")))
+
+	msg := validSpaceHost(host)
+	switch {
+	case !spaceNamePattern.MatchString(name):
+		msg = "Name must start with a letter or digit and contain only lowercase letters, digits and hyphens"
+	case name == "default":
+		msg = `"default" is reserved`
+	case title == "":
+		msg = "Title is required"
+	}
+	if msg == "" {
+		if _, err := h.DB.GetSpaceByName(r.Context(), name); err == nil {
+			msg = "A space with this name already exists"
+		}
+	}
+	if msg == "" && host != "" {
+		if _, err := h.DB.GetSpaceByHost(r.Context(), host); err == nil {
+			msg = "Another space already uses this host"
+		}
+	}
+	if msg != "" {
+		q := url.Values{"error": {msg}, "name": {name}, "title": {title}, "host": {host}}
+		http.Redirect(w, r, "/admin/spaces/new?"+q.Encode(), http.StatusSeeOther)
+		return
+	}
+
+	space, err := h.DB.CreateSpace(r.Context(), name, title, host, userID(r.Context()))
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("AdminCreateSpace", "error", err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/spaces/"+space.ID+"/edit", http.StatusSeeOther)
+}
+
+// AdminEditSpaceForm renders the edit space form.
+func (h *Handlers) AdminEditSpaceForm(w http.ResponseWriter, r *http.Request) {
+	if !h.requireDefaultSpace(w, r) {
+		return
+	}
+
+	space, err := h.DB.GetSpace(r.Context(), r.PathValue("id"))
+	if err != nil {
+		h.notFound(w, r)
+		return
+	}
+
+	data := AdminSpaceFormData{
+		AdminData: h.adminData(r, "spaces"),
+		Space:     AdminSpace{Space: space, URL: spaceURL(space) + "/"},
+		Error:     r.URL.Query().Get("error"),
+	}
+
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "admin-space-form.html", data); err != nil {
+		slog.Error("AdminEditSpaceForm template", "error", err)
+	}
+}
+
+// AdminUpdateSpace handles the edit space form submission. The name is part
+// of the space's URLs and cannot be changed.
+func (h *Handlers) AdminUpdateSpace(w http.ResponseWriter, r *http.Request) {
+	if !h.requireDefaultSpace(w, r) {
+		return
+	}
+	id := r.PathValue("id")
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	title := strings.TrimSpace(r.FormValue("title"))
+	host := strings.ToLower(strings.TrimSpace(r.FormValue("host")))
+
+	msg := validSpaceHost(host)
+	if title == "" {
+		msg = "Title is required"
+	}
+	if msg == "" && host != "" {
+		if other, err := h.DB.GetSpaceByHost(r.Context(), host); err == nil && other.ID != id {
+			msg = "Another space already uses this host"
+		}
+	}
+	if msg != "" {
+		http.Redirect(w, r, "/admin/spaces/"+id+"/edit?error="+url.QueryEscape(msg), http.StatusSeeOther)
+		return
+	}
+
+	if _, err := h.DB.UpdateSpace(r.Context(), id, title, host); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			h.notFound(w, r)
+			return
+		}
+		h.serverError(w, r)
+		slog.Error("AdminUpdateSpace", "error", err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/spaces", http.StatusSeeOther)
+}
+
+// AdminDeleteSpace deletes a space with all its content. Its members keep
+// their accounts.
+func (h *Handlers) AdminDeleteSpace(w http.ResponseWriter, r *http.Request) {
+	if !h.requireDefaultSpace(w, r) {
+		return
+	}
+
+	id := r.PathValue("id")
+	if err := h.DB.DeleteSpace(r.Context(), id); err != nil {
+		if errors.Is(err, db.ErrDefaultSpace) {
+			http.Redirect(w, r, "/admin/spaces/"+id+"/edit?error="+url.QueryEscape("The default space cannot be deleted"), http.StatusSeeOther)
+			return
+		}
+		h.serverError(w, r)
+		slog.Error("AdminDeleteSpace", "error", err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/spaces", http.StatusSeeOther)
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// staticHash is the content hash of a static file, kept with the size and
+// modification time it was computed for so that edits in dev mode are
+// picked up.
+type staticHash struct {
+	hash    string
+	size    int64
+	modTime time.Time
+}
+
+// assetHashPattern matches the content hash staticAsset puts into file
+// names, e.g. the "3f2a9c1b7d0e" in "fonts/inter.3f2a9c1b7d0e.woff2".
+var assetHashPattern = regexp.MustCompile(`^(.+)\.([0-9a-f]{12})(\.[^./]+)$`)
+
+// assetHash returns the content hash of a file in the static filesystem,
+// or false if there is no such file.
+func (h *Handlers) assetHash(name string) (string, bool) {
+	if h.StaticFS == nil {
+		return "", false
+	}
+	info, err := fs.Stat(h.StaticFS, name)
+	if err != nil || info.IsDir() {
+		return "", false
+	}
+	if v, ok := h.staticHashes.Load(name); ok {
+		if c := v.(staticHash); c.size == info.Size() && c.modTime.Equal(info.ModTime()) {
+			return c.hash, true
+		}
+	}
+	data, err := fs.ReadFile(h.StaticFS, name)
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])[:12]
+	h.staticHashes.Store(name, staticHash{hash: hash, size: info.Size(), modTime: info.ModTime()})
+	return hash, true
+}
+
+// assetURL returns the URL of a file in the static filesystem with its
+// content hash in the name, or "" when the file is not present.
+func (h *Handlers) assetURL(name string) string {
+	hash, ok := h.assetHash(name)
+	if !ok {
+		return ""
+	}
+	ext := path.Ext(name)
+	return "/static/" + strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+// fontFiles are the self-hosted fonts. They are not part of the repository;
+// make vendor-fonts downloads them into static/ before the build embeds it.
+// Both are variable fonts covering every weight.
+var fontFiles = []struct{ family, file string }{
+	{"Inter", "fonts/inter.woff2"},
+	{"JetBrains Mono", "fonts/jetbrains-mono.woff2"},
+}
+
+// fontStacks are the body fonts a theme can use. Inter is self-hosted when
+// its font file is installed; the others use fonts installed on the reader's
+// device.
+var fontStacks = map[string]string{
+	"inter":  `'Inter', -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif`,
+	"system": `-apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, 'Helvetica Neue', Arial, sans-serif`,
+	"serif":  `Charter, 'Bitstream Charter', 'Sitka Text', Cambria, Georgia, serif`,
+}
+
+// fontNames lists the keys of fontStacks in the order the theme builder
+// shows them; the first is the default.
+var fontNames = []string{"inter", "system", "serif"}
+
+// ValidFont checks if a font name is valid.
+func ValidFont(f string) bool {
+	_, ok := fontStacks[f]
+	return ok
+}
+
+const monoFontStack = `'JetBrains Mono', 'Fira Code', 'SF Mono', Consolas, monospace`
+
+// fontCSS returns the @font-face rules for the self-hosted fonts that are
+// present and the default --font-sans and --font-mono variables. Without
+// the font files, pages use the fonts of the reader's device; no font is
+// ever loaded from another site.
+func (h *Handlers) fontCSS() template.HTML {
+	var b strings.Builder
+	for _, f := range fontFiles {
+		if u := h.assetURL(f.file); u != "" {
+			fmt.Fprintf(&b, "<link rel=\"preload\" href=\"%s\" as=\"font\" type=\"font/woff2\" crossorigin>\n", u)
+		}
+	}
+	b.WriteString("<style>\n")
+	for _, f := range fontFiles {
+		if u := h.assetURL(f.file); u != "" {
+			fmt.Fprintf(&b, "  @font-face { font-family: '%s'; font-style: normal; font-weight: 100 900; font-display: swap; src: url(%s) format('woff2'); }\n", f.family, u)
+		}
+	}
+	fmt.Fprintf(&b, "  :root { --font-sans: %s; --font-mono: %s; }\n</style>", fontStacks[fontNames[0]], monoFontStack)
+	return template.HTML(b.String())
+}
+
+// StaticAssetFunc returns a template.FuncMap with a "staticAsset" function
+// that returns the hashed URL of a file in the static filesystem, or "" when
+// the file is not present, and a "fontCSS" function that returns the
+// self-hosted font faces. Templates use them to include optional vendored
+// assets.
+func (h *Handlers) StaticAssetFunc() template.FuncMap {
+	return template.FuncMap{
+		"staticAsset": h.assetURL,
+		"fontCSS":     h.fontCSS,
+	}
+}
+
+// Static serves files from the static filesystem. Names with the current
+// content hash, as returned by staticAsset, are cached for good; other
+// names, including ones with an outdated hash, are revalidated on each use.
+func (h *Handlers) Static(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("path")
+	cache := "no-cache"
+	info, err := fs.Stat(h.StaticFS, name)
+	if err != nil || info.IsDir() {
+		m := assetHashPattern.FindStringSubmatch(name)
+		if m == nil {
+			http.NotFound(w, r)
+			return
+		}
+		name = m[1] + m[3]
+		hash, ok := h.assetHash(name)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if hash == m[2] {
+			cache = "public, max-age=31536000, immutable"
+		}
+	}
+	w.Header().Set("Cache-Control", cache)
+	http.ServeFileFS(w, r, h.StaticFS, name)
+}
@@ -0,0 +1,447 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"docgen/internal/db"
+	"docgen/internal/diff"
+)
+
+// Changed lines shown in a notification, for an immediate email and for
+// each page of a digest.
+const (
+	maxExcerptLines       = 20
+	maxDigestExcerptLines = 8
+)
+
+type NotificationsData struct {
+	AdminData
+	Frequency     string
+	Subscriptions []db.Subscription
+	Saved         bool
+}
+
+type UnsubscribeData struct {
+	SiteTitle string
+	ThemeCSS  template.HTML
+	Token     string
+	ID        string
+	Target    string
+	Error     string
+	Done      bool
+}
+
+func notificationsNav(active string) []AdminNavItem {
+	return []AdminNavItem{
+		{Title: "Notifications", Path: "/notifications", IsActive: active == "notifications"},
+		{Title: "Preferences", Path: "/preferences", IsActive: active == "preferences"},
+	}
+}
+
+// WatchPage subscribes the current user to changes of a page.
+func (h *Handlers) WatchPage(w http.ResponseWriter, r *http.Request) {
+	h.setPageSubscription(w, r, true)
+}
+
+// UnwatchPage removes the current user's subscription to a page.
+func (h *Handlers) UnwatchPage(w http.ResponseWriter, r *http.Request) {
+	h.setPageSubscription(w, r, false)
+}
+
+func (h *Handlers) setPageSubscription(w http.ResponseWriter, r *http.Request, subscribe bool) {
+	sectionName := r.PathValue("section")
+	slug := r.PathValue("slug")
+
+	section, err := h.DB.GetSectionByName(r.Context(), sectionName)
+	if err != nil || !h.sectionVisible(r.Context(), section) {
+		h.notFound(w, r)
+		return
+	}
+
+	if !h.canAccessSection(r.Context(), section.RequiredRole) {
+		h.forbidden(w, r)
+		return
+	}
+
+	page, err := h.DB.GetPage(r.Context(), section.ID, slug, h.showDrafts(r.Context()))
+	if err != nil {
+		h.notFound(w, r)
+		return
+	}
+
+	if subscribe {
+		err = h.DB.Subscribe(r.Context(), userID(r.Context()), &page.ID, nil)
+	} else {
+		err = h.DB.Unsubscribe(r.Context(), userID(r.Context()), &page.ID, nil)
+	}
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("setPageSubscription", "error", err)
+		return
+	}
+
+	http.Redirect(w, r, "/"+section.Name+"/"+page.Slug+"#watch", http.StatusSeeOther)
+}
+
+// WatchSection subscribes the current user to changes of every page in a
+// section.
+func (h *Handlers) WatchSection(w http.ResponseWriter, r *http.Request) {
+	h.setSectionSubscription(w, r, true)
+}
+
+// UnwatchSection removes the current user's subscription to a section.
+func (h *Handlers) UnwatchSection(w http.ResponseWriter, r *http.Request) {
+	h.setSectionSubscription(w, r, false)
+}
+
+func (h *Handlers) setSectionSubscription(w http.ResponseWriter, r *http.Request, subscribe bool) {
+	section, err := h.DB.GetSectionByName(r.Context(), r.PathValue("section"))
+	if err != nil || !h.sectionVisible(r.Context(), section) {
+		h.notFound(w, r)
+		return
+	}
+
+	if !h.canAccessSection(r.Context(), section.RequiredRole) {
+		h.forbidden(w, r)
+		return
+	}
+
+	if subscribe {
+		err = h.DB.Subscribe(r.Context(), userID(r.Context()), nil, &section.ID)
+	} else {
+		err = h.DB.Unsubscribe(r.Context(), userID(r.Context()), nil, &section.ID)
+	}
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("setSectionSubscription", "error", err)
+		return
+	}
+
+	// The forms sit on pages of the section; go back to the one used.
+	target := "/" + section.Name + "/"
+	if slug := r.FormValue("slug"); slug != "" && !strings.ContainsAny(slug, "/?#") {
+		target += slug + "#watch"
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+// Notifications shows the current user's subscriptions and how often they
+// want to be notified.
+func (h *Handlers) Notifications(w http.ResponseWriter, r *http.Request) {
+	settings, err := h.DB.GetNotifySettings(r.Context(), userID(r.Context()))
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("Notifications settings", "error", err)
+		return
+	}
+
+	subs, err := h.DB.ListUserSubscriptions(r.Context(), userID(r.Context()))
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("Notifications", "error", err)
+		return
+	}
+
+	data := NotificationsData{
+		AdminData:     h.adminData(r, "notifications"),
+		Frequency:     settings.Frequency,
+		Subscriptions: subs,
+		Saved:         r.URL.Query().Get("saved") == "1",
+	}
+	data.NavItems = notificationsNav("notifications")
+	data.IsEditor = h.isEditor(r.Context())
+
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "notifications.html", data); err != nil {
+		slog.Error("Notifications template", "error", err)
+	}
+}
+
+// UpdateNotifications saves the current user's notification frequency.
+func (h *Handlers) UpdateNotifications(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	frequency := r.FormValue("frequency")
+	if frequency != db.NotifyImmediate && frequency != db.NotifyDaily {
+		http.Error(w, "invalid frequency", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.DB.SetNotifyFrequency(r.Context(), userID(r.Context()), frequency); err != nil {
+		h.serverError(w, r)
+		slog.Error("UpdateNotifications", "error", err)
+		return
+	}
+
+	http.Redirect(w, r, "/notifications?saved=1", http.StatusSeeOther)
+}
+
+// DeleteSubscription removes one of the current user's subscriptions.
+func (h *Handlers) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
+	if err := h.DB.DeleteSubscription(r.Context(), r.PathValue("id"), userID(r.Context())); err != nil {
+		h.serverError(w, r)
+		slog.Error("DeleteSubscription", "error", err)
+		return
+	}
+
+	http.Redirect(w, r, "/notifications", http.StatusSeeOther)
+}
+
+// UnsubscribePage confirms the unsubscribe link of a notification email. It
+// works without a session; the token in the link identifies the user.
+func (h *Handlers) UnsubscribePage(w http.ResponseWriter, r *http.Request) {
+	title, _, themeCSS := h.siteSettings(r.Context())
+	data := UnsubscribeData{
+		SiteTitle: title,
+		ThemeCSS:  themeCSS,
+		Token:     r.URL.Query().Get("token"),
+		ID:        r.URL.Query().Get("id"),
+	}
+
+	sub, err := h.DB.GetSubscriptionByToken(r.Context(), data.Token, data.ID)
+	if err != nil {
+		data.Error = "This unsubscribe link is invalid, or you have already unsubscribed"
+		w.WriteHeader(http.StatusNotFound)
+	} else {
+		data.Target = h.subscriptionTarget(r.Context(), sub)
+	}
+
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "unsubscribe.html", data); err != nil {
+		slog.Error("UnsubscribePage template", "error", err)
+	}
+}
+
+// Unsubscribe removes the subscription of an unsubscribe link.
+func (h *Handlers) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	title, _, themeCSS := h.siteSettings(r.Context())
+	data := UnsubscribeData{
+		SiteTitle: title,
+		ThemeCSS:  themeCSS,
+		Token:     r.FormValue("token"),
+		ID:        r.FormValue("id"),
+	}
+
+	sub, err := h.DB.GetSubscriptionByToken(r.Context(), data.Token, data.ID)
+	if err != nil {
+		data.Error = "This unsubscribe link is invalid, or you have already unsubscribed"
+		w.WriteHeader(http.StatusNotFound)
+		h.tmpl(r.Context()).ExecuteTemplate(w, "unsubscribe.html", data)
+		return
+	}
+
+	if err := h.DB.DeleteSubscription(r.Context(), sub.ID, sub.UserID); err != nil {
+		h.serverError(w, r)
+		slog.Error("Unsubscribe", "error", err)
+		return
+	}
+
+	data.Target = h.subscriptionTarget(r.Context(), sub)
+	data.Done = true
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "unsubscribe.html", data); err != nil {
+		slog.Error("Unsubscribe template", "error", err)
+	}
+}
+
+// subscriptionTarget describes what a subscription is to, for messages.
+func (h *Handlers) subscriptionTarget(ctx context.Context, s db.Subscription) string {
+	if s.PageID != nil {
+		return h.t(ctx, "the page \"%s\"", s.PageTitle)
+	}
+	return h.t(ctx, "the %s section", s.SectionTitle)
+}
+
+// notificationFooter explains why a notification was sent and how to stop it.
+func notificationFooter(ctx context.Context, pageLevel bool, sectionTitle, token, subscriptionID string) string {
+	reason := "this page"
+	if !pageLevel {
+		reason = "the " + sectionTitle + " section"
+	}
+	return fmt.Sprintf("You receive this because you watch %s.\r\nUnsubscribe: %s/unsubscribe?token=%s&id=%s\r\nNotification settings: %s/notifications\r\n",
+		reason, siteURL(ctx), url.QueryEscape(token), url.QueryEscape(subscriptionID), siteURL(ctx))
+}
+
+// notifySubscribers tells the users watching a page or its section that a
+// new version of it was published. fromVersion is the version readers saw
+// before, 0 if the page was not published. Subscribers who chose a daily
+// digest get the change queued instead. Nothing is sent while the page is
+// not live, and never to the user who published it.
+func (h *Handlers) notifySubscribers(ctx context.Context, section db.Section, page db.Page, fromVersion int) {
+	now := time.Now()
+	if !section.Live(now) || !page.Live(now) {
+		return
+	}
+
+	subs, err := h.DB.ListSubscribers(ctx, page.ID, section.ID)
+	if err != nil {
+		slog.Error("notifySubscribers", "error", err)
+		return
+	}
+
+	actor := userID(ctx)
+	var excerpt string
+	for _, s := range subs {
+		if s.ID == actor || !h.userCanAccessSection(ctx, s.ID, section.RequiredRole) {
+			continue
+		}
+
+		if s.Frequency == db.NotifyDaily {
+			if err := h.DB.AddDigestEntry(ctx, s.ID, page.ID, s.SubscriptionID, fromVersion, page.Version); err != nil {
+				slog.Error("notifySubscribers digest", "error", err)
+			}
+			continue
+		}
+
+		if excerpt == "" {
+			excerpt = h.changeExcerpt(ctx, page.ID, fromVersion, page.Version, maxExcerptLines)
+		}
+		link := siteURL(ctx) + "/" + section.Name + "/" + page.Slug
+		body := fmt.Sprintf("%s published a new version of \"%s\" in %s.\r\n\r\n%s\r\n%s\r\n\r\n-- \r\n%s",
+			userFirstname(ctx), page.Title, section.Title, excerpt, link,
+			notificationFooter(ctx, s.PageLevel, section.Title, s.Token, s.SubscriptionID))
+		h.notifyUsers(ctx, []db.User{s.User}, "Updated: "+page.Title, body)
+	}
+}
+
+// changeExcerpt summarises the change between two versions of a page from
+// pages_history. Version 0 stands for an empty page.
+func (h *Handlers) changeExcerpt(ctx context.Context, pageID string, fromVersion, toVersion, maxLines int) string {
+	var oldMD string
+	if fromVersion > 0 {
+		old, err := h.DB.GetPageVersion(ctx, pageID, fromVersion)
+		if err != nil {
+			slog.Error("changeExcerpt old version", "version", fromVersion, "error", err)
+		}
+		oldMD = old.ContentMD
+	}
+	cur, err := h.DB.GetPageVersion(ctx, pageID, toVersion)
+	if err != nil {
+		slog.Error("changeExcerpt new version", "version", toVersion, "error", err)
+		return ""
+	}
+	return diffExcerpt(oldMD, cur.ContentMD, maxLines)
+}
+
+// diffExcerpt renders the changed lines between two texts as "+ " and "- "
+// lines, cut after maxLines.
+func diffExcerpt(a, b string, maxLines int) string {
+	lines := diff.Lines(a, b)
+	inserted, deleted := diff.Stats(lines)
+	if inserted+deleted == 0 {
+		return "The content is unchanged.\r\n"
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d line(s) added, %d removed:\r\n\r\n", inserted, deleted)
+	shown := 0
+	for _, l := range lines {
+		if l.Op == diff.Equal {
+			continue
+		}
+		if shown == maxLines {
+			fmt.Fprintf(&sb, "[... %d more changed line(s)]\r\n", inserted+deleted-shown)
+			break
+		}
+		prefix := "+ "
+		if l.IsDelete() {
+			prefix = "- "
+		}
+		sb.WriteString(prefix + l.Text + "\r\n")
+		shown++
+	}
+	return sb.String()
+}
+
+// SendDigests emails the queued changes to every user whose daily digest is
+// due, one email per space so that each carries its own title and links.
+// It runs in the background, so the emails are sent directly; the changes
+// are deleted once their email is sent and otherwise go out with the next
+// digest. Safe on multiple replicas: the digests are claimed under an
+// advisory lock.
+func (h *Handlers) SendDigests(ctx context.Context) {
+	entries, ran, err := h.DB.ClaimDueDigests(ctx)
+	if err != nil {
+		slog.Error("digest failed", "error", err)
+		return
+	}
+	if !ran || len(entries) == 0 {
+		return
+	}
+
+	// Entries come ordered by user and space.
+	for len(entries) > 0 {
+		n := 1
+		for n < len(entries) && entries[n].UserID == entries[0].UserID && entries[n].SpaceID == entries[0].SpaceID {
+			n++
+		}
+		h.sendDigest(ctx, entries[:n])
+		entries = entries[n:]
+	}
+}
+
+// sendDigest sends one user's digest of the changes in one space.
+func (h *Handlers) sendDigest(ctx context.Context, entries []db.DigestEntry) {
+	uid := entries[0].UserID
+	space, err := h.DB.GetSpace(ctx, entries[0].SpaceID)
+	if err != nil {
+		slog.Error("digest space", "error", err)
+		return
+	}
+	ctx = db.WithSpace(ctx, space)
+
+	u, err := h.DB.GetUserByID(ctx, uid)
+	if err != nil {
+		slog.Error("digest user", "error", err)
+		return
+	}
+	settings, err := h.DB.GetNotifySettings(ctx, uid)
+	if err != nil {
+		slog.Error("digest settings", "error", err)
+		return
+	}
+
+	var ids []string
+	var sb strings.Builder
+	n := 0
+	// Access may have been revoked since the changes were queued; such
+	// changes are dropped with the rest.
+	member, _ := h.DB.IsSpaceMember(ctx, space.ID, uid)
+	for _, e := range entries {
+		ids = append(ids, e.EntryIDs...)
+		if !member || !h.userCanAccessSection(ctx, uid, e.RequiredRole) {
+			continue
+		}
+		n++
+		fmt.Fprintf(&sb, "%s (%s)\r\n%s/%s/%s\r\n\r\n%s\r\n%s\r\n",
+			e.PageTitle, e.SectionTitle, siteURL(ctx), e.SectionName, e.Slug,
+			h.changeExcerpt(ctx, e.PageID, e.FromVersion, e.ToVersion, maxDigestExcerptLines),
+			notificationFooter(ctx, e.PageLevel, e.SectionTitle, settings.Token, e.SubscriptionID))
+	}
+
+	if n > 0 {
+		body := fmt.Sprintf("%d page(s) you watch changed since your last digest.\r\n\r\n%s", n, sb.String())
+		subject := h.notificationSubject(ctx, "Daily digest of documentation changes")
+		if err := sendEmail(u.Email, subject, notificationBody(u, body)); err != nil {
+			slog.Error("digest email", "to", u.Email, "error", err)
+			return
+		}
+		slog.Info("digest sent", "to", u.Email, "space", space.Name, "pages", n)
+	}
+
+	if err := h.DB.DeleteDigestEntries(ctx, ids); err != nil {
+		slog.Error("digest delete", "error", err)
+	}
+}
@@ -1,8 +1,17 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"html/template"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"docgen/internal/db"
+
+	"github.com/jackc/pgx/v5"
 )
 
 type themeVars struct {
@@ -261,10 +270,14 @@ var accents = map[string]accentVars{
 	},
 }
 
+// AutoTheme follows the reader's light or dark mode setting, showing
+// Daylight or Midnight.
+const AutoTheme = "auto"
+
 // ValidTheme checks if a theme name is valid.
 func ValidTheme(t string) bool {
 	_, ok := themes[t]
-	return ok
+	return ok || t == AutoTheme
 }
 
 // ValidAccent checks if an accent color name is valid.
@@ -273,10 +286,108 @@ func ValidAccent(a string) bool {
 	return ok
 }
 
+// ThemeVar is one CSS custom property set by a theme. Name has no leading
+// dashes, e.g. "bg-body".
+type ThemeVar struct {
+	Name  string
+	Value string
+}
+
+func (t themeVars) list() []ThemeVar {
+	return []ThemeVar{
+		{"bg-body", t.BgBody},
+		{"bg-sidebar", t.BgSidebar},
+		{"bg-content", t.BgContent},
+		{"bg-code", t.BgCode},
+		{"bg-card", t.BgCard},
+		{"bg-card-hover", t.BgCardHover},
+		{"text-primary", t.TextPrimary},
+		{"text-secondary", t.TextSecondary},
+		{"text-muted", t.TextMuted},
+		{"text-code", t.TextCode},
+		{"heading-gradient-start", t.HeadingStart},
+		{"border-glass", t.BorderGlass},
+		{"border-glass-hover", t.BorderGlassHover},
+		{"table-stripe", t.TableStripe},
+		{"glass-white-03", t.GlassWhite03},
+		{"glass-white-04", t.GlassWhite04},
+		{"glass-white-05", t.GlassWhite05},
+		{"glass-white-06", t.GlassWhite06},
+		{"glass-white-10", t.GlassWhite10},
+		{"glass-white-12", t.GlassWhite12},
+		{"input-bg", t.InputBg},
+		{"input-bg-focus", t.InputBgFocus},
+		{"hover-bg", t.HoverBg},
+	}
+}
+
+func (a accentVars) list() []ThemeVar {
+	return []ThemeVar{
+		{"accent-1", a.Accent1},
+		{"accent-2", a.Accent2},
+		{"accent-3", a.Accent3},
+		{"accent-dim", a.AccentDim},
+		{"glow-purple", a.GlowPurple},
+		{"glow-blue", a.GlowBlue},
+		{"btn-gradient-end", a.BtnGradEnd},
+		{"accent-heading-tint", a.HeadingTint},
+		{"accent-badge-bg", a.BadgeBg},
+		{"accent-badge-border", a.BadgeBorder},
+		{"accent-active-bg", a.ActiveBg},
+		{"accent-hover-bg", a.HoverBg},
+		{"accent-focus-shadow", a.FocusShadow},
+		{"accent-card-grad1", a.CardGrad1},
+		{"accent-card-grad2", a.CardGrad2},
+		{"accent-card-border", a.CardBorder},
+		{"accent-card-overlay1", a.CardOverlay1},
+		{"accent-card-overlay2", a.CardOverlay2},
+		{"accent-btn-shadow", a.BtnShadow},
+		{"accent-add-card-hover", a.AddCardHover},
+		{"accent-add-card-shadow", a.AddCardShadow},
+		{"accent-icon-grad2-bg", a.IconGrad2Bg},
+		{"accent-icon-grad2-border", a.IconGrad2Bdr},
+		{"accent-icon-grad3-bg", a.IconGrad3Bg},
+		{"accent-icon-grad3-border", a.IconGrad3Bdr},
+		{"accent-icon-grad-mix", a.IconGradMix},
+		{"accent-edit-hover-bg", a.EditHoverBg},
+		{"accent-version-border", a.VersionBdr},
+		{"accent-icon-box-shadow", a.IconBoxShadow},
+		{"accent-table-head-bg", a.TableHeadBg},
+		{"accent-table-hover-bg", a.TableHoverBg},
+		{"accent-blockquote-bg", a.BlockquoteBg},
+		{"accent-copy-hover-border", a.CopyHoverBdr},
+	}
+}
+
+// ThemeVarsFor returns the variables of a built-in theme and accent color:
+// first the base variables, then the accent ones. Unknown names fall back
+// to midnight and blue.
+func ThemeVarsFor(themeName, accentColor string) (base, accent []ThemeVar) {
+	t, ok := themes[themeName]
+	if !ok {
+		t = themes["midnight"]
+	}
+	a, ok := accents[accentColor]
+	if !ok {
+		a = accents["blue"]
+	}
+	return t.list(), a.list()
+}
+
+// themeValuePattern matches the CSS values a custom theme may use: colors
+// as hex, rgb(), hsl() or names. Anything that could end the declaration
+// or the <style> element is rejected.
+var themeValuePattern = regexp.MustCompile(`^[#a-zA-Z0-9(),.%/ -]{1,64}$`)
+
+// ValidThemeValue reports whether v is acceptable as a custom theme value.
+func ValidThemeValue(v string) bool {
+	return themeValuePattern.MatchString(v)
+}
+
 // ThemeCSS returns a <style> block that overrides :root CSS variables for the
-// selected theme and accent color. For the default (midnight + blue) it returns
-// an empty string so there is zero visual regression.
-func ThemeCSS(themeName, accentColor string) template.HTML {
+// selected theme, accent color and body font. For the default (midnight +
+// blue + Inter) it returns an empty string so there is zero visual regression.
+func ThemeCSS(themeName, accentColor, font string) template.HTML {
 	if themeName == "" {
 		themeName = "midnight"
 	}
@@ -284,96 +395,363 @@ func ThemeCSS(themeName, accentColor string) template.HTML {
 		accentColor = "blue"
 	}
 
+	fontVar := fontVars(font)
+
 	// Default combo: no override needed
-	if themeName == "midnight" && accentColor == "blue" {
+	if themeName == "midnight" && accentColor == "blue" && len(fontVar) == 0 {
 		return ""
 	}
 
-	t, ok := themes[themeName]
-	if !ok {
-		t = themes["midnight"]
+	if themeName == AutoTheme {
+		lightBase, lightAccent := ThemeVarsFor("daylight", accentColor)
+		darkBase, darkAccent := ThemeVarsFor("midnight", accentColor)
+		return template.HTML("<style>\n  @media (prefers-color-scheme: light) {\n" +
+			rootRule(append(append(lightBase, lightAccent...), fontVar...), "    ") +
+			"  }\n  @media (prefers-color-scheme: dark) {\n" +
+			rootRule(append(append(darkBase, darkAccent...), fontVar...), "    ") +
+			"  }\n</style>")
 	}
-	a, ok := accents[accentColor]
-	if !ok {
-		a = accents["blue"]
+
+	base, accent := ThemeVarsFor(themeName, accentColor)
+	return themeStyle(append(append(base, accent...), fontVar...))
+}
+
+// fontVars returns the variable that switches the body font to font, or
+// nothing for the default font and unknown names.
+func fontVars(font string) []ThemeVar {
+	if font == "" || font == fontNames[0] || !ValidFont(font) {
+		return nil
+	}
+	return []ThemeVar{{"font-sans", fontStacks[font]}}
+}
+
+// CustomThemeCSS returns the <style> block for an admin-defined theme with
+// the given body font. Variables it does not set, or sets to an invalid
+// value, keep their midnight and blue values.
+func CustomThemeCSS(vars map[string]string, font string) template.HTML {
+	base, accent := ThemeVarsFor("midnight", "blue")
+	all := append(base, accent...)
+	for i, v := range all {
+		if val, ok := vars[v.Name]; ok && ValidThemeValue(val) {
+			all[i].Value = val
+		}
+	}
+	return themeStyle(append(all, fontVars(font)...))
+}
+
+// ThemeColor returns a custom theme's value for the named variable, or its
+// midnight and blue value if the theme does not set a valid one. It is for
+// theme swatches in style attributes.
+func ThemeColor(vars map[string]string, name string) template.CSS {
+	if v, ok := vars[name]; ok && ValidThemeValue(v) {
+		return template.CSS(v)
+	}
+	base, accent := ThemeVarsFor("midnight", "blue")
+	for _, v := range append(base, accent...) {
+		if v.Name == name {
+			return template.CSS(v.Value)
+		}
+	}
+	return ""
+}
+
+func themeStyle(vars []ThemeVar) template.HTML {
+	return template.HTML("<style>\n" + rootRule(vars, "  ") + "</style>")
+}
+
+// rootRule returns vars as a :root rule with every line indented by indent.
+func rootRule(vars []ThemeVar, indent string) string {
+	var b strings.Builder
+	b.WriteString(indent + ":root {\n")
+	for _, v := range vars {
+		fmt.Fprintf(&b, "%s  --%s: %s;\n", indent, v.Name, v.Value)
+	}
+	b.WriteString(indent + "}\n")
+	return b.String()
+}
+
+// ValidCustomCSS reports whether css can be placed inside a <style>
+// element without closing it.
+func ValidCustomCSS(css string) bool {
+	return !strings.Contains(css, "</")
+}
+
+// CustomCSS returns the site's custom CSS as a <style> block, or an empty
+// string if there is none or it is not valid.
+func CustomCSS(css string) template.HTML {
+	if strings.TrimSpace(css) == "" || !ValidCustomCSS(css) {
+		return ""
+	}
+	return template.HTML("<style>\n" + css + "\n</style>")
+}
+
+// Built-in themes and accent colors in the order the settings page shows
+// them.
+var (
+	themeNames  = []string{"midnight", "slate", "silver", "daylight"}
+	accentNames = []string{"blue", "purple", "green", "orange", "red", "teal", "pink"}
+)
+
+var hexColorPattern = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// ThemeVarField is one variable in the theme builder form.
+type ThemeVarField struct {
+	ThemeVar
+	// Default is the value used when the field is left empty.
+	Default string
+	// Hex is Value as #rrggbb for the color picker, or empty if Value is
+	// not a hex color.
+	Hex string
+}
+
+func themeVarFields(vars []ThemeVar, set map[string]string) []ThemeVarField {
+	fields := make([]ThemeVarField, len(vars))
+	for i, v := range vars {
+		f := ThemeVarField{ThemeVar: v, Default: v.Value}
+		if val, ok := set[v.Name]; ok {
+			f.Value = val
+		}
+		if hexColorPattern.MatchString(f.Value) {
+			f.Hex = strings.ToLower(f.Value)
+			if len(f.Hex) == 4 {
+				f.Hex = string([]byte{'#', f.Hex[1], f.Hex[1], f.Hex[2], f.Hex[2], f.Hex[3], f.Hex[3]})
+			}
+		}
+		fields[i] = f
+	}
+	return fields
+}
+
+// FontOption is an entry of the font select in the theme builder.
+type FontOption struct {
+	Name  string
+	Label string
+	// Stack is the CSS font-family value, for the live preview.
+	Stack string
+}
+
+func fontOptions() []FontOption {
+	labels := map[string]string{"inter": "Inter", "system": "System UI", "serif": "Serif"}
+	var options []FontOption
+	for _, name := range fontNames {
+		options = append(options, FontOption{Name: name, Label: labels[name], Stack: fontStacks[name]})
+	}
+	return options
+}
+
+type AdminThemesData struct {
+	AdminData
+	Themes []db.CustomTheme
+	// Current is the name of the theme the site uses.
+	Current string
+}
+
+type AdminThemeFormData struct {
+	AdminData
+	Theme      db.CustomTheme
+	BaseVars   []ThemeVarField
+	AccentVars []ThemeVarField
+	Fonts      []FontOption
+	// ThemeNames and AccentNames list the built-ins a new theme can start
+	// from; StartTheme and StartAccent are the ones it started from.
+	ThemeNames  []string
+	AccentNames []string
+	StartTheme  string
+	StartAccent string
+	IsNew       bool
+	Error       string
+}
+
+// newThemeFormData returns the builder form for t. Variables t does not set
+// show the values of the given built-in theme and accent color.
+func (h *Handlers) newThemeFormData(r *http.Request, t db.CustomTheme, startTheme, startAccent string) AdminThemeFormData {
+	base, accent := ThemeVarsFor(startTheme, startAccent)
+	return AdminThemeFormData{
+		AdminData:   h.adminData(r, "themes"),
+		Theme:       t,
+		BaseVars:    themeVarFields(base, t.Vars),
+		AccentVars:  themeVarFields(accent, t.Vars),
+		Fonts:       fontOptions(),
+		ThemeNames:  themeNames,
+		AccentNames: accentNames,
+		StartTheme:  startTheme,
+		StartAccent: startAccent,
+	}
+}
+
+// AdminThemes lists the custom themes.
+func (h *Handlers) AdminThemes(w http.ResponseWriter, r *http.Request) {
+	customThemes, err := h.DB.ListCustomThemes(r.Context())
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("AdminThemes", "error", err)
+		return
+	}
+	settings, _ := h.DB.GetSiteSettings(r.Context())
+
+	data := AdminThemesData{
+		AdminData: h.adminData(r, "themes"),
+		Themes:    customThemes,
+		Current:   settings.Theme,
+	}
+
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "admin-themes.html", data); err != nil {
+		slog.Error("AdminThemes template", "error", err)
+	}
+}
+
+// AdminNewThemeForm renders the theme builder for a new theme, filled in
+// from the built-in theme and accent color given in the query.
+func (h *Handlers) AdminNewThemeForm(w http.ResponseWriter, r *http.Request) {
+	startTheme := r.URL.Query().Get("theme")
+	if _, ok := themes[startTheme]; !ok {
+		startTheme = "midnight"
+	}
+	startAccent := r.URL.Query().Get("accent")
+	if !ValidAccent(startAccent) {
+		startAccent = "blue"
+	}
+
+	data := h.newThemeFormData(r, db.CustomTheme{Font: fontNames[0]}, startTheme, startAccent)
+	data.IsNew = true
+
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "admin-theme-form.html", data); err != nil {
+		slog.Error("AdminNewThemeForm template", "error", err)
+	}
+}
+
+// parseThemeForm reads and validates the theme builder form, returning a
+// user-facing error message if it is invalid. Empty variables are left out
+// so that they fall back to the defaults.
+func (h *Handlers) parseThemeForm(r *http.Request, id string) (db.CustomTheme, string) {
+	t := db.CustomTheme{
+		ID:    id,
+		Name:  strings.ToLower(strings.TrimSpace(r.FormValue("name"))),
+		Label: strings.TrimSpace(r.FormValue("label")),
+		Vars:  map[string]string{},
+		Font:  r.FormValue("font"),
+	}
+	msg := ""
+	if !ValidFont(t.Font) {
+		t.Font = fontNames[0]
+	}
+	base, accent := ThemeVarsFor("midnight", "blue")
+	for _, v := range append(base, accent...) {
+		val := strings.TrimSpace(r.FormValue("var-" + v.Name))
+		if val == "" {
+			continue
+		}
+		t.Vars[v.Name] = val
+		if msg == "" && !ValidThemeValue(val) {
+			msg = h.t(r.Context(), "--%s must be a color such as #1a1d2e or rgba(255,255,255,0.1)", v.Name)
+		}
+	}
+
+	switch {
+	case !spaceNamePattern.MatchString(t.Name):
+		msg = "Name must start with a letter or digit and contain only lowercase letters, digits and hyphens"
+	case ValidTheme(t.Name):
+		msg = h.t(r.Context(), `"%s" is a built-in theme`, t.Name)
+	case t.Label == "":
+		msg = "Label is required"
+	}
+	if msg == "" {
+		if other, err := h.DB.GetCustomThemeByName(r.Context(), t.Name); err == nil && other.ID != id {
+			msg = "A theme with this name already exists"
+		}
+	}
+	return t, msg
+}
+
+// renderThemeFormError shows the submitted theme builder form again with
+// an error message.
+func (h *Handlers) renderThemeFormError(w http.ResponseWriter, r *http.Request, t db.CustomTheme, msg string) {
+	data := h.newThemeFormData(r, t, "midnight", "blue")
+	data.IsNew = t.ID == ""
+	data.Error = msg
+	w.WriteHeader(http.StatusBadRequest)
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "admin-theme-form.html", data); err != nil {
+		slog.Error("renderThemeFormError template", "error", err)
+	}
+}
+
+// AdminCreateTheme handles the new theme form submission.
+func (h *Handlers) AdminCreateTheme(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	t, msg := h.parseThemeForm(r, "")
+	if msg != "" {
+		h.renderThemeFormError(w, r, t, msg)
+		return
+	}
+
+	if _, err := h.DB.CreateCustomTheme(r.Context(), t.Name, t.Label, t.Vars, t.Font, userID(r.Context())); err != nil {
+		h.serverError(w, r)
+		slog.Error("AdminCreateTheme", "error", err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/themes", http.StatusSeeOther)
+}
+
+// AdminEditThemeForm renders the theme builder for an existing theme.
+func (h *Handlers) AdminEditThemeForm(w http.ResponseWriter, r *http.Request) {
+	t, err := h.DB.GetCustomTheme(r.Context(), r.PathValue("id"))
+	if err != nil {
+		h.notFound(w, r)
+		return
+	}
+
+	data := h.newThemeFormData(r, t, "midnight", "blue")
+
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "admin-theme-form.html", data); err != nil {
+		slog.Error("AdminEditThemeForm template", "error", err)
+	}
+}
+
+// AdminUpdateTheme handles the edit theme form submission.
+func (h *Handlers) AdminUpdateTheme(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := h.DB.GetCustomTheme(r.Context(), id); err != nil {
+		h.notFound(w, r)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	t, msg := h.parseThemeForm(r, id)
+	if msg != "" {
+		h.renderThemeFormError(w, r, t, msg)
+		return
+	}
+
+	if _, err := h.DB.UpdateCustomTheme(r.Context(), id, t.Name, t.Label, t.Vars, t.Font, userID(r.Context())); err != nil {
+		h.serverError(w, r)
+		slog.Error("AdminUpdateTheme", "error", err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/themes", http.StatusSeeOther)
+}
+
+// AdminDeleteTheme deletes a custom theme. A site using it falls back to
+// midnight.
+func (h *Handlers) AdminDeleteTheme(w http.ResponseWriter, r *http.Request) {
+	if err := h.DB.DeleteCustomTheme(r.Context(), r.PathValue("id")); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			h.notFound(w, r)
+			return
+		}
+		h.serverError(w, r)
+		slog.Error("AdminDeleteTheme", "error", err)
+		return
 	}
 
-	css := fmt.Sprintf(`<style>
-  :root {
-    --bg-body: %s;
-    --bg-sidebar: %s;
-    --bg-content: %s;
-    --bg-code: %s;
-    --bg-card: %s;
-    --bg-card-hover: %s;
-    --text-primary: %s;
-    --text-secondary: %s;
-    --text-muted: %s;
-    --border-glass: %s;
-    --border-glass-hover: %s;
-    --table-stripe: %s;
-    --accent-1: %s;
-    --accent-2: %s;
-    --accent-3: %s;
-    --accent-dim: %s;
-    --glow-purple: %s;
-    --glow-blue: %s;
-    --btn-gradient-end: %s;
-    --accent-heading-tint: %s;
-    --accent-badge-bg: %s;
-    --accent-badge-border: %s;
-    --accent-active-bg: %s;
-    --accent-hover-bg: %s;
-    --accent-focus-shadow: %s;
-    --accent-card-grad1: %s;
-    --accent-card-grad2: %s;
-    --accent-card-border: %s;
-    --accent-card-overlay1: %s;
-    --accent-card-overlay2: %s;
-    --accent-btn-shadow: %s;
-    --accent-add-card-hover: %s;
-    --accent-add-card-shadow: %s;
-    --accent-icon-grad2-bg: %s;
-    --accent-icon-grad2-border: %s;
-    --accent-icon-grad3-bg: %s;
-    --accent-icon-grad3-border: %s;
-    --accent-icon-grad-mix: %s;
-    --accent-edit-hover-bg: %s;
-    --accent-version-border: %s;
-    --accent-icon-box-shadow: %s;
-    --accent-table-head-bg: %s;
-    --accent-table-hover-bg: %s;
-    --accent-blockquote-bg: %s;
-    --accent-copy-hover-border: %s;
-    --heading-gradient-start: %s;
-    --text-code: %s;
-    --glass-white-06: %s;
-    --glass-white-03: %s;
-    --glass-white-04: %s;
-    --glass-white-05: %s;
-    --glass-white-10: %s;
-    --glass-white-12: %s;
-    --input-bg: %s;
-    --input-bg-focus: %s;
-    --hover-bg: %s;
-  }
-</style>`,
-		t.BgBody, t.BgSidebar, t.BgContent, t.BgCode, t.BgCard, t.BgCardHover,
-		t.TextPrimary, t.TextSecondary, t.TextMuted,
-		t.BorderGlass, t.BorderGlassHover, t.TableStripe,
-		a.Accent1, a.Accent2, a.Accent3, a.AccentDim,
-		a.GlowPurple, a.GlowBlue, a.BtnGradEnd, a.HeadingTint,
-		a.BadgeBg, a.BadgeBorder, a.ActiveBg, a.HoverBg, a.FocusShadow,
-		a.CardGrad1, a.CardGrad2, a.CardBorder,
-		a.CardOverlay1, a.CardOverlay2,
-		a.BtnShadow, a.AddCardHover, a.AddCardShadow,
-		a.IconGrad2Bg, a.IconGrad2Bdr, a.IconGrad3Bg, a.IconGrad3Bdr,
-		a.IconGradMix, a.EditHoverBg, a.VersionBdr, a.IconBoxShadow,
-		a.TableHeadBg, a.TableHoverBg, a.BlockquoteBg, a.CopyHoverBdr,
-		t.HeadingStart, t.TextCode,
-		t.GlassWhite06, t.GlassWhite03, t.GlassWhite04, t.GlassWhite05,
-		t.GlassWhite10, t.GlassWhite12,
-		t.InputBg, t.InputBgFocus, t.HoverBg,
-	)
-	return template.HTML(css)
+	http.Redirect(w, r, "/admin/themes", http.StatusSeeOther)
 }
@@ -0,0 +1,322 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+
+	"docgen/internal/db"
+)
+
+// localeCookieName holds the language a reader picked with the language
+// switcher. It takes precedence over the Accept-Language header.
+const localeCookieName = "locale"
+
+// LocaleLink is an entry of the language switcher on pages.
+type LocaleLink struct {
+	Code     string
+	Name     string
+	IsActive bool
+}
+
+// TranslationStatus describes a page's translation into one locale, for
+// editors.
+type TranslationStatus struct {
+	Locale   string
+	Name     string
+	Exists   bool
+	Outdated bool
+}
+
+type TranslationFormData struct {
+	AdminData
+	Section    db.Section
+	Page       db.Page
+	Locale     string
+	LocaleName string
+	Title      string
+	ContentMD  string
+	Exists     bool
+	Outdated   bool
+	// SourceVersion is the page version the translation was made from.
+	SourceVersion int
+	Error         string
+}
+
+// localeName returns the name of a locale in its own language, e.g.
+// "Deutsch" for "de".
+func localeName(code string) string {
+	tag, err := language.Parse(code)
+	if err != nil {
+		return code
+	}
+	if name := display.Self.Name(tag); name != "" {
+		return name
+	}
+	return code
+}
+
+// parseLocales parses a comma- or space-separated list of language tags into
+// their canonical form, leaving out duplicates and the default locale.
+func parseLocales(s, defaultLocale string) ([]string, error) {
+	locales := []string{}
+	fields := strings.FieldsFunc(s, func(r rune) bool { return r == ',' || unicode.IsSpace(r) })
+	for _, f := range fields {
+		tag, err := language.Parse(f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid language %q", f)
+		}
+		code := tag.String()
+		if code != defaultLocale && !slices.Contains(locales, code) {
+			locales = append(locales, code)
+		}
+	}
+	return locales, nil
+}
+
+// requestLocale picks the locale to show a reader: the one chosen with the
+// language switcher, else the best match for the Accept-Language header,
+// else the site's default locale.
+func requestLocale(r *http.Request, settings db.SiteSettings) string {
+	if len(settings.Locales) == 0 {
+		return settings.DefaultLocale
+	}
+	available := settings.AllLocales()
+	if c, err := r.Cookie(localeCookieName); err == nil && slices.Contains(available, c.Value) {
+		return c.Value
+	}
+
+	desired, _, err := language.ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+	if err != nil || len(desired) == 0 {
+		return settings.DefaultLocale
+	}
+	tags := make([]language.Tag, len(available))
+	for i, l := range available {
+		tags[i] = language.Make(l)
+	}
+	_, index, confidence := language.NewMatcher(tags).Match(desired...)
+	if confidence == language.No {
+		return settings.DefaultLocale
+	}
+	return available[index]
+}
+
+// localeLinks returns the language switcher entries, or nil when the site
+// has a single language.
+func localeLinks(settings db.SiteSettings, current string) []LocaleLink {
+	if len(settings.Locales) == 0 {
+		return nil
+	}
+	var links []LocaleLink
+	for _, l := range settings.AllLocales() {
+		links = append(links, LocaleLink{Code: l, Name: localeName(l), IsActive: l == current})
+	}
+	return links
+}
+
+// translatePage looks up the translation of a page into locale and puts the
+// translated titles into pages, the section's page list. It returns nil when
+// locale is the default one or the page has no translation into it.
+func (h *Handlers) translatePage(ctx context.Context, settings db.SiteSettings, locale string, page db.Page, pages []db.Page) *db.PageTranslation {
+	if locale == settings.DefaultLocale {
+		return nil
+	}
+
+	titles, err := h.DB.ListTranslatedTitles(ctx, page.SectionID, locale)
+	if err != nil {
+		slog.Error("translatePage titles", "error", err)
+	}
+	for i, p := range pages {
+		if title, ok := titles[p.ID]; ok {
+			pages[i].Title = title
+		}
+	}
+
+	t, err := h.DB.GetPageTranslation(ctx, page.ID, locale)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			slog.Error("translatePage", "error", err)
+		}
+		return nil
+	}
+	return &t
+}
+
+// translationStatuses lists the state of a page's translations into each of
+// the site's other locales.
+func (h *Handlers) translationStatuses(ctx context.Context, settings db.SiteSettings, page db.Page) []TranslationStatus {
+	if len(settings.Locales) == 0 {
+		return nil
+	}
+	translations, err := h.DB.ListPageTranslations(ctx, page.ID)
+	if err != nil {
+		slog.Error("translationStatuses", "error", err)
+	}
+	var statuses []TranslationStatus
+	for _, l := range settings.Locales {
+		t, ok := translations[l]
+		statuses = append(statuses, TranslationStatus{
+			Locale:   l,
+			Name:     localeName(l),
+			Exists:   ok,
+			Outdated: ok && t.Outdated(page.Version),
+		})
+	}
+	return statuses
+}
+
+// SetLocale stores the language picked with the language switcher and
+// returns to the page it was picked on. A locale the site doesn't offer
+// clears the choice, so the Accept-Language header applies again.
+func (h *Handlers) SetLocale(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	settings, _ := h.DB.GetSiteSettings(r.Context())
+	cookie := &http.Cookie{
+		Name:     localeCookieName,
+		Value:    r.FormValue("locale"),
+		Path:     "/",
+		MaxAge:   365 * 24 * 60 * 60,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if !slices.Contains(settings.AllLocales(), cookie.Value) {
+		cookie.Value = ""
+		cookie.MaxAge = -1
+	}
+	http.SetCookie(w, cookie)
+
+	next := r.FormValue("next")
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		next = "/"
+	}
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+// loadTranslationPage resolves the section, page and locale of a translation
+// request, writing an error response when they don't exist.
+func (h *Handlers) loadTranslationPage(w http.ResponseWriter, r *http.Request, locale string) (db.Section, db.Page, bool) {
+	section, err := h.DB.GetSectionByName(r.Context(), r.PathValue("section"))
+	if err != nil {
+		h.notFound(w, r)
+		return db.Section{}, db.Page{}, false
+	}
+	page, err := h.DB.GetPage(r.Context(), section.ID, r.PathValue("slug"), true)
+	if err != nil {
+		h.notFound(w, r)
+		return db.Section{}, db.Page{}, false
+	}
+	settings, _ := h.DB.GetSiteSettings(r.Context())
+	if !slices.Contains(settings.Locales, locale) {
+		h.notFound(w, r)
+		return db.Section{}, db.Page{}, false
+	}
+	return section, page, true
+}
+
+// EditTranslation shows the form for translating a page into the locale in
+// the query string, next to the page's published content.
+func (h *Handlers) EditTranslation(w http.ResponseWriter, r *http.Request) {
+	locale := r.URL.Query().Get("locale")
+	section, page, ok := h.loadTranslationPage(w, r, locale)
+	if !ok {
+		return
+	}
+
+	data := TranslationFormData{
+		AdminData:  h.adminData(r, ""),
+		Section:    section,
+		Page:       page,
+		Locale:     locale,
+		LocaleName: localeName(locale),
+		Title:      page.Title,
+		Error:      r.URL.Query().Get("error"),
+	}
+	t, err := h.DB.GetPageTranslation(r.Context(), page.ID, locale)
+	switch {
+	case err == nil:
+		data.Title, data.ContentMD = t.Title, t.ContentMD
+		data.Exists = true
+		data.Outdated = t.Outdated(page.Version)
+		data.SourceVersion = t.SourceVersion
+	case !errors.Is(err, pgx.ErrNoRows):
+		h.serverError(w, r)
+		slog.Error("EditTranslation", "error", err)
+		return
+	}
+
+	settings, _ := h.DB.GetSiteSettings(r.Context())
+	data.NavItems = []AdminNavItem{{Title: "Edit page", Path: fmt.Sprintf("/%s/%s/edit", section.Name, page.Slug)}}
+	for _, l := range settings.Locales {
+		data.NavItems = append(data.NavItems, AdminNavItem{
+			Title:    localeName(l),
+			Path:     fmt.Sprintf("/%s/%s/translate?locale=%s", section.Name, page.Slug, url.QueryEscape(l)),
+			IsActive: l == locale,
+		})
+	}
+
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "translation-form.html", data); err != nil {
+		slog.Error("EditTranslation template", "error", err)
+	}
+}
+
+// SaveTranslation handles the translation form submission. The translation
+// is marked as made from the page's current version.
+func (h *Handlers) SaveTranslation(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+	locale := r.FormValue("locale")
+	section, page, ok := h.loadTranslationPage(w, r, locale)
+	if !ok {
+		return
+	}
+	formPath := fmt.Sprintf("/%s/%s/translate?locale=%s", section.Name, page.Slug, url.QueryEscape(locale))
+
+	title := r.FormValue("title")
+	contentMD := r.FormValue("content_md")
+	if title == "" || contentMD == "" {
+		http.Redirect(w, r, formPath+"&error="+url.QueryEscape("Title and content are required"), http.StatusSeeOther)
+		return
+	}
+
+	if err := h.DB.SavePageTranslation(r.Context(), page.ID, locale, title, contentMD, page.Version, userID(r.Context())); err != nil {
+		h.serverError(w, r)
+		slog.Error("SaveTranslation", "error", err)
+		return
+	}
+	http.Redirect(w, r, formPath, http.StatusSeeOther)
+}
+
+func (h *Handlers) DeleteTranslation(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+	locale := r.FormValue("locale")
+	section, page, ok := h.loadTranslationPage(w, r, locale)
+	if !ok {
+		return
+	}
+
+	if err := h.DB.DeletePageTranslation(r.Context(), page.ID, locale); err != nil {
+		h.serverError(w, r)
+		slog.Error("DeleteTranslation", "error", err)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/%s/%s/edit", section.Name, page.Slug), http.StatusSeeOther)
+}
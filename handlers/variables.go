@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+
+	"docgen/internal/db"
+)
+
+type AdminVariablesData struct {
+	AdminData
+	Variables []db.SiteVariable
+}
+
+type AdminVariableFormData struct {
+	AdminData
+	Variable  db.SiteVariable
+	SectionID string
+	Sections  []db.Section
+	IsNew     bool
+	Error     string
+}
+
+var validVariableKey = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// AdminVariables lists all site variables and their section overrides.
+func (h *Handlers) AdminVariables(w http.ResponseWriter, r *http.Request) {
+	vars, err := h.DB.ListSiteVariables(r.Context())
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("AdminVariables", "error", err)
+		return
+	}
+
+	data := AdminVariablesData{
+		AdminData: h.adminData(r, "variables"),
+		Variables: vars,
+	}
+
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "admin-variables.html", data); err != nil {
+		slog.Error("AdminVariables template", "error", err)
+	}
+}
+
+// AdminNewVariableForm renders the create variable form. The key and
+// section_id query parameters prefill it, e.g. to add a section override.
+func (h *Handlers) AdminNewVariableForm(w http.ResponseWriter, r *http.Request) {
+	sections, err := h.DB.ListSections(r.Context(), true)
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("AdminNewVariableForm", "error", err)
+		return
+	}
+
+	data := AdminVariableFormData{
+		AdminData: h.adminData(r, "variables"),
+		Variable:  db.SiteVariable{Key: r.URL.Query().Get("key")},
+		SectionID: r.URL.Query().Get("section_id"),
+		Sections:  sections,
+		IsNew:     true,
+		Error:     r.URL.Query().Get("error"),
+	}
+
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "admin-variable-form.html", data); err != nil {
+		slog.Error("AdminNewVariableForm template", "error", err)
+	}
+}
+
+// AdminCreateVariable handles the create variable form submission.
+func (h *Handlers) AdminCreateVariable(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	key := r.FormValue("key")
+	value := r.FormValue("value")
+	sectionIDStr := r.FormValue("section_id")
+
+	var sectionID *string
+	if sectionIDStr != "" {
+		sectionID = &sectionIDStr
+	}
+
+	if msg := h.checkVariableKey(r.Context(), key, sectionID, ""); msg != "" {
+		q := url.Values{"error": {msg}, "key": {key}, "section_id": {sectionIDStr}}
+		http.Redirect(w, r, "/admin/variables/new?"+q.Encode(), http.StatusSeeOther)
+		return
+	}
+
+	if err := h.DB.CreateSiteVariable(r.Context(), key, value, sectionID, userID(r.Context())); err != nil {
+		h.serverError(w, r)
+		slog.Error("AdminCreateVariable", "error", err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/variables", http.StatusSeeOther)
+}
+
+// AdminEditVariableForm renders the edit variable form.
+func (h *Handlers) AdminEditVariableForm(w http.ResponseWriter, r *http.Request) {
+	v, err := h.DB.GetSiteVariable(r.Context(), r.PathValue("id"))
+	if err != nil {
+		h.notFound(w, r)
+		return
+	}
+
+	sections, err := h.DB.ListSections(r.Context(), true)
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("AdminEditVariableForm", "error", err)
+		return
+	}
+
+	data := AdminVariableFormData{
+		AdminData: h.adminData(r, "variables"),
+		Variable:  v,
+		Sections:  sections,
+		Error:     r.URL.Query().Get("error"),
+	}
+	if v.SectionID != nil {
+		data.SectionID = *v.SectionID
+	}
+
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "admin-variable-form.html", data); err != nil {
+		slog.Error("AdminEditVariableForm template", "error", err)
+	}
+}
+
+// AdminUpdateVariable handles the edit variable form submission.
+func (h *Handlers) AdminUpdateVariable(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	key := r.FormValue("key")
+	value := r.FormValue("value")
+	sectionIDStr := r.FormValue("section_id")
+
+	var sectionID *string
+	if sectionIDStr != "" {
+		sectionID = &sectionIDStr
+	}
+
+	if msg := h.checkVariableKey(r.Context(), key, sectionID, id); msg != "" {
+		http.Redirect(w, r, "/admin/variables/"+id+"/edit?error="+url.QueryEscape(msg), http.StatusSeeOther)
+		return
+	}
+
+	if err := h.DB.UpdateSiteVariable(r.Context(), id, key, value, sectionID, userID(r.Context())); err != nil {
+		h.serverError(w, r)
+		slog.Error("AdminUpdateVariable", "error", err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/variables", http.StatusSeeOther)
+}
+
+// AdminDeleteVariable removes a variable or section override.
+func (h *Handlers) AdminDeleteVariable(w http.ResponseWriter, r *http.Request) {
+	if err := h.DB.DeleteSiteVariable(r.Context(), r.PathValue("id")); err != nil {
+		h.serverError(w, r)
+		slog.Error("AdminDeleteVariable", "error", err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/variables", http.StatusSeeOther)
+}
+
+// checkVariableKey validates a variable key and returns a user-facing error
+// message, or "" if the key is usable in the given scope. selfID is the
+// variable being edited.
+func (h *Handlers) checkVariableKey(ctx context.Context, key string, sectionID *string, selfID string) string {
+	if !validVariableKey.MatchString(key) {
+		return "Key must use letters, digits and underscores"
+	}
+	exists, err := h.DB.SiteVariableExists(ctx, key, sectionID, selfID)
+	if err != nil {
+		slog.Error("checkVariableKey", "error", err)
+	}
+	if exists {
+		if sectionID != nil {
+			return h.t(ctx, "This section already overrides \"%s\"", key)
+		}
+		return h.t(ctx, "A global variable \"%s\" already exists", key)
+	}
+	return ""
+}
@@ -0,0 +1,434 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"docgen/internal/db"
+)
+
+// latestVersion is the version name that stands for the latest
+// documentation, i.e. the pages outside of any version.
+const latestVersion = "latest"
+
+// validVersionName keeps version names apart from the first path segment of
+// the other routes: "v2", "v2.0" and "2024.1" are valid.
+var validVersionName = regexp.MustCompile(`^v?[0-9][0-9A-Za-z._-]*$`)
+
+// reservedSlugs are the actions that follow a page in routes such as
+// /{section}/{slug}/edit. Versioned pages live at /{version}/{section}/{slug},
+// so a page with one of these slugs would be unreachable in every version:
+// its path would open the action of another page instead.
+var reservedSlugs = map[string]bool{
+	"edit":               true,
+	"preview":            true,
+	"delete":             true,
+	"discard-draft":      true,
+	"comments":           true,
+	"feedback":           true,
+	"subscribe":          true,
+	"unsubscribe":        true,
+	"translate":          true,
+	"delete-translation": true,
+}
+
+// VersionLink is an entry of the version switcher on pages.
+type VersionLink struct {
+	Name     string
+	Path     string
+	Frozen   bool
+	IsActive bool
+	IsLatest bool
+}
+
+type AdminVersionsData struct {
+	AdminData
+	Versions []VersionView
+	Sections []db.Section
+	Error    string
+}
+
+// VersionView is a version with the titles of its sections.
+type VersionView struct {
+	db.DocVersion
+	SectionTitles []string
+}
+
+type VersionPageFormData struct {
+	AdminData
+	Version db.DocVersion
+	Section db.Section
+	Page    db.Page
+	Error   string
+}
+
+// versionLinks returns the version switcher entries for a page: the latest
+// documentation and each version the section was branched into. An entry
+// links to the same page where the version has it and to the section's first
+// page otherwise. current is the version shown, empty for the latest one.
+func (h *Handlers) versionLinks(ctx context.Context, section db.Section, slug, current string) []VersionLink {
+	versions, err := h.DB.ListSectionVersions(ctx, section.ID, slug)
+	if err != nil {
+		slog.Error("versionLinks", "error", err)
+		return nil
+	}
+	if len(versions) == 0 {
+		return nil
+	}
+
+	latest := VersionLink{Name: "Latest", Path: "/" + section.Name + "/", IsActive: current == "", IsLatest: true}
+	if current == "" {
+		latest.Path += slug
+	} else if _, err := h.DB.GetPage(ctx, section.ID, slug, h.showDrafts(ctx)); err == nil {
+		latest.Path += slug
+	}
+	links := []VersionLink{latest}
+	for _, v := range versions {
+		link := VersionLink{
+			Name:     v.Name,
+			Path:     "/" + v.Name + "/" + section.Name + "/",
+			Frozen:   v.Frozen,
+			IsActive: v.Name == current,
+		}
+		if v.HasPage {
+			link.Path += slug
+		}
+		links = append(links, link)
+	}
+	return links
+}
+
+// loadVersionSection looks up the version and section named in the path and
+// checks that the user may see the section. Paths under the "latest" alias
+// are redirected to the latest documentation. It writes the response itself
+// and returns ok false when the request is handled.
+func (h *Handlers) loadVersionSection(w http.ResponseWriter, r *http.Request) (version db.DocVersion, section db.Section, ok bool) {
+	name := r.PathValue("version")
+	if name == latestVersion {
+		target := strings.TrimPrefix(r.URL.Path, "/"+latestVersion)
+		if r.URL.RawQuery != "" {
+			target += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, target, http.StatusFound)
+		return version, section, false
+	}
+
+	version, err := h.DB.GetDocVersionByName(r.Context(), name)
+	if err != nil {
+		h.notFound(w, r)
+		return version, section, false
+	}
+
+	section, err = h.DB.GetSectionByName(r.Context(), r.PathValue("section"))
+	if err != nil || !h.sectionVisible(r.Context(), section) {
+		h.notFound(w, r)
+		return version, section, false
+	}
+
+	if !h.canAccessSection(r.Context(), section.RequiredRole) {
+		h.forbidden(w, r)
+		return version, section, false
+	}
+	return version, section, true
+}
+
+// VersionSection redirects to the first page of a section in a version.
+func (h *Handlers) VersionSection(w http.ResponseWriter, r *http.Request) {
+	version, section, ok := h.loadVersionSection(w, r)
+	if !ok {
+		return
+	}
+
+	pages, err := h.DB.ListVersionPages(r.Context(), version.ID, section.ID)
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("VersionSection", "error", err)
+		return
+	}
+	tree := buildPageTree(pages, "")
+	if len(tree) == 0 {
+		h.notFound(w, r)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/%s/%s/%s", version.Name, section.Name, tree[0].Slug), http.StatusFound)
+}
+
+// VersionPage shows a page as it is in a version.
+func (h *Handlers) VersionPage(w http.ResponseWriter, r *http.Request) {
+	version, section, ok := h.loadVersionSection(w, r)
+	if !ok {
+		return
+	}
+	slug := r.PathValue("slug")
+
+	page, err := h.DB.GetVersionPage(r.Context(), version.ID, section.ID, slug)
+	if err != nil {
+		h.notFound(w, r)
+		return
+	}
+
+	allPages, err := h.DB.ListVersionPages(r.Context(), version.ID, section.ID)
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("VersionPage", "error", err)
+		return
+	}
+
+	content, err := h.renderMarkdown(r.Context(), section.ID, page.ContentMD)
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("VersionPage render", "error", err)
+		return
+	}
+
+	siteTitle, badge, themeCSS := h.siteSettings(r.Context())
+	previewing := inPreviewMode(r.Context())
+	var previewRolesStr string
+	if previewing {
+		previewRolesStr = strings.Join(PreviewRolesFromContext(r.Context()), ", ")
+		if previewRolesStr == "" {
+			previewRolesStr = "(no custom roles)"
+		}
+	}
+	navPages := buildPageTree(allPages, slug)
+	data := SiteData{
+		SiteTitle: siteTitle,
+		Badge:     badge,
+		ThemeCSS:  themeCSS,
+		Pages:     navPages,
+		Current: TemplatePage{
+			Title:   page.Title,
+			Slug:    page.Slug,
+			Content: content,
+		},
+		Section: TemplateSection{
+			ID:       section.ID,
+			Name:     section.Name,
+			Title:    section.Title,
+			BasePath: "/" + version.Name + "/" + section.Name + "/",
+		},
+		HomePath:      "/",
+		UserFirstname: userFirstname(r.Context()),
+		IsEditor:      h.isEditor(r.Context()),
+		PreviewMode:   previewing,
+		PreviewRoles:  previewRolesStr,
+		DocVersion:    version.Name,
+		VersionFrozen: version.Frozen,
+		Versions:      h.versionLinks(r.Context(), section, slug, version.Name),
+		Nav:           pageNav(navPages, slug),
+	}
+	settings, _ := h.DB.GetSiteSettings(r.Context())
+	data.NavLinks = settings.NavLinks
+
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "page.html", data); err != nil {
+		slog.Error("VersionPage template", "error", err)
+	}
+}
+
+// EditVersionPage renders the form for changing a page of a version.
+func (h *Handlers) EditVersionPage(w http.ResponseWriter, r *http.Request) {
+	version, section, ok := h.loadVersionSection(w, r)
+	if !ok {
+		return
+	}
+	if version.Frozen {
+		h.forbidden(w, r)
+		return
+	}
+
+	page, err := h.DB.GetVersionPage(r.Context(), version.ID, section.ID, r.PathValue("slug"))
+	if err != nil {
+		h.notFound(w, r)
+		return
+	}
+
+	pages, err := h.DB.ListVersionPages(r.Context(), version.ID, section.ID)
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("EditVersionPage", "error", err)
+		return
+	}
+
+	data := VersionPageFormData{
+		AdminData: h.adminData(r, ""),
+		Version:   version,
+		Section:   section,
+		Page:      page,
+		Error:     r.URL.Query().Get("error"),
+	}
+	data.NavItems = nil
+	for _, p := range pages {
+		data.NavItems = append(data.NavItems, AdminNavItem{
+			Title:    p.Title,
+			Path:     fmt.Sprintf("/%s/%s/%s/edit", version.Name, section.Name, p.Slug),
+			IsActive: p.Slug == page.Slug,
+		})
+	}
+
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "version-page-form.html", data); err != nil {
+		slog.Error("EditVersionPage template", "error", err)
+	}
+}
+
+// SaveVersionPage handles the version page form submission.
+func (h *Handlers) SaveVersionPage(w http.ResponseWriter, r *http.Request) {
+	version, section, ok := h.loadVersionSection(w, r)
+	if !ok {
+		return
+	}
+	slug := r.PathValue("slug")
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	title := r.FormValue("title")
+	contentMD := r.FormValue("content_md")
+	if title == "" || contentMD == "" {
+		msg := "Title and content are required"
+		http.Redirect(w, r, fmt.Sprintf("/%s/%s/%s/edit?error=%s", version.Name, section.Name, slug, url.QueryEscape(msg)), http.StatusSeeOther)
+		return
+	}
+
+	page, err := h.DB.GetVersionPage(r.Context(), version.ID, section.ID, slug)
+	if err != nil {
+		h.notFound(w, r)
+		return
+	}
+
+	updated, err := h.DB.UpdateVersionPage(r.Context(), page.ID, title, contentMD, userID(r.Context()))
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("SaveVersionPage", "error", err)
+		return
+	}
+	if !updated {
+		// The version was frozen in the meantime.
+		h.forbidden(w, r)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/%s/%s/%s", version.Name, section.Name, slug), http.StatusSeeOther)
+}
+
+// AdminVersions lists the documentation versions.
+func (h *Handlers) AdminVersions(w http.ResponseWriter, r *http.Request) {
+	versions, err := h.DB.ListDocVersions(r.Context())
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("AdminVersions", "error", err)
+		return
+	}
+	sections, err := h.DB.ListSections(r.Context(), true)
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("AdminVersions sections", "error", err)
+		return
+	}
+
+	data := AdminVersionsData{
+		AdminData: h.adminData(r, "versions"),
+		Sections:  sections,
+		Error:     r.URL.Query().Get("error"),
+	}
+	for _, v := range versions {
+		view := VersionView{DocVersion: v}
+		for _, s := range sections {
+			if v.HasSection(s.ID) {
+				view.SectionTitles = append(view.SectionTitles, s.Title)
+			}
+		}
+		data.Versions = append(data.Versions, view)
+	}
+
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "admin-versions.html", data); err != nil {
+		slog.Error("AdminVersions template", "error", err)
+	}
+}
+
+// AdminBranchVersion copies the live pages of a section, or of all sections,
+// into a named version.
+func (h *Handlers) AdminBranchVersion(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	sectionID := r.FormValue("section_id")
+
+	fail := func(msg string) {
+		http.Redirect(w, r, "/admin/versions?error="+url.QueryEscape(msg), http.StatusSeeOther)
+	}
+
+	if !validVersionName.MatchString(name) {
+		fail(`Version names start with a digit or "v" and a digit, e.g. "v2.0", and may contain letters, digits, ".", "_" and "-"`)
+		return
+	}
+	if _, err := h.DB.GetSectionByName(r.Context(), name); err == nil {
+		fail(h.t(r.Context(), `"%s" is the name of a section`, name))
+		return
+	}
+
+	sections, err := h.DB.ListSections(r.Context(), true)
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("AdminBranchVersion sections", "error", err)
+		return
+	}
+	var ids []string
+	for _, s := range sections {
+		if sectionID == "" || s.ID == sectionID {
+			ids = append(ids, s.ID)
+		}
+	}
+	if len(ids) == 0 {
+		fail("Unknown section")
+		return
+	}
+
+	_, err = h.DB.BranchDocVersion(r.Context(), name, ids, userID(r.Context()))
+	switch {
+	case errors.Is(err, db.ErrVersionFrozen):
+		fail(h.t(r.Context(), "Version %s is frozen", name))
+		return
+	case errors.Is(err, db.ErrNothingToBranch):
+		fail(h.t(r.Context(), "Nothing to branch: the sections have no published pages or are already in version %s", name))
+		return
+	case err != nil:
+		h.serverError(w, r)
+		slog.Error("AdminBranchVersion", "error", err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/versions", http.StatusSeeOther)
+}
+
+// AdminFreezeVersion freezes a version, making it read-only, or thaws it.
+func (h *Handlers) AdminFreezeVersion(w http.ResponseWriter, r *http.Request) {
+	frozen := r.FormValue("frozen") == "true"
+	if err := h.DB.SetDocVersionFrozen(r.Context(), r.PathValue("id"), frozen); err != nil {
+		h.serverError(w, r)
+		slog.Error("AdminFreezeVersion", "error", err)
+		return
+	}
+	http.Redirect(w, r, "/admin/versions", http.StatusSeeOther)
+}
+
+// AdminDeleteVersion removes a version and its pages.
+func (h *Handlers) AdminDeleteVersion(w http.ResponseWriter, r *http.Request) {
+	if err := h.DB.DeleteDocVersion(r.Context(), r.PathValue("id")); err != nil {
+		h.serverError(w, r)
+		slog.Error("AdminDeleteVersion", "error", err)
+		return
+	}
+	http.Redirect(w, r, "/admin/versions", http.StatusSeeOther)
+}
@@ -0,0 +1,549 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"docgen/internal/db"
+	"docgen/internal/webhook"
+)
+
+const (
+	webhookBatchSize   = 10
+	webhookLease       = 5 * time.Minute
+	webhookMaxAttempts = 10
+	webhookRetention   = 30 * 24 * time.Hour
+)
+
+// webhookEvents are the events a webhook can subscribe to, grouped by
+// resource. "<resource>.*" subscribes to all events of a resource.
+var webhookEvents = []WebhookEventGroup{
+	{Resource: "page", Events: []string{"page.created", "page.updated", "page.published", "page.unpublished", "page.deleted"}},
+	{Resource: "section", Events: []string{"section.created", "section.updated", "section.deleted"}},
+	{Resource: "image", Events: []string{"image.created", "image.updated", "image.deleted"}},
+	{Resource: "import", Events: []string{"import.completed"}},
+	{Resource: "user", Events: []string{"user.created"}},
+}
+
+type WebhookEventGroup struct {
+	Resource string
+	Events   []string
+}
+
+func validWebhookEvent(event string) bool {
+	for _, g := range webhookEvents {
+		if event == g.Resource+".*" || slices.Contains(g.Events, event) {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookPayload is the JSON body of every delivery. Data depends on the
+// event.
+type WebhookPayload struct {
+	Event     string        `json:"event"`
+	CreatedAt time.Time     `json:"created_at"`
+	Site      string        `json:"site"`
+	Actor     *WebhookActor `json:"actor,omitempty"`
+	Data      any           `json:"data"`
+}
+
+type WebhookActor struct {
+	ID    string `json:"id"`
+	Email string `json:"email"`
+	Name  string `json:"name"`
+}
+
+type webhookPage struct {
+	ID        string `json:"id"`
+	Section   string `json:"section"`
+	Slug      string `json:"slug"`
+	Title     string `json:"title"`
+	Version   int    `json:"version,omitempty"`
+	Published bool   `json:"published"`
+	ContentMD string `json:"content_md,omitempty"`
+	URL       string `json:"url"`
+}
+
+type webhookSection struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	Title        string `json:"title"`
+	Description  string `json:"description,omitempty"`
+	RequiredRole string `json:"required_role,omitempty"`
+	Version      int    `json:"version,omitempty"`
+	URL          string `json:"url"`
+}
+
+type webhookImage struct {
+	Filename         string `json:"filename"`
+	PreviousFilename string `json:"previous_filename,omitempty"`
+	ContentType      string `json:"content_type,omitempty"`
+	Size             int    `json:"size,omitempty"`
+	Version          int    `json:"version,omitempty"`
+	URL              string `json:"url"`
+}
+
+func pageEvent(ctx context.Context, section db.Section, p db.Page) webhookPage {
+	return webhookPage{
+		ID:        p.ID,
+		Section:   section.Name,
+		Slug:      p.Slug,
+		Title:     p.Title,
+		Version:   p.Version,
+		Published: p.Published,
+		ContentMD: p.ContentMD,
+		URL:       siteURL(ctx) + "/" + section.Name + "/" + p.Slug,
+	}
+}
+
+func sectionEvent(ctx context.Context, s db.Section) webhookSection {
+	return webhookSection{
+		ID:           s.ID,
+		Name:         s.Name,
+		Title:        s.Title,
+		Description:  s.Description,
+		RequiredRole: s.RequiredRole,
+		Version:      s.Version,
+		URL:          siteURL(ctx) + "/" + s.Name + "/",
+	}
+}
+
+func imageEvent(ctx context.Context, img db.Image) webhookImage {
+	return webhookImage{
+		Filename:    img.Filename,
+		ContentType: img.ContentType,
+		Size:        len(img.Data),
+		Version:     img.Version,
+		URL:         imageURL(ctx, img.Filename),
+	}
+}
+
+func imageURL(ctx context.Context, filename string) string {
+	return siteURL(ctx) + "/images/" + url.PathEscape(filename)
+}
+
+// emitPageVisibility sends page.published when a change made a page visible
+// to readers and page.unpublished when it hid it, like the scheduler does
+// when a scheduled time passes.
+func (h *Handlers) emitPageVisibility(ctx context.Context, section db.Section, before, after db.Page) {
+	now := time.Now()
+	switch was, is := before.Live(now), after.Live(now); {
+	case is && !was:
+		h.emitEvent(ctx, "page.published", pageEvent(ctx, section, after))
+	case was && !is:
+		h.emitEvent(ctx, "page.unpublished", pageEvent(ctx, section, after))
+	}
+}
+
+// emitEvent queues an event for the webhooks subscribed to it. Failures are
+// logged; the change it reports has already been saved.
+func (h *Handlers) emitEvent(ctx context.Context, event string, data any) {
+	payload := WebhookPayload{
+		Event:     event,
+		CreatedAt: time.Now().UTC(),
+		Site:      siteURL(ctx),
+		Data:      data,
+	}
+	if u := UserFromContext(ctx); u != nil {
+		payload.Actor = &WebhookActor{ID: u.ID, Email: u.Email, Name: u.Firstname + " " + u.Lastname}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		slog.Error("emitEvent marshal", "event", event, "error", err)
+		return
+	}
+	n, err := h.DB.EnqueueWebhookEvent(ctx, event, string(body))
+	if err != nil {
+		slog.Error("emitEvent", "event", event, "error", err)
+		return
+	}
+	if n > 0 {
+		h.Webhooks.Notify()
+	}
+}
+
+// WebhookSender sends queued webhook deliveries from Run. The queue lives in
+// the database, so deliveries survive restarts and several replicas can send
+// from it at once. Failed deliveries are retried with exponential backoff
+// until webhookMaxAttempts is reached.
+type WebhookSender struct {
+	DB       *db.Queries
+	Client   *http.Client
+	Interval time.Duration
+	wake     chan struct{}
+}
+
+// NewWebhookSender returns a sender that polls the queue every 15 seconds,
+// or sooner when notified of a new event.
+func NewWebhookSender(q *db.Queries) *WebhookSender {
+	return &WebhookSender{
+		DB:       q,
+		Client:   &http.Client{Timeout: 10 * time.Second},
+		Interval: 15 * time.Second,
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Notify wakes the sender up to send newly queued deliveries.
+func (s *WebhookSender) Notify() {
+	if s == nil {
+		return
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run sends due deliveries until ctx is cancelled, and prunes the delivery
+// log once an hour.
+func (s *WebhookSender) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	var lastPrune time.Time
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.wake:
+		case <-ctx.Done():
+			return
+		}
+
+		s.sendDue(ctx)
+
+		if time.Since(lastPrune) > time.Hour {
+			lastPrune = time.Now()
+			if n, err := s.DB.PruneWebhookDeliveries(ctx, time.Now().Add(-webhookRetention)); err != nil {
+				slog.Error("webhook prune failed", "error", err)
+			} else if n > 0 {
+				slog.Info("webhook deliveries pruned", "count", n)
+			}
+		}
+	}
+}
+
+func (s *WebhookSender) sendDue(ctx context.Context) {
+	for {
+		deliveries, err := s.DB.ClaimWebhookDeliveries(ctx, webhookBatchSize, webhookLease)
+		if err != nil {
+			slog.Error("webhook claim failed", "error", err)
+			return
+		}
+		for _, d := range deliveries {
+			s.send(ctx, d)
+		}
+		if len(deliveries) < webhookBatchSize {
+			return
+		}
+	}
+}
+
+// send makes one delivery attempt and records its outcome. Any 2xx response
+// counts as delivered.
+func (s *WebhookSender) send(ctx context.Context, d db.WebhookDelivery) {
+	body := []byte(d.Payload)
+	var status *int
+	errMsg := ""
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(body))
+	if err != nil {
+		errMsg = err.Error()
+	} else {
+		ts := time.Now().Unix()
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "simple-doc-webhooks")
+		req.Header.Set(webhook.HeaderEvent, d.Event)
+		req.Header.Set(webhook.HeaderDelivery, d.ID)
+		req.Header.Set(webhook.HeaderTimestamp, fmt.Sprint(ts))
+		req.Header.Set(webhook.HeaderSignature, webhook.Sign(d.Secret, ts, body))
+
+		resp, err := s.Client.Do(req)
+		if err != nil {
+			errMsg = err.Error()
+		} else {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+			code := resp.StatusCode
+			status = &code
+			if code < 200 || code > 299 {
+				errMsg = resp.Status
+			}
+		}
+	}
+
+	state := db.DeliveryDelivered
+	next := time.Now()
+	if errMsg != "" {
+		attempts := d.Attempts + 1
+		if attempts >= webhookMaxAttempts {
+			state = db.DeliveryFailed
+			slog.Warn("webhook delivery failed", "delivery", d.ID, "url", d.URL, "attempts", attempts, "error", errMsg)
+		} else {
+			state = db.DeliveryPending
+			next = next.Add(webhookBackoff(attempts))
+		}
+	}
+
+	if err := s.DB.RecordWebhookAttempt(ctx, d.ID, state, status, errMsg, next); err != nil {
+		slog.Error("webhook record attempt", "delivery", d.ID, "error", err)
+	}
+}
+
+// webhookBackoff returns the wait before the retry following the given
+// failed attempt: 30s, 1m, 2m, ... capped at 6 hours.
+func webhookBackoff(attempt int) time.Duration {
+	return min(30*time.Second<<(attempt-1), 6*time.Hour)
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+type AdminWebhooksData struct {
+	AdminData
+	Webhooks []db.Webhook
+}
+
+type AdminWebhookFormData struct {
+	AdminData
+	Webhook     db.Webhook
+	EventGroups []WebhookEventGroup
+	Deliveries  []db.WebhookDelivery
+	IsNew       bool
+	Error       string
+	Notice      string
+}
+
+// AdminWebhooks lists the configured webhooks.
+func (h *Handlers) AdminWebhooks(w http.ResponseWriter, r *http.Request) {
+	hooks, err := h.DB.ListWebhooks(r.Context())
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("AdminWebhooks", "error", err)
+		return
+	}
+
+	data := AdminWebhooksData{
+		AdminData: h.adminData(r, "webhooks"),
+		Webhooks:  hooks,
+	}
+
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "admin-webhooks.html", data); err != nil {
+		slog.Error("AdminWebhooks template", "error", err)
+	}
+}
+
+// AdminNewWebhookForm renders the create webhook form.
+func (h *Handlers) AdminNewWebhookForm(w http.ResponseWriter, r *http.Request) {
+	data := AdminWebhookFormData{
+		AdminData:   h.adminData(r, "webhooks"),
+		Webhook:     db.Webhook{URL: r.URL.Query().Get("url"), Active: true},
+		EventGroups: webhookEvents,
+		IsNew:       true,
+		Error:       r.URL.Query().Get("error"),
+	}
+
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "admin-webhook-form.html", data); err != nil {
+		slog.Error("AdminNewWebhookForm template", "error", err)
+	}
+}
+
+// parseWebhookForm reads and validates the webhook form, returning a
+// user-facing error message if it is invalid.
+func parseWebhookForm(r *http.Request) (target, description string, events []string, active bool, msg string) {
+	target = strings.TrimSpace(r.FormValue("url"))
+	description = strings.TrimSpace(r.FormValue("description"))
+	active = r.FormValue("active") == "on"
+	for _, e := range r.Form["events"] {
+		if validWebhookEvent(e) {
+			events = append(events, e)
+		}
+	}
+
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return target, description, events, active, "URL must be an absolute http or https URL"
+	}
+	if len(events) == 0 {
+		return target, description, events, active, "Select at least one event"
+	}
+	return target, description, events, active, ""
+}
+
+// AdminCreateWebhook handles the create webhook form submission and shows
+// the new webhook with its generated secret.
+func (h *Handlers) AdminCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	target, description, events, active, msg := parseWebhookForm(r)
+	if msg != "" {
+		q := url.Values{"error": {msg}, "url": {target}}
+		http.Redirect(w, r, "/admin/webhooks/new?"+q.Encode(), http.StatusSeeOther)
+		return
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("AdminCreateWebhook secret", "error", err)
+		return
+	}
+
+	id, err := h.DB.CreateWebhook(r.Context(), target, description, secret, events, active)
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("AdminCreateWebhook", "error", err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/webhooks/"+id+"/edit", http.StatusSeeOther)
+}
+
+// AdminEditWebhookForm renders the edit webhook form with the webhook's
+// recent deliveries.
+func (h *Handlers) AdminEditWebhookForm(w http.ResponseWriter, r *http.Request) {
+	hook, err := h.DB.GetWebhook(r.Context(), r.PathValue("id"))
+	if err != nil {
+		h.notFound(w, r)
+		return
+	}
+
+	deliveries, err := h.DB.ListWebhookDeliveries(r.Context(), hook.ID, 50)
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("AdminEditWebhookForm deliveries", "error", err)
+		return
+	}
+
+	data := AdminWebhookFormData{
+		AdminData:   h.adminData(r, "webhooks"),
+		Webhook:     hook,
+		EventGroups: webhookEvents,
+		Deliveries:  deliveries,
+		Error:       r.URL.Query().Get("error"),
+		Notice:      r.URL.Query().Get("notice"),
+	}
+
+	if err := h.tmpl(r.Context()).ExecuteTemplate(w, "admin-webhook-form.html", data); err != nil {
+		slog.Error("AdminEditWebhookForm template", "error", err)
+	}
+}
+
+// AdminUpdateWebhook handles the edit webhook form submission.
+func (h *Handlers) AdminUpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	target, description, events, active, msg := parseWebhookForm(r)
+	if msg != "" {
+		http.Redirect(w, r, "/admin/webhooks/"+id+"/edit?error="+url.QueryEscape(msg), http.StatusSeeOther)
+		return
+	}
+
+	if err := h.DB.UpdateWebhook(r.Context(), id, target, description, events, active); err != nil {
+		h.serverError(w, r)
+		slog.Error("AdminUpdateWebhook", "error", err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/webhooks", http.StatusSeeOther)
+}
+
+// AdminRotateWebhookSecret replaces a webhook's signing secret. Queued
+// deliveries are signed with the new secret when they are sent.
+func (h *Handlers) AdminRotateWebhookSecret(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		h.serverError(w, r)
+		slog.Error("AdminRotateWebhookSecret", "error", err)
+		return
+	}
+	if err := h.DB.SetWebhookSecret(r.Context(), id, secret); err != nil {
+		h.serverError(w, r)
+		slog.Error("AdminRotateWebhookSecret", "error", err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/webhooks/"+id+"/edit?notice="+url.QueryEscape("A new secret was generated"), http.StatusSeeOther)
+}
+
+// AdminTestWebhook queues a "ping" delivery to a webhook, whatever events it
+// is subscribed to.
+func (h *Handlers) AdminTestWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, err := h.DB.GetWebhook(r.Context(), r.PathValue("id"))
+	if err != nil {
+		h.notFound(w, r)
+		return
+	}
+
+	payload := WebhookPayload{
+		Event:     "ping",
+		CreatedAt: time.Now().UTC(),
+		Site:      siteURL(r.Context()),
+		Data:      map[string]string{"webhook_id": hook.ID},
+	}
+	if u := UserFromContext(r.Context()); u != nil {
+		payload.Actor = &WebhookActor{ID: u.ID, Email: u.Email, Name: u.Firstname + " " + u.Lastname}
+	}
+	body, _ := json.Marshal(payload)
+
+	if err := h.DB.EnqueueWebhookDelivery(r.Context(), hook.ID, "ping", string(body)); err != nil {
+		h.serverError(w, r)
+		slog.Error("AdminTestWebhook", "error", err)
+		return
+	}
+	h.Webhooks.Notify()
+
+	http.Redirect(w, r, "/admin/webhooks/"+hook.ID+"/edit?notice="+url.QueryEscape("A test delivery was queued"), http.StatusSeeOther)
+}
+
+// AdminDeleteWebhook removes a webhook and its delivery log.
+func (h *Handlers) AdminDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if err := h.DB.DeleteWebhook(r.Context(), r.PathValue("id")); err != nil {
+		h.serverError(w, r)
+		slog.Error("AdminDeleteWebhook", "error", err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/webhooks", http.StatusSeeOther)
+}
+
+// AdminRetryWebhookDelivery queues a delivery to be sent again.
+func (h *Handlers) AdminRetryWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := h.DB.RetryWebhookDelivery(r.Context(), r.PathValue("id"))
+	if err != nil {
+		h.notFound(w, r)
+		return
+	}
+	h.Webhooks.Notify()
+
+	http.Redirect(w, r, "/admin/webhooks/"+webhookID+"/edit?notice="+url.QueryEscape("The delivery was queued again"), http.StatusSeeOther)
+}
@@ -0,0 +1,283 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ViewCount is a batch of views of one page or section by one role on one
+// day. Visitors holds the salted hashes of the users who viewed it.
+type ViewCount struct {
+	Day      time.Time
+	TargetID string
+	Role     string
+	Views    int
+	Visitors []string
+}
+
+type PageViewStats struct {
+	PageID       string
+	SectionName  string
+	SectionTitle string
+	Slug         string
+	Title        string
+	Views        int
+	Visitors     int
+}
+
+type SectionViewStats struct {
+	SectionName  string
+	SectionTitle string
+	PageViews    int
+	Visitors     int
+	LandingViews int
+}
+
+type DailyViews struct {
+	Day      time.Time
+	Views    int
+	Visitors int
+}
+
+type RoleViews struct {
+	Role  string
+	Views int
+}
+
+// --- Analytics queries ---
+
+// AnalyticsSalt returns the salt for hashing visitors on the given day,
+// creating it from candidate if the day has none yet.
+func (q *Queries) AnalyticsSalt(ctx context.Context, day time.Time, candidate string) (string, error) {
+	var salt string
+	err := q.Pool.QueryRow(ctx,
+		`WITH ins AS (
+		   INSERT INTO analytics_salts (day, salt) VALUES ($1, $2)
+		   ON CONFLICT (day) DO NOTHING
+		   RETURNING salt
+		 )
+		 SELECT salt FROM ins
+		 UNION ALL
+		 SELECT salt FROM analytics_salts WHERE day = $1
+		 LIMIT 1`, day, candidate).Scan(&salt)
+	return salt, err
+}
+
+// RecordAnalytics adds a batch of page views and section views to the daily
+// counts, and prunes visitor hashes and salts of past days.
+func (q *Queries) RecordAnalytics(ctx context.Context, pages, sections []ViewCount) error {
+	tx, err := q.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := recordViews(ctx, tx, "page_views", "page_id", "pages", pages); err != nil {
+		return err
+	}
+	if err := recordViews(ctx, tx, "section_views", "section_id", "sections", sections); err != nil {
+		return err
+	}
+
+	// Hashes are only needed to tell whether a visitor was already counted
+	// today; yesterday is kept for batches that straddle midnight.
+	if _, err := tx.Exec(ctx, `DELETE FROM view_visitors WHERE day < current_date - 1`); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM analytics_salts WHERE day < current_date - 1`); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// recordViews upserts view counts into table, counting as visitors only the
+// hashes not seen before on that day. Views of a target that has since been
+// deleted from parent are dropped.
+func recordViews(ctx context.Context, tx pgx.Tx, table, column, parent string, counts []ViewCount) error {
+	for _, c := range counts {
+		var visitors int
+		if err := tx.QueryRow(ctx,
+			`WITH ins AS (
+			   INSERT INTO view_visitors (day, target_id, role, visitor_hash)
+			   SELECT $1, $2, $3, unnest($4::text[])
+			   ON CONFLICT DO NOTHING
+			   RETURNING 1
+			 )
+			 SELECT count(*) FROM ins`,
+			c.Day, c.TargetID, c.Role, c.Visitors).Scan(&visitors); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO `+table+` (day, `+column+`, role, views, visitors)
+			 SELECT $1, $2, $3, $4, $5 WHERE EXISTS (SELECT 1 FROM `+parent+` WHERE id = $2)
+			 ON CONFLICT (day, `+column+`, role) DO UPDATE
+			 SET views = `+table+`.views + $4, visitors = `+table+`.visitors + $5`,
+			c.Day, c.TargetID, c.Role, c.Views, visitors); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// spacePages selects the ids of the pages in the space given as the second
+// query argument.
+const spacePages = `SELECT p.id FROM pages p JOIN sections s ON s.id = p.section_id WHERE s.space_id = $2`
+
+// ListTopPages returns the most viewed pages since the given day.
+func (q *Queries) ListTopPages(ctx context.Context, since time.Time, limit int) ([]PageViewStats, error) {
+	rows, err := q.Pool.Query(ctx,
+		`SELECT p.id, s.name, s.title, p.slug, p.title, sum(v.views), sum(v.visitors)
+		 FROM page_views v
+		 JOIN pages p ON p.id = v.page_id
+		 JOIN sections s ON s.id = p.section_id
+		 WHERE v.day >= $1 AND s.space_id = $3 AND p.deleted = false AND s.deleted = false
+		 GROUP BY p.id, s.id
+		 ORDER BY 6 DESC, s.title, p.title
+		 LIMIT $2`, since, limit, spaceID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []PageViewStats
+	for rows.Next() {
+		var s PageViewStats
+		if err := rows.Scan(&s.PageID, &s.SectionName, &s.SectionTitle, &s.Slug, &s.Title, &s.Views, &s.Visitors); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+// ListPopularPages returns the most viewed pages in the given sections
+// since the given day, for the home page. Unlike ListTopPages it leaves out
+// pages that are not live unless includeUnpublished is set.
+func (q *Queries) ListPopularPages(ctx context.Context, sectionIDs []string, includeUnpublished bool, since time.Time, limit int) ([]PageViewStats, error) {
+	rows, err := q.Pool.Query(ctx,
+		`SELECT p.id, s.name, s.title, p.slug, p.title, sum(v.views), sum(v.visitors)
+		 FROM page_views v
+		 JOIN (SELECT * FROM pages WHERE deleted = false AND (`+pageLive+` OR $2)) p ON p.id = v.page_id
+		 JOIN sections s ON s.id = p.section_id
+		 WHERE v.day >= $3 AND s.id = ANY($1)
+		 GROUP BY p.id, p.slug, p.title, s.id
+		 ORDER BY 6 DESC, s.title, p.title
+		 LIMIT $4`, sectionIDs, includeUnpublished, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []PageViewStats
+	for rows.Next() {
+		var s PageViewStats
+		if err := rows.Scan(&s.PageID, &s.SectionName, &s.SectionTitle, &s.Slug, &s.Title, &s.Views, &s.Visitors); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+// ListSectionViewStats returns the page views per section since the given
+// day, together with the visits to the section itself.
+func (q *Queries) ListSectionViewStats(ctx context.Context, since time.Time) ([]SectionViewStats, error) {
+	rows, err := q.Pool.Query(ctx,
+		`SELECT s.name, s.title,
+		        COALESCE((SELECT sum(v.views) FROM page_views v JOIN pages p ON p.id = v.page_id
+		                  WHERE p.section_id = s.id AND v.day >= $1), 0),
+		        COALESCE((SELECT sum(v.visitors) FROM page_views v JOIN pages p ON p.id = v.page_id
+		                  WHERE p.section_id = s.id AND v.day >= $1), 0),
+		        COALESCE((SELECT sum(v.views) FROM section_views v WHERE v.section_id = s.id AND v.day >= $1), 0)
+		 FROM sections s
+		 WHERE s.space_id = $2 AND s.deleted = false
+		 ORDER BY 3 DESC, s.sort_order`, since, spaceID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []SectionViewStats
+	for rows.Next() {
+		var s SectionViewStats
+		if err := rows.Scan(&s.SectionName, &s.SectionTitle, &s.PageViews, &s.Visitors, &s.LandingViews); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+// ListDailyViews returns the total page views per day since the given day.
+// Days without views are omitted.
+func (q *Queries) ListDailyViews(ctx context.Context, since time.Time) ([]DailyViews, error) {
+	rows, err := q.Pool.Query(ctx,
+		`SELECT day, sum(views), sum(visitors) FROM page_views
+		 WHERE day >= $1 AND page_id IN (`+spacePages+`)
+		 GROUP BY day ORDER BY day`, since, spaceID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var days []DailyViews
+	for rows.Next() {
+		var d DailyViews
+		if err := rows.Scan(&d.Day, &d.Views, &d.Visitors); err != nil {
+			return nil, err
+		}
+		days = append(days, d)
+	}
+	return days, rows.Err()
+}
+
+// ListRoleViews returns the page views per role since the given day.
+func (q *Queries) ListRoleViews(ctx context.Context, since time.Time) ([]RoleViews, error) {
+	rows, err := q.Pool.Query(ctx,
+		`SELECT role, sum(views) FROM page_views
+		 WHERE day >= $1 AND page_id IN (`+spacePages+`)
+		 GROUP BY role ORDER BY 2 DESC, role`, since, spaceID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []RoleViews
+	for rows.Next() {
+		var r RoleViews
+		if err := rows.Scan(&r.Role, &r.Views); err != nil {
+			return nil, err
+		}
+		roles = append(roles, r)
+	}
+	return roles, rows.Err()
+}
+
+// ListZeroViewPages returns the published pages that have not been viewed
+// since the given day.
+func (q *Queries) ListZeroViewPages(ctx context.Context, since time.Time) ([]PageViewStats, error) {
+	rows, err := q.Pool.Query(ctx,
+		`SELECT p.id, s.name, s.title, p.slug, p.title
+		 FROM (SELECT * FROM pages WHERE deleted = false AND `+pageLive+`) p
+		 JOIN sections s ON s.id = p.section_id
+		 WHERE s.space_id = $2 AND s.deleted = false
+		   AND NOT EXISTS (SELECT 1 FROM page_views v WHERE v.page_id = p.id AND v.day >= $1)
+		 ORDER BY s.sort_order, p.sort_order`, since, spaceID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pages []PageViewStats
+	for rows.Next() {
+		var s PageViewStats
+		if err := rows.Scan(&s.PageID, &s.SectionName, &s.SectionTitle, &s.Slug, &s.Title); err != nil {
+			return nil, err
+		}
+		pages = append(pages, s)
+	}
+	return pages, rows.Err()
+}
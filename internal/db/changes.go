@@ -0,0 +1,151 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// PageChange is a saved revision of a page, for the recent changes page and
+// feeds. Title is the title at that revision; Slug is the page's current
+// slug, for linking.
+type PageChange struct {
+	ID           string
+	PageID       string
+	SectionName  string
+	SectionTitle string
+	Slug         string
+	Title        string
+	Version      int
+	Summary      string
+	AuthorName   string
+	ChangedAt    time.Time
+}
+
+// SectionChange is a saved revision of a section's settings.
+type SectionChange struct {
+	Version    int
+	Title      string
+	Summary    string
+	AuthorName string
+	ChangedAt  time.Time
+}
+
+// --- Change queries ---
+
+// ListPageChanges returns the latest revisions of the pages in the given
+// sections, newest first, or only those of the page with the given slug
+// when slug is not empty. Unless includeUnpublished is set, only revisions
+// of live pages are listed.
+func (q *Queries) ListPageChanges(ctx context.Context, sectionIDs []string, slug string, includeUnpublished bool, limit int) ([]PageChange, error) {
+	rows, err := q.Pool.Query(ctx,
+		`SELECT h.id, h.page_id, s.name, s.title, p.slug, h.title, h.version, h.summary,
+		        COALESCE(u.firstname || ' ' || u.lastname, ''), h.changed_at
+		 FROM pages_history h
+		 JOIN (SELECT id, section_id, slug FROM pages
+		       WHERE deleted = false AND (`+pageLive+` OR $2)) p ON p.id = h.page_id
+		 JOIN sections s ON s.id = p.section_id
+		 LEFT JOIN users u ON u.id = h.changed_by
+		 WHERE s.id = ANY($1) AND ($4 = '' OR p.slug = $4)
+		 ORDER BY h.changed_at DESC
+		 LIMIT $3`, sectionIDs, includeUnpublished, limit, slug)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []PageChange
+	for rows.Next() {
+		var c PageChange
+		if err := rows.Scan(&c.ID, &c.PageID, &c.SectionName, &c.SectionTitle, &c.Slug, &c.Title, &c.Version, &c.Summary,
+			&c.AuthorName, &c.ChangedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// ListRecentlyUpdatedPages returns the latest revision of each of the most
+// recently changed pages in the given sections, newest first, with the
+// pages' current titles. Unless includeUnpublished is set, only live pages
+// are listed.
+func (q *Queries) ListRecentlyUpdatedPages(ctx context.Context, sectionIDs []string, includeUnpublished bool, limit int) ([]PageChange, error) {
+	rows, err := q.Pool.Query(ctx,
+		`SELECT * FROM (
+		   SELECT DISTINCT ON (h.page_id) h.id, h.page_id, s.name, s.title, p.slug, p.title, h.version, h.summary,
+		          COALESCE(u.firstname || ' ' || u.lastname, ''), h.changed_at
+		   FROM pages_history h
+		   JOIN (SELECT id, section_id, slug, title FROM pages
+		         WHERE deleted = false AND (`+pageLive+` OR $2)) p ON p.id = h.page_id
+		   JOIN sections s ON s.id = p.section_id
+		   LEFT JOIN users u ON u.id = h.changed_by
+		   WHERE s.id = ANY($1)
+		   ORDER BY h.page_id, h.changed_at DESC
+		 ) latest
+		 ORDER BY changed_at DESC
+		 LIMIT $3`, sectionIDs, includeUnpublished, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []PageChange
+	for rows.Next() {
+		var c PageChange
+		if err := rows.Scan(&c.ID, &c.PageID, &c.SectionName, &c.SectionTitle, &c.Slug, &c.Title, &c.Version, &c.Summary,
+			&c.AuthorName, &c.ChangedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// ListSectionHistory returns the latest revisions of a section, newest first.
+func (q *Queries) ListSectionHistory(ctx context.Context, sectionID string, limit int) ([]SectionChange, error) {
+	rows, err := q.Pool.Query(ctx,
+		`SELECT h.version, h.title, h.summary, COALESCE(u.firstname || ' ' || u.lastname, ''), h.changed_at
+		 FROM sections_history h
+		 LEFT JOIN users u ON u.id = h.changed_by
+		 WHERE h.section_id = $1
+		 ORDER BY h.version DESC
+		 LIMIT $2`, sectionID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []SectionChange
+	for rows.Next() {
+		var c SectionChange
+		if err := rows.Scan(&c.Version, &c.Title, &c.Summary, &c.AuthorName, &c.ChangedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+func (q *Queries) GetFeedToken(ctx context.Context, userID string) (string, error) {
+	var token string
+	err := q.Pool.QueryRow(ctx,
+		`SELECT feed_token::text FROM users WHERE id = $1`, userID).Scan(&token)
+	return token, err
+}
+
+// ResetFeedToken gives a user a new feed token, so that feed URLs handed out
+// before stop working.
+func (q *Queries) ResetFeedToken(ctx context.Context, userID string) error {
+	_, err := q.Pool.Exec(ctx,
+		`UPDATE users SET feed_token = gen_random_uuid(), updated_at = now() WHERE id = $1`, userID)
+	return err
+}
+
+func (q *Queries) GetUserByFeedToken(ctx context.Context, token string) (User, error) {
+	var u User
+	err := q.Pool.QueryRow(ctx,
+		`SELECT id, firstname, lastname, company, email, password, last_login, created_at, updated_at
+		 FROM users WHERE feed_token::text = $1`, token).
+		Scan(&u.ID, &u.Firstname, &u.Lastname, &u.Company, &u.Email, &u.Password, &u.LastLogin, &u.CreatedAt, &u.UpdatedAt)
+	return u, err
+}
@@ -0,0 +1,213 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CommentThread is a discussion on a page. AnchorText is the passage the
+// thread was started on; nil for threads about the whole page.
+type CommentThread struct {
+	ID           string
+	PageID       string
+	SectionID    string
+	SectionName  string
+	RequiredRole string
+	Slug         string
+	PageTitle    string
+	AnchorText   *string
+	Resolved     bool
+	ResolvedAt   *time.Time
+	ResolverName string
+	CreatedBy    string
+	CreatorName  string
+	CommentCount int
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+type PageComment struct {
+	ID          string
+	ThreadID    string
+	AuthorID    string
+	AuthorName  string
+	AuthorEmail string
+	Body        string
+	CreatedAt   time.Time
+}
+
+// --- Comment queries ---
+
+const commentThreadColumns = `t.id, t.page_id, p.section_id, s.name, COALESCE(s.required_role, ''), p.slug, p.title,
+	t.anchor_text, t.resolved, t.resolved_at, COALESCE(ru.firstname || ' ' || ru.lastname, ''),
+	COALESCE(t.created_by::text, ''), COALESCE(cu.firstname || ' ' || cu.lastname, ''),
+	(SELECT count(*) FROM page_comments c WHERE c.thread_id = t.id),
+	t.created_at, t.updated_at
+	FROM comment_threads t
+	JOIN pages p ON p.id = t.page_id
+	JOIN sections s ON s.id = p.section_id
+	LEFT JOIN users ru ON ru.id = t.resolved_by
+	LEFT JOIN users cu ON cu.id = t.created_by`
+
+func scanCommentThread(row pgx.Row, t *CommentThread) error {
+	return row.Scan(&t.ID, &t.PageID, &t.SectionID, &t.SectionName, &t.RequiredRole, &t.Slug, &t.PageTitle,
+		&t.AnchorText, &t.Resolved, &t.ResolvedAt, &t.ResolverName,
+		&t.CreatedBy, &t.CreatorName, &t.CommentCount, &t.CreatedAt, &t.UpdatedAt)
+}
+
+// CreateCommentThread starts a thread on a page with its first comment.
+func (q *Queries) CreateCommentThread(ctx context.Context, pageID string, anchorText *string, authorID, body string) (string, error) {
+	var id string
+	err := q.Pool.QueryRow(ctx,
+		`WITH thread AS (
+		   INSERT INTO comment_threads (page_id, anchor_text, created_by)
+		   VALUES ($1, $2, $3)
+		   RETURNING id
+		 )
+		 INSERT INTO page_comments (thread_id, author_id, body)
+		 SELECT id, $3, $4 FROM thread
+		 RETURNING thread_id`,
+		pageID, anchorText, authorID, body).Scan(&id)
+	return id, err
+}
+
+func (q *Queries) GetCommentThread(ctx context.Context, id string) (CommentThread, error) {
+	var t CommentThread
+	err := scanCommentThread(q.Pool.QueryRow(ctx,
+		`SELECT `+commentThreadColumns+` WHERE t.id = $1 AND s.space_id = $2 AND p.deleted = false AND s.deleted = false`, id, spaceID(ctx)), &t)
+	return t, err
+}
+
+// ListCommentThreads returns the threads on a page, open threads first.
+func (q *Queries) ListCommentThreads(ctx context.Context, pageID string) ([]CommentThread, error) {
+	rows, err := q.Pool.Query(ctx,
+		`SELECT `+commentThreadColumns+`
+		 WHERE t.page_id = $1
+		 ORDER BY t.resolved, t.created_at`, pageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var threads []CommentThread
+	for rows.Next() {
+		var t CommentThread
+		if err := scanCommentThread(rows, &t); err != nil {
+			return nil, err
+		}
+		threads = append(threads, t)
+	}
+	return threads, rows.Err()
+}
+
+// ListPageComments returns the comments of all threads on a page, oldest
+// first.
+func (q *Queries) ListPageComments(ctx context.Context, pageID string) ([]PageComment, error) {
+	rows, err := q.Pool.Query(ctx,
+		`SELECT c.id, c.thread_id, COALESCE(c.author_id::text, ''), COALESCE(u.firstname || ' ' || u.lastname, ''),
+		        COALESCE(u.email, ''), c.body, c.created_at
+		 FROM page_comments c
+		 JOIN comment_threads t ON t.id = c.thread_id
+		 LEFT JOIN users u ON u.id = c.author_id
+		 WHERE t.page_id = $1
+		 ORDER BY c.created_at`, pageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var comments []PageComment
+	for rows.Next() {
+		var c PageComment
+		if err := rows.Scan(&c.ID, &c.ThreadID, &c.AuthorID, &c.AuthorName, &c.AuthorEmail, &c.Body, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+	return comments, rows.Err()
+}
+
+// CreatePageComment adds a reply to a thread.
+func (q *Queries) CreatePageComment(ctx context.Context, threadID, authorID, body string) error {
+	tx, err := q.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO page_comments (thread_id, author_id, body) VALUES ($1, $2, $3)`,
+		threadID, authorID, body); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx,
+		`UPDATE comment_threads SET updated_at = now() WHERE id = $1`, threadID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// SetCommentThreadResolved resolves or reopens a thread.
+func (q *Queries) SetCommentThreadResolved(ctx context.Context, id string, resolved bool, changedBy string) error {
+	_, err := q.Pool.Exec(ctx,
+		`UPDATE comment_threads
+		 SET resolved = $2,
+		     resolved_by = CASE WHEN $2 THEN $3::uuid END,
+		     resolved_at = CASE WHEN $2 THEN now() END,
+		     updated_at = now()
+		 WHERE id = $1`, id, resolved, changedBy)
+	return err
+}
+
+// ListCommentThreadParticipants returns the users who started or replied to
+// a thread.
+func (q *Queries) ListCommentThreadParticipants(ctx context.Context, threadID string) ([]User, error) {
+	rows, err := q.Pool.Query(ctx,
+		`SELECT u.id, u.firstname, u.lastname, u.company, u.email, u.password, u.last_login, u.created_at, u.updated_at
+		 FROM users u
+		 WHERE u.id IN (
+		   SELECT created_by FROM comment_threads WHERE id = $1
+		   UNION
+		   SELECT author_id FROM page_comments WHERE thread_id = $1
+		 )
+		 ORDER BY u.firstname, u.lastname`, threadID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Firstname, &u.Lastname, &u.Company, &u.Email, &u.Password, &u.LastLogin, &u.CreatedAt, &u.UpdatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// GetUsersByEmail returns the members of the current space with the given
+// email addresses; unknown addresses are skipped.
+func (q *Queries) GetUsersByEmail(ctx context.Context, emails []string) ([]User, error) {
+	rows, err := q.Pool.Query(ctx,
+		`SELECT id, firstname, lastname, company, email, password, last_login, created_at, updated_at
+		 FROM users WHERE lower(email) = ANY($1)
+		 AND id IN (SELECT user_id FROM space_members WHERE space_id = $2)`, emails, spaceID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Firstname, &u.Lastname, &u.Company, &u.Email, &u.Password, &u.LastLogin, &u.CreatedAt, &u.UpdatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
@@ -2,8 +2,11 @@ package db
 
 import (
 	"context"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -17,8 +20,38 @@ type Section struct {
 	Version      int
 	RequiredRole string
 	RowID        *string
+	// RequiredApprovals is the number of reviewer approvals a page change
+	// needs before it can be published. 0 disables review.
+	RequiredApprovals int
+	PublishAt         *time.Time
+	UnpublishAt       *time.Time
+	// RequireSummary makes a change summary mandatory for changes to the
+	// section, its pages and its images.
+	RequireSummary bool
+	// LandingMD is the Markdown of the section's landing page. Sections
+	// without one open at their first page.
+	LandingMD string
+	// ShowPageMeta shows when and by whom each page was last updated at the
+	// end of the page.
+	ShowPageMeta bool
 }
 
+// Live reports whether the section's schedule makes it visible to readers
+// at the given time.
+func (s Section) Live(now time.Time) bool {
+	return scheduleLive(s.PublishAt, s.UnpublishAt, now)
+}
+
+// sectionColumns is the column list scanned by scanSection.
+const sectionColumns = `id, name, title, description, icon, sort_order, version, COALESCE(required_role, ''), row_id, required_approvals, publish_at, unpublish_at, require_summary, landing_md, show_page_meta`
+
+func scanSection(row pgx.Row, s *Section) error {
+	return row.Scan(&s.ID, &s.Name, &s.Title, &s.Description, &s.Icon, &s.SortOrder, &s.Version, &s.RequiredRole, &s.RowID, &s.RequiredApprovals, &s.PublishAt, &s.UnpublishAt, &s.RequireSummary, &s.LandingMD, &s.ShowPageMeta)
+}
+
+// sectionLive is the SQL counterpart of Section.Live.
+const sectionLive = `(publish_at IS NULL OR publish_at <= now()) AND (unpublish_at IS NULL OR unpublish_at > now())`
+
 type SectionRow struct {
 	ID          string
 	Title       string
@@ -28,14 +61,53 @@ type SectionRow struct {
 }
 
 type Page struct {
-	ID         string
-	SectionID  string
-	Slug       string
-	Title      string
-	ContentMD  string
-	SortOrder  int
-	Version    int
-	ParentSlug *string
+	ID             string
+	SectionID      string
+	Slug           string
+	Title          string
+	ContentMD      string
+	SortOrder      int
+	Version        int
+	ParentSlug     *string
+	Published      bool
+	DraftTitle     *string
+	DraftContentMD *string
+	PublishAt      *time.Time
+	UnpublishAt    *time.Time
+	UpdatedAt      time.Time
+	// ChangedBy is the ID of the user who last changed the page, nil for
+	// pages created by the seed.
+	ChangedBy *string
+}
+
+// HasDraft reports whether the page has staged changes that are not yet
+// published.
+func (p Page) HasDraft() bool {
+	return p.DraftContentMD != nil
+}
+
+// Live reports whether the page is visible to readers at the given time:
+// published, or scheduled to publish, and within its schedule.
+func (p Page) Live(now time.Time) bool {
+	return (p.Published || p.PublishAt != nil) && scheduleLive(p.PublishAt, p.UnpublishAt, now)
+}
+
+// pageColumns is the column list scanned by scanPage.
+const pageColumns = `id, section_id, slug, title, content_md, sort_order, version, parent_slug, published, draft_title, draft_content_md, publish_at, unpublish_at, updated_at, changed_by`
+
+func scanPage(row pgx.Row, p *Page) error {
+	return row.Scan(&p.ID, &p.SectionID, &p.Slug, &p.Title, &p.ContentMD, &p.SortOrder, &p.Version, &p.ParentSlug, &p.Published, &p.DraftTitle, &p.DraftContentMD, &p.PublishAt, &p.UnpublishAt, &p.UpdatedAt, &p.ChangedBy)
+}
+
+// pageLive is the SQL counterpart of Page.Live.
+const pageLive = `(published OR publish_at IS NOT NULL)
+	AND (publish_at IS NULL OR publish_at <= now()) AND (unpublish_at IS NULL OR unpublish_at > now())`
+
+func scheduleLive(publishAt, unpublishAt *time.Time, now time.Time) bool {
+	if publishAt != nil && publishAt.After(now) {
+		return false
+	}
+	return unpublishAt == nil || unpublishAt.After(now)
 }
 
 type PageOrderItem struct {
@@ -101,27 +173,83 @@ type Role struct {
 }
 
 type Session struct {
-	ID           string
-	UserID       string
-	Token        string
-	ExpiresAt    time.Time
-	CreatedAt    time.Time
-	PreviewRoles *string
+	ID            string
+	UserID        string
+	Token         string
+	ExpiresAt     time.Time
+	CreatedAt     time.Time
+	PreviewRoles  *string
+	PreviewDrafts bool
 }
 
 type SiteSettings struct {
-	SiteTitle          string
-	Badge              string
-	Heading            string
-	Description        string
-	Footer             string
-	Theme              string
-	AccentColor        string
+	SiteTitle   string
+	Badge       string
+	Heading     string
+	Description string
+	Footer      string
+	Theme       string
+	AccentColor string
+	// Font is the body font used with the built-in themes.
+	Font string
+	// DefaultLocale is the language pages are written in; Locales lists the
+	// other languages they may be translated into.
+	DefaultLocale string
+	Locales       []string
+	// UILanguage is the default language of the user interface.
+	UILanguage string
+	// CustomCSS is appended after the theme variables on every page.
+	CustomCSS string
+	SiteLayout
 	Version            int
 	FaviconContentType string
 	HasFavicon         bool
 }
 
+// NavLink is a link admins add to the header or to a footer column.
+type NavLink struct {
+	Label string `json:"label"`
+	URL   string `json:"url"`
+}
+
+// External reports whether the link leaves the site.
+func (l NavLink) External() bool {
+	return !strings.HasPrefix(l.URL, "/")
+}
+
+// FooterColumn is a titled column of links in the home page footer.
+type FooterColumn struct {
+	Title string    `json:"title"`
+	Links []NavLink `json:"links"`
+}
+
+// Home page blocks generated from the pages, enabled in
+// SiteLayout.HomeBlocks.
+const (
+	HomeBlockPopular = "popular"
+	HomeBlockRecent  = "recent"
+)
+
+// SiteLayout is the custom navigation and the optional home page blocks.
+type SiteLayout struct {
+	NavLinks      []NavLink
+	FooterColumns []FooterColumn
+	// HomeIntro is Markdown shown above the sections on the home page.
+	HomeIntro  string
+	HomeBlocks []string
+}
+
+// HasHomeBlock reports whether the given generated home page block is
+// enabled.
+func (l SiteLayout) HasHomeBlock(block string) bool {
+	return slices.Contains(l.HomeBlocks, block)
+}
+
+// AllLocales returns the default locale followed by the other locales.
+func (s SiteSettings) AllLocales() []string {
+	return append([]string{s.DefaultLocale}, s.Locales...)
+}
+
 type UserWithRoles struct {
 	User
 	Roles []string
@@ -147,9 +275,11 @@ type Queries struct {
 	Pool *pgxpool.Pool
 }
 
-func (q *Queries) ListSections(ctx context.Context) ([]Section, error) {
+// ListSections returns the sections in display order. Sections outside
+// their publishing schedule are only included when includeUnpublished is set.
+func (q *Queries) ListSections(ctx context.Context, includeUnpublished bool) ([]Section, error) {
 	rows, err := q.Pool.Query(ctx,
-		`SELECT id, name, title, description, icon, sort_order, version, COALESCE(required_role, ''), row_id FROM sections WHERE deleted = false ORDER BY sort_order`)
+		`SELECT `+sectionColumns+` FROM sections WHERE space_id = $2 AND deleted = false AND (`+sectionLive+` OR $1) ORDER BY sort_order`, includeUnpublished, spaceID(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -158,7 +288,7 @@ func (q *Queries) ListSections(ctx context.Context) ([]Section, error) {
 	var sections []Section
 	for rows.Next() {
 		var s Section
-		if err := rows.Scan(&s.ID, &s.Name, &s.Title, &s.Description, &s.Icon, &s.SortOrder, &s.Version, &s.RequiredRole, &s.RowID); err != nil {
+		if err := scanSection(rows, &s); err != nil {
 			return nil, err
 		}
 		sections = append(sections, s)
@@ -168,24 +298,25 @@ func (q *Queries) ListSections(ctx context.Context) ([]Section, error) {
 
 func (q *Queries) GetSection(ctx context.Context, id string) (Section, error) {
 	var s Section
-	err := q.Pool.QueryRow(ctx,
-		`SELECT id, name, title, description, icon, sort_order, version, COALESCE(required_role, ''), row_id FROM sections WHERE id = $1 AND deleted = false`, id).
-		Scan(&s.ID, &s.Name, &s.Title, &s.Description, &s.Icon, &s.SortOrder, &s.Version, &s.RequiredRole, &s.RowID)
+	err := scanSection(q.Pool.QueryRow(ctx,
+		`SELECT `+sectionColumns+` FROM sections WHERE id = $1 AND space_id = $2 AND deleted = false`, id, spaceID(ctx)), &s)
 	return s, err
 }
 
 func (q *Queries) GetSectionByName(ctx context.Context, name string) (Section, error) {
 	var s Section
-	err := q.Pool.QueryRow(ctx,
-		`SELECT id, name, title, description, icon, sort_order, version, COALESCE(required_role, ''), row_id FROM sections WHERE name = $1 AND deleted = false`, name).
-		Scan(&s.ID, &s.Name, &s.Title, &s.Description, &s.Icon, &s.SortOrder, &s.Version, &s.RequiredRole, &s.RowID)
+	err := scanSection(q.Pool.QueryRow(ctx,
+		`SELECT `+sectionColumns+` FROM sections WHERE name = $1 AND space_id = $2 AND deleted = false`, name, spaceID(ctx)), &s)
 	return s, err
 }
 
-func (q *Queries) ListPagesBySection(ctx context.Context, sectionID string) ([]Page, error) {
+// ListPagesBySection returns the pages of a section. Pages that are not live
+// (unpublished or outside their schedule) are only included when
+// includeUnpublished is set.
+func (q *Queries) ListPagesBySection(ctx context.Context, sectionID string, includeUnpublished bool) ([]Page, error) {
 	rows, err := q.Pool.Query(ctx,
-		`SELECT id, section_id, slug, title, content_md, sort_order, version, parent_slug
-		 FROM pages WHERE section_id = $1 AND deleted = false ORDER BY sort_order`, sectionID)
+		`SELECT `+pageColumns+`
+		 FROM pages WHERE section_id = $1 AND deleted = false AND (`+pageLive+` OR $2) ORDER BY sort_order`, sectionID, includeUnpublished)
 	if err != nil {
 		return nil, err
 	}
@@ -194,7 +325,7 @@ func (q *Queries) ListPagesBySection(ctx context.Context, sectionID string) ([]P
 	var pages []Page
 	for rows.Next() {
 		var p Page
-		if err := rows.Scan(&p.ID, &p.SectionID, &p.Slug, &p.Title, &p.ContentMD, &p.SortOrder, &p.Version, &p.ParentSlug); err != nil {
+		if err := scanPage(rows, &p); err != nil {
 			return nil, err
 		}
 		pages = append(pages, p)
@@ -202,29 +333,30 @@ func (q *Queries) ListPagesBySection(ctx context.Context, sectionID string) ([]P
 	return pages, rows.Err()
 }
 
-func (q *Queries) GetPage(ctx context.Context, sectionID, slug string) (Page, error) {
+func (q *Queries) GetPage(ctx context.Context, sectionID, slug string, includeUnpublished bool) (Page, error) {
 	var p Page
-	err := q.Pool.QueryRow(ctx,
-		`SELECT id, section_id, slug, title, content_md, sort_order, version, parent_slug
-		 FROM pages WHERE section_id = $1 AND slug = $2 AND deleted = false`, sectionID, slug).
-		Scan(&p.ID, &p.SectionID, &p.Slug, &p.Title, &p.ContentMD, &p.SortOrder, &p.Version, &p.ParentSlug)
+	err := scanPage(q.Pool.QueryRow(ctx,
+		`SELECT `+pageColumns+`
+		 FROM pages WHERE section_id = $1 AND slug = $2 AND deleted = false AND (`+pageLive+` OR $3)`, sectionID, slug, includeUnpublished), &p)
 	return p, err
 }
 
-func (q *Queries) GetFirstPage(ctx context.Context, sectionID string) (Page, error) {
+func (q *Queries) GetFirstPage(ctx context.Context, sectionID string, includeUnpublished bool) (Page, error) {
 	var p Page
-	err := q.Pool.QueryRow(ctx,
-		`SELECT id, section_id, slug, title, content_md, sort_order, version, parent_slug
-		 FROM pages WHERE section_id = $1 AND deleted = false AND parent_slug IS NULL ORDER BY sort_order LIMIT 1`, sectionID).
-		Scan(&p.ID, &p.SectionID, &p.Slug, &p.Title, &p.ContentMD, &p.SortOrder, &p.Version, &p.ParentSlug)
+	err := scanPage(q.Pool.QueryRow(ctx,
+		`SELECT `+pageColumns+`
+		 FROM pages WHERE section_id = $1 AND deleted = false AND (`+pageLive+` OR $2) AND parent_slug IS NULL ORDER BY sort_order LIMIT 1`, sectionID, includeUnpublished), &p)
 	return p, err
 }
 
+// GetImage returns the space's image with the given filename, falling back
+// to the generated images shared by all spaces.
 func (q *Queries) GetImage(ctx context.Context, filename string) (Image, error) {
 	var img Image
 	err := q.Pool.QueryRow(ctx,
 		`SELECT id, filename, content_type, data, COALESCE(section_id, ''), created_at, version
-		 FROM images WHERE filename = $1`, filename).
+		 FROM images WHERE filename = $1 AND (space_id = $2 OR space_id IS NULL)
+		 ORDER BY space_id NULLS LAST LIMIT 1`, filename, spaceID(ctx)).
 		Scan(&img.ID, &img.Filename, &img.ContentType, &img.Data, &img.SectionID, &img.CreatedAt, &img.Version)
 	return img, err
 }
@@ -252,7 +384,8 @@ func (q *Queries) ListImageMetasBySection(ctx context.Context, sectionID string)
 func (q *Queries) ListAllImageMetas(ctx context.Context) ([]ImageMetaWithSection, error) {
 	rows, err := q.Pool.Query(ctx,
 		`SELECT i.id, i.filename, i.content_type, length(i.data), COALESCE(i.section_id, ''), i.created_at, i.version, COALESCE(s.title, '')
-		 FROM images i LEFT JOIN sections s ON s.id = i.section_id ORDER BY i.filename`)
+		 FROM images i LEFT JOIN sections s ON s.id = i.section_id
+		 WHERE i.space_id = $1 ORDER BY i.filename`, spaceID(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -272,22 +405,43 @@ func (q *Queries) ListAllImageMetas(ctx context.Context) ([]ImageMetaWithSection
 func (q *Queries) CreateImage(ctx context.Context, filename, contentType string, data []byte, sectionID, changedBy string) (Image, error) {
 	var img Image
 	err := q.Pool.QueryRow(ctx,
-		`INSERT INTO images (filename, content_type, data, section_id, changed_by)
-		 VALUES ($1, $2, $3, $4, $5)
+		`INSERT INTO images (space_id, filename, content_type, data, section_id, changed_by)
+		 VALUES ($6, $1, $2, $3, $4, $5)
 		 RETURNING id, filename, content_type, data, COALESCE(section_id, ''), created_at, version`,
-		filename, contentType, data, sectionID, changedBy).
+		filename, contentType, data, sectionID, changedBy, spaceID(ctx)).
 		Scan(&img.ID, &img.Filename, &img.ContentType, &img.Data, &img.SectionID, &img.CreatedAt, &img.Version)
 	return img, err
 }
 
+// ImageExists reports whether a generated image with the given filename is
+// stored.
+func (q *Queries) ImageExists(ctx context.Context, filename string) (bool, error) {
+	var exists bool
+	err := q.Pool.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM images WHERE filename = $1 AND space_id IS NULL)`, filename).Scan(&exists)
+	return exists, err
+}
+
+// CreateGeneratedImage stores a server-generated image that is not owned by a
+// space, section or user. Existing images with the same filename are left
+// untouched.
+func (q *Queries) CreateGeneratedImage(ctx context.Context, filename, contentType string, data []byte) error {
+	_, err := q.Pool.Exec(ctx,
+		`INSERT INTO images (filename, content_type, data)
+		 VALUES ($1, $2, $3)
+		 ON CONFLICT (space_id, filename) DO NOTHING`,
+		filename, contentType, data)
+	return err
+}
+
 func (q *Queries) UpdateImage(ctx context.Context, filename, contentType string, data []byte, changedBy string) (Image, error) {
 	var img Image
 	err := q.Pool.QueryRow(ctx,
 		`UPDATE images
 		 SET content_type = $2, data = $3, version = version + 1, updated_at = now(), changed_by = $4
-		 WHERE filename = $1
+		 WHERE filename = $1 AND space_id = $5
 		 RETURNING id, filename, content_type, data, COALESCE(section_id, ''), created_at, version`,
-		filename, contentType, data, changedBy).
+		filename, contentType, data, changedBy, spaceID(ctx)).
 		Scan(&img.ID, &img.Filename, &img.ContentType, &img.Data, &img.SectionID, &img.CreatedAt, &img.Version)
 	return img, err
 }
@@ -297,100 +451,148 @@ func (q *Queries) RenameImage(ctx context.Context, oldFilename, newFilename, cha
 	err := q.Pool.QueryRow(ctx,
 		`UPDATE images
 		 SET filename = $2, version = version + 1, updated_at = now(), changed_by = $3
-		 WHERE filename = $1
+		 WHERE filename = $1 AND space_id = $4
 		 RETURNING id, filename, content_type, data, COALESCE(section_id, ''), created_at, version`,
-		oldFilename, newFilename, changedBy).
+		oldFilename, newFilename, changedBy, spaceID(ctx)).
 		Scan(&img.ID, &img.Filename, &img.ContentType, &img.Data, &img.SectionID, &img.CreatedAt, &img.Version)
 	return img, err
 }
 
 func (q *Queries) DeleteImage(ctx context.Context, filename string) error {
 	_, err := q.Pool.Exec(ctx,
-		`DELETE FROM images WHERE filename = $1`, filename)
+		`DELETE FROM images WHERE filename = $1 AND space_id = $2`, filename, spaceID(ctx))
 	return err
 }
 
-func (q *Queries) SaveImageHistory(ctx context.Context, img Image, changedBy string) error {
+// SaveImageHistory records a version of an image. summary is the editor's
+// optional description of the change.
+func (q *Queries) SaveImageHistory(ctx context.Context, img Image, changedBy, summary string) error {
 	_, err := q.Pool.Exec(ctx,
-		`INSERT INTO images_history (image_id, version, filename, content_type, data, created_at, changed_by)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
-		img.ID, img.Version, img.Filename, img.ContentType, img.Data, img.CreatedAt, changedBy)
+		`INSERT INTO images_history (image_id, version, filename, content_type, data, created_at, changed_by, summary)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		img.ID, img.Version, img.Filename, img.ContentType, img.Data, img.CreatedAt, changedBy, summary)
 	return err
 }
 
 func (q *Queries) UpdatePage(ctx context.Context, sectionID, slug, title, contentMD, changedBy string) (Page, error) {
 	var p Page
-	err := q.Pool.QueryRow(ctx,
+	err := scanPage(q.Pool.QueryRow(ctx,
 		`UPDATE pages
 		 SET title = $3, content_md = $4, version = version + 1, updated_at = now(), changed_by = $5
 		 WHERE section_id = $1 AND slug = $2
-		 RETURNING id, section_id, slug, title, content_md, sort_order, version, parent_slug`,
-		sectionID, slug, title, contentMD, changedBy).
-		Scan(&p.ID, &p.SectionID, &p.Slug, &p.Title, &p.ContentMD, &p.SortOrder, &p.Version, &p.ParentSlug)
+		 RETURNING `+pageColumns,
+		sectionID, slug, title, contentMD, changedBy), &p)
 	return p, err
 }
 
-func (q *Queries) CreatePage(ctx context.Context, sectionID, slug, title, contentMD string, sortOrder int, changedBy string) (Page, error) {
+// SavePageDraft stages changes to a published page without publishing them.
+// The page's updated_at and changed_by keep describing the published
+// revision.
+func (q *Queries) SavePageDraft(ctx context.Context, sectionID, slug, title, contentMD string) error {
+	_, err := q.Pool.Exec(ctx,
+		`UPDATE pages
+		 SET draft_title = $3, draft_content_md = $4, draft_updated_at = now()
+		 WHERE section_id = $1 AND slug = $2 AND deleted = false`,
+		sectionID, slug, title, contentMD)
+	return err
+}
+
+// PublishPage makes the given title and content the published revision and
+// clears any draft.
+func (q *Queries) PublishPage(ctx context.Context, sectionID, slug, title, contentMD, changedBy string) (Page, error) {
 	var p Page
-	err := q.Pool.QueryRow(ctx,
-		`INSERT INTO pages (section_id, slug, title, content_md, sort_order, changed_by)
-		 VALUES ($1, $2, $3, $4, $5, $6)
-		 RETURNING id, section_id, slug, title, content_md, sort_order, version, parent_slug`,
-		sectionID, slug, title, contentMD, sortOrder, changedBy).
-		Scan(&p.ID, &p.SectionID, &p.Slug, &p.Title, &p.ContentMD, &p.SortOrder, &p.Version, &p.ParentSlug)
+	err := scanPage(q.Pool.QueryRow(ctx,
+		`UPDATE pages
+		 SET title = $3, content_md = $4, published = true,
+		     draft_title = NULL, draft_content_md = NULL, draft_updated_at = NULL,
+		     version = version + 1, updated_at = now(), changed_by = $5
+		 WHERE section_id = $1 AND slug = $2
+		 RETURNING `+pageColumns,
+		sectionID, slug, title, contentMD, changedBy), &p)
 	return p, err
 }
 
-func (q *Queries) SavePageHistory(ctx context.Context, p Page, changedBy string) error {
+// SetPageSchedule sets or clears the times at which a page goes live and
+// is taken down again.
+func (q *Queries) SetPageSchedule(ctx context.Context, sectionID, slug string, publishAt, unpublishAt *time.Time) error {
 	_, err := q.Pool.Exec(ctx,
-		`INSERT INTO pages_history (page_id, version, section_id, slug, title, content_md, sort_order, changed_by)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
-		p.ID, p.Version, p.SectionID, p.Slug, p.Title, p.ContentMD, p.SortOrder, changedBy)
+		`UPDATE pages SET publish_at = $3, unpublish_at = $4
+		 WHERE section_id = $1 AND slug = $2 AND deleted = false`,
+		sectionID, slug, publishAt, unpublishAt)
+	return err
+}
+
+func (q *Queries) DiscardPageDraft(ctx context.Context, sectionID, slug string) error {
+	_, err := q.Pool.Exec(ctx,
+		`UPDATE pages SET draft_title = NULL, draft_content_md = NULL, draft_updated_at = NULL
+		 WHERE section_id = $1 AND slug = $2`,
+		sectionID, slug)
+	return err
+}
+
+func (q *Queries) CreatePage(ctx context.Context, sectionID, slug, title, contentMD string, sortOrder int, published bool, changedBy string) (Page, error) {
+	var p Page
+	err := scanPage(q.Pool.QueryRow(ctx,
+		`INSERT INTO pages (section_id, slug, title, content_md, sort_order, published, changed_by)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)
+		 RETURNING `+pageColumns,
+		sectionID, slug, title, contentMD, sortOrder, published, changedBy), &p)
+	return p, err
+}
+
+// SavePageHistory records a revision of a page. summary is the editor's
+// optional description of the change.
+func (q *Queries) SavePageHistory(ctx context.Context, p Page, changedBy, summary string) error {
+	_, err := q.Pool.Exec(ctx,
+		`INSERT INTO pages_history (page_id, version, section_id, slug, title, content_md, sort_order, changed_by, summary)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		p.ID, p.Version, p.SectionID, p.Slug, p.Title, p.ContentMD, p.SortOrder, changedBy, summary)
 	return err
 }
 
 func (q *Queries) CreateSection(ctx context.Context, name, title, description, icon string, sortOrder int, requiredRole, changedBy string, rowID *string) (Section, error) {
 	var s Section
 	// If a soft-deleted section with this name exists, reactivate it
-	err := q.Pool.QueryRow(ctx,
+	err := scanSection(q.Pool.QueryRow(ctx,
 		`UPDATE sections
 		 SET title = $2, description = $3, icon = $4, sort_order = $5, required_role = NULLIF($6, ''),
 		     changed_by = $7, row_id = $8, deleted = false, version = version + 1, updated_at = now()
-		 WHERE name = $1 AND deleted = true
-		 RETURNING id, name, title, description, icon, sort_order, version, COALESCE(required_role, ''), row_id`,
-		name, title, description, icon, sortOrder, requiredRole, changedBy, rowID).
-		Scan(&s.ID, &s.Name, &s.Title, &s.Description, &s.Icon, &s.SortOrder, &s.Version, &s.RequiredRole, &s.RowID)
+		 WHERE name = $1 AND space_id = $9 AND deleted = true
+		 RETURNING `+sectionColumns,
+		name, title, description, icon, sortOrder, requiredRole, changedBy, rowID, spaceID(ctx)), &s)
 	if err == nil {
 		return s, nil
 	}
 	// Otherwise insert fresh (id auto-generated)
-	err = q.Pool.QueryRow(ctx,
-		`INSERT INTO sections (name, title, description, icon, sort_order, required_role, changed_by, row_id)
-		 VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8)
-		 RETURNING id, name, title, description, icon, sort_order, version, COALESCE(required_role, ''), row_id`,
-		name, title, description, icon, sortOrder, requiredRole, changedBy, rowID).
-		Scan(&s.ID, &s.Name, &s.Title, &s.Description, &s.Icon, &s.SortOrder, &s.Version, &s.RequiredRole, &s.RowID)
+	err = scanSection(q.Pool.QueryRow(ctx,
+		`INSERT INTO sections (space_id, name, title, description, icon, sort_order, required_role, changed_by, row_id)
+		 VALUES ($9, $1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8)
+		 RETURNING `+sectionColumns,
+		name, title, description, icon, sortOrder, requiredRole, changedBy, rowID, spaceID(ctx)), &s)
 	return s, err
 }
 
-func (q *Queries) UpdateSection(ctx context.Context, id, title, description, icon, requiredRole, changedBy string) (Section, error) {
+func (q *Queries) UpdateSection(ctx context.Context, id, title, description, icon, requiredRole string, requiredApprovals int, publishAt, unpublishAt *time.Time, requireSummary bool, landingMD string, showPageMeta bool, changedBy string) (Section, error) {
 	var s Section
-	err := q.Pool.QueryRow(ctx,
+	err := scanSection(q.Pool.QueryRow(ctx,
 		`UPDATE sections
-		 SET title = $2, description = $3, icon = $4, required_role = NULLIF($5, ''),
+		 SET title = $2, description = $3, icon = $4, required_role = NULLIF($5, ''), required_approvals = $7,
+		     publish_at = $8, unpublish_at = $9, require_summary = $10, landing_md = $12,
+		     show_page_meta = $13,
 		     version = version + 1, updated_at = now(), changed_by = $6
-		 WHERE id = $1
-		 RETURNING id, name, title, description, icon, sort_order, version, COALESCE(required_role, ''), row_id`,
-		id, title, description, icon, requiredRole, changedBy).
-		Scan(&s.ID, &s.Name, &s.Title, &s.Description, &s.Icon, &s.SortOrder, &s.Version, &s.RequiredRole, &s.RowID)
+		 WHERE id = $1 AND space_id = $11
+		 RETURNING `+sectionColumns,
+		id, title, description, icon, requiredRole, changedBy, requiredApprovals, publishAt, unpublishAt, requireSummary, spaceID(ctx), landingMD, showPageMeta), &s)
 	return s, err
 }
 
-func (q *Queries) SaveSectionHistory(ctx context.Context, s Section, changedBy string) error {
+// SaveSectionHistory records a version of a section. summary is the
+// editor's optional description of the change.
+func (q *Queries) SaveSectionHistory(ctx context.Context, s Section, changedBy, summary string) error {
 	_, err := q.Pool.Exec(ctx,
-		`INSERT INTO sections_history (section_id, version, title, description, icon, sort_order, required_role, changed_by, row_id)
-		 VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, $9)`,
-		s.ID, s.Version, s.Title, s.Description, s.Icon, s.SortOrder, s.RequiredRole, changedBy, s.RowID)
+		`INSERT INTO sections_history (section_id, version, title, description, icon, sort_order, required_role, changed_by, row_id, summary, landing_md)
+		 VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, $9, $10, $11)`,
+		s.ID, s.Version, s.Title, s.Description, s.Icon, s.SortOrder, s.RequiredRole, changedBy, s.RowID, summary, s.LandingMD)
 	return err
 }
 
@@ -403,14 +605,14 @@ func (q *Queries) SoftDeleteSection(ctx context.Context, id, changedBy string) e
 
 	_, err = tx.Exec(ctx,
 		`UPDATE pages SET deleted = true, version = version + 1, updated_at = now(), changed_by = $2
-		 WHERE section_id = $1 AND deleted = false`, id, changedBy)
+		 WHERE section_id = (SELECT id FROM sections WHERE id = $1 AND space_id = $3) AND deleted = false`, id, changedBy, spaceID(ctx))
 	if err != nil {
 		return err
 	}
 
 	_, err = tx.Exec(ctx,
 		`UPDATE sections SET deleted = true, version = version + 1, updated_at = now(), changed_by = $2
-		 WHERE id = $1`, id, changedBy)
+		 WHERE id = $1 AND space_id = $3`, id, changedBy, spaceID(ctx))
 	if err != nil {
 		return err
 	}
@@ -428,21 +630,26 @@ func (q *Queries) SoftDeletePage(ctx context.Context, sectionID, slug, changedBy
 func (q *Queries) GetSiteSettings(ctx context.Context) (SiteSettings, error) {
 	var s SiteSettings
 	err := q.Pool.QueryRow(ctx,
-		`SELECT site_title, badge, heading, description, footer, theme, accent_color, version,
+		`SELECT site_title, badge, heading, description, footer, theme, accent_color, font, default_locale, locales, ui_language, custom_css,
+		        nav_links, footer_columns, home_intro, home_blocks, version,
 		        COALESCE(favicon_content_type, ''), favicon_data IS NOT NULL
-		 FROM site_settings WHERE singleton = TRUE`).
-		Scan(&s.SiteTitle, &s.Badge, &s.Heading, &s.Description, &s.Footer, &s.Theme, &s.AccentColor, &s.Version,
-			&s.FaviconContentType, &s.HasFavicon)
+		 FROM site_settings WHERE space_id = $1`, spaceID(ctx)).
+		Scan(&s.SiteTitle, &s.Badge, &s.Heading, &s.Description, &s.Footer, &s.Theme, &s.AccentColor, &s.Font,
+			&s.DefaultLocale, &s.Locales, &s.UILanguage, &s.CustomCSS,
+			&s.NavLinks, &s.FooterColumns, &s.HomeIntro, &s.HomeBlocks, &s.Version, &s.FaviconContentType, &s.HasFavicon)
 	if err != nil {
 		return SiteSettings{
-			SiteTitle:   "SolarFlux Documentation",
-			Badge:       "API Documentation",
-			Heading:     "SolarFlux API Docs",
-			Description: "Technical documentation for the SolarFlux space weather monitoring platform.",
-			Footer:      "SolarFlux Platform",
-			Theme:       "midnight",
-			AccentColor: "blue",
-			Version:     1,
+			SiteTitle:     "SolarFlux Documentation",
+			Badge:         "API Documentation",
+			Heading:       "SolarFlux API Docs",
+			Description:   "Technical documentation for the SolarFlux space weather monitoring platform.",
+			Footer:        "SolarFlux Platform",
+			Theme:         "midnight",
+			AccentColor:   "blue",
+			Font:          "inter",
+			DefaultLocale: "en",
+			UILanguage:    "en",
+			Version:       1,
 		}, nil
 	}
 	if s.Theme == "" {
@@ -454,25 +661,53 @@ func (q *Queries) GetSiteSettings(ctx context.Context) (SiteSettings, error) {
 	return s, nil
 }
 
-func (q *Queries) UpdateSiteSettings(ctx context.Context, siteTitle, badge, heading, description, footer, theme, accentColor, changedBy string) (SiteSettings, error) {
+func (q *Queries) UpdateSiteSettings(ctx context.Context, siteTitle, badge, heading, description, footer, theme, accentColor, font, defaultLocale string, locales []string, uiLanguage, customCSS string, layout SiteLayout, changedBy string) (SiteSettings, error) {
+	layout = layout.nonNil()
 	var s SiteSettings
 	err := q.Pool.QueryRow(ctx,
 		`UPDATE site_settings
 		 SET site_title = $1, badge = $2, heading = $3, description = $4, footer = $5,
-		     theme = $6, accent_color = $7, changed_by = $8,
+		     theme = $6, accent_color = $7, default_locale = $8, locales = $9, ui_language = $10, custom_css = $11,
+		     nav_links = $12, footer_columns = $13, home_intro = $14, home_blocks = $15, changed_by = $16, font = $18,
 		     version = version + 1, updated_at = now()
-		 WHERE singleton = TRUE
-		 RETURNING site_title, badge, heading, description, footer, theme, accent_color, version`,
-		siteTitle, badge, heading, description, footer, theme, accentColor, changedBy).
-		Scan(&s.SiteTitle, &s.Badge, &s.Heading, &s.Description, &s.Footer, &s.Theme, &s.AccentColor, &s.Version)
+		 WHERE space_id = $17
+		 RETURNING site_title, badge, heading, description, footer, theme, accent_color, font, default_locale, locales, ui_language, custom_css,
+		           nav_links, footer_columns, home_intro, home_blocks, version`,
+		siteTitle, badge, heading, description, footer, theme, accentColor, defaultLocale, locales, uiLanguage, customCSS,
+		layout.NavLinks, layout.FooterColumns, layout.HomeIntro, layout.HomeBlocks, changedBy, spaceID(ctx), font).
+		Scan(&s.SiteTitle, &s.Badge, &s.Heading, &s.Description, &s.Footer, &s.Theme, &s.AccentColor, &s.Font, &s.DefaultLocale, &s.Locales, &s.UILanguage, &s.CustomCSS,
+			&s.NavLinks, &s.FooterColumns, &s.HomeIntro, &s.HomeBlocks, &s.Version)
 	return s, err
 }
 
+// nonNil returns the layout with empty lists in place of nil ones, which
+// would be stored as NULL.
+func (l SiteLayout) nonNil() SiteLayout {
+	if l.NavLinks == nil {
+		l.NavLinks = []NavLink{}
+	}
+	if l.FooterColumns == nil {
+		l.FooterColumns = []FooterColumn{}
+	}
+	for i := range l.FooterColumns {
+		if l.FooterColumns[i].Links == nil {
+			l.FooterColumns[i].Links = []NavLink{}
+		}
+	}
+	if l.HomeBlocks == nil {
+		l.HomeBlocks = []string{}
+	}
+	return l
+}
+
 func (q *Queries) SaveSiteSettingsHistory(ctx context.Context, s SiteSettings, changedBy string) error {
+	layout := s.SiteLayout.nonNil()
 	_, err := q.Pool.Exec(ctx,
-		`INSERT INTO site_settings_history (version, site_title, badge, heading, description, footer, theme, accent_color, changed_by)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
-		s.Version, s.SiteTitle, s.Badge, s.Heading, s.Description, s.Footer, s.Theme, s.AccentColor, changedBy)
+		`INSERT INTO site_settings_history (space_id, version, site_title, badge, heading, description, footer, theme, accent_color, default_locale, locales, ui_language, custom_css,
+		                                    nav_links, footer_columns, home_intro, home_blocks, changed_by, font)
+		 VALUES ($18, $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $19)`,
+		s.Version, s.SiteTitle, s.Badge, s.Heading, s.Description, s.Footer, s.Theme, s.AccentColor, s.DefaultLocale, s.Locales, s.UILanguage, s.CustomCSS,
+		layout.NavLinks, layout.FooterColumns, layout.HomeIntro, layout.HomeBlocks, changedBy, spaceID(ctx), s.Font)
 	return err
 }
 
@@ -480,22 +715,22 @@ func (q *Queries) GetFavicon(ctx context.Context) ([]byte, string, error) {
 	var data []byte
 	var contentType string
 	err := q.Pool.QueryRow(ctx,
-		`SELECT favicon_data, favicon_content_type FROM site_settings WHERE singleton = TRUE AND favicon_data IS NOT NULL`).
+		`SELECT favicon_data, favicon_content_type FROM site_settings WHERE space_id = $1 AND favicon_data IS NOT NULL`, spaceID(ctx)).
 		Scan(&data, &contentType)
 	return data, contentType, err
 }
 
 func (q *Queries) UpdateFavicon(ctx context.Context, data []byte, contentType, changedBy string) error {
 	_, err := q.Pool.Exec(ctx,
-		`UPDATE site_settings SET favicon_data = $1, favicon_content_type = $2, changed_by = $3, version = version + 1, updated_at = now() WHERE singleton = TRUE`,
-		data, contentType, changedBy)
+		`UPDATE site_settings SET favicon_data = $1, favicon_content_type = $2, changed_by = $3, version = version + 1, updated_at = now() WHERE space_id = $4`,
+		data, contentType, changedBy, spaceID(ctx))
 	return err
 }
 
 func (q *Queries) DeleteFavicon(ctx context.Context, changedBy string) error {
 	_, err := q.Pool.Exec(ctx,
-		`UPDATE site_settings SET favicon_data = NULL, favicon_content_type = NULL, changed_by = $1, version = version + 1, updated_at = now() WHERE singleton = TRUE`,
-		changedBy)
+		`UPDATE site_settings SET favicon_data = NULL, favicon_content_type = NULL, changed_by = $1, version = version + 1, updated_at = now() WHERE space_id = $2`,
+		changedBy, spaceID(ctx))
 	return err
 }
 
@@ -546,21 +781,21 @@ func (q *Queries) CreateSession(ctx context.Context, userID, token string, expir
 func (q *Queries) GetSessionByToken(ctx context.Context, token string) (Session, error) {
 	var s Session
 	err := q.Pool.QueryRow(ctx,
-		`SELECT id, user_id, token, expires_at, created_at, preview_roles
+		`SELECT id, user_id, token, expires_at, created_at, preview_roles, preview_drafts
 		 FROM sessions WHERE token = $1 AND expires_at > now()`, token).
-		Scan(&s.ID, &s.UserID, &s.Token, &s.ExpiresAt, &s.CreatedAt, &s.PreviewRoles)
+		Scan(&s.ID, &s.UserID, &s.Token, &s.ExpiresAt, &s.CreatedAt, &s.PreviewRoles, &s.PreviewDrafts)
 	return s, err
 }
 
-func (q *Queries) SetSessionPreviewRoles(ctx context.Context, token, roles string) error {
+func (q *Queries) SetSessionPreviewRoles(ctx context.Context, token, roles string, drafts bool) error {
 	_, err := q.Pool.Exec(ctx,
-		`UPDATE sessions SET preview_roles = $2 WHERE token = $1`, token, roles)
+		`UPDATE sessions SET preview_roles = $2, preview_drafts = $3 WHERE token = $1`, token, roles, drafts)
 	return err
 }
 
 func (q *Queries) ClearSessionPreviewRoles(ctx context.Context, token string) error {
 	_, err := q.Pool.Exec(ctx,
-		`UPDATE sessions SET preview_roles = NULL WHERE token = $1`, token)
+		`UPDATE sessions SET preview_roles = NULL, preview_drafts = false WHERE token = $1`, token)
 	return err
 }
 
@@ -590,16 +825,17 @@ func (q *Queries) CreateUser(ctx context.Context, firstname, lastname, company,
 func (q *Queries) AssignRole(ctx context.Context, userID, roleName string) error {
 	_, err := q.Pool.Exec(ctx,
 		`INSERT INTO user_roles (user_id, role_id)
-		 SELECT $1, id FROM roles WHERE name = $2
-		 ON CONFLICT DO NOTHING`, userID, roleName)
+		 SELECT $1, id FROM roles WHERE name = $2 AND space_id = $3
+		 ON CONFLICT DO NOTHING`, userID, roleName, spaceID(ctx))
 	return err
 }
 
+// GetUserRoles returns the names of the user's roles in the current space.
 func (q *Queries) GetUserRoles(ctx context.Context, userID string) ([]string, error) {
 	rows, err := q.Pool.Query(ctx,
 		`SELECT r.name FROM roles r
 		 JOIN user_roles ur ON ur.role_id = r.id
-		 WHERE ur.user_id = $1 ORDER BY r.name`, userID)
+		 WHERE ur.user_id = $1 AND r.space_id = $2 ORDER BY r.name`, userID, spaceID(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -618,7 +854,7 @@ func (q *Queries) GetUserRoles(ctx context.Context, userID string) ([]string, er
 
 func (q *Queries) ListRoles(ctx context.Context) ([]Role, error) {
 	rows, err := q.Pool.Query(ctx,
-		`SELECT id, name, description, created_at, updated_at FROM roles WHERE name NOT IN ('admin', 'editor', 'viewer') ORDER BY name`)
+		`SELECT id, name, description, created_at, updated_at FROM roles WHERE space_id = $1 AND name NOT IN ('admin', 'editor', 'reviewer', 'viewer') ORDER BY name`, spaceID(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -641,17 +877,19 @@ func (q *Queries) HasRole(ctx context.Context, userID, roleName string) (bool, e
 		`SELECT EXISTS(
 			SELECT 1 FROM user_roles ur
 			JOIN roles r ON r.id = ur.role_id
-			WHERE ur.user_id = $1 AND r.name = $2
-		)`, userID, roleName).Scan(&exists)
+			WHERE ur.user_id = $1 AND r.name = $2 AND r.space_id = $3
+		)`, userID, roleName, spaceID(ctx)).Scan(&exists)
 	return exists, err
 }
 
 // --- Admin queries ---
 
+// ListUsers returns the members of the current space.
 func (q *Queries) ListUsers(ctx context.Context) ([]UserWithRoles, error) {
 	rows, err := q.Pool.Query(ctx,
 		`SELECT id, firstname, lastname, company, email, password, last_login, created_at, updated_at
-		 FROM users ORDER BY firstname, lastname`)
+		 FROM users WHERE id IN (SELECT user_id FROM space_members WHERE space_id = $1)
+		 ORDER BY firstname, lastname`, spaceID(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -679,17 +917,19 @@ func (q *Queries) ListUsers(ctx context.Context) ([]UserWithRoles, error) {
 	return users, nil
 }
 
-// ListNonEditorUsers returns all users that do not have the admin or editor role.
+// ListNonEditorUsers returns the members of the current space that do not
+// have the admin or editor role there.
 func (q *Queries) ListNonEditorUsers(ctx context.Context) ([]UserWithRoles, error) {
 	rows, err := q.Pool.Query(ctx,
 		`SELECT u.id, u.firstname, u.lastname, u.company, u.email, u.password, u.last_login, u.created_at, u.updated_at
 		 FROM users u
-		 WHERE u.id NOT IN (
+		 WHERE u.id IN (SELECT user_id FROM space_members WHERE space_id = $1)
+		 AND u.id NOT IN (
 		   SELECT ur.user_id FROM user_roles ur
 		   JOIN roles r ON r.id = ur.role_id
-		   WHERE r.name IN ('admin', 'editor')
+		   WHERE r.space_id = $1 AND r.name IN ('admin', 'editor')
 		 )
-		 ORDER BY u.firstname, u.lastname`)
+		 ORDER BY u.firstname, u.lastname`, spaceID(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -750,8 +990,11 @@ func (q *Queries) SaveUserHistory(ctx context.Context, userID string, version in
 	return err
 }
 
+// SetUserRoles replaces the user's roles in the current space.
 func (q *Queries) SetUserRoles(ctx context.Context, userID string, roleNames []string) error {
-	_, err := q.Pool.Exec(ctx, `DELETE FROM user_roles WHERE user_id = $1`, userID)
+	_, err := q.Pool.Exec(ctx,
+		`DELETE FROM user_roles WHERE user_id = $1 AND role_id IN (SELECT id FROM roles WHERE space_id = $2)`,
+		userID, spaceID(ctx))
 	if err != nil {
 		return err
 	}
@@ -766,7 +1009,7 @@ func (q *Queries) SetUserRoles(ctx context.Context, userID string, roleNames []s
 func (q *Queries) GetRole(ctx context.Context, id string) (Role, error) {
 	var r Role
 	err := q.Pool.QueryRow(ctx,
-		`SELECT id, name, description, created_at, updated_at FROM roles WHERE id = $1`, id).
+		`SELECT id, name, description, created_at, updated_at FROM roles WHERE id = $1 AND space_id = $2`, id, spaceID(ctx)).
 		Scan(&r.ID, &r.Name, &r.Description, &r.CreatedAt, &r.UpdatedAt)
 	return r, err
 }
@@ -774,10 +1017,10 @@ func (q *Queries) GetRole(ctx context.Context, id string) (Role, error) {
 func (q *Queries) CreateRole(ctx context.Context, name, description string) (Role, error) {
 	var r Role
 	err := q.Pool.QueryRow(ctx,
-		`INSERT INTO roles (name, description)
-		 VALUES ($1, $2)
+		`INSERT INTO roles (space_id, name, description)
+		 VALUES ($3, $1, $2)
 		 RETURNING id, name, description, created_at, updated_at`,
-		name, description).
+		name, description, spaceID(ctx)).
 		Scan(&r.ID, &r.Name, &r.Description, &r.CreatedAt, &r.UpdatedAt)
 	return r, err
 }
@@ -787,9 +1030,9 @@ func (q *Queries) UpdateRole(ctx context.Context, id, name, description string)
 	err := q.Pool.QueryRow(ctx,
 		`UPDATE roles
 		 SET name = $2, description = $3, version = version + 1, updated_at = now()
-		 WHERE id = $1
+		 WHERE id = $1 AND space_id = $4
 		 RETURNING id, name, description, created_at, updated_at`,
-		id, name, description).
+		id, name, description, spaceID(ctx)).
 		Scan(&r.ID, &r.Name, &r.Description, &r.CreatedAt, &r.UpdatedAt)
 	return r, err
 }
@@ -810,7 +1053,7 @@ func (q *Queries) SaveRoleHistory(ctx context.Context, roleID string, version in
 
 func (q *Queries) ListAllRoles(ctx context.Context) ([]Role, error) {
 	rows, err := q.Pool.Query(ctx,
-		`SELECT id, name, description, created_at, updated_at FROM roles ORDER BY name`)
+		`SELECT id, name, description, created_at, updated_at FROM roles WHERE space_id = $1 ORDER BY name`, spaceID(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -865,7 +1108,7 @@ func (q *Queries) DeletePasswordResetToken(ctx context.Context, token string) er
 
 func (q *Queries) ListSectionRows(ctx context.Context) ([]SectionRow, error) {
 	rows, err := q.Pool.Query(ctx,
-		`SELECT id, title, description, sort_order, version FROM section_rows WHERE deleted = false ORDER BY sort_order`)
+		`SELECT id, title, description, sort_order, version FROM section_rows WHERE space_id = $1 AND deleted = false ORDER BY sort_order`, spaceID(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -885,7 +1128,7 @@ func (q *Queries) ListSectionRows(ctx context.Context) ([]SectionRow, error) {
 func (q *Queries) GetSectionRow(ctx context.Context, id string) (SectionRow, error) {
 	var r SectionRow
 	err := q.Pool.QueryRow(ctx,
-		`SELECT id, title, description, sort_order, version FROM section_rows WHERE id = $1 AND deleted = false`, id).
+		`SELECT id, title, description, sort_order, version FROM section_rows WHERE id = $1 AND space_id = $2 AND deleted = false`, id, spaceID(ctx)).
 		Scan(&r.ID, &r.Title, &r.Description, &r.SortOrder, &r.Version)
 	return r, err
 }
@@ -893,10 +1136,10 @@ func (q *Queries) GetSectionRow(ctx context.Context, id string) (SectionRow, err
 func (q *Queries) CreateSectionRow(ctx context.Context, title, description string, sortOrder int, changedBy string) (SectionRow, error) {
 	var r SectionRow
 	err := q.Pool.QueryRow(ctx,
-		`INSERT INTO section_rows (title, description, sort_order, changed_by)
-		 VALUES ($1, $2, $3, $4)
+		`INSERT INTO section_rows (space_id, title, description, sort_order, changed_by)
+		 VALUES ($5, $1, $2, $3, $4)
 		 RETURNING id, title, description, sort_order, version`,
-		title, description, sortOrder, changedBy).
+		title, description, sortOrder, changedBy, spaceID(ctx)).
 		Scan(&r.ID, &r.Title, &r.Description, &r.SortOrder, &r.Version)
 	return r, err
 }
@@ -906,9 +1149,9 @@ func (q *Queries) UpdateSectionRow(ctx context.Context, id string, title, descri
 	err := q.Pool.QueryRow(ctx,
 		`UPDATE section_rows
 		 SET title = $2, description = $3, version = version + 1, updated_at = now(), changed_by = $4
-		 WHERE id = $1
+		 WHERE id = $1 AND space_id = $5
 		 RETURNING id, title, description, sort_order, version`,
-		id, title, description, changedBy).
+		id, title, description, changedBy, spaceID(ctx)).
 		Scan(&r.ID, &r.Title, &r.Description, &r.SortOrder, &r.Version)
 	return r, err
 }
@@ -922,14 +1165,14 @@ func (q *Queries) SoftDeleteSectionRow(ctx context.Context, id string, changedBy
 
 	_, err = tx.Exec(ctx,
 		`UPDATE sections SET row_id = NULL, version = version + 1, updated_at = now(), changed_by = $2
-		 WHERE row_id = $1 AND deleted = false`, id, changedBy)
+		 WHERE row_id = $1 AND space_id = $3 AND deleted = false`, id, changedBy, spaceID(ctx))
 	if err != nil {
 		return err
 	}
 
 	_, err = tx.Exec(ctx,
 		`UPDATE section_rows SET deleted = true, version = version + 1, updated_at = now(), changed_by = $2
-		 WHERE id = $1`, id, changedBy)
+		 WHERE id = $1 AND space_id = $3`, id, changedBy, spaceID(ctx))
 	if err != nil {
 		return err
 	}
@@ -1005,8 +1248,8 @@ func (q *Queries) ReorderSectionsAndRows(ctx context.Context, sections []Reorder
 	for _, s := range sections {
 		_, err := tx.Exec(ctx,
 			`UPDATE sections SET sort_order = $2, row_id = $3, version = version + 1, updated_at = now(), changed_by = $4
-			 WHERE id = $1`,
-			s.SectionID, s.SortOrder, s.RowID, changedBy)
+			 WHERE id = $1 AND space_id = $5`,
+			s.SectionID, s.SortOrder, s.RowID, changedBy, spaceID(ctx))
 		if err != nil {
 			return err
 		}
@@ -1015,8 +1258,8 @@ func (q *Queries) ReorderSectionsAndRows(ctx context.Context, sections []Reorder
 	for _, r := range sectionRows {
 		_, err := tx.Exec(ctx,
 			`UPDATE section_rows SET sort_order = $2, version = version + 1, updated_at = now(), changed_by = $3
-			 WHERE id = $1`,
-			r.RowID, r.SortOrder, changedBy)
+			 WHERE id = $1 AND space_id = $4`,
+			r.RowID, r.SortOrder, changedBy, spaceID(ctx))
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,123 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// PageFeedback is a user's answer to "Was this page helpful?".
+type PageFeedback struct {
+	PageID    string
+	UserID    string
+	UserName  string
+	Helpful   bool
+	Comment   string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type FeedbackSummary struct {
+	Helpful    int
+	NotHelpful int
+}
+
+// Total returns the number of votes.
+func (s FeedbackSummary) Total() int {
+	return s.Helpful + s.NotHelpful
+}
+
+// PageFeedbackStats is the feedback on one page, for the feedback dashboard.
+type PageFeedbackStats struct {
+	FeedbackSummary
+	PageID       string
+	SectionName  string
+	SectionTitle string
+	RequiredRole string
+	Slug         string
+	Title        string
+	Comments     []PageFeedback
+}
+
+// --- Feedback queries ---
+
+// SetPageFeedback records a user's vote on a page, replacing an earlier one.
+func (q *Queries) SetPageFeedback(ctx context.Context, pageID, userID string, helpful bool, comment string) error {
+	_, err := q.Pool.Exec(ctx,
+		`INSERT INTO page_feedback (page_id, user_id, helpful, comment)
+		 VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (page_id, user_id) DO UPDATE SET helpful = $3, comment = $4, updated_at = now()`,
+		pageID, userID, helpful, comment)
+	return err
+}
+
+// GetPageFeedback returns a user's vote on a page.
+func (q *Queries) GetPageFeedback(ctx context.Context, pageID, userID string) (PageFeedback, error) {
+	var f PageFeedback
+	err := q.Pool.QueryRow(ctx,
+		`SELECT page_id, user_id, helpful, comment, created_at, updated_at
+		 FROM page_feedback WHERE page_id = $1 AND user_id = $2`, pageID, userID).
+		Scan(&f.PageID, &f.UserID, &f.Helpful, &f.Comment, &f.CreatedAt, &f.UpdatedAt)
+	return f, err
+}
+
+func (q *Queries) GetFeedbackSummary(ctx context.Context, pageID string) (FeedbackSummary, error) {
+	var s FeedbackSummary
+	err := q.Pool.QueryRow(ctx,
+		`SELECT count(*) FILTER (WHERE helpful), count(*) FILTER (WHERE NOT helpful)
+		 FROM page_feedback WHERE page_id = $1`, pageID).
+		Scan(&s.Helpful, &s.NotHelpful)
+	return s, err
+}
+
+// ListPageFeedbackStats returns the pages that have feedback, most negative
+// feedback first.
+func (q *Queries) ListPageFeedbackStats(ctx context.Context) ([]PageFeedbackStats, error) {
+	rows, err := q.Pool.Query(ctx,
+		`SELECT count(*) FILTER (WHERE f.helpful), count(*) FILTER (WHERE NOT f.helpful),
+		        p.id, s.name, s.title, COALESCE(s.required_role, ''), p.slug, p.title
+		 FROM page_feedback f
+		 JOIN pages p ON p.id = f.page_id
+		 JOIN sections s ON s.id = p.section_id
+		 WHERE s.space_id = $1 AND p.deleted = false AND s.deleted = false
+		 GROUP BY p.id, s.id
+		 ORDER BY 2 DESC, 1, s.title, p.title`, spaceID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []PageFeedbackStats
+	for rows.Next() {
+		var s PageFeedbackStats
+		if err := rows.Scan(&s.Helpful, &s.NotHelpful, &s.PageID, &s.SectionName, &s.SectionTitle, &s.RequiredRole, &s.Slug, &s.Title); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+// ListFeedbackComments returns the votes that came with a comment, newest
+// first.
+func (q *Queries) ListFeedbackComments(ctx context.Context) ([]PageFeedback, error) {
+	rows, err := q.Pool.Query(ctx,
+		`SELECT f.page_id, f.user_id, u.firstname || ' ' || u.lastname, f.helpful, f.comment, f.created_at, f.updated_at
+		 FROM page_feedback f JOIN users u ON u.id = f.user_id
+		 WHERE f.comment <> ''
+		   AND f.page_id IN (SELECT p.id FROM pages p JOIN sections s ON s.id = p.section_id WHERE s.space_id = $1)
+		 ORDER BY f.updated_at DESC`, spaceID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var feedback []PageFeedback
+	for rows.Next() {
+		var f PageFeedback
+		if err := rows.Scan(&f.PageID, &f.UserID, &f.UserName, &f.Helpful, &f.Comment, &f.CreatedAt, &f.UpdatedAt); err != nil {
+			return nil, err
+		}
+		feedback = append(feedback, f)
+	}
+	return feedback, rows.Err()
+}
@@ -0,0 +1,73 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// QueuedMail is an email waiting in the mail queue.
+type QueuedMail struct {
+	ID        string
+	Recipient string
+	Subject   string
+	Body      string
+	Attempts  int
+}
+
+// --- Mail queue queries ---
+
+// EnqueueMail queues an email to be sent in the background.
+func (q *Queries) EnqueueMail(ctx context.Context, to, subject, body string) error {
+	_, err := q.Pool.Exec(ctx,
+		`INSERT INTO mail_queue (recipient, subject, body) VALUES ($1, $2, $3)`,
+		to, subject, body)
+	return err
+}
+
+// ClaimMail returns up to limit due emails and pushes their next attempt
+// back by lease, so that other replicas skip them while they are being
+// sent. An email whose sender dies is retried once the lease runs out.
+func (q *Queries) ClaimMail(ctx context.Context, limit int, lease time.Duration) ([]QueuedMail, error) {
+	rows, err := q.Pool.Query(ctx,
+		`WITH due AS (
+		   SELECT id FROM mail_queue
+		   WHERE next_attempt_at <= now()
+		   ORDER BY next_attempt_at
+		   LIMIT $1
+		   FOR UPDATE SKIP LOCKED
+		 )
+		 UPDATE mail_queue m
+		 SET next_attempt_at = now() + $2::interval
+		 FROM due
+		 WHERE m.id = due.id
+		 RETURNING m.id, m.recipient, m.subject, m.body, m.attempts`,
+		limit, lease)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mails []QueuedMail
+	for rows.Next() {
+		var m QueuedMail
+		if err := rows.Scan(&m.ID, &m.Recipient, &m.Subject, &m.Body, &m.Attempts); err != nil {
+			return nil, err
+		}
+		mails = append(mails, m)
+	}
+	return mails, rows.Err()
+}
+
+// DeleteMail removes an email from the queue once it is sent or given up on.
+func (q *Queries) DeleteMail(ctx context.Context, id string) error {
+	_, err := q.Pool.Exec(ctx, `DELETE FROM mail_queue WHERE id = $1`, id)
+	return err
+}
+
+// RetryMail records a failed attempt and schedules the next one.
+func (q *Queries) RetryMail(ctx context.Context, id, lastError string, nextAttempt time.Time) error {
+	_, err := q.Pool.Exec(ctx,
+		`UPDATE mail_queue SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3 WHERE id = $1`,
+		id, lastError, nextAttempt)
+	return err
+}
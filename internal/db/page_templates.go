@@ -0,0 +1,103 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// PageTemplate is a Markdown skeleton for new pages. A nil SectionID makes
+// the template available in every section.
+type PageTemplate struct {
+	ID           string
+	Name         string
+	Description  string
+	SectionID    *string
+	SectionTitle string
+	ContentMD    string
+	Version      int
+	UpdatedAt    time.Time
+}
+
+// --- Page template queries ---
+
+const pageTemplateColumns = `t.id, t.name, t.description, t.section_id, COALESCE(s.title, ''), t.content_md, t.version, t.updated_at
+	 FROM page_templates t LEFT JOIN sections s ON s.id = t.section_id`
+
+func scanPageTemplates(rows pgx.Rows) ([]PageTemplate, error) {
+	var templates []PageTemplate
+	for rows.Next() {
+		var t PageTemplate
+		if err := rows.Scan(&t.ID, &t.Name, &t.Description, &t.SectionID, &t.SectionTitle, &t.ContentMD, &t.Version, &t.UpdatedAt); err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	return templates, rows.Err()
+}
+
+func (q *Queries) ListPageTemplates(ctx context.Context) ([]PageTemplate, error) {
+	rows, err := q.Pool.Query(ctx,
+		`SELECT `+pageTemplateColumns+`
+		 WHERE t.space_id = $1 AND t.deleted = false ORDER BY t.section_id NULLS FIRST, t.name`, spaceID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanPageTemplates(rows)
+}
+
+// ListPageTemplatesForSection returns the global templates and those scoped
+// to the given section.
+func (q *Queries) ListPageTemplatesForSection(ctx context.Context, sectionID string) ([]PageTemplate, error) {
+	rows, err := q.Pool.Query(ctx,
+		`SELECT `+pageTemplateColumns+`
+		 WHERE t.space_id = $2 AND t.deleted = false AND (t.section_id IS NULL OR t.section_id = $1)
+		 ORDER BY t.name`, sectionID, spaceID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanPageTemplates(rows)
+}
+
+func (q *Queries) GetPageTemplate(ctx context.Context, id string) (PageTemplate, error) {
+	var t PageTemplate
+	err := q.Pool.QueryRow(ctx,
+		`SELECT `+pageTemplateColumns+` WHERE t.id = $1 AND t.space_id = $2 AND t.deleted = false`, id, spaceID(ctx)).
+		Scan(&t.ID, &t.Name, &t.Description, &t.SectionID, &t.SectionTitle, &t.ContentMD, &t.Version, &t.UpdatedAt)
+	return t, err
+}
+
+func (q *Queries) GetPageTemplateByName(ctx context.Context, name string) (PageTemplate, error) {
+	var t PageTemplate
+	err := q.Pool.QueryRow(ctx,
+		`SELECT `+pageTemplateColumns+` WHERE t.name = $1 AND t.space_id = $2 AND t.deleted = false`, name, spaceID(ctx)).
+		Scan(&t.ID, &t.Name, &t.Description, &t.SectionID, &t.SectionTitle, &t.ContentMD, &t.Version, &t.UpdatedAt)
+	return t, err
+}
+
+func (q *Queries) CreatePageTemplate(ctx context.Context, name, description string, sectionID *string, contentMD, changedBy string) error {
+	_, err := q.Pool.Exec(ctx,
+		`INSERT INTO page_templates (space_id, name, description, section_id, content_md, changed_by)
+		 VALUES ($6, $1, $2, $3, $4, $5)`,
+		name, description, sectionID, contentMD, changedBy, spaceID(ctx))
+	return err
+}
+
+func (q *Queries) UpdatePageTemplate(ctx context.Context, id, name, description string, sectionID *string, contentMD, changedBy string) error {
+	_, err := q.Pool.Exec(ctx,
+		`UPDATE page_templates
+		 SET name = $2, description = $3, section_id = $4, content_md = $5, version = version + 1, updated_at = now(), changed_by = $6
+		 WHERE id = $1 AND space_id = $7 AND deleted = false`,
+		id, name, description, sectionID, contentMD, changedBy, spaceID(ctx))
+	return err
+}
+
+func (q *Queries) SoftDeletePageTemplate(ctx context.Context, id, changedBy string) error {
+	_, err := q.Pool.Exec(ctx,
+		`UPDATE page_templates SET deleted = true, version = version + 1, updated_at = now(), changed_by = $2
+		 WHERE id = $1 AND space_id = $3`, id, changedBy, spaceID(ctx))
+	return err
+}
@@ -0,0 +1,55 @@
+package db
+
+import "context"
+
+// --- Preference queries ---
+
+// GetUILanguage returns the user interface language of a user, falling back
+// to the space's. userID may be empty for visitors who are not signed in.
+func (q *Queries) GetUILanguage(ctx context.Context, userID string) (string, error) {
+	var lang string
+	err := q.Pool.QueryRow(ctx,
+		`SELECT COALESCE(
+		     (SELECT ui_language FROM users WHERE id = NULLIF($1, '')::uuid),
+		     (SELECT ui_language FROM site_settings WHERE space_id = $2),
+		     'en')`, userID, spaceID(ctx)).Scan(&lang)
+	return lang, err
+}
+
+// GetUserUILanguage returns the language a user picked for the user
+// interface, or "" when they use the site's.
+func (q *Queries) GetUserUILanguage(ctx context.Context, userID string) (string, error) {
+	var lang *string
+	err := q.Pool.QueryRow(ctx,
+		`SELECT ui_language FROM users WHERE id = $1`, userID).Scan(&lang)
+	if lang == nil {
+		return "", err
+	}
+	return *lang, err
+}
+
+// SetUserUILanguage sets the language of the user interface for a user. An
+// empty language makes them use the site's.
+func (q *Queries) SetUserUILanguage(ctx context.Context, userID, lang string) error {
+	_, err := q.Pool.Exec(ctx,
+		`UPDATE users SET ui_language = NULLIF($2, ''), updated_at = now() WHERE id = $1`, userID, lang)
+	return err
+}
+
+// GetUserTheme returns the theme and accent color a user picked, each ""
+// when they use the site's.
+func (q *Queries) GetUserTheme(ctx context.Context, userID string) (theme, accentColor string, err error) {
+	err = q.Pool.QueryRow(ctx,
+		`SELECT COALESCE(theme, ''), COALESCE(accent_color, '') FROM users WHERE id = $1`, userID).
+		Scan(&theme, &accentColor)
+	return theme, accentColor, err
+}
+
+// SetUserTheme sets the theme and accent color for a user. Empty values make
+// them use the site's.
+func (q *Queries) SetUserTheme(ctx context.Context, userID, theme, accentColor string) error {
+	_, err := q.Pool.Exec(ctx,
+		`UPDATE users SET theme = NULLIF($2, ''), accent_color = NULLIF($3, ''), updated_at = now() WHERE id = $1`,
+		userID, theme, accentColor)
+	return err
+}
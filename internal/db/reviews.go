@@ -0,0 +1,233 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Review statuses. Open, changes_requested and approved reviews are active;
+// a page has at most one active review.
+const (
+	ReviewOpen             = "open"
+	ReviewChangesRequested = "changes_requested"
+	ReviewApproved         = "approved"
+	ReviewPublished        = "published"
+	ReviewWithdrawn        = "withdrawn"
+)
+
+// PageReview is a proposed page change awaiting approval. Title and
+// ContentMD are the proposal; BaseTitle and BaseContentMD the published
+// revision it was made against.
+type PageReview struct {
+	ID                string
+	PageID            string
+	SectionID         string
+	SectionName       string
+	SectionTitle      string
+	RequiredRole      string
+	RequiredApprovals int
+	Slug              string
+	Title             string
+	ContentMD         string
+	BaseTitle         string
+	BaseContentMD     string
+	Summary           string
+	Status            string
+	SubmittedBy       string
+	SubmitterName     string
+	Approvals         int
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+}
+
+// IsActive reports whether the review is still awaiting a decision or
+// publication.
+func (r PageReview) IsActive() bool {
+	return r.Status == ReviewOpen || r.Status == ReviewChangesRequested || r.Status == ReviewApproved
+}
+
+type ReviewVote struct {
+	ReviewerID   string
+	ReviewerName string
+	Decision     string
+	CreatedAt    time.Time
+}
+
+// ReviewComment is a comment on a review. OldLine/NewLine point at a line of
+// the diff; both are nil for general comments.
+type ReviewComment struct {
+	ID         string
+	AuthorName string
+	OldLine    *int
+	NewLine    *int
+	Body       string
+	CreatedAt  time.Time
+}
+
+// --- Review queries ---
+
+const pageReviewColumns = `r.id, r.page_id, p.section_id, s.name, s.title, COALESCE(s.required_role, ''), s.required_approvals, p.slug,
+	r.title, r.content_md, r.base_title, r.base_content_md, r.summary, r.status, COALESCE(r.submitted_by::text, ''),
+	COALESCE(u.firstname || ' ' || u.lastname, ''),
+	(SELECT count(*) FROM review_votes v WHERE v.review_id = r.id AND v.decision = 'approve'),
+	r.created_at, r.updated_at
+	FROM page_reviews r
+	JOIN pages p ON p.id = r.page_id
+	JOIN sections s ON s.id = p.section_id
+	LEFT JOIN users u ON u.id = r.submitted_by`
+
+func scanPageReview(row pgx.Row, r *PageReview) error {
+	return row.Scan(&r.ID, &r.PageID, &r.SectionID, &r.SectionName, &r.SectionTitle, &r.RequiredRole, &r.RequiredApprovals, &r.Slug,
+		&r.Title, &r.ContentMD, &r.BaseTitle, &r.BaseContentMD, &r.Summary, &r.Status, &r.SubmittedBy,
+		&r.SubmitterName, &r.Approvals, &r.CreatedAt, &r.UpdatedAt)
+}
+
+// CreatePageReview submits a page change for review and withdraws any
+// earlier active review of the same page.
+func (q *Queries) CreatePageReview(ctx context.Context, pageID, title, contentMD, baseTitle, baseContentMD, summary, submittedBy string) (string, error) {
+	var id string
+	err := q.Pool.QueryRow(ctx,
+		`WITH withdrawn AS (
+		   UPDATE page_reviews SET status = 'withdrawn', updated_at = now()
+		   WHERE page_id = $1 AND status IN ('open', 'changes_requested', 'approved')
+		 )
+		 INSERT INTO page_reviews (page_id, title, content_md, base_title, base_content_md, summary, submitted_by)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)
+		 RETURNING id`,
+		pageID, title, contentMD, baseTitle, baseContentMD, summary, submittedBy).Scan(&id)
+	return id, err
+}
+
+func (q *Queries) GetPageReview(ctx context.Context, id string) (PageReview, error) {
+	var r PageReview
+	err := scanPageReview(q.Pool.QueryRow(ctx,
+		`SELECT `+pageReviewColumns+` WHERE r.id = $1 AND s.space_id = $2 AND p.deleted = false`, id, spaceID(ctx)), &r)
+	return r, err
+}
+
+// GetActivePageReview returns the active review of a page, if any.
+func (q *Queries) GetActivePageReview(ctx context.Context, pageID string) (PageReview, error) {
+	var r PageReview
+	err := scanPageReview(q.Pool.QueryRow(ctx,
+		`SELECT `+pageReviewColumns+`
+		 WHERE r.page_id = $1 AND r.status IN ('open', 'changes_requested', 'approved')
+		 ORDER BY r.created_at DESC LIMIT 1`, pageID), &r)
+	return r, err
+}
+
+// ListActivePageReviews returns the active reviews of the current space,
+// oldest first.
+func (q *Queries) ListActivePageReviews(ctx context.Context) ([]PageReview, error) {
+	rows, err := q.Pool.Query(ctx,
+		`SELECT `+pageReviewColumns+`
+		 WHERE s.space_id = $1 AND r.status IN ('open', 'changes_requested', 'approved') AND p.deleted = false AND s.deleted = false
+		 ORDER BY r.created_at`, spaceID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reviews []PageReview
+	for rows.Next() {
+		var r PageReview
+		if err := scanPageReview(rows, &r); err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, r)
+	}
+	return reviews, rows.Err()
+}
+
+func (q *Queries) SetPageReviewStatus(ctx context.Context, id, status string) error {
+	_, err := q.Pool.Exec(ctx,
+		`UPDATE page_reviews SET status = $2, updated_at = now() WHERE id = $1`, id, status)
+	return err
+}
+
+// SetReviewVote records a reviewer's decision, replacing an earlier one.
+func (q *Queries) SetReviewVote(ctx context.Context, reviewID, reviewerID, decision string) error {
+	_, err := q.Pool.Exec(ctx,
+		`INSERT INTO review_votes (review_id, reviewer_id, decision)
+		 VALUES ($1, $2, $3)
+		 ON CONFLICT (review_id, reviewer_id) DO UPDATE SET decision = $3, created_at = now()`,
+		reviewID, reviewerID, decision)
+	return err
+}
+
+func (q *Queries) ListReviewVotes(ctx context.Context, reviewID string) ([]ReviewVote, error) {
+	rows, err := q.Pool.Query(ctx,
+		`SELECT v.reviewer_id, u.firstname || ' ' || u.lastname, v.decision, v.created_at
+		 FROM review_votes v JOIN users u ON u.id = v.reviewer_id
+		 WHERE v.review_id = $1 ORDER BY v.created_at`, reviewID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var votes []ReviewVote
+	for rows.Next() {
+		var v ReviewVote
+		if err := rows.Scan(&v.ReviewerID, &v.ReviewerName, &v.Decision, &v.CreatedAt); err != nil {
+			return nil, err
+		}
+		votes = append(votes, v)
+	}
+	return votes, rows.Err()
+}
+
+func (q *Queries) CreateReviewComment(ctx context.Context, reviewID, authorID string, oldLine, newLine *int, body string) error {
+	_, err := q.Pool.Exec(ctx,
+		`INSERT INTO review_comments (review_id, author_id, old_line, new_line, body)
+		 VALUES ($1, $2, $3, $4, $5)`,
+		reviewID, authorID, oldLine, newLine, body)
+	return err
+}
+
+func (q *Queries) ListReviewComments(ctx context.Context, reviewID string) ([]ReviewComment, error) {
+	rows, err := q.Pool.Query(ctx,
+		`SELECT c.id, COALESCE(u.firstname || ' ' || u.lastname, ''), c.old_line, c.new_line, c.body, c.created_at
+		 FROM review_comments c LEFT JOIN users u ON u.id = c.author_id
+		 WHERE c.review_id = $1 ORDER BY c.created_at`, reviewID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var comments []ReviewComment
+	for rows.Next() {
+		var c ReviewComment
+		if err := rows.Scan(&c.ID, &c.AuthorName, &c.OldLine, &c.NewLine, &c.Body, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+	return comments, rows.Err()
+}
+
+// ListUsersWithRole returns the users that have the given role in the
+// current space.
+func (q *Queries) ListUsersWithRole(ctx context.Context, roleName string) ([]User, error) {
+	rows, err := q.Pool.Query(ctx,
+		`SELECT u.id, u.firstname, u.lastname, u.company, u.email, u.password, u.last_login, u.created_at, u.updated_at
+		 FROM users u
+		 JOIN user_roles ur ON ur.user_id = u.id
+		 JOIN roles r ON r.id = ur.role_id
+		 WHERE r.name = $1 AND r.space_id = $2
+		 ORDER BY u.firstname, u.lastname`, roleName, spaceID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Firstname, &u.Lastname, &u.Company, &u.Email, &u.Password, &u.LastLogin, &u.CreatedAt, &u.UpdatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
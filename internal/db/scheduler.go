@@ -0,0 +1,102 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// schedulerLockKey is the advisory lock key held while schedules are
+// applied, so that only one replica does the work at a time.
+const schedulerLockKey int64 = 0x73646f63_73636864 // "sdocschd"
+
+// ScheduleResult describes the changes made by one scheduler run.
+type ScheduleResult struct {
+	Ran               bool
+	PagesPublished    []ScheduledPage
+	PagesUnpublished  []ScheduledPage
+	SectionsPublished int64
+}
+
+// ScheduledPage is a page changed by the scheduler, with the space it
+// belongs to, since the scheduler runs for all spaces at once.
+type ScheduledPage struct {
+	Page
+	SpaceID string
+}
+
+// --- Scheduler queries ---
+
+// ApplySchedules folds passed page schedules into pages.published, recording
+// a history entry for each page, and clears passed section publish times.
+// Readers see the scheduled state as soon as it is due; this only makes it
+// permanent. The history entries are attributed to the page's last editor.
+// If another replica holds the lock the run is skipped and Ran is false.
+func (q *Queries) ApplySchedules(ctx context.Context) (ScheduleResult, error) {
+	var res ScheduleResult
+
+	tx, err := q.Pool.Begin(ctx)
+	if err != nil {
+		return res, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := tx.QueryRow(ctx, `SELECT pg_try_advisory_xact_lock($1)`, schedulerLockKey).Scan(&res.Ran); err != nil {
+		return res, err
+	}
+	if !res.Ran {
+		return res, nil
+	}
+
+	res.PagesPublished, err = applyPageSchedule(ctx, tx,
+		`published = true, publish_at = NULL`, `publish_at <= now()`, "Published on schedule")
+	if err != nil {
+		return res, err
+	}
+	res.PagesUnpublished, err = applyPageSchedule(ctx, tx,
+		`published = false, unpublish_at = NULL`, `unpublish_at <= now()`, "Unpublished on schedule")
+	if err != nil {
+		return res, err
+	}
+
+	// Sections have no published flag; an expired section stays hidden
+	// until an editor clears or moves its unpublish time.
+	tag, err := tx.Exec(ctx,
+		`UPDATE sections SET publish_at = NULL WHERE deleted = false AND publish_at <= now()`)
+	if err != nil {
+		return res, err
+	}
+	res.SectionsPublished = tag.RowsAffected()
+
+	return res, tx.Commit(ctx)
+}
+
+// applyPageSchedule applies set to the pages matching due, records a history
+// entry with the given summary for each and returns the changed pages.
+func applyPageSchedule(ctx context.Context, tx pgx.Tx, set, due, summary string) ([]ScheduledPage, error) {
+	rows, err := tx.Query(ctx,
+		`WITH changed AS (
+		   UPDATE pages SET `+set+`, version = version + 1, updated_at = now()
+		   WHERE deleted = false AND `+due+`
+		   RETURNING `+pageColumns+`
+		 ), history AS (
+		   INSERT INTO pages_history (page_id, version, section_id, slug, title, content_md, sort_order, changed_by, summary)
+		   SELECT id, version, section_id, slug, title, content_md, sort_order, changed_by, $1 FROM changed
+		 )
+		 SELECT changed.*, s.space_id FROM changed JOIN sections s ON s.id = changed.section_id`,
+		summary)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pages []ScheduledPage
+	for rows.Next() {
+		var p ScheduledPage
+		if err := rows.Scan(&p.ID, &p.SectionID, &p.Slug, &p.Title, &p.ContentMD, &p.SortOrder, &p.Version, &p.ParentSlug, &p.Published, &p.DraftTitle, &p.DraftContentMD, &p.PublishAt, &p.UnpublishAt, &p.UpdatedAt, &p.ChangedBy, &p.SpaceID); err != nil {
+			return nil, err
+		}
+		pages = append(pages, p)
+	}
+	return pages, rows.Err()
+}
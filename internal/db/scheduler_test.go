@@ -0,0 +1,104 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"docgen/internal/db"
+	"docgen/internal/dbtest"
+)
+
+func TestApplySchedules(t *testing.T) {
+	q := dbtest.Open(t)
+	ctx := context.Background()
+	user := dbtest.User(t, q)
+
+	section, err := q.CreateSection(ctx, dbtest.Name("section-"), "Scheduled", "", "", 0, "", user.ID, nil)
+	if err != nil {
+		t.Fatalf("CreateSection: %v", err)
+	}
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+
+	create := func(slug string, published bool, publishAt, unpublishAt *time.Time) db.Page {
+		t.Helper()
+		p, err := q.CreatePage(ctx, section.ID, slug, slug, "Content", 0, published, user.ID)
+		if err != nil {
+			t.Fatalf("CreatePage %s: %v", slug, err)
+		}
+		if err := q.SetPageSchedule(ctx, section.ID, slug, publishAt, unpublishAt); err != nil {
+			t.Fatalf("SetPageSchedule %s: %v", slug, err)
+		}
+		return p
+	}
+	due := create("due", false, &past, nil)
+	expired := create("expired", true, nil, &past)
+	later := create("later", false, &future, nil)
+	window := create("window", false, &past, &future)
+
+	res, err := q.ApplySchedules(ctx)
+	if err != nil {
+		t.Fatalf("ApplySchedules: %v", err)
+	}
+	if !res.Ran {
+		t.Fatal("ApplySchedules did not run")
+	}
+
+	tests := []struct {
+		page           db.Page
+		published      bool
+		publishAt      bool
+		unpublishAt    bool
+		bumped         bool
+		historySummary string
+	}{
+		{due, true, false, false, true, "Published on schedule"},
+		{expired, false, false, false, true, "Unpublished on schedule"},
+		{later, false, true, false, false, ""},
+		{window, true, false, true, true, "Published on schedule"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.page.Slug, func(t *testing.T) {
+			got, err := q.GetPage(ctx, section.ID, tt.page.Slug, true)
+			if err != nil {
+				t.Fatalf("GetPage: %v", err)
+			}
+			if got.Published != tt.published {
+				t.Errorf("published = %v, want %v", got.Published, tt.published)
+			}
+			if (got.PublishAt != nil) != tt.publishAt {
+				t.Errorf("publish_at = %v, want set %v", got.PublishAt, tt.publishAt)
+			}
+			if (got.UnpublishAt != nil) != tt.unpublishAt {
+				t.Errorf("unpublish_at = %v, want set %v", got.UnpublishAt, tt.unpublishAt)
+			}
+
+			wantVersion := tt.page.Version
+			if tt.bumped {
+				wantVersion++
+			}
+			if got.Version != wantVersion {
+				t.Errorf("version = %d, want %d", got.Version, wantVersion)
+			}
+
+			var summary string
+			err = q.Pool.QueryRow(ctx,
+				`SELECT summary FROM pages_history WHERE page_id = $1 AND version = $2`,
+				got.ID, got.Version).Scan(&summary)
+			if tt.bumped && (err != nil || summary != tt.historySummary) {
+				t.Errorf("history summary = %q (%v), want %q", summary, err, tt.historySummary)
+			}
+		})
+	}
+
+	again, err := q.ApplySchedules(ctx)
+	if err != nil {
+		t.Fatalf("second ApplySchedules: %v", err)
+	}
+	for _, p := range append(again.PagesPublished, again.PagesUnpublished...) {
+		if p.SectionID == section.ID {
+			t.Errorf("second run changed %s again", p.Slug)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package db
+
+import (
+	"context"
+	"regexp"
+	"time"
+)
+
+type Snippet struct {
+	ID          string
+	Name        string
+	Description string
+	ContentMD   string
+	Version     int
+	UpdatedAt   time.Time
+}
+
+// SnippetUsage is a page that includes a snippet.
+type SnippetUsage struct {
+	SectionName  string
+	SectionTitle string
+	Slug         string
+	Title        string
+}
+
+// --- Snippet queries ---
+
+func (q *Queries) ListSnippets(ctx context.Context) ([]Snippet, error) {
+	rows, err := q.Pool.Query(ctx,
+		`SELECT id, name, description, content_md, version, updated_at
+		 FROM snippets WHERE space_id = $1 AND deleted = false ORDER BY name`, spaceID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snippets []Snippet
+	for rows.Next() {
+		var s Snippet
+		if err := rows.Scan(&s.ID, &s.Name, &s.Description, &s.ContentMD, &s.Version, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+	return snippets, rows.Err()
+}
+
+func (q *Queries) GetSnippet(ctx context.Context, id string) (Snippet, error) {
+	var s Snippet
+	err := q.Pool.QueryRow(ctx,
+		`SELECT id, name, description, content_md, version, updated_at
+		 FROM snippets WHERE id = $1 AND space_id = $2 AND deleted = false`, id, spaceID(ctx)).
+		Scan(&s.ID, &s.Name, &s.Description, &s.ContentMD, &s.Version, &s.UpdatedAt)
+	return s, err
+}
+
+func (q *Queries) GetSnippetByName(ctx context.Context, name string) (Snippet, error) {
+	var s Snippet
+	err := q.Pool.QueryRow(ctx,
+		`SELECT id, name, description, content_md, version, updated_at
+		 FROM snippets WHERE name = $1 AND space_id = $2 AND deleted = false`, name, spaceID(ctx)).
+		Scan(&s.ID, &s.Name, &s.Description, &s.ContentMD, &s.Version, &s.UpdatedAt)
+	return s, err
+}
+
+func (q *Queries) CreateSnippet(ctx context.Context, name, description, contentMD, changedBy string) (Snippet, error) {
+	var s Snippet
+	err := q.Pool.QueryRow(ctx,
+		`INSERT INTO snippets (space_id, name, description, content_md, changed_by)
+		 VALUES ($5, $1, $2, $3, $4)
+		 RETURNING id, name, description, content_md, version, updated_at`,
+		name, description, contentMD, changedBy, spaceID(ctx)).
+		Scan(&s.ID, &s.Name, &s.Description, &s.ContentMD, &s.Version, &s.UpdatedAt)
+	return s, err
+}
+
+func (q *Queries) UpdateSnippet(ctx context.Context, id, name, description, contentMD, changedBy string) (Snippet, error) {
+	var s Snippet
+	err := q.Pool.QueryRow(ctx,
+		`UPDATE snippets
+		 SET name = $2, description = $3, content_md = $4, version = version + 1, updated_at = now(), changed_by = $5
+		 WHERE id = $1 AND space_id = $6 AND deleted = false
+		 RETURNING id, name, description, content_md, version, updated_at`,
+		id, name, description, contentMD, changedBy, spaceID(ctx)).
+		Scan(&s.ID, &s.Name, &s.Description, &s.ContentMD, &s.Version, &s.UpdatedAt)
+	return s, err
+}
+
+func (q *Queries) SoftDeleteSnippet(ctx context.Context, id, changedBy string) error {
+	_, err := q.Pool.Exec(ctx,
+		`UPDATE snippets SET deleted = true, version = version + 1, updated_at = now(), changed_by = $2
+		 WHERE id = $1 AND space_id = $3`, id, changedBy, spaceID(ctx))
+	return err
+}
+
+func (q *Queries) SaveSnippetHistory(ctx context.Context, s Snippet, changedBy string) error {
+	_, err := q.Pool.Exec(ctx,
+		`INSERT INTO snippets_history (snippet_id, version, name, description, content_md, changed_by)
+		 VALUES ($1, $2, $3, $4, $5, $6)`,
+		s.ID, s.Version, s.Name, s.Description, s.ContentMD, changedBy)
+	return err
+}
+
+// snippetRefPattern returns a PostgreSQL regular expression matching an
+// include of the named snippet.
+func snippetRefPattern(name string) string {
+	return `\{\{<\s*snippet\s+"` + regexp.QuoteMeta(name) + `"\s*>\}\}`
+}
+
+// ListPagesUsingSnippet returns the pages whose markdown includes the named
+// snippet directly.
+func (q *Queries) ListPagesUsingSnippet(ctx context.Context, name string) ([]SnippetUsage, error) {
+	rows, err := q.Pool.Query(ctx,
+		`SELECT s.name, s.title, p.slug, p.title
+		 FROM pages p JOIN sections s ON s.id = p.section_id
+		 WHERE s.space_id = $2 AND p.deleted = false AND s.deleted = false AND p.content_md ~ $1
+		 ORDER BY s.sort_order, p.sort_order`, snippetRefPattern(name), spaceID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var usages []SnippetUsage
+	for rows.Next() {
+		var u SnippetUsage
+		if err := rows.Scan(&u.SectionName, &u.SectionTitle, &u.Slug, &u.Title); err != nil {
+			return nil, err
+		}
+		usages = append(usages, u)
+	}
+	return usages, rows.Err()
+}
+
+// ListSnippetsUsingSnippet returns the snippets that include the named
+// snippet directly.
+func (q *Queries) ListSnippetsUsingSnippet(ctx context.Context, name string) ([]Snippet, error) {
+	rows, err := q.Pool.Query(ctx,
+		`SELECT id, name, description, content_md, version, updated_at
+		 FROM snippets WHERE space_id = $3 AND deleted = false AND name != $1 AND content_md ~ $2 ORDER BY name`,
+		name, snippetRefPattern(name), spaceID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snippets []Snippet
+	for rows.Next() {
+		var s Snippet
+		if err := rows.Scan(&s.ID, &s.Name, &s.Description, &s.ContentMD, &s.Version, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+	return snippets, rows.Err()
+}
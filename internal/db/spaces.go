@@ -0,0 +1,237 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// DefaultSpaceID is the id of the space that holds the content created
+// before spaces existed. It is served at the root of the site and cannot be
+// deleted.
+const DefaultSpaceID = "00000000-0000-0000-0000-000000000001"
+
+// Space is a separate documentation site with its own sections, rows,
+// images, site settings and roles. Users are shared between spaces and can
+// be members of several.
+type Space struct {
+	ID    string
+	Name  string
+	Title string
+	// Host is the host name the space is served on, if any. Spaces are also
+	// reachable under /s/<name>/ on every host.
+	Host      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// IsDefault reports whether s is the default space.
+func (s Space) IsDefault() bool {
+	return s.ID == DefaultSpaceID
+}
+
+type spaceKey struct{}
+
+// WithSpace returns a context whose queries are scoped to the given space.
+func WithSpace(ctx context.Context, s Space) context.Context {
+	return context.WithValue(ctx, spaceKey{}, s)
+}
+
+// SpaceFrom returns the space set by WithSpace. Contexts without a space
+// belong to the default space.
+func SpaceFrom(ctx context.Context) Space {
+	if s, ok := ctx.Value(spaceKey{}).(Space); ok {
+		return s
+	}
+	return Space{ID: DefaultSpaceID, Name: "default", Title: "Default"}
+}
+
+// spaceID returns the id of the space that queries run with ctx are scoped
+// to.
+func spaceID(ctx context.Context) string {
+	return SpaceFrom(ctx).ID
+}
+
+// --- Space queries ---
+
+const spaceColumns = `id, name, title, COALESCE(host, ''), created_at, updated_at`
+
+func scanSpace(row pgx.Row, s *Space) error {
+	return row.Scan(&s.ID, &s.Name, &s.Title, &s.Host, &s.CreatedAt, &s.UpdatedAt)
+}
+
+func (q *Queries) querySpaces(ctx context.Context, sql string, args ...any) ([]Space, error) {
+	rows, err := q.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var spaces []Space
+	for rows.Next() {
+		var s Space
+		if err := scanSpace(rows, &s); err != nil {
+			return nil, err
+		}
+		spaces = append(spaces, s)
+	}
+	return spaces, rows.Err()
+}
+
+// ListSpaces returns all spaces, the default space first.
+func (q *Queries) ListSpaces(ctx context.Context) ([]Space, error) {
+	return q.querySpaces(ctx,
+		`SELECT `+spaceColumns+` FROM spaces ORDER BY id <> $1, title`, DefaultSpaceID)
+}
+
+// ListUserSpaces returns the spaces the user is a member of, the default
+// space first.
+func (q *Queries) ListUserSpaces(ctx context.Context, userID string) ([]Space, error) {
+	return q.querySpaces(ctx,
+		`SELECT `+spaceColumns+` FROM spaces
+		 WHERE id IN (SELECT space_id FROM space_members WHERE user_id = $1)
+		 ORDER BY id <> $2, title`, userID, DefaultSpaceID)
+}
+
+func (q *Queries) GetSpace(ctx context.Context, id string) (Space, error) {
+	var s Space
+	err := scanSpace(q.Pool.QueryRow(ctx,
+		`SELECT `+spaceColumns+` FROM spaces WHERE id = $1`, id), &s)
+	return s, err
+}
+
+func (q *Queries) GetSpaceByName(ctx context.Context, name string) (Space, error) {
+	var s Space
+	err := scanSpace(q.Pool.QueryRow(ctx,
+		`SELECT `+spaceColumns+` FROM spaces WHERE name = $1`, name), &s)
+	return s, err
+}
+
+// GetSpaceByHost returns the space served on the given host name.
+func (q *Queries) GetSpaceByHost(ctx context.Context, host string) (Space, error) {
+	var s Space
+	err := scanSpace(q.Pool.QueryRow(ctx,
+		`SELECT `+spaceColumns+` FROM spaces WHERE host = lower($1)`, host), &s)
+	return s, err
+}
+
+// CreateSpace creates a space with default site settings and the built-in
+// roles. The creator becomes a member and admin of the new space.
+func (q *Queries) CreateSpace(ctx context.Context, name, title, host, createdBy string) (Space, error) {
+	tx, err := q.Pool.Begin(ctx)
+	if err != nil {
+		return Space{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	var s Space
+	err = scanSpace(tx.QueryRow(ctx,
+		`INSERT INTO spaces (name, title, host)
+		 VALUES ($1, $2, NULLIF(lower($3), ''))
+		 RETURNING `+spaceColumns,
+		name, title, host), &s)
+	if err != nil {
+		return Space{}, err
+	}
+
+	_, err = tx.Exec(ctx,
+		`INSERT INTO site_settings (space_id, site_title, heading, changed_by) VALUES ($1, $2, $2, $3)`,
+		s.ID, title, createdBy)
+	if err != nil {
+		return Space{}, err
+	}
+
+	_, err = tx.Exec(ctx,
+		`INSERT INTO roles (space_id, name, description) VALUES
+		 ($1, 'admin', 'Full access to all features'),
+		 ($1, 'editor', 'Can edit content'),
+		 ($1, 'reviewer', 'Can review and approve page changes')`, s.ID)
+	if err != nil {
+		return Space{}, err
+	}
+
+	_, err = tx.Exec(ctx,
+		`INSERT INTO space_members (space_id, user_id) VALUES ($1, $2)`, s.ID, createdBy)
+	if err != nil {
+		return Space{}, err
+	}
+	_, err = tx.Exec(ctx,
+		`INSERT INTO user_roles (user_id, role_id)
+		 SELECT $2, id FROM roles WHERE space_id = $1 AND name = 'admin'`, s.ID, createdBy)
+	if err != nil {
+		return Space{}, err
+	}
+
+	return s, tx.Commit(ctx)
+}
+
+func (q *Queries) UpdateSpace(ctx context.Context, id, title, host string) (Space, error) {
+	var s Space
+	err := scanSpace(q.Pool.QueryRow(ctx,
+		`UPDATE spaces SET title = $2, host = NULLIF(lower($3), ''), updated_at = now()
+		 WHERE id = $1
+		 RETURNING `+spaceColumns,
+		id, title, host), &s)
+	return s, err
+}
+
+// ErrDefaultSpace is returned when deleting the default space.
+var ErrDefaultSpace = errors.New("the default space cannot be deleted")
+
+// DeleteSpace deletes a space and all of its content.
+func (q *Queries) DeleteSpace(ctx context.Context, id string) error {
+	if id == DefaultSpaceID {
+		return ErrDefaultSpace
+	}
+	_, err := q.Pool.Exec(ctx, `DELETE FROM spaces WHERE id = $1`, id)
+	return err
+}
+
+// IsSpaceMember reports whether the user is a member of the space.
+func (q *Queries) IsSpaceMember(ctx context.Context, spaceID, userID string) (bool, error) {
+	var exists bool
+	err := q.Pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM space_members WHERE space_id = $1 AND user_id = $2)`,
+		spaceID, userID).Scan(&exists)
+	return exists, err
+}
+
+// CountUserSpaces returns the number of spaces the user is a member of.
+func (q *Queries) CountUserSpaces(ctx context.Context, userID string) (int, error) {
+	var n int
+	err := q.Pool.QueryRow(ctx,
+		`SELECT count(*) FROM space_members WHERE user_id = $1`, userID).Scan(&n)
+	return n, err
+}
+
+// AddSpaceMember makes the user a member of the current space.
+func (q *Queries) AddSpaceMember(ctx context.Context, userID string) error {
+	_, err := q.Pool.Exec(ctx,
+		`INSERT INTO space_members (space_id, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+		spaceID(ctx), userID)
+	return err
+}
+
+// RemoveSpaceMember removes the user and their roles from the current space.
+func (q *Queries) RemoveSpaceMember(ctx context.Context, userID string) error {
+	tx, err := q.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx,
+		`DELETE FROM user_roles WHERE user_id = $2 AND role_id IN (SELECT id FROM roles WHERE space_id = $1)`,
+		spaceID(ctx), userID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx,
+		`DELETE FROM space_members WHERE space_id = $1 AND user_id = $2`, spaceID(ctx), userID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,281 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// digestLockKey is the advisory lock key held while due digests are taken,
+// so that a digest is sent by one replica only.
+const digestLockKey int64 = 0x73646f63_64676573 // "sdocdges"
+
+// Notification frequencies.
+const (
+	NotifyImmediate = "immediate"
+	NotifyDaily     = "daily"
+)
+
+// Subscription is a user's subscription to a page or to a whole section.
+// Exactly one of PageID and SectionID is set; the page fields are empty for
+// section subscriptions.
+type Subscription struct {
+	ID           string
+	UserID       string
+	PageID       *string
+	SectionID    *string
+	SectionName  string
+	SectionTitle string
+	Slug         string
+	PageTitle    string
+	CreatedAt    time.Time
+}
+
+// NotifySettings are a user's notification preferences. Token authenticates
+// the unsubscribe links in their emails.
+type NotifySettings struct {
+	Frequency string
+	Token     string
+}
+
+// Subscriber is a user to notify about a page change, with the subscription
+// that matched it.
+type Subscriber struct {
+	User
+	Frequency      string
+	Token          string
+	SubscriptionID string
+	PageLevel      bool
+}
+
+// DigestEntry is a page change waiting for a user's daily digest.
+type DigestEntry struct {
+	UserID         string
+	SpaceID        string
+	PageID         string
+	SectionName    string
+	SectionTitle   string
+	RequiredRole   string
+	Slug           string
+	PageTitle      string
+	FromVersion    int
+	ToVersion      int
+	SubscriptionID string
+	PageLevel      bool
+	// EntryIDs are the queued changes merged into this entry, to be deleted
+	// once the digest is sent.
+	EntryIDs []string
+}
+
+// --- Subscription queries ---
+
+// Subscribe subscribes a user to a page or, when pageID is nil, to a
+// section. Subscribing twice is not an error.
+func (q *Queries) Subscribe(ctx context.Context, userID string, pageID, sectionID *string) error {
+	_, err := q.Pool.Exec(ctx,
+		`INSERT INTO subscriptions (user_id, page_id, section_id) VALUES ($1, $2, $3)
+		 ON CONFLICT DO NOTHING`,
+		userID, pageID, sectionID)
+	return err
+}
+
+// Unsubscribe removes a user's subscription to a page or section.
+func (q *Queries) Unsubscribe(ctx context.Context, userID string, pageID, sectionID *string) error {
+	_, err := q.Pool.Exec(ctx,
+		`DELETE FROM subscriptions
+		 WHERE user_id = $1 AND page_id IS NOT DISTINCT FROM $2 AND section_id IS NOT DISTINCT FROM $3`,
+		userID, pageID, sectionID)
+	return err
+}
+
+// DeleteSubscription removes a subscription by id, if it belongs to the user.
+func (q *Queries) DeleteSubscription(ctx context.Context, id, userID string) error {
+	_, err := q.Pool.Exec(ctx,
+		`DELETE FROM subscriptions WHERE id = $1 AND user_id = $2`, id, userID)
+	return err
+}
+
+// GetSubscriptionState reports whether a user is subscribed to a page and to
+// its section.
+func (q *Queries) GetSubscriptionState(ctx context.Context, userID, pageID, sectionID string) (page, section bool, err error) {
+	err = q.Pool.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM subscriptions WHERE user_id = $1 AND page_id = $2),
+		        EXISTS (SELECT 1 FROM subscriptions WHERE user_id = $1 AND section_id = $3)`,
+		userID, pageID, sectionID).Scan(&page, &section)
+	return page, section, err
+}
+
+// ListUserSubscriptions returns a user's subscriptions in the current space,
+// sections first.
+func (q *Queries) ListUserSubscriptions(ctx context.Context, userID string) ([]Subscription, error) {
+	rows, err := q.Pool.Query(ctx,
+		`SELECT sub.id, sub.user_id, sub.page_id, sub.section_id, s.name, s.title,
+		        COALESCE(p.slug, ''), COALESCE(p.title, ''), sub.created_at
+		 FROM subscriptions sub
+		 LEFT JOIN pages p ON p.id = sub.page_id
+		 JOIN sections s ON s.id = COALESCE(sub.section_id, p.section_id)
+		 WHERE sub.user_id = $1 AND s.space_id = $2 AND s.deleted = false AND (p.id IS NULL OR p.deleted = false)
+		 ORDER BY sub.page_id IS NOT NULL, s.sort_order, p.sort_order`, userID, spaceID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subs []Subscription
+	for rows.Next() {
+		var s Subscription
+		if err := rows.Scan(&s.ID, &s.UserID, &s.PageID, &s.SectionID, &s.SectionName, &s.SectionTitle,
+			&s.Slug, &s.PageTitle, &s.CreatedAt); err != nil {
+			return nil, err
+		}
+		subs = append(subs, s)
+	}
+	return subs, rows.Err()
+}
+
+func (q *Queries) GetNotifySettings(ctx context.Context, userID string) (NotifySettings, error) {
+	var s NotifySettings
+	err := q.Pool.QueryRow(ctx,
+		`SELECT notify_frequency, notify_token::text FROM users WHERE id = $1`, userID).
+		Scan(&s.Frequency, &s.Token)
+	return s, err
+}
+
+func (q *Queries) SetNotifyFrequency(ctx context.Context, userID, frequency string) error {
+	_, err := q.Pool.Exec(ctx,
+		`UPDATE users SET notify_frequency = $2, updated_at = now() WHERE id = $1`, userID, frequency)
+	return err
+}
+
+// GetSubscriptionByToken returns the subscription with the given id if it
+// belongs to the user owning the notify token.
+func (q *Queries) GetSubscriptionByToken(ctx context.Context, token, id string) (Subscription, error) {
+	var s Subscription
+	err := q.Pool.QueryRow(ctx,
+		`SELECT sub.id, sub.user_id, sub.page_id, sub.section_id, s.name, s.title,
+		        COALESCE(p.slug, ''), COALESCE(p.title, ''), sub.created_at
+		 FROM subscriptions sub
+		 JOIN users u ON u.id = sub.user_id
+		 LEFT JOIN pages p ON p.id = sub.page_id
+		 JOIN sections s ON s.id = COALESCE(sub.section_id, p.section_id)
+		 WHERE u.notify_token::text = $1 AND sub.id::text = $2`, token, id).
+		Scan(&s.ID, &s.UserID, &s.PageID, &s.SectionID, &s.SectionName, &s.SectionTitle,
+			&s.Slug, &s.PageTitle, &s.CreatedAt)
+	return s, err
+}
+
+// ListSubscribers returns the members of the current space subscribed to a
+// page or to its section. A user subscribed to both is listed once, with the
+// page subscription.
+func (q *Queries) ListSubscribers(ctx context.Context, pageID, sectionID string) ([]Subscriber, error) {
+	rows, err := q.Pool.Query(ctx,
+		`SELECT DISTINCT ON (u.id)
+		        u.id, u.firstname, u.lastname, u.company, u.email, u.password, u.last_login, u.created_at, u.updated_at,
+		        u.notify_frequency, u.notify_token::text, sub.id, sub.page_id IS NOT NULL
+		 FROM subscriptions sub
+		 JOIN users u ON u.id = sub.user_id
+		 WHERE (sub.page_id = $1 OR sub.section_id = $2)
+		   AND u.id IN (SELECT user_id FROM space_members WHERE space_id = $3)
+		 ORDER BY u.id, sub.page_id IS NULL`, pageID, sectionID, spaceID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subs []Subscriber
+	for rows.Next() {
+		var s Subscriber
+		if err := rows.Scan(&s.ID, &s.Firstname, &s.Lastname, &s.Company, &s.Email, &s.Password, &s.LastLogin, &s.CreatedAt, &s.UpdatedAt,
+			&s.Frequency, &s.Token, &s.SubscriptionID, &s.PageLevel); err != nil {
+			return nil, err
+		}
+		subs = append(subs, s)
+	}
+	return subs, rows.Err()
+}
+
+// AddDigestEntry queues a page change for a user's daily digest.
+func (q *Queries) AddDigestEntry(ctx context.Context, userID, pageID, subscriptionID string, fromVersion, toVersion int) error {
+	_, err := q.Pool.Exec(ctx,
+		`INSERT INTO digest_entries (user_id, page_id, subscription_id, from_version, to_version)
+		 VALUES ($1, $2, $3, $4, $5)`,
+		userID, pageID, subscriptionID, fromVersion, toVersion)
+	return err
+}
+
+// ClaimDueDigests returns the queued changes of every user whose last digest
+// is at least a day old, and marks their digest as sent so that no other run
+// claims them again today. The changes stay queued until DeleteDigestEntries
+// is called for them; changes to deleted pages are dropped. Several changes
+// to one page are merged into one entry spanning all of them. If another
+// replica holds the lock nothing is returned and ran is false.
+func (q *Queries) ClaimDueDigests(ctx context.Context) (entries []DigestEntry, ran bool, err error) {
+	tx, err := q.Pool.Begin(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := tx.QueryRow(ctx, `SELECT pg_try_advisory_xact_lock($1)`, digestLockKey).Scan(&ran); err != nil {
+		return nil, false, err
+	}
+	if !ran {
+		return nil, false, nil
+	}
+
+	rows, err := tx.Query(ctx,
+		`WITH due AS (
+		   UPDATE users SET last_digest_at = now()
+		   WHERE (last_digest_at IS NULL OR last_digest_at <= now() - interval '1 day')
+		     AND EXISTS (SELECT 1 FROM digest_entries e WHERE e.user_id = users.id)
+		   RETURNING id
+		 ), dropped AS (
+		   DELETE FROM digest_entries e USING due, pages p, sections s
+		   WHERE e.user_id = due.id AND p.id = e.page_id AND s.id = p.section_id AND (p.deleted OR s.deleted)
+		 )
+		 SELECT t.user_id, s.space_id, t.page_id, s.name, s.title, COALESCE(s.required_role, ''), p.slug, p.title,
+		        min(t.from_version), max(t.to_version),
+		        (array_agg(t.subscription_id))[1], bool_or(sub.page_id IS NOT NULL), array_agg(t.id::text)
+		 FROM digest_entries t
+		 JOIN due ON due.id = t.user_id
+		 JOIN pages p ON p.id = t.page_id
+		 JOIN sections s ON s.id = p.section_id
+		 JOIN subscriptions sub ON sub.id = t.subscription_id
+		 WHERE p.deleted = false AND s.deleted = false
+		 GROUP BY t.user_id, t.page_id, s.id, p.id
+		 ORDER BY t.user_id, s.space_id, s.sort_order, p.sort_order`)
+	if err != nil {
+		return nil, true, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e DigestEntry
+		if err := rows.Scan(&e.UserID, &e.SpaceID, &e.PageID, &e.SectionName, &e.SectionTitle, &e.RequiredRole, &e.Slug, &e.PageTitle,
+			&e.FromVersion, &e.ToVersion, &e.SubscriptionID, &e.PageLevel, &e.EntryIDs); err != nil {
+			return nil, true, err
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, true, err
+	}
+
+	return entries, true, tx.Commit(ctx)
+}
+
+// DeleteDigestEntries removes queued changes that have been sent.
+func (q *Queries) DeleteDigestEntries(ctx context.Context, ids []string) error {
+	_, err := q.Pool.Exec(ctx, `DELETE FROM digest_entries WHERE id = ANY($1)`, ids)
+	return err
+}
+
+// GetPageVersion returns the content of a page at the given version from
+// pages_history.
+func (q *Queries) GetPageVersion(ctx context.Context, pageID string, version int) (PageHistory, error) {
+	var h PageHistory
+	err := q.Pool.QueryRow(ctx,
+		`SELECT id, page_id, version, section_id, slug, title, content_md, sort_order, changed_at
+		 FROM pages_history WHERE page_id = $1 AND version = $2`, pageID, version).
+		Scan(&h.ID, &h.PageID, &h.Version, &h.SectionID, &h.Slug, &h.Title, &h.ContentMD, &h.SortOrder, &h.ChangedAt)
+	return h, err
+}
@@ -0,0 +1,128 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// CustomTheme is a theme defined by an admin. Vars maps CSS variable names
+// without the leading dashes to their values.
+type CustomTheme struct {
+	ID    string
+	Name  string
+	Label string
+	Vars  map[string]string
+	// Font is the name of the body font, such as "inter".
+	Font      string
+	Version   int
+	UpdatedAt time.Time
+}
+
+// --- Custom theme queries ---
+
+func (q *Queries) ListCustomThemes(ctx context.Context) ([]CustomTheme, error) {
+	rows, err := q.Pool.Query(ctx,
+		`SELECT id, name, label, vars, font, version, updated_at
+		 FROM custom_themes WHERE space_id = $1 ORDER BY label, name`, spaceID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var themes []CustomTheme
+	for rows.Next() {
+		var t CustomTheme
+		if err := rows.Scan(&t.ID, &t.Name, &t.Label, &t.Vars, &t.Font, &t.Version, &t.UpdatedAt); err != nil {
+			return nil, err
+		}
+		themes = append(themes, t)
+	}
+	return themes, rows.Err()
+}
+
+func (q *Queries) GetCustomTheme(ctx context.Context, id string) (CustomTheme, error) {
+	var t CustomTheme
+	err := q.Pool.QueryRow(ctx,
+		`SELECT id, name, label, vars, font, version, updated_at
+		 FROM custom_themes WHERE id = $1 AND space_id = $2`, id, spaceID(ctx)).
+		Scan(&t.ID, &t.Name, &t.Label, &t.Vars, &t.Font, &t.Version, &t.UpdatedAt)
+	return t, err
+}
+
+func (q *Queries) GetCustomThemeByName(ctx context.Context, name string) (CustomTheme, error) {
+	var t CustomTheme
+	err := q.Pool.QueryRow(ctx,
+		`SELECT id, name, label, vars, font, version, updated_at
+		 FROM custom_themes WHERE name = $1 AND space_id = $2`, name, spaceID(ctx)).
+		Scan(&t.ID, &t.Name, &t.Label, &t.Vars, &t.Font, &t.Version, &t.UpdatedAt)
+	return t, err
+}
+
+func (q *Queries) CreateCustomTheme(ctx context.Context, name, label string, vars map[string]string, font, changedBy string) (CustomTheme, error) {
+	var t CustomTheme
+	err := q.Pool.QueryRow(ctx,
+		`INSERT INTO custom_themes (space_id, name, label, vars, font, changed_by)
+		 VALUES ($6, $1, $2, $3, $4, $5)
+		 RETURNING id, name, label, vars, font, version, updated_at`,
+		name, label, vars, font, changedBy, spaceID(ctx)).
+		Scan(&t.ID, &t.Name, &t.Label, &t.Vars, &t.Font, &t.Version, &t.UpdatedAt)
+	return t, err
+}
+
+// UpdateCustomTheme changes a theme. The site's theme setting follows a
+// rename so that the theme in use stays selected.
+func (q *Queries) UpdateCustomTheme(ctx context.Context, id, name, label string, vars map[string]string, font, changedBy string) (CustomTheme, error) {
+	tx, err := q.Pool.Begin(ctx)
+	if err != nil {
+		return CustomTheme{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	var oldName string
+	if err := tx.QueryRow(ctx,
+		`SELECT name FROM custom_themes WHERE id = $1 AND space_id = $2 FOR UPDATE`, id, spaceID(ctx)).
+		Scan(&oldName); err != nil {
+		return CustomTheme{}, err
+	}
+	var t CustomTheme
+	if err := tx.QueryRow(ctx,
+		`UPDATE custom_themes
+		 SET name = $2, label = $3, vars = $4, font = $5, version = version + 1, updated_at = now(), changed_by = $6
+		 WHERE id = $1
+		 RETURNING id, name, label, vars, font, version, updated_at`,
+		id, name, label, vars, font, changedBy).
+		Scan(&t.ID, &t.Name, &t.Label, &t.Vars, &t.Font, &t.Version, &t.UpdatedAt); err != nil {
+		return CustomTheme{}, err
+	}
+	if oldName != name {
+		if _, err := tx.Exec(ctx,
+			`UPDATE site_settings SET theme = $2 WHERE space_id = $3 AND theme = $1`,
+			oldName, name, spaceID(ctx)); err != nil {
+			return CustomTheme{}, err
+		}
+	}
+	return t, tx.Commit(ctx)
+}
+
+// DeleteCustomTheme removes a theme. If it is the site's theme, the site
+// falls back to midnight.
+func (q *Queries) DeleteCustomTheme(ctx context.Context, id string) error {
+	tx, err := q.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var name string
+	if err := tx.QueryRow(ctx,
+		`DELETE FROM custom_themes WHERE id = $1 AND space_id = $2 RETURNING name`, id, spaceID(ctx)).
+		Scan(&name); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx,
+		`UPDATE site_settings SET theme = 'midnight' WHERE space_id = $2 AND theme = $1`,
+		name, spaceID(ctx)); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,96 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// PageTranslation is a page's title and content in another locale.
+// SourceVersion is the page version it was translated from.
+type PageTranslation struct {
+	ID            string
+	PageID        string
+	Locale        string
+	Title         string
+	ContentMD     string
+	SourceVersion int
+	UpdatedAt     time.Time
+}
+
+// Outdated reports whether the page has changed since it was translated.
+func (t PageTranslation) Outdated(pageVersion int) bool {
+	return t.SourceVersion < pageVersion
+}
+
+// --- Translation queries ---
+
+const translationColumns = `id, page_id, locale, title, content_md, source_version, updated_at`
+
+func (q *Queries) GetPageTranslation(ctx context.Context, pageID, locale string) (PageTranslation, error) {
+	var t PageTranslation
+	err := q.Pool.QueryRow(ctx,
+		`SELECT `+translationColumns+` FROM page_translations WHERE page_id = $1 AND locale = $2`, pageID, locale).
+		Scan(&t.ID, &t.PageID, &t.Locale, &t.Title, &t.ContentMD, &t.SourceVersion, &t.UpdatedAt)
+	return t, err
+}
+
+// ListPageTranslations returns the translations of a page by locale.
+func (q *Queries) ListPageTranslations(ctx context.Context, pageID string) (map[string]PageTranslation, error) {
+	rows, err := q.Pool.Query(ctx,
+		`SELECT `+translationColumns+` FROM page_translations WHERE page_id = $1`, pageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	translations := make(map[string]PageTranslation)
+	for rows.Next() {
+		var t PageTranslation
+		if err := rows.Scan(&t.ID, &t.PageID, &t.Locale, &t.Title, &t.ContentMD, &t.SourceVersion, &t.UpdatedAt); err != nil {
+			return nil, err
+		}
+		translations[t.Locale] = t
+	}
+	return translations, rows.Err()
+}
+
+// ListTranslatedTitles returns the translated titles of a section's pages in
+// a locale, by page id.
+func (q *Queries) ListTranslatedTitles(ctx context.Context, sectionID, locale string) (map[string]string, error) {
+	rows, err := q.Pool.Query(ctx,
+		`SELECT t.page_id, t.title FROM page_translations t
+		 JOIN pages p ON p.id = t.page_id
+		 WHERE p.section_id = $1 AND t.locale = $2`, sectionID, locale)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	titles := make(map[string]string)
+	for rows.Next() {
+		var pageID, title string
+		if err := rows.Scan(&pageID, &title); err != nil {
+			return nil, err
+		}
+		titles[pageID] = title
+	}
+	return titles, rows.Err()
+}
+
+// SavePageTranslation creates or replaces the translation of a page into a
+// locale.
+func (q *Queries) SavePageTranslation(ctx context.Context, pageID, locale, title, contentMD string, sourceVersion int, changedBy string) error {
+	_, err := q.Pool.Exec(ctx,
+		`INSERT INTO page_translations (page_id, locale, title, content_md, source_version, changed_by)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 ON CONFLICT (page_id, locale) DO UPDATE
+		 SET title = $3, content_md = $4, source_version = $5, changed_by = $6, updated_at = now()`,
+		pageID, locale, title, contentMD, sourceVersion, changedBy)
+	return err
+}
+
+func (q *Queries) DeletePageTranslation(ctx context.Context, pageID, locale string) error {
+	_, err := q.Pool.Exec(ctx,
+		`DELETE FROM page_translations WHERE page_id = $1 AND locale = $2`, pageID, locale)
+	return err
+}
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// SiteVariable is a {{var.key}} value. A nil SectionID is the global value;
+// otherwise it overrides the global value within that section.
+type SiteVariable struct {
+	ID           string
+	Key          string
+	Value        string
+	SectionID    *string
+	SectionTitle string
+	UpdatedAt    time.Time
+}
+
+// --- Site variable queries ---
+
+func (q *Queries) ListSiteVariables(ctx context.Context) ([]SiteVariable, error) {
+	rows, err := q.Pool.Query(ctx,
+		`SELECT v.id, v.key, v.value, v.section_id, COALESCE(s.title, ''), v.updated_at
+		 FROM site_variables v LEFT JOIN sections s ON s.id = v.section_id
+		 WHERE v.space_id = $1 AND (v.section_id IS NULL OR s.deleted = false)
+		 ORDER BY v.key, v.section_id NULLS FIRST`, spaceID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var vars []SiteVariable
+	for rows.Next() {
+		var v SiteVariable
+		if err := rows.Scan(&v.ID, &v.Key, &v.Value, &v.SectionID, &v.SectionTitle, &v.UpdatedAt); err != nil {
+			return nil, err
+		}
+		vars = append(vars, v)
+	}
+	return vars, rows.Err()
+}
+
+// GetVariableValues returns the variables in effect for a section, with
+// section overrides applied on top of the global values. An empty sectionID
+// returns the global values only.
+func (q *Queries) GetVariableValues(ctx context.Context, sectionID string) (map[string]string, error) {
+	rows, err := q.Pool.Query(ctx,
+		`SELECT key, value FROM site_variables
+		 WHERE space_id = $2 AND (section_id IS NULL OR section_id = NULLIF($1, '')::uuid)
+		 ORDER BY section_id NULLS FIRST`, sectionID, spaceID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := map[string]string{}
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		values[key] = value
+	}
+	return values, rows.Err()
+}
+
+func (q *Queries) GetSiteVariable(ctx context.Context, id string) (SiteVariable, error) {
+	var v SiteVariable
+	err := q.Pool.QueryRow(ctx,
+		`SELECT v.id, v.key, v.value, v.section_id, COALESCE(s.title, ''), v.updated_at
+		 FROM site_variables v LEFT JOIN sections s ON s.id = v.section_id
+		 WHERE v.id = $1 AND v.space_id = $2`, id, spaceID(ctx)).
+		Scan(&v.ID, &v.Key, &v.Value, &v.SectionID, &v.SectionTitle, &v.UpdatedAt)
+	return v, err
+}
+
+// SiteVariableExists reports whether key already has a value in the given
+// scope, ignoring the variable with id excludeID.
+func (q *Queries) SiteVariableExists(ctx context.Context, key string, sectionID *string, excludeID string) (bool, error) {
+	var exists bool
+	err := q.Pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM site_variables
+		 WHERE space_id = $4 AND key = $1 AND section_id IS NOT DISTINCT FROM $2 AND id::text != $3)`,
+		key, sectionID, excludeID, spaceID(ctx)).Scan(&exists)
+	return exists, err
+}
+
+func (q *Queries) CreateSiteVariable(ctx context.Context, key, value string, sectionID *string, changedBy string) error {
+	_, err := q.Pool.Exec(ctx,
+		`INSERT INTO site_variables (space_id, key, value, section_id, changed_by) VALUES ($5, $1, $2, $3, $4)`,
+		key, value, sectionID, changedBy, spaceID(ctx))
+	return err
+}
+
+func (q *Queries) UpdateSiteVariable(ctx context.Context, id, key, value string, sectionID *string, changedBy string) error {
+	_, err := q.Pool.Exec(ctx,
+		`UPDATE site_variables SET key = $2, value = $3, section_id = $4, updated_at = now(), changed_by = $5
+		 WHERE id = $1 AND space_id = $6`,
+		id, key, value, sectionID, changedBy, spaceID(ctx))
+	return err
+}
+
+func (q *Queries) DeleteSiteVariable(ctx context.Context, id string) error {
+	_, err := q.Pool.Exec(ctx, `DELETE FROM site_variables WHERE id = $1 AND space_id = $2`, id, spaceID(ctx))
+	return err
+}
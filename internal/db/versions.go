@@ -0,0 +1,209 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	// ErrVersionFrozen is returned when branching into a frozen version.
+	ErrVersionFrozen = errors.New("version is frozen")
+	// ErrNothingToBranch is returned when none of the sections to branch
+	// has live pages that are not in the version already.
+	ErrNothingToBranch = errors.New("nothing to branch")
+)
+
+// DocVersion is a named version of the documentation, holding copies of the
+// pages of the sections branched into it.
+type DocVersion struct {
+	ID        string
+	Name      string
+	Frozen    bool
+	CreatedAt time.Time
+	// SectionIDs lists the sections branched into the version.
+	SectionIDs []string
+	PageCount  int
+}
+
+// HasSection reports whether the section was branched into the version.
+func (v DocVersion) HasSection(sectionID string) bool {
+	return slices.Contains(v.SectionIDs, sectionID)
+}
+
+// SectionVersion is a version a section was branched into, for the version
+// switcher. HasPage reports whether the version has the page being viewed.
+type SectionVersion struct {
+	Name    string
+	Frozen  bool
+	HasPage bool
+}
+
+// --- Version queries ---
+
+// ListDocVersions returns all versions, newest first.
+func (q *Queries) ListDocVersions(ctx context.Context) ([]DocVersion, error) {
+	rows, err := q.Pool.Query(ctx,
+		`SELECT v.id, v.name, v.frozen, v.created_at,
+		        COALESCE(array_agg(DISTINCT p.section_id) FILTER (WHERE p.section_id IS NOT NULL), '{}'),
+		        count(p.id)
+		 FROM doc_versions v
+		 LEFT JOIN version_pages p ON p.version_id = v.id
+		 WHERE v.space_id = $1
+		 GROUP BY v.id
+		 ORDER BY v.created_at DESC`, spaceID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []DocVersion
+	for rows.Next() {
+		var v DocVersion
+		if err := rows.Scan(&v.ID, &v.Name, &v.Frozen, &v.CreatedAt, &v.SectionIDs, &v.PageCount); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// ListSectionVersions returns the versions a section was branched into,
+// newest first.
+func (q *Queries) ListSectionVersions(ctx context.Context, sectionID, slug string) ([]SectionVersion, error) {
+	rows, err := q.Pool.Query(ctx,
+		`SELECT v.name, v.frozen, bool_or(p.slug = $2)
+		 FROM doc_versions v
+		 JOIN version_pages p ON p.version_id = v.id
+		 WHERE p.section_id = $1
+		 GROUP BY v.id
+		 ORDER BY v.created_at DESC`, sectionID, slug)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []SectionVersion
+	for rows.Next() {
+		var v SectionVersion
+		if err := rows.Scan(&v.Name, &v.Frozen, &v.HasPage); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+func (q *Queries) GetDocVersionByName(ctx context.Context, name string) (DocVersion, error) {
+	var v DocVersion
+	err := q.Pool.QueryRow(ctx,
+		`SELECT id, name, frozen, created_at FROM doc_versions WHERE name = $1 AND space_id = $2`, name, spaceID(ctx)).
+		Scan(&v.ID, &v.Name, &v.Frozen, &v.CreatedAt)
+	return v, err
+}
+
+// BranchDocVersion copies the live pages of the given sections into the
+// named version, creating it if needed, and returns how many pages were
+// copied. Sections already in the version are left as they are.
+func (q *Queries) BranchDocVersion(ctx context.Context, name string, sectionIDs []string, createdBy string) (int64, error) {
+	tx, err := q.Pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	var id string
+	var frozen bool
+	err = tx.QueryRow(ctx,
+		`INSERT INTO doc_versions (space_id, name, created_by) VALUES ($3, $1, $2)
+		 ON CONFLICT (space_id, name) DO UPDATE SET name = EXCLUDED.name
+		 RETURNING id, frozen`, name, createdBy, spaceID(ctx)).Scan(&id, &frozen)
+	if err != nil {
+		return 0, err
+	}
+	if frozen {
+		return 0, ErrVersionFrozen
+	}
+
+	tag, err := tx.Exec(ctx,
+		`INSERT INTO version_pages (version_id, section_id, slug, title, content_md, sort_order, parent_slug, changed_by)
+		 SELECT $1, section_id, slug, title, content_md, sort_order, parent_slug, $3
+		 FROM pages
+		 WHERE section_id = ANY($2) AND deleted = false AND `+pageLive+`
+		   AND section_id IN (SELECT id FROM sections WHERE space_id = $4)
+		   AND section_id NOT IN (SELECT section_id FROM version_pages WHERE version_id = $1)`,
+		id, sectionIDs, createdBy, spaceID(ctx))
+	if err != nil {
+		return 0, err
+	}
+	if tag.RowsAffected() == 0 {
+		return 0, ErrNothingToBranch
+	}
+	return tag.RowsAffected(), tx.Commit(ctx)
+}
+
+func (q *Queries) SetDocVersionFrozen(ctx context.Context, id string, frozen bool) error {
+	_, err := q.Pool.Exec(ctx, `UPDATE doc_versions SET frozen = $2 WHERE id = $1 AND space_id = $3`, id, frozen, spaceID(ctx))
+	return err
+}
+
+// DeleteDocVersion removes a version and its pages.
+func (q *Queries) DeleteDocVersion(ctx context.Context, id string) error {
+	_, err := q.Pool.Exec(ctx, `DELETE FROM doc_versions WHERE id = $1 AND space_id = $2`, id, spaceID(ctx))
+	return err
+}
+
+// Version pages are returned as Pages so that they share the page tree and
+// rendering code. They have no drafts or schedule and are always published.
+const versionPageColumns = `id, section_id, slug, title, content_md, sort_order, parent_slug`
+
+func scanVersionPage(row pgx.Row, p *Page) error {
+	p.Published = true
+	return row.Scan(&p.ID, &p.SectionID, &p.Slug, &p.Title, &p.ContentMD, &p.SortOrder, &p.ParentSlug)
+}
+
+func (q *Queries) ListVersionPages(ctx context.Context, versionID, sectionID string) ([]Page, error) {
+	rows, err := q.Pool.Query(ctx,
+		`SELECT `+versionPageColumns+` FROM version_pages
+		 WHERE version_id = $1 AND section_id = $2
+		 ORDER BY sort_order, slug`, versionID, sectionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pages []Page
+	for rows.Next() {
+		var p Page
+		if err := scanVersionPage(rows, &p); err != nil {
+			return nil, err
+		}
+		pages = append(pages, p)
+	}
+	return pages, rows.Err()
+}
+
+func (q *Queries) GetVersionPage(ctx context.Context, versionID, sectionID, slug string) (Page, error) {
+	var p Page
+	err := scanVersionPage(q.Pool.QueryRow(ctx,
+		`SELECT `+versionPageColumns+` FROM version_pages
+		 WHERE version_id = $1 AND section_id = $2 AND slug = $3`, versionID, sectionID, slug), &p)
+	return p, err
+}
+
+// UpdateVersionPage changes a page of a version that is not frozen. It
+// reports whether a page was updated.
+func (q *Queries) UpdateVersionPage(ctx context.Context, id, title, contentMD, changedBy string) (bool, error) {
+	tag, err := q.Pool.Exec(ctx,
+		`UPDATE version_pages p SET title = $2, content_md = $3, updated_at = now(), changed_by = $4
+		 FROM doc_versions v
+		 WHERE p.id = $1 AND v.id = p.version_id AND v.space_id = $5 AND NOT v.frozen`,
+		id, title, contentMD, changedBy, spaceID(ctx))
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
@@ -0,0 +1,233 @@
+package db
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// Webhook delivery statuses.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook is an endpoint notified of content events. Events lists event
+// names such as "page.updated" and wildcards such as "page.*".
+type Webhook struct {
+	ID          string
+	URL         string
+	Description string
+	Secret      string
+	Events      []string
+	Active      bool
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	// Counts of the deliveries in the log, for the list page.
+	Pending int
+	Failed  int
+}
+
+// HasEvent reports whether the webhook lists event, as a name or wildcard.
+func (w Webhook) HasEvent(event string) bool {
+	return slices.Contains(w.Events, event)
+}
+
+type WebhookDelivery struct {
+	ID             string
+	WebhookID      string
+	URL            string
+	Secret         string
+	Event          string
+	Payload        string
+	Status         string
+	Attempts       int
+	NextAttemptAt  time.Time
+	LastAttemptAt  *time.Time
+	ResponseStatus *int
+	LastError      string
+	CreatedAt      time.Time
+}
+
+// --- Webhook queries ---
+
+func (q *Queries) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	rows, err := q.Pool.Query(ctx,
+		`SELECT w.id, w.url, w.description, w.secret, w.events, w.active, w.created_at, w.updated_at,
+		        (SELECT count(*) FROM webhook_deliveries d WHERE d.webhook_id = w.id AND d.status = 'pending'),
+		        (SELECT count(*) FROM webhook_deliveries d WHERE d.webhook_id = w.id AND d.status = 'failed')
+		 FROM webhooks w
+		 WHERE w.space_id = $1
+		 ORDER BY w.created_at`, spaceID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hooks []Webhook
+	for rows.Next() {
+		var w Webhook
+		if err := rows.Scan(&w.ID, &w.URL, &w.Description, &w.Secret, &w.Events, &w.Active, &w.CreatedAt, &w.UpdatedAt,
+			&w.Pending, &w.Failed); err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, w)
+	}
+	return hooks, rows.Err()
+}
+
+func (q *Queries) GetWebhook(ctx context.Context, id string) (Webhook, error) {
+	var w Webhook
+	err := q.Pool.QueryRow(ctx,
+		`SELECT id, url, description, secret, events, active, created_at, updated_at
+		 FROM webhooks WHERE id = $1 AND space_id = $2`, id, spaceID(ctx)).
+		Scan(&w.ID, &w.URL, &w.Description, &w.Secret, &w.Events, &w.Active, &w.CreatedAt, &w.UpdatedAt)
+	return w, err
+}
+
+func (q *Queries) CreateWebhook(ctx context.Context, url, description, secret string, events []string, active bool) (string, error) {
+	var id string
+	err := q.Pool.QueryRow(ctx,
+		`INSERT INTO webhooks (space_id, url, description, secret, events, active)
+		 VALUES ($6, $1, $2, $3, $4, $5)
+		 RETURNING id`,
+		url, description, secret, events, active, spaceID(ctx)).Scan(&id)
+	return id, err
+}
+
+func (q *Queries) UpdateWebhook(ctx context.Context, id, url, description string, events []string, active bool) error {
+	_, err := q.Pool.Exec(ctx,
+		`UPDATE webhooks SET url = $2, description = $3, events = $4, active = $5, updated_at = now()
+		 WHERE id = $1 AND space_id = $6`,
+		id, url, description, events, active, spaceID(ctx))
+	return err
+}
+
+func (q *Queries) SetWebhookSecret(ctx context.Context, id, secret string) error {
+	_, err := q.Pool.Exec(ctx,
+		`UPDATE webhooks SET secret = $2, updated_at = now() WHERE id = $1 AND space_id = $3`, id, secret, spaceID(ctx))
+	return err
+}
+
+func (q *Queries) DeleteWebhook(ctx context.Context, id string) error {
+	_, err := q.Pool.Exec(ctx, `DELETE FROM webhooks WHERE id = $1 AND space_id = $2`, id, spaceID(ctx))
+	return err
+}
+
+// EnqueueWebhookEvent queues a delivery of an event to every active webhook
+// of the current space subscribed to it, and returns how many were queued.
+func (q *Queries) EnqueueWebhookEvent(ctx context.Context, event, payload string) (int64, error) {
+	tag, err := q.Pool.Exec(ctx,
+		`INSERT INTO webhook_deliveries (webhook_id, event, payload)
+		 SELECT id, $1, $2 FROM webhooks
+		 WHERE space_id = $3 AND active AND ($1 = ANY(events) OR split_part($1, '.', 1) || '.*' = ANY(events))`,
+		event, payload, spaceID(ctx))
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// EnqueueWebhookDelivery queues a delivery to a single webhook, whatever
+// events it is subscribed to.
+func (q *Queries) EnqueueWebhookDelivery(ctx context.Context, webhookID, event, payload string) error {
+	_, err := q.Pool.Exec(ctx,
+		`INSERT INTO webhook_deliveries (webhook_id, event, payload) VALUES ($1, $2, $3)`,
+		webhookID, event, payload)
+	return err
+}
+
+// ClaimWebhookDeliveries returns up to limit due deliveries and pushes their
+// next attempt back by lease, so that other replicas skip them while they
+// are being sent. A delivery whose sender dies is retried once the lease
+// runs out.
+func (q *Queries) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]WebhookDelivery, error) {
+	rows, err := q.Pool.Query(ctx,
+		`WITH due AS (
+		   SELECT id FROM webhook_deliveries
+		   WHERE status = 'pending' AND next_attempt_at <= now()
+		   ORDER BY next_attempt_at
+		   LIMIT $1
+		   FOR UPDATE SKIP LOCKED
+		 )
+		 UPDATE webhook_deliveries d
+		 SET next_attempt_at = now() + $2::interval
+		 FROM due, webhooks w
+		 WHERE d.id = due.id AND w.id = d.webhook_id
+		 RETURNING d.id, d.webhook_id, w.url, w.secret, d.event, d.payload, d.attempts`,
+		limit, lease)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []WebhookDelivery
+	for rows.Next() {
+		var d WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.URL, &d.Secret, &d.Event, &d.Payload, &d.Attempts); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// RecordWebhookAttempt stores the outcome of sending a delivery. A pending
+// status schedules another attempt at nextAttempt.
+func (q *Queries) RecordWebhookAttempt(ctx context.Context, id, status string, responseStatus *int, lastError string, nextAttempt time.Time) error {
+	_, err := q.Pool.Exec(ctx,
+		`UPDATE webhook_deliveries
+		 SET status = $2, attempts = attempts + 1, last_attempt_at = now(),
+		     response_status = $3, last_error = $4, next_attempt_at = $5
+		 WHERE id = $1`,
+		id, status, responseStatus, lastError, nextAttempt)
+	return err
+}
+
+// RetryWebhookDelivery queues a delivery to be sent again now.
+func (q *Queries) RetryWebhookDelivery(ctx context.Context, id string) (string, error) {
+	var webhookID string
+	err := q.Pool.QueryRow(ctx,
+		`UPDATE webhook_deliveries SET status = 'pending', next_attempt_at = now()
+		 WHERE id = $1 AND webhook_id IN (SELECT id FROM webhooks WHERE space_id = $2)
+		 RETURNING webhook_id`, id, spaceID(ctx)).Scan(&webhookID)
+	return webhookID, err
+}
+
+// ListWebhookDeliveries returns the latest deliveries of a webhook.
+func (q *Queries) ListWebhookDeliveries(ctx context.Context, webhookID string, limit int) ([]WebhookDelivery, error) {
+	rows, err := q.Pool.Query(ctx,
+		`SELECT id, webhook_id, event, payload, status, attempts, next_attempt_at, last_attempt_at,
+		        response_status, last_error, created_at
+		 FROM webhook_deliveries
+		 WHERE webhook_id = $1
+		 ORDER BY created_at DESC
+		 LIMIT $2`, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []WebhookDelivery
+	for rows.Next() {
+		var d WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &d.NextAttemptAt, &d.LastAttemptAt,
+			&d.ResponseStatus, &d.LastError, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// PruneWebhookDeliveries removes finished deliveries older than the given
+// time from the log.
+func (q *Queries) PruneWebhookDeliveries(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := q.Pool.Exec(ctx,
+		`DELETE FROM webhook_deliveries WHERE status <> 'pending' AND created_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
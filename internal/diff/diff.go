@@ -0,0 +1,126 @@
+// Package diff computes line-based differences between two texts.
+package diff
+
+import "strings"
+
+// Op is the kind of a diff line.
+type Op int
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+// Line is one line of a diff. OldNum and NewNum are 1-based line numbers in
+// the old and new text; the one that does not apply is 0.
+type Line struct {
+	Op     Op
+	Text   string
+	OldNum int
+	NewNum int
+}
+
+func (l Line) IsInsert() bool { return l.Op == Insert }
+func (l Line) IsDelete() bool { return l.Op == Delete }
+
+// maxCells bounds the LCS table. Larger inputs fall back to replacing the
+// whole differing middle section.
+const maxCells = 4 << 20
+
+// Lines returns the line diff turning a into b.
+func Lines(a, b string) []Line {
+	old := splitLines(a)
+	cur := splitLines(b)
+
+	// Trim the common prefix and suffix; typical edits are small.
+	prefix := 0
+	for prefix < len(old) && prefix < len(cur) && old[prefix] == cur[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(cur)-prefix &&
+		old[len(old)-1-suffix] == cur[len(cur)-1-suffix] {
+		suffix++
+	}
+
+	var out []Line
+	for i := 0; i < prefix; i++ {
+		out = append(out, Line{Op: Equal, Text: old[i], OldNum: i + 1, NewNum: i + 1})
+	}
+	out = append(out, middle(old[prefix:len(old)-suffix], cur[prefix:len(cur)-suffix], prefix, prefix)...)
+	for i := 0; i < suffix; i++ {
+		o := len(old) - suffix + i
+		n := len(cur) - suffix + i
+		out = append(out, Line{Op: Equal, Text: old[o], OldNum: o + 1, NewNum: n + 1})
+	}
+	return out
+}
+
+// middle diffs the differing parts of the texts with a longest common
+// subsequence table. oldOff and newOff are the line offsets of the slices.
+func middle(a, b []string, oldOff, newOff int) []Line {
+	var out []Line
+	if len(a)*len(b) > maxCells {
+		for i, s := range a {
+			out = append(out, Line{Op: Delete, Text: s, OldNum: oldOff + i + 1})
+		}
+		for j, s := range b {
+			out = append(out, Line{Op: Insert, Text: s, NewNum: newOff + j + 1})
+		}
+		return out
+	}
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			out = append(out, Line{Op: Equal, Text: a[i], OldNum: oldOff + i + 1, NewNum: newOff + j + 1})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			out = append(out, Line{Op: Delete, Text: a[i], OldNum: oldOff + i + 1})
+			i++
+		default:
+			out = append(out, Line{Op: Insert, Text: b[j], NewNum: newOff + j + 1})
+			j++
+		}
+	}
+	return out
+}
+
+// Stats counts the inserted and deleted lines of a diff.
+func Stats(lines []Line) (inserted, deleted int) {
+	for _, l := range lines {
+		switch l.Op {
+		case Insert:
+			inserted++
+		case Delete:
+			deleted++
+		}
+	}
+	return inserted, deleted
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,111 @@
+package diff
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// format writes a diff one line per entry as "op old new text", with "="
+// for equal, "+" for inserted and "-" for deleted lines.
+func format(lines []Line) []string {
+	ops := map[Op]string{Equal: "=", Insert: "+", Delete: "-"}
+	var out []string
+	for _, l := range lines {
+		out = append(out, fmt.Sprintf("%s %d %d %s", ops[l.Op], l.OldNum, l.NewNum, l.Text))
+	}
+	return out
+}
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []string
+	}{
+		{"both empty", "", "", nil},
+		{"identical", "a\nb\n", "a\nb\n", []string{"= 1 1 a", "= 2 2 b"}},
+		{"from empty", "", "a\nb", []string{"+ 0 1 a", "+ 0 2 b"}},
+		{"to empty", "a\nb", "", []string{"- 1 0 a", "- 2 0 b"}},
+		{"changed line", "a\nb\nc", "a\nx\nc", []string{"= 1 1 a", "- 2 0 b", "+ 0 2 x", "= 3 3 c"}},
+		{"inserted line", "a\nc", "a\nb\nc", []string{"= 1 1 a", "+ 0 2 b", "= 2 3 c"}},
+		{"deleted line", "a\nb\nc", "a\nc", []string{"= 1 1 a", "- 2 0 b", "= 3 2 c"}},
+		{"moved line", "a\nb\nc\nd", "b\nc\na\nd", []string{"- 1 0 a", "= 2 1 b", "= 3 2 c", "+ 0 3 a", "= 4 4 d"}},
+		{"CRLF and trailing newline", "a\r\nb\r\n", "a\nb", []string{"= 1 1 a", "= 2 2 b"}},
+		{"blank lines", "a\n\nb", "a\nb", []string{"= 1 1 a", "- 2 0 ", "= 3 2 b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := format(Lines(tt.a, tt.b)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lines(%q, %q) =\n%s\nwant\n%s", tt.a, tt.b, strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+// TestLinesReconstruct checks that the old and new texts can be rebuilt
+// from every diff and that line numbers count up without gaps.
+func TestLinesReconstruct(t *testing.T) {
+	pairs := [][2]string{
+		{"a\nb\nc\nd\ne", "a\nc\nd\nf\ne\ng"},
+		{"x\nx\nx", "x\ny\nx\nx"},
+		{"1\n2\n3\n4\n5\n6", "6\n5\n4\n3\n2\n1"},
+	}
+	for _, p := range pairs {
+		var oldLines, newLines []string
+		oldNum, newNum := 0, 0
+		for _, l := range Lines(p[0], p[1]) {
+			if l.Op != Insert {
+				oldNum++
+				if l.OldNum != oldNum {
+					t.Errorf("%q: old line number %d, want %d", p, l.OldNum, oldNum)
+				}
+				oldLines = append(oldLines, l.Text)
+			}
+			if l.Op != Delete {
+				newNum++
+				if l.NewNum != newNum {
+					t.Errorf("%q: new line number %d, want %d", p, l.NewNum, newNum)
+				}
+				newLines = append(newLines, l.Text)
+			}
+		}
+		if got := strings.Join(oldLines, "\n"); got != p[0] {
+			t.Errorf("old text rebuilt as %q, want %q", got, p[0])
+		}
+		if got := strings.Join(newLines, "\n"); got != p[1] {
+			t.Errorf("new text rebuilt as %q, want %q", got, p[1])
+		}
+	}
+}
+
+// TestLinesLarge checks the fallback for inputs too large for the LCS
+// table: the differing middle is deleted and inserted as a whole.
+func TestLinesLarge(t *testing.T) {
+	n := 3000
+	var a, b strings.Builder
+	a.WriteString("same\n")
+	b.WriteString("same\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&a, "old %d\n", i)
+		fmt.Fprintf(&b, "new %d\n", i)
+	}
+	lines := Lines(a.String(), b.String())
+	if len(lines) != 1+2*n {
+		t.Fatalf("got %d lines, want %d", len(lines), 1+2*n)
+	}
+	if lines[0].Op != Equal || lines[1].Op != Delete || lines[n].Op != Delete || lines[n+1].Op != Insert {
+		t.Errorf("unexpected fallback diff: %v %v %v %v", lines[0], lines[1], lines[n], lines[n+1])
+	}
+	if ins, del := Stats(lines); ins != n || del != n {
+		t.Errorf("Stats = %d, %d; want %d, %d", ins, del, n, n)
+	}
+}
+
+func TestStats(t *testing.T) {
+	ins, del := Stats(Lines("a\nb\nc", "a\nx\ny\nc"))
+	if ins != 2 || del != 1 {
+		t.Errorf("Stats = %d, %d; want 2, 1", ins, del)
+	}
+}
@@ -0,0 +1,51 @@
+package markdown
+
+import (
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// outsideCode applies fn to the parts of source that are not code and keeps
+// code blocks and code spans as written, so that pages can show snippet and
+// variable syntax in code without it being expanded.
+func outsideCode(source string, fn func(string) string) string {
+	src := []byte(source)
+	doc := md.Parser().Parse(text.NewReader(src))
+
+	var code [][2]int
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n.(type) {
+		case *ast.FencedCodeBlock, *ast.CodeBlock:
+			if lines := n.Lines(); lines.Len() > 0 {
+				code = append(code, [2]int{lines.At(0).Start, lines.At(lines.Len() - 1).Stop})
+			}
+			return ast.WalkSkipChildren, nil
+		case *ast.CodeSpan:
+			first, ok1 := n.FirstChild().(*ast.Text)
+			last, ok2 := n.LastChild().(*ast.Text)
+			if ok1 && ok2 {
+				code = append(code, [2]int{first.Segment.Start, last.Segment.Stop})
+			}
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	if len(code) == 0 {
+		return fn(source)
+	}
+
+	var b strings.Builder
+	pos := 0
+	for _, c := range code {
+		b.WriteString(fn(source[pos:c[0]]))
+		b.WriteString(source[c[0]:c[1]])
+		pos = c[1]
+	}
+	b.WriteString(fn(source[pos:]))
+	return b.String()
+}
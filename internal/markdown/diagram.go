@@ -0,0 +1,97 @@
+package markdown
+
+import (
+	"bytes"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/util"
+)
+
+// DiagramFunc renders the source of a diagram block server-side and returns
+// the URL of the resulting image. It returns ok == false when the diagram
+// could not be rendered, in which case the block is shown as a plain code
+// block.
+type DiagramFunc func(lang string, source []byte) (url string, ok bool)
+
+var diagramFunc DiagramFunc
+
+// SetDiagramRenderer installs the server-side diagram renderer. It must be
+// called before the first Render.
+func SetDiagramRenderer(fn DiagramFunc) {
+	diagramFunc = fn
+}
+
+// diagramLanguages lists the fenced code block languages treated as diagrams.
+var diagramLanguages = map[string]bool{
+	"mermaid":  true,
+	"plantuml": true,
+}
+
+// diagrams is a goldmark extension that renders fenced code blocks tagged
+// with a diagram language as images.
+type diagrams struct{}
+
+func (diagrams) Extend(m goldmark.Markdown) {
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(newDiagramRenderer(), 100),
+	))
+}
+
+// diagramRenderer overrides the default fenced code block renderer and
+// delegates every non-diagram block back to it.
+type diagramRenderer struct {
+	fallback renderer.NodeRendererFunc
+}
+
+type funcCapture map[ast.NodeKind]renderer.NodeRendererFunc
+
+func (c funcCapture) Register(kind ast.NodeKind, fn renderer.NodeRendererFunc) {
+	c[kind] = fn
+}
+
+func newDiagramRenderer() *diagramRenderer {
+	funcs := funcCapture{}
+	html.NewRenderer().RegisterFuncs(funcs)
+	return &diagramRenderer{fallback: funcs[ast.KindFencedCodeBlock]}
+}
+
+func (r *diagramRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, r.renderFencedCodeBlock)
+}
+
+func (r *diagramRenderer) renderFencedCodeBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*ast.FencedCodeBlock)
+	lang := string(n.Language(source))
+	if !diagramLanguages[lang] {
+		return r.fallback(w, source, node, entering)
+	}
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	var src bytes.Buffer
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		src.Write(line.Value(source))
+	}
+
+	if diagramFunc != nil {
+		if url, ok := diagramFunc(lang, src.Bytes()); ok {
+			_, _ = w.WriteString(`<img class="diagram diagram-` + lang + `" src="`)
+			_, _ = w.Write(util.EscapeHTML(util.URLEscape([]byte(url), true)))
+			_, _ = w.WriteString(`" alt="` + lang + ` diagram">` + "\n")
+			return ast.WalkSkipChildren, nil
+		}
+	}
+
+	// Emit the whole code block now; the exit call is a no-op for diagram
+	// languages.
+	if _, err := r.fallback(w, source, node, true); err != nil {
+		return ast.WalkStop, err
+	}
+	return r.fallback(w, source, node, false)
+}
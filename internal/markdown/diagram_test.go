@@ -0,0 +1,48 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderDiagram(t *testing.T) {
+	defer SetDiagramRenderer(nil)
+
+	src := "```mermaid\ngraph TD; A-->B\n```\n"
+
+	SetDiagramRenderer(nil)
+	out, err := Render([]byte(src))
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if got := string(out); !strings.Contains(got, `<pre><code class="language-mermaid">graph TD; A--&gt;B`) || strings.Contains(got, "<img") {
+		t.Errorf("without a renderer, want a code block:\n%s", got)
+	}
+
+	SetDiagramRenderer(func(lang string, source []byte) (string, bool) {
+		return "", false
+	})
+	out, err = Render([]byte(src))
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if got := string(out); !strings.Contains(got, `<code class="language-mermaid">`) {
+		t.Errorf("when rendering fails, want a code block:\n%s", got)
+	}
+
+	var gotLang, gotSource string
+	SetDiagramRenderer(func(lang string, source []byte) (string, bool) {
+		gotLang, gotSource = lang, string(source)
+		return "/images/diagram-abc.svg", true
+	})
+	out, err = Render([]byte(src))
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if gotLang != "mermaid" || gotSource != "graph TD; A-->B\n" {
+		t.Errorf("renderer called with %q, %q", gotLang, gotSource)
+	}
+	if got := string(out); !strings.Contains(got, `<img class="diagram diagram-mermaid" src="/images/diagram-abc.svg" alt="mermaid diagram">`) || strings.Contains(got, "<code") {
+		t.Errorf("want an image:\n%s", got)
+	}
+}
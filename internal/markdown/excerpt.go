@@ -0,0 +1,72 @@
+package markdown
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Excerpt returns the plain text of the first paragraph of source that has
+// any, cut at a word boundary to at most maxRunes runes with an ellipsis.
+// Images are left out, so a diagram above the first paragraph is skipped.
+// It returns "" if source has no such paragraph.
+func Excerpt(source string, maxRunes int) string {
+	src := []byte(source)
+	doc := md.Parser().Parse(text.NewReader(src))
+
+	var b strings.Builder
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		if _, ok := n.(*ast.Paragraph); !ok {
+			return ast.WalkContinue, nil
+		}
+		writeText(&b, n, src)
+		if strings.TrimSpace(b.String()) == "" {
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkStop, nil
+	})
+
+	s := strings.Join(strings.Fields(b.String()), " ")
+	if utf8.RuneCountInString(s) <= maxRunes {
+		return s
+	}
+	cut := string([]rune(s)[:maxRunes])
+	if i := strings.LastIndexByte(cut, ' '); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,;:.") + "…"
+}
+
+// writeText appends the text of n's inline descendants to b.
+func writeText(b *strings.Builder, n ast.Node, src []byte) {
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		switch t := c.(type) {
+		case *ast.Text:
+			// Backslash escapes and entity references are decoded, as
+			// the HTML renderer does.
+			v := util.UnescapePunctuations(t.Segment.Value(src))
+			b.Write(util.ResolveEntityNames(util.ResolveNumericReferences(v)))
+			if t.SoftLineBreak() || t.HardLineBreak() {
+				b.WriteByte(' ')
+			}
+		case *ast.String:
+			b.Write(t.Value)
+		case *ast.CodeSpan:
+			for g := t.FirstChild(); g != nil; g = g.NextSibling() {
+				if s, ok := g.(*ast.Text); ok {
+					b.Write(s.Segment.Value(src))
+				}
+			}
+		case *ast.RawHTML, *ast.Image:
+			// Inline HTML tags and images are left out.
+		default:
+			writeText(b, c, src)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package markdown
+
+import "testing"
+
+func TestExcerpt(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       string
+		maxRunes int
+		want     string
+	}{
+		{"empty", "", 20, ""},
+		{"heading only", "# Title", 20, ""},
+		{"code only", "```\ncode\n```", 20, ""},
+		{"short", "Short text.", 20, "Short text."},
+		{"skips heading", "# Title\n\nFirst paragraph.\n\nSecond.", 40, "First paragraph."},
+		{"inline markup", "Some *emphasis*, `code` and [a link](/x).", 60, "Some emphasis, code and a link."},
+		{"soft line breaks", "Line one\nline two", 40, "Line one line two"},
+		{"inline HTML", "Text <b>bold</b> here.", 40, "Text bold here."},
+		{"skips image", "# Intro\n\n![Architecture](static/images/a.svg)\n\nThe API serves data.", 40, "The API serves data."},
+		{"cut at word", "First para with more words than fit", 20, "First para with…"},
+		{"cut drops punctuation", "Alpha beta, gamma delta epsilon", 12, "Alpha beta…"},
+		{"counts runes", "Größe über alles ist schön", 17, "Größe über alles…"},
+		{"exact length", "Exactly twenty chars", 20, "Exactly twenty chars"},
+		{"entities", "A &amp; B &#169;", 20, "A & B ©"},
+		{"escapes", `Not \*emphasis\*`, 30, "Not *emphasis*"},
+		{"code keeps entities", "Use `&amp;` here", 30, "Use &amp; here"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Excerpt(tt.in, tt.maxRunes); got != tt.want {
+				t.Errorf("Excerpt(%q, %d) = %q, want %q", tt.in, tt.maxRunes, got, tt.want)
+			}
+		})
+	}
+}
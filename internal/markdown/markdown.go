@@ -13,7 +13,7 @@ var md goldmark.Markdown
 
 func init() {
 	md = goldmark.New(
-		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithExtensions(extension.GFM, diagrams{}, math{}),
 		goldmark.WithParserOptions(
 			parser.WithAutoHeadingID(),
 		),
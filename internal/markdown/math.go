@@ -0,0 +1,211 @@
+package markdown
+
+import (
+	"bytes"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// math is a goldmark extension for LaTeX math: $inline$ and $$display$$
+// spans inside paragraphs, and $$ ... $$ blocks on their own lines. Formulas
+// are rendered server-side to MathML, so no client-side script is needed.
+type math struct{}
+
+func (math) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithInlineParsers(util.Prioritized(&mathInlineParser{}, 150)),
+		parser.WithBlockParsers(util.Prioritized(&mathBlockParser{}, 90)),
+	)
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(&mathRenderer{}, 100),
+	))
+}
+
+var (
+	kindMathInline = ast.NewNodeKind("MathInline")
+	kindMathBlock  = ast.NewNodeKind("MathBlock")
+)
+
+// mathInline is an inline formula. Display is set for $$...$$ spans.
+type mathInline struct {
+	ast.BaseInline
+	TeX     []byte
+	Display bool
+}
+
+func (n *mathInline) Kind() ast.NodeKind { return kindMathInline }
+
+func (n *mathInline) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"TeX": string(n.TeX)}, nil)
+}
+
+// mathBlock is a display formula spanning one or more lines.
+type mathBlock struct {
+	ast.BaseBlock
+	TeX    bytes.Buffer
+	closed bool
+}
+
+func (n *mathBlock) Kind() ast.NodeKind { return kindMathBlock }
+
+func (n *mathBlock) IsRaw() bool { return true }
+
+func (n *mathBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"TeX": n.TeX.String()}, nil)
+}
+
+type mathInlineParser struct{}
+
+func (p *mathInlineParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+// Parse follows the usual Markdown math conventions so that prices like
+// "$5 and $10" stay plain text: the opening $ must not be followed by a
+// space, and the closing $ must not be preceded by a space or followed by a
+// digit.
+func (p *mathInlineParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	delim := 1
+	if len(line) > 1 && line[1] == '$' {
+		delim = 2
+	}
+	body := line[delim:]
+	if len(body) == 0 || (delim == 1 && isMathSpace(body[0])) {
+		return nil
+	}
+	for i := 0; i < len(body); i++ {
+		switch body[i] {
+		case '\\':
+			i++
+		case '$':
+			if delim == 2 {
+				if i+1 < len(body) && body[i+1] == '$' && i > 0 {
+					block.Advance(delim + i + 2)
+					return &mathInline{TeX: append([]byte(nil), body[:i]...), Display: true}
+				}
+				continue
+			}
+			if i == 0 || isMathSpace(body[i-1]) {
+				continue
+			}
+			if i+1 < len(body) && body[i+1] >= '0' && body[i+1] <= '9' {
+				continue
+			}
+			block.Advance(delim + i + 1)
+			return &mathInline{TeX: append([]byte(nil), body[:i]...)}
+		case '\n':
+			return nil
+		}
+	}
+	return nil
+}
+
+func isMathSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+type mathBlockParser struct{}
+
+func (b *mathBlockParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+func (b *mathBlockParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+	pos := pc.BlockOffset()
+	if pos < 0 || !bytes.HasPrefix(line[pos:], []byte("$$")) {
+		return nil, parser.NoChildren
+	}
+	node := &mathBlock{}
+	rest := bytes.TrimSpace(line[pos+2:])
+	if len(rest) >= 2 && bytes.HasSuffix(rest, []byte("$$")) {
+		// Single-line block: $$ x^2 $$
+		node.TeX.Write(bytes.TrimSpace(rest[:len(rest)-2]))
+		node.closed = true
+	} else if len(rest) > 0 {
+		// Text after an unclosed $$ starts a paragraph with inline math.
+		return nil, parser.NoChildren
+	} else if !hasMathBlockEnd(reader.Source()[segment.Stop:]) {
+		// Without a closing line the $$ is literal text, so that a stray
+		// delimiter does not turn the rest of the page into TeX.
+		return nil, parser.NoChildren
+	}
+	reader.AdvanceToEOL()
+	return node, parser.NoChildren
+}
+
+func (b *mathBlockParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	n := node.(*mathBlock)
+	if n.closed {
+		return parser.Close
+	}
+	line, _ := reader.PeekLine()
+	trimmed := bytes.TrimSpace(line)
+	if bytes.HasSuffix(trimmed, []byte("$$")) {
+		n.TeX.Write(bytes.TrimSpace(trimmed[:len(trimmed)-2]))
+		reader.AdvanceToEOL()
+		return parser.Close
+	}
+	n.TeX.Write(trimmed)
+	n.TeX.WriteByte('\n')
+	reader.AdvanceToEOL()
+	return parser.Continue | parser.NoChildren
+}
+
+// hasMathBlockEnd reports whether a line of src ends with $$, which closes
+// a math block.
+func hasMathBlockEnd(src []byte) bool {
+	for len(src) > 0 {
+		line := src
+		if i := bytes.IndexByte(src, '\n'); i >= 0 {
+			line, src = src[:i], src[i+1:]
+		} else {
+			src = nil
+		}
+		if bytes.HasSuffix(bytes.TrimSpace(line), []byte("$$")) {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *mathBlockParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (b *mathBlockParser) CanInterruptParagraph() bool {
+	return true
+}
+
+func (b *mathBlockParser) CanAcceptIndentedLine() bool {
+	return false
+}
+
+type mathRenderer struct{}
+
+func (r *mathRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindMathInline, r.renderInline)
+	reg.Register(kindMathBlock, r.renderBlock)
+}
+
+func (r *mathRenderer) renderInline(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		n := node.(*mathInline)
+		_, _ = w.WriteString(texToMathML(string(n.TeX), n.Display))
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+func (r *mathRenderer) renderBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		n := node.(*mathBlock)
+		_, _ = w.WriteString(`<div class="math-block">`)
+		_, _ = w.WriteString(texToMathML(n.TeX.String(), true))
+		_, _ = w.WriteString("</div>\n")
+	}
+	return ast.WalkSkipChildren, nil
+}
@@ -0,0 +1,154 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderMath(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "prices stay text",
+			in:      "Costs $5 and $10 today.",
+			want:    []string{"<p>Costs $5 and $10 today.</p>"},
+			notWant: []string{"<math"},
+		},
+		{
+			name:    "digit after closing dollar",
+			in:      "Price $5$10 x",
+			want:    []string{"<p>Price $5$10 x</p>"},
+			notWant: []string{"<math"},
+		},
+		{
+			name:    "space inside delimiters",
+			in:      "a $ b $ c",
+			want:    []string{"<p>a $ b $ c</p>"},
+			notWant: []string{"<math"},
+		},
+		{
+			name:    "escaped dollars",
+			in:      `\$5 and \$6`,
+			want:    []string{"<p>$5 and $6</p>"},
+			notWant: []string{"<math"},
+		},
+		{
+			name: "inline",
+			in:   "Energy $E=mc^2$ here.",
+			want: []string{
+				`<p>Energy <math xmlns="http://www.w3.org/1998/Math/MathML" display="inline">`,
+				"<mi>E</mi><mo>=</mo><mi>m</mi><msup><mi>c</mi><mn>2</mn></msup>",
+				`<annotation encoding="application/x-tex">E=mc^2</annotation>`,
+				"</math> here.</p>",
+			},
+		},
+		{
+			name: "inline display",
+			in:   "inline $$y$$ display",
+			want: []string{`<p>inline <math xmlns="http://www.w3.org/1998/Math/MathML" display="block">`, "</math> display</p>"},
+		},
+		{
+			name: "single-line block",
+			in:   "$$x^2$$",
+			want: []string{`<div class="math-block"><math xmlns="http://www.w3.org/1998/Math/MathML" display="block"><semantics><msup><mi>x</mi><mn>2</mn></msup>`},
+		},
+		{
+			name: "multi-line block",
+			in:   "$$\na+b\n= c\n$$\n\nAfter.",
+			want: []string{
+				`<div class="math-block">`,
+				"<mi>a</mi><mo>+</mo><mi>b</mi><mo>=</mo><mi>c</mi>",
+				"<annotation encoding=\"application/x-tex\">a+b\n= c</annotation>",
+				"<p>After.</p>",
+			},
+		},
+		{
+			name:    "text after unclosed opening delimiter",
+			in:      "$$ unclosed text",
+			want:    []string{"<p>$$ unclosed text</p>"},
+			notWant: []string{"<math"},
+		},
+		{
+			name:    "unclosed block",
+			in:      "$$\nx\n\nmore",
+			want:    []string{"<p>$$\nx</p>", "<p>more</p>"},
+			notWant: []string{"<math"},
+		},
+		{
+			name:    "unclosed block before a heading",
+			in:      "Text\n\n$$ \n\n# Heading",
+			want:    []string{"<p>$$</p>", `<h1 id="heading">Heading</h1>`},
+			notWant: []string{"<math"},
+		},
+		{
+			name: "fraction",
+			in:   `$\frac{a}{b}$`,
+			want: []string{"<mfrac><mi>a</mi><mi>b</mi></mfrac>"},
+		},
+		{
+			name: "square root",
+			in:   `$\sqrt{x}$`,
+			want: []string{"<msqrt><mi>x</mi></msqrt>"},
+		},
+		{
+			name: "nth root",
+			in:   `$\sqrt[3]{x}$`,
+			want: []string{"<mroot><mi>x</mi><mn>3</mn></mroot>"},
+		},
+		{
+			name: "matrix",
+			in:   "$$\n\\begin{pmatrix}a & b \\\\ c & d\\end{pmatrix}\n$$",
+			want: []string{
+				`<mo fence="true">(</mo><mtable><mtr><mtd><mi>a</mi></mtd><mtd><mi>b</mi></mtd></mtr><mtr><mtd><mi>c</mi></mtd><mtd><mi>d</mi></mtd></mtr></mtable><mo fence="true">)</mo>`,
+			},
+		},
+		{
+			name: "cases",
+			in:   "$$\n\\begin{cases}1 & x>0 \\\\ 0 & \\text{otherwise}\\end{cases}\n$$",
+			want: []string{
+				`<mo fence="true">{</mo><mtable columnalign="left">`,
+				"<mtd><mtext>otherwise</mtext></mtd>",
+			},
+		},
+		{
+			name:    "operators are escaped",
+			in:      `$a<b \& c>d$`,
+			want:    []string{"<mo>&lt;</mo>", "<mo>&amp;</mo>", "<mo>&gt;</mo>", "a&lt;b \\&amp; c&gt;d</annotation>"},
+			notWant: []string{"<mo><</mo>"},
+		},
+		{
+			name:    "text is escaped",
+			in:      `$\text{<script>}$`,
+			want:    []string{"<mtext>&lt;script&gt;</mtext>"},
+			notWant: []string{"<script>"},
+		},
+		{
+			name: "unknown command",
+			in:   `$\foo$`,
+			want: []string{`<merror><mtext>\foo</mtext></merror>`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := Render([]byte(tt.in))
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			got := string(out)
+			for _, w := range tt.want {
+				if !strings.Contains(got, w) {
+					t.Errorf("output does not contain %q:\n%s", w, got)
+				}
+			}
+			for _, w := range tt.notWant {
+				if strings.Contains(got, w) {
+					t.Errorf("output contains %q:\n%s", w, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,520 @@
+package markdown
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// texToMathML converts a LaTeX math expression to a MathML <math> element.
+// It covers the subset commonly used in technical documentation: scripts,
+// fractions, roots, Greek letters, operators and relations, accents,
+// \left/\right delimiters, \text and font commands, and matrix/cases/aligned
+// environments. Unknown commands are rendered as <merror> so typos are
+// visible instead of silently dropped. The original TeX is kept as an
+// annotation so it survives copy and paste.
+func texToMathML(tex string, display bool) string {
+	p := &texParser{src: []rune(tex), display: display}
+	body := p.parseSeq()
+	mode := "inline"
+	if display {
+		mode = "block"
+	}
+	var b strings.Builder
+	b.WriteString(`<math xmlns="http://www.w3.org/1998/Math/MathML" display="` + mode + `">`)
+	b.WriteString("<semantics>")
+	b.WriteString(mrow(body))
+	b.WriteString(`<annotation encoding="application/x-tex">`)
+	b.WriteString(html.EscapeString(strings.TrimSpace(tex)))
+	b.WriteString("</annotation></semantics></math>")
+	return b.String()
+}
+
+type texParser struct {
+	src     []rune
+	pos     int
+	display bool
+}
+
+func mrow(items []string) string {
+	if len(items) == 1 {
+		return items[0]
+	}
+	return "<mrow>" + strings.Join(items, "") + "</mrow>"
+}
+
+func mi(s string) string { return "<mi>" + html.EscapeString(s) + "</mi>" }
+func mo(s string) string { return "<mo>" + html.EscapeString(s) + "</mo>" }
+func mn(s string) string { return "<mn>" + html.EscapeString(s) + "</mn>" }
+
+func isTeXLetter(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+func (p *texParser) skipSpace() {
+	for p.pos < len(p.src) && unicode.IsSpace(p.src[p.pos]) {
+		p.pos++
+	}
+}
+
+// next returns the next token: a control sequence (\name or \c), or a
+// single character. It returns "" at the end of input.
+func (p *texParser) next() string {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return ""
+	}
+	r := p.src[p.pos]
+	if r == '\\' && p.pos+1 < len(p.src) {
+		j := p.pos + 1
+		if isTeXLetter(p.src[j]) {
+			for j < len(p.src) && isTeXLetter(p.src[j]) {
+				j++
+			}
+		} else {
+			j++
+		}
+		tok := string(p.src[p.pos:j])
+		p.pos = j
+		return tok
+	}
+	p.pos++
+	return string(r)
+}
+
+func (p *texParser) peek() string {
+	pos := p.pos
+	tok := p.next()
+	p.pos = pos
+	return tok
+}
+
+// rawGroup reads a brace-delimited argument verbatim, e.g. for \text{...}.
+func (p *texParser) rawGroup() string {
+	p.skipSpace()
+	if p.pos >= len(p.src) || p.src[p.pos] != '{' {
+		return p.next()
+	}
+	depth := 0
+	start := p.pos + 1
+	for ; p.pos < len(p.src); p.pos++ {
+		switch p.src[p.pos] {
+		case '\\':
+			p.pos++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				s := string(p.src[start:p.pos])
+				p.pos++
+				return s
+			}
+		}
+	}
+	return string(p.src[start:])
+}
+
+// parseSeq parses atoms until the end of input or one of the stop tokens,
+// which is left unconsumed.
+func (p *texParser) parseSeq(stops ...string) []string {
+	var items []string
+	for {
+		tok := p.peek()
+		if tok == "" {
+			return items
+		}
+		for _, s := range stops {
+			if tok == s {
+				return items
+			}
+		}
+		p.next()
+		if atom, ok := p.parseAtom(tok); ok {
+			items = append(items, atom)
+		}
+	}
+}
+
+// parseArg parses a required argument: a braced group or a single token.
+func (p *texParser) parseArg() string {
+	tok := p.next()
+	if tok == "{" {
+		items := p.parseSeq("}")
+		p.next()
+		if len(items) == 0 {
+			return "<mrow></mrow>"
+		}
+		return mrow(items)
+	}
+	if tok == "" {
+		return "<mrow></mrow>"
+	}
+	s, _ := p.parseBase(tok)
+	return s
+}
+
+// atom kinds that change how scripts are attached.
+const (
+	atomOrdinary = iota
+	atomLargeOp  // \sum, \prod: limits above/below in display mode
+	atomLimits   // \lim, \max: limits below in display mode
+)
+
+// parseAtom parses a base token and any following sub/superscripts.
+func (p *texParser) parseAtom(tok string) (string, bool) {
+	base, kind := p.parseBase(tok)
+	if base == "" {
+		return "", false
+	}
+	var sub, sup string
+	limits := p.display && kind != atomOrdinary
+	for {
+		switch p.peek() {
+		case "^":
+			p.next()
+			sup = p.parseArg()
+			continue
+		case "_":
+			p.next()
+			sub = p.parseArg()
+			continue
+		case "'":
+			p.next()
+			sup = mo("′")
+			continue
+		case `\limits`:
+			p.next()
+			limits = kind != atomOrdinary
+			continue
+		case `\nolimits`:
+			p.next()
+			limits = false
+			continue
+		}
+		break
+	}
+	under, over, both := "msub", "msup", "msubsup"
+	if limits {
+		under, over, both = "munder", "mover", "munderover"
+	}
+	switch {
+	case sub != "" && sup != "":
+		return "<" + both + ">" + base + sub + sup + "</" + both + ">", true
+	case sub != "":
+		return "<" + under + ">" + base + sub + "</" + under + ">", true
+	case sup != "":
+		return "<" + over + ">" + base + sup + "</" + over + ">", true
+	}
+	return base, true
+}
+
+// parseBase converts a single token (and its arguments) to MathML.
+func (p *texParser) parseBase(tok string) (string, int) {
+	r := []rune(tok)
+	if len(r) == 1 {
+		c := r[0]
+		switch {
+		case c >= '0' && c <= '9' || c == '.' && p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9':
+			num := tok
+			for p.pos < len(p.src) {
+				d := p.src[p.pos]
+				if d >= '0' && d <= '9' || d == '.' && p.pos+1 < len(p.src) && p.src[p.pos+1] >= '0' && p.src[p.pos+1] <= '9' {
+					num += string(d)
+					p.pos++
+					continue
+				}
+				break
+			}
+			return mn(num), atomOrdinary
+		case c == '{':
+			items := p.parseSeq("}")
+			p.next()
+			return "<mrow>" + strings.Join(items, "") + "</mrow>", atomOrdinary
+		case c == '}' || c == '&':
+			// Unbalanced brace or stray alignment marker.
+			return "", atomOrdinary
+		case c == '~':
+			return `<mspace width="0.2778em"></mspace>`, atomOrdinary
+		case c == '-':
+			return mo("−"), atomOrdinary
+		case c == '*':
+			return mo("∗"), atomOrdinary
+		case strings.ContainsRune("+=<>()[]|/,;:!?.", c):
+			return mo(tok), atomOrdinary
+		case c == '^' || c == '_':
+			// Script without a base: attach to an empty row.
+			p.pos--
+			return "<mrow></mrow>", atomOrdinary
+		default:
+			return mi(tok), atomOrdinary
+		}
+	}
+
+	name := tok[1:]
+	if s, ok := texIdentifiers[name]; ok {
+		return mi(s), atomOrdinary
+	}
+	if s, ok := texOperators[name]; ok {
+		return mo(s), atomOrdinary
+	}
+	if s, ok := texLargeOperators[name]; ok {
+		if p.display && s != "∫" && s != "∬" && s != "∭" && s != "∮" {
+			return `<mo largeop="true" movablelimits="true">` + s + "</mo>", atomLargeOp
+		}
+		return `<mo largeop="true">` + s + "</mo>", atomOrdinary
+	}
+	if texFunctions[name] {
+		kind := atomOrdinary
+		if texLimitFunctions[name] {
+			kind = atomLimits
+		}
+		return mi(name), kind
+	}
+	if w, ok := texSpaces[name]; ok {
+		return `<mspace width="` + w + `"></mspace>`, atomOrdinary
+	}
+	if accent, ok := texAccents[name]; ok {
+		arg := p.parseArg()
+		if name == "underline" {
+			return `<munder accentunder="true">` + arg + mo(accent) + "</munder>", atomOrdinary
+		}
+		return `<mover accent="true">` + arg + mo(accent) + "</mover>", atomOrdinary
+	}
+
+	switch name {
+	case "frac", "dfrac", "tfrac", "cfrac":
+		num := p.parseArg()
+		den := p.parseArg()
+		return "<mfrac>" + num + den + "</mfrac>", atomOrdinary
+	case "binom":
+		top := p.parseArg()
+		bottom := p.parseArg()
+		return "<mrow>" + mo("(") + `<mfrac linethickness="0">` + top + bottom + "</mfrac>" + mo(")") + "</mrow>", atomOrdinary
+	case "sqrt":
+		if p.peek() == "[" {
+			p.next()
+			index := mrow(p.parseSeq("]"))
+			p.next()
+			return "<mroot>" + p.parseArg() + index + "</mroot>", atomOrdinary
+		}
+		return "<msqrt>" + p.parseArg() + "</msqrt>", atomOrdinary
+	case "text", "textrm", "mbox", "textit", "textbf":
+		return "<mtext>" + html.EscapeString(p.rawGroup()) + "</mtext>", atomOrdinary
+	case "mathrm", "operatorname", "mathup":
+		return `<mi mathvariant="normal">` + html.EscapeString(p.rawGroup()) + "</mi>", atomOrdinary
+	case "mathbf", "boldsymbol", "bm":
+		return `<mi mathvariant="bold">` + html.EscapeString(p.rawGroup()) + "</mi>", atomOrdinary
+	case "mathit":
+		return `<mi mathvariant="italic">` + html.EscapeString(p.rawGroup()) + "</mi>", atomOrdinary
+	case "mathcal", "mathscr":
+		return `<mi mathvariant="script">` + html.EscapeString(p.rawGroup()) + "</mi>", atomOrdinary
+	case "mathbb":
+		return mi(doubleStruck(p.rawGroup())), atomOrdinary
+	case "left", "right", "bigl", "bigr", "Bigl", "Bigr", "big", "Big":
+		if name == "left" {
+			return p.parseLeftRight(), atomOrdinary
+		}
+		d := p.delimiter(p.next())
+		if d == "" {
+			return "", atomOrdinary
+		}
+		return mo(d), atomOrdinary
+	case "begin":
+		return p.parseEnvironment(p.rawGroup()), atomOrdinary
+	case "displaystyle", "textstyle", "scriptstyle", "limits", "nolimits", "\\":
+		return "", atomOrdinary
+	}
+	return "<merror><mtext>" + html.EscapeString(tok) + "</mtext></merror>", atomOrdinary
+}
+
+// delimiter maps a delimiter token after \left or \right to its character.
+// "." is the invisible delimiter.
+func (p *texParser) delimiter(tok string) string {
+	switch tok {
+	case ".":
+		return ""
+	case `\{`, `\lbrace`:
+		return "{"
+	case `\}`, `\rbrace`:
+		return "}"
+	case `\|`, `\Vert`:
+		return "‖"
+	case `\vert`:
+		return "|"
+	}
+	if s, ok := texOperators[strings.TrimPrefix(tok, `\`)]; ok && strings.HasPrefix(tok, `\`) {
+		return s
+	}
+	return tok
+}
+
+func (p *texParser) parseLeftRight() string {
+	open := p.delimiter(p.next())
+	items := p.parseSeq(`\right`)
+	p.next()
+	closing := p.delimiter(p.next())
+	var b strings.Builder
+	b.WriteString("<mrow>")
+	if open != "" {
+		b.WriteString(`<mo fence="true" stretchy="true">` + html.EscapeString(open) + "</mo>")
+	}
+	b.WriteString(strings.Join(items, ""))
+	if closing != "" {
+		b.WriteString(`<mo fence="true" stretchy="true">` + html.EscapeString(closing) + "</mo>")
+	}
+	b.WriteString("</mrow>")
+	return b.String()
+}
+
+// parseEnvironment handles matrix-like environments, splitting cells on &
+// and rows on \\.
+func (p *texParser) parseEnvironment(name string) string {
+	var rows [][]string
+	var row []string
+	for {
+		cell := p.parseSeq("&", `\\`, `\end`)
+		row = append(row, mrow(append([]string{}, cell...)))
+		tok := p.next()
+		if tok == "&" {
+			continue
+		}
+		rows = append(rows, row)
+		row = nil
+		if tok == `\end` {
+			p.rawGroup()
+			break
+		}
+		if tok == "" {
+			break
+		}
+	}
+	// A trailing \\ leaves an empty last row.
+	if n := len(rows); n > 1 && len(rows[n-1]) == 1 && rows[n-1][0] == "<mrow></mrow>" {
+		rows = rows[:n-1]
+	}
+
+	attrs := ""
+	switch strings.TrimSuffix(name, "*") {
+	case "cases":
+		attrs = ` columnalign="left"`
+	case "aligned", "align", "split", "alignat":
+		attrs = ` columnalign="right left" columnspacing="0"`
+	}
+	var b strings.Builder
+	b.WriteString("<mtable" + attrs + ">")
+	for _, r := range rows {
+		b.WriteString("<mtr>")
+		for _, c := range r {
+			b.WriteString("<mtd>" + c + "</mtd>")
+		}
+		b.WriteString("</mtr>")
+	}
+	b.WriteString("</mtable>")
+	table := b.String()
+
+	fence := func(open, closing string) string {
+		return "<mrow>" + `<mo fence="true">` + open + "</mo>" + table + `<mo fence="true">` + closing + "</mo></mrow>"
+	}
+	switch name {
+	case "pmatrix":
+		return fence("(", ")")
+	case "bmatrix":
+		return fence("[", "]")
+	case "Bmatrix":
+		return fence("{", "}")
+	case "vmatrix":
+		return fence("|", "|")
+	case "Vmatrix":
+		return fence("‖", "‖")
+	case "cases":
+		return "<mrow>" + `<mo fence="true">{</mo>` + table + "</mrow>"
+	}
+	return table
+}
+
+func doubleStruck(s string) string {
+	special := map[rune]rune{'C': 'ℂ', 'H': 'ℍ', 'N': 'ℕ', 'P': 'ℙ', 'Q': 'ℚ', 'R': 'ℝ', 'Z': 'ℤ'}
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case special[r] != 0:
+			b.WriteRune(special[r])
+		case r >= 'A' && r <= 'Z':
+			b.WriteRune(0x1D538 + r - 'A')
+		case r >= 'a' && r <= 'z':
+			b.WriteRune(0x1D552 + r - 'a')
+		case r >= '0' && r <= '9':
+			b.WriteRune(0x1D7D8 + r - '0')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+var texIdentifiers = map[string]string{
+	"alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ", "epsilon": "ϵ",
+	"varepsilon": "ε", "zeta": "ζ", "eta": "η", "theta": "θ", "vartheta": "ϑ",
+	"iota": "ι", "kappa": "κ", "lambda": "λ", "mu": "μ", "nu": "ν", "xi": "ξ",
+	"pi": "π", "varpi": "ϖ", "rho": "ρ", "varrho": "ϱ", "sigma": "σ",
+	"varsigma": "ς", "tau": "τ", "upsilon": "υ", "phi": "ϕ", "varphi": "φ",
+	"chi": "χ", "psi": "ψ", "omega": "ω",
+	"Gamma": "Γ", "Delta": "Δ", "Theta": "Θ", "Lambda": "Λ", "Xi": "Ξ",
+	"Pi": "Π", "Sigma": "Σ", "Upsilon": "Υ", "Phi": "Φ", "Psi": "Ψ", "Omega": "Ω",
+	"infty": "∞", "partial": "∂", "nabla": "∇", "hbar": "ℏ", "ell": "ℓ",
+	"emptyset": "∅", "varnothing": "∅", "Re": "ℜ", "Im": "ℑ", "aleph": "ℵ",
+	"angle": "∠", "triangle": "△", "prime": "′", "degree": "°",
+}
+
+var texOperators = map[string]string{
+	"pm": "±", "mp": "∓", "times": "×", "div": "÷", "cdot": "⋅", "ast": "∗",
+	"star": "⋆", "circ": "∘", "bullet": "∙", "oplus": "⊕", "otimes": "⊗",
+	"leq": "≤", "le": "≤", "geq": "≥", "ge": "≥", "neq": "≠", "ne": "≠",
+	"ll": "≪", "gg": "≫", "approx": "≈", "equiv": "≡", "sim": "∼",
+	"simeq": "≃", "cong": "≅", "propto": "∝", "lesssim": "≲", "gtrsim": "≳",
+	"to": "→", "rightarrow": "→", "leftarrow": "←", "gets": "←",
+	"Rightarrow": "⇒", "Leftarrow": "⇐", "leftrightarrow": "↔",
+	"Leftrightarrow": "⇔", "iff": "⟺", "implies": "⟹", "mapsto": "↦",
+	"uparrow": "↑", "downarrow": "↓",
+	"in": "∈", "notin": "∉", "ni": "∋", "subset": "⊂", "subseteq": "⊆",
+	"supset": "⊃", "supseteq": "⊇", "cup": "∪", "cap": "∩", "setminus": "∖",
+	"forall": "∀", "exists": "∃", "neg": "¬", "lnot": "¬", "land": "∧",
+	"wedge": "∧", "lor": "∨", "vee": "∨", "perp": "⊥", "parallel": "∥",
+	"mid": "|", "vert": "|", "Vert": "‖", "|": "‖",
+	"ldots": "…", "dots": "…", "cdots": "⋯", "vdots": "⋮", "ddots": "⋱",
+	"langle": "⟨", "rangle": "⟩", "lfloor": "⌊", "rfloor": "⌋",
+	"lceil": "⌈", "rceil": "⌉", "lbrace": "{", "rbrace": "}",
+	"{": "{", "}": "}", "%": "%", "$": "$", "_": "_", "#": "#", "&": "&",
+}
+
+var texLargeOperators = map[string]string{
+	"sum": "∑", "prod": "∏", "coprod": "∐", "int": "∫", "iint": "∬",
+	"iiint": "∭", "oint": "∮", "bigcup": "⋃", "bigcap": "⋂",
+	"bigoplus": "⨁", "bigotimes": "⨂",
+}
+
+var texFunctions = map[string]bool{
+	"sin": true, "cos": true, "tan": true, "cot": true, "sec": true, "csc": true,
+	"arcsin": true, "arccos": true, "arctan": true, "sinh": true, "cosh": true,
+	"tanh": true, "log": true, "ln": true, "lg": true, "exp": true, "min": true,
+	"max": true, "sup": true, "inf": true, "lim": true, "limsup": true,
+	"liminf": true, "det": true, "dim": true, "ker": true, "gcd": true,
+	"arg": true, "deg": true, "Pr": true, "mod": true,
+}
+
+var texLimitFunctions = map[string]bool{
+	"lim": true, "limsup": true, "liminf": true, "min": true, "max": true,
+	"sup": true, "inf": true, "det": true, "gcd": true, "Pr": true,
+}
+
+var texSpaces = map[string]string{
+	",": "0.1667em", ":": "0.2222em", ">": "0.2222em", ";": "0.2778em",
+	"!": "-0.1667em", " ": "0.25em", "quad": "1em", "qquad": "2em",
+}
+
+var texAccents = map[string]string{
+	"hat": "^", "widehat": "^", "bar": "¯", "overline": "¯", "vec": "→",
+	"overrightarrow": "→", "dot": "˙", "ddot": "¨", "tilde": "˜",
+	"widetilde": "˜", "underline": "_",
+}
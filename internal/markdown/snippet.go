@@ -0,0 +1,63 @@
+package markdown
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// snippetRef matches {{< snippet "name" >}}. The escaped form
+// {{</* snippet "name" */>}} is left alone here and unescaped afterwards, so
+// pages can document the syntax itself.
+var (
+	snippetRef        = regexp.MustCompile(`\{\{<\s*snippet\s+"([^"]+)"\s*>\}\}`)
+	snippetRefEscaped = regexp.MustCompile(`\{\{</\*\s*(snippet\s+"[^"]+")\s*\*/>\}\}`)
+)
+
+// maxSnippetDepth bounds nested includes even without a cycle.
+const maxSnippetDepth = 10
+
+// SnippetLookup returns the markdown of a named snippet.
+type SnippetLookup func(name string) (string, bool)
+
+// ExpandSnippets replaces snippet includes in source with the snippet's
+// markdown, recursively. Includes in code blocks and code spans are left as
+// written. Missing snippets and include cycles are replaced by an inline
+// error message rather than failing the whole page.
+func ExpandSnippets(source string, lookup SnippetLookup) string {
+	out := expandSnippets(source, lookup, nil)
+	return snippetRefEscaped.ReplaceAllString(out, `{{< $1 >}}`)
+}
+
+func expandSnippets(source string, lookup SnippetLookup, stack []string) string {
+	if !strings.Contains(source, "{{<") {
+		return source
+	}
+	return outsideCode(source, func(s string) string {
+		return expandSnippetRefs(s, lookup, stack)
+	})
+}
+
+func expandSnippetRefs(source string, lookup SnippetLookup, stack []string) string {
+	return snippetRef.ReplaceAllStringFunc(source, func(ref string) string {
+		name := snippetRef.FindStringSubmatch(ref)[1]
+		for _, s := range stack {
+			if s == name {
+				return snippetError(fmt.Sprintf("snippet include cycle: %s → %s", strings.Join(stack, " → "), name))
+			}
+		}
+		if len(stack) >= maxSnippetDepth {
+			return snippetError(fmt.Sprintf("snippets nested too deeply at %q", name))
+		}
+		content, ok := lookup(name)
+		if !ok {
+			return snippetError(fmt.Sprintf("snippet %q not found", name))
+		}
+		return expandSnippets(content, lookup, append(stack[:len(stack):len(stack)], name))
+	})
+}
+
+func snippetError(msg string) string {
+	return `<span class="snippet-error">` + html.EscapeString(msg) + `</span>`
+}
@@ -0,0 +1,49 @@
+package markdown
+
+import "testing"
+
+func TestExpandSnippets(t *testing.T) {
+	snippets := map[string]string{
+		"note":  "**Note:** rate limits apply.",
+		"outer": "Before {{< snippet \"note\" >}} after.",
+		"a":     "A {{< snippet \"b\" >}}",
+		"b":     "B {{< snippet \"a\" >}}",
+		"self":  "S {{< snippet \"self\" >}}",
+		"html":  "{{< snippet \"<b>\" >}}",
+		"twice": "{{< snippet \"note\" >}} {{< snippet \"note\" >}}",
+	}
+	// deepa includes deepb, which includes deepc, and so on.
+	for i := 0; i <= maxSnippetDepth+1; i++ {
+		snippets["deep"+string(rune('a'+i))] = "{{< snippet \"deep" + string(rune('a'+i+1)) + "\" >}}"
+	}
+	lookup := func(name string) (string, bool) {
+		s, ok := snippets[name]
+		return s, ok
+	}
+
+	tests := []struct {
+		name, in, want string
+	}{
+		{"no includes", "Plain text.", "Plain text."},
+		{"include", `{{< snippet "note" >}}`, "**Note:** rate limits apply."},
+		{"extra spaces", `{{<  snippet   "note"  >}}`, "**Note:** rate limits apply."},
+		{"nested", `{{< snippet "outer" >}}`, "Before **Note:** rate limits apply. after."},
+		{"same snippet twice", `{{< snippet "twice" >}}`, "**Note:** rate limits apply. **Note:** rate limits apply."},
+		{"missing", `x {{< snippet "nope" >}}`, `x <span class="snippet-error">snippet &#34;nope&#34; not found</span>`},
+		{"self cycle", `{{< snippet "self" >}}`, `S <span class="snippet-error">snippet include cycle: self → self</span>`},
+		{"cycle", `{{< snippet "a" >}}`, `A B <span class="snippet-error">snippet include cycle: a → b → a</span>`},
+		{"error is escaped", `{{< snippet "html" >}}`, `<span class="snippet-error">snippet &#34;&lt;b&gt;&#34; not found</span>`},
+		{"escaped include", `{{</* snippet "note" */>}}`, `{{< snippet "note" >}}`},
+		{"too deep", `{{< snippet "deepa" >}}`, `<span class="snippet-error">snippets nested too deeply at &#34;deepk&#34;</span>`},
+		{"code span", "Write `{{< snippet \"note\" >}}` to include {{< snippet \"note\" >}}", "Write `{{< snippet \"note\" >}}` to include **Note:** rate limits apply."},
+		{"fenced code", "```\n{{< snippet \"note\" >}}\n```\n\n{{< snippet \"note\" >}}", "```\n{{< snippet \"note\" >}}\n```\n\n**Note:** rate limits apply."},
+		{"indented code", "Text\n\n    {{< snippet \"note\" >}}\n", "Text\n\n    {{< snippet \"note\" >}}\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExpandSnippets(tt.in, lookup); got != tt.want {
+				t.Errorf("ExpandSnippets(%q) =\n%q\nwant\n%q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+)
+
+// variableRef matches {{var.key}} references.
+var variableRef = regexp.MustCompile(`\{\{\s*var\.([A-Za-z0-9_]+)\s*\}\}`)
+
+// ExpandVariables replaces {{var.key}} references in source with their
+// values. References in code blocks and code spans, and unknown keys, are
+// left as written so that pages can show the syntax and typos stay visible.
+func ExpandVariables(source string, values map[string]string) string {
+	if len(values) == 0 || !strings.Contains(source, "var.") {
+		return source
+	}
+	return outsideCode(source, func(s string) string {
+		return variableRef.ReplaceAllStringFunc(s, func(ref string) string {
+			if v, ok := values[variableRef.FindStringSubmatch(ref)[1]]; ok {
+				return v
+			}
+			return ref
+		})
+	})
+}
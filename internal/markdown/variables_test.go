@@ -0,0 +1,38 @@
+package markdown
+
+import "testing"
+
+func TestExpandVariables(t *testing.T) {
+	values := map[string]string{
+		"api_host":    "api.example.com",
+		"version":     "2.1",
+		"placeholder": "{{var.version}}",
+	}
+	tests := []struct {
+		name   string
+		in     string
+		values map[string]string
+		want   string
+	}{
+		{"no values", "{{var.version}}", nil, "{{var.version}}"},
+		{"plain text", "Nothing here.", values, "Nothing here."},
+		{"one", "https://{{var.api_host}}/v1", values, "https://api.example.com/v1"},
+		{"several", "{{var.api_host}} {{var.version}} {{var.version}}", values, "api.example.com 2.1 2.1"},
+		{"spaces", "{{ var.version }}", values, "2.1"},
+		{"unknown key stays", "{{var.missing}} {{var.version}}", values, "{{var.missing}} 2.1"},
+		{"keys are case sensitive", "{{var.Version}}", values, "{{var.Version}}"},
+		{"not a variable", "{{version}} {{ var. version }}", values, "{{version}} {{ var. version }}"},
+		{"values are not expanded again", "{{var.placeholder}}", values, "{{var.version}}"},
+		{"code span", "`{{var.version}}` is {{var.version}}", values, "`{{var.version}}` is 2.1"},
+		{"double backtick code span", "``a `{{var.version}}` b`` {{var.version}}", values, "``a `{{var.version}}` b`` 2.1"},
+		{"fenced code", "{{var.version}}\n\n~~~yaml\nversion: {{var.version}}\n~~~\n", values, "2.1\n\n~~~yaml\nversion: {{var.version}}\n~~~\n"},
+		{"fenced code in a quote", "> ```\n> {{var.version}}\n> ```\n", values, "> ```\n> {{var.version}}\n> ```\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExpandVariables(tt.in, tt.values); got != tt.want {
+				t.Errorf("ExpandVariables(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -3,24 +3,38 @@ package portability
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"log/slog"
 	"time"
 
+	"docgen/internal/markdown"
+
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // Export bundle types
 
 type ExportBundle struct {
-	Version      string              `json:"version"`
-	ExportedAt   time.Time           `json:"exported_at"`
-	Roles        []RoleExport        `json:"roles"`
-	SectionRows  []SectionRowExport  `json:"section_rows"`
-	Sections     []SectionExport     `json:"sections"`
-	Pages        []PageExport        `json:"pages"`
-	Images       []ImageExport       `json:"images"`
-	SiteSettings *SiteSettingsExport `json:"site_settings"`
+	Version    string    `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+	// Space is the name of the exported space. It is informational: a
+	// bundle can be imported into any space.
+	Space        string                `json:"space,omitempty"`
+	Roles        []RoleExport          `json:"roles"`
+	SectionRows  []SectionRowExport    `json:"section_rows"`
+	Sections     []SectionExport       `json:"sections"`
+	Pages        []PageExport          `json:"pages"`
+	Images       []ImageExport         `json:"images"`
+	Snippets     []SnippetExport       `json:"snippets,omitempty"`
+	Templates    []TemplateExport      `json:"page_templates,omitempty"`
+	Variables    []VariableExport      `json:"variables,omitempty"`
+	Comments     []CommentThreadExport `json:"comment_threads,omitempty"`
+	Versions     []DocVersionExport    `json:"doc_versions,omitempty"`
+	Translations []TranslationExport   `json:"page_translations,omitempty"`
+	Themes       []CustomThemeExport   `json:"custom_themes,omitempty"`
+	SiteSettings *SiteSettingsExport   `json:"site_settings"`
 }
 
 type RoleExport struct {
@@ -43,17 +57,25 @@ type SectionRowExport struct {
 }
 
 type SectionExport struct {
-	ID           string    `json:"id"`
-	Name         string    `json:"name"`
-	Title        string    `json:"title"`
-	Description  string    `json:"description"`
-	SortOrder    int       `json:"sort_order"`
-	Icon         string    `json:"icon"`
-	RowID        *string   `json:"row_id,omitempty"`
-	RequiredRole *string   `json:"required_role,omitempty"`
-	Deleted      bool      `json:"deleted"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID                string     `json:"id"`
+	Name              string     `json:"name"`
+	Title             string     `json:"title"`
+	Description       string     `json:"description"`
+	SortOrder         int        `json:"sort_order"`
+	Icon              string     `json:"icon"`
+	RowID             *string    `json:"row_id,omitempty"`
+	RequiredRole      *string    `json:"required_role,omitempty"`
+	RequiredApprovals int        `json:"required_approvals,omitempty"`
+	PublishAt         *time.Time `json:"publish_at,omitempty"`
+	UnpublishAt       *time.Time `json:"unpublish_at,omitempty"`
+	RequireSummary    bool       `json:"require_summary,omitempty"`
+	LandingMD         string     `json:"landing_md,omitempty"`
+	Deleted           bool       `json:"deleted"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+	// HidePageMeta is inverted so that bundles from before the setting
+	// existed import with the "last updated" footer shown.
+	HidePageMeta bool `json:"hide_page_meta,omitempty"`
 }
 
 type PageExport struct {
@@ -67,6 +89,13 @@ type PageExport struct {
 	Deleted    bool      `json:"deleted"`
 	CreatedAt  time.Time `json:"created_at"`
 	UpdatedAt  time.Time `json:"updated_at"`
+	// Published is nil in exports made before drafts existed, which means
+	// published.
+	Published      *bool      `json:"published,omitempty"`
+	DraftTitle     *string    `json:"draft_title,omitempty"`
+	DraftContentMD *string    `json:"draft_content_md,omitempty"`
+	PublishAt      *time.Time `json:"publish_at,omitempty"`
+	UnpublishAt    *time.Time `json:"unpublish_at,omitempty"`
 }
 
 type ImageExport struct {
@@ -77,32 +106,163 @@ type ImageExport struct {
 	CreatedAt   time.Time `json:"created_at"`
 }
 
-type SiteSettingsExport struct {
-	SiteTitle   string    `json:"site_title"`
-	Badge       string    `json:"badge"`
-	Heading     string    `json:"heading"`
+type SnippetExport struct {
+	Name        string    `json:"name"`
 	Description string    `json:"description"`
-	Footer      string    `json:"footer"`
-	Theme       string    `json:"theme"`
-	AccentColor string    `json:"accent_color"`
-	Version     int       `json:"version"`
+	ContentMD   string    `json:"content_md"`
+	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
-// Export reads site data from the database and returns an ExportBundle.
-func Export(ctx context.Context, pool *pgxpool.Pool, includeDeleted bool) (*ExportBundle, error) {
+// TemplateExport refers to its section by name so it survives the section
+// ID remapping on import.
+type TemplateExport struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	SectionName *string   `json:"section_name,omitempty"`
+	ContentMD   string    `json:"content_md"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// VariableExport is a site variable; SectionName is set for section
+// overrides.
+type VariableExport struct {
+	Key         string  `json:"key"`
+	Value       string  `json:"value"`
+	SectionName *string `json:"section_name,omitempty"`
+}
+
+// CustomThemeExport is a theme defined by an admin, matched by name on
+// import.
+type CustomThemeExport struct {
+	Name      string            `json:"name"`
+	Label     string            `json:"label"`
+	Vars      map[string]string `json:"vars"`
+	Font      string            `json:"font,omitempty"`
+	Version   int               `json:"version"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// CommentThreadExport is a discussion thread on a page. Users are referred to
+// by email; on import, comments by users that do not exist have no author.
+type CommentThreadExport struct {
+	ID         string          `json:"id"`
+	PageID     string          `json:"page_id"`
+	AnchorText *string         `json:"anchor_text,omitempty"`
+	Resolved   bool            `json:"resolved"`
+	ResolvedBy *string         `json:"resolved_by,omitempty"`
+	ResolvedAt *time.Time      `json:"resolved_at,omitempty"`
+	CreatedBy  *string         `json:"created_by,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+	Comments   []CommentExport `json:"comments"`
+}
+
+type CommentExport struct {
+	ID          string    `json:"id"`
+	AuthorEmail *string   `json:"author_email,omitempty"`
+	Body        string    `json:"body"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// DocVersionExport is a documentation version with its pages, which refer to
+// their section by name.
+type DocVersionExport struct {
+	Name      string              `json:"name"`
+	Frozen    bool                `json:"frozen"`
+	CreatedAt time.Time           `json:"created_at"`
+	Pages     []VersionPageExport `json:"pages"`
+}
+
+type VersionPageExport struct {
+	SectionName string    `json:"section_name"`
+	Slug        string    `json:"slug"`
+	Title       string    `json:"title"`
+	ContentMD   string    `json:"content_md"`
+	SortOrder   int       `json:"sort_order"`
+	ParentSlug  *string   `json:"parent_slug,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TranslationExport is a page's translation into another locale. Page
+// versions are not exported, so instead of the version it was translated
+// from it records whether the translation was outdated.
+type TranslationExport struct {
+	PageID    string    `json:"page_id"`
+	Locale    string    `json:"locale"`
+	Title     string    `json:"title"`
+	ContentMD string    `json:"content_md"`
+	Outdated  bool      `json:"outdated,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type SiteSettingsExport struct {
+	SiteTitle   string `json:"site_title"`
+	Badge       string `json:"badge"`
+	Heading     string `json:"heading"`
+	Description string `json:"description"`
+	Footer      string `json:"footer"`
+	Theme       string `json:"theme"`
+	AccentColor string `json:"accent_color"`
+	// Font is empty in exports made before the site font existed, which
+	// means "inter".
+	Font string `json:"font,omitempty"`
+	// DefaultLocale is empty in exports made before translations existed,
+	// which means "en".
+	DefaultLocale string               `json:"default_locale,omitempty"`
+	Locales       []string             `json:"locales,omitempty"`
+	UILanguage    string               `json:"ui_language,omitempty"`
+	CustomCSS     string               `json:"custom_css,omitempty"`
+	NavLinks      []NavLinkExport      `json:"nav_links,omitempty"`
+	FooterColumns []FooterColumnExport `json:"footer_columns,omitempty"`
+	HomeIntro     string               `json:"home_intro,omitempty"`
+	HomeBlocks    []string             `json:"home_blocks,omitempty"`
+	Version       int                  `json:"version"`
+	UpdatedAt     time.Time            `json:"updated_at"`
+}
+
+type NavLinkExport struct {
+	Label string `json:"label"`
+	URL   string `json:"url"`
+}
+
+type FooterColumnExport struct {
+	Title string          `json:"title"`
+	Links []NavLinkExport `json:"links"`
+}
+
+// ExportOptions controls what Export includes.
+type ExportOptions struct {
+	// IncludeDeleted exports soft-deleted records too.
+	IncludeDeleted bool
+	// ExpandVariables replaces {{var.key}} references in page content with
+	// their values, for exports that are read outside of this site. Such an
+	// export no longer tracks later changes to the variables when imported.
+	ExpandVariables bool
+	// IncludeComments exports the discussion threads on pages. They are
+	// left out by default as they name users of this site.
+	IncludeComments bool
+}
+
+// Export reads the data of a space from the database and returns an
+// ExportBundle. Users and images shared by all spaces are not exported.
+func Export(ctx context.Context, pool *pgxpool.Pool, spaceID string, opts ExportOptions) (*ExportBundle, error) {
 	bundle := &ExportBundle{
 		Version:    "2.0",
 		ExportedAt: time.Now().UTC(),
 	}
+	if err := pool.QueryRow(ctx, `SELECT name FROM spaces WHERE id = $1`, spaceID).Scan(&bundle.Space); err != nil {
+		return nil, fmt.Errorf("query space: %w", err)
+	}
 
-	deletedFilter := " WHERE deleted = false"
-	if includeDeleted {
+	deletedFilter := " AND deleted = false"
+	if opts.IncludeDeleted {
 		deletedFilter = ""
 	}
 
 	// Export roles
-	rows, err := pool.Query(ctx, `SELECT id, name, description, created_at, updated_at FROM roles ORDER BY name`)
+	rows, err := pool.Query(ctx, `SELECT id, name, description, created_at, updated_at FROM roles WHERE space_id = $1 ORDER BY name`, spaceID)
 	if err != nil {
 		return nil, fmt.Errorf("query roles: %w", err)
 	}
@@ -117,7 +277,7 @@ func Export(ctx context.Context, pool *pgxpool.Pool, includeDeleted bool) (*Expo
 	slog.Info("exported roles", "count", len(bundle.Roles))
 
 	// Export section_rows
-	rows, err = pool.Query(ctx, `SELECT id, title, description, sort_order, version, deleted, created_at, updated_at FROM section_rows`+deletedFilter+` ORDER BY id`)
+	rows, err = pool.Query(ctx, `SELECT id, title, description, sort_order, version, deleted, created_at, updated_at FROM section_rows WHERE space_id = $1`+deletedFilter+` ORDER BY id`, spaceID)
 	if err != nil {
 		return nil, fmt.Errorf("query section_rows: %w", err)
 	}
@@ -132,13 +292,13 @@ func Export(ctx context.Context, pool *pgxpool.Pool, includeDeleted bool) (*Expo
 	slog.Info("exported section_rows", "count", len(bundle.SectionRows))
 
 	// Export sections
-	rows, err = pool.Query(ctx, `SELECT id, name, title, description, sort_order, icon, row_id, required_role, deleted, created_at, updated_at FROM sections`+deletedFilter+` ORDER BY sort_order, id`)
+	rows, err = pool.Query(ctx, `SELECT id, name, title, description, sort_order, icon, row_id, required_role, required_approvals, publish_at, unpublish_at, require_summary, landing_md, NOT show_page_meta, deleted, created_at, updated_at FROM sections WHERE space_id = $1`+deletedFilter+` ORDER BY sort_order, id`, spaceID)
 	if err != nil {
 		return nil, fmt.Errorf("query sections: %w", err)
 	}
 	for rows.Next() {
 		var s SectionExport
-		if err := rows.Scan(&s.ID, &s.Name, &s.Title, &s.Description, &s.SortOrder, &s.Icon, &s.RowID, &s.RequiredRole, &s.Deleted, &s.CreatedAt, &s.UpdatedAt); err != nil {
+		if err := rows.Scan(&s.ID, &s.Name, &s.Title, &s.Description, &s.SortOrder, &s.Icon, &s.RowID, &s.RequiredRole, &s.RequiredApprovals, &s.PublishAt, &s.UnpublishAt, &s.RequireSummary, &s.LandingMD, &s.HidePageMeta, &s.Deleted, &s.CreatedAt, &s.UpdatedAt); err != nil {
 			return nil, fmt.Errorf("scan section: %w", err)
 		}
 		bundle.Sections = append(bundle.Sections, s)
@@ -147,13 +307,13 @@ func Export(ctx context.Context, pool *pgxpool.Pool, includeDeleted bool) (*Expo
 	slog.Info("exported sections", "count", len(bundle.Sections))
 
 	// Export pages
-	rows, err = pool.Query(ctx, `SELECT id, section_id, slug, title, content_md, sort_order, parent_slug, deleted, created_at, updated_at FROM pages`+deletedFilter+` ORDER BY section_id, sort_order, id`)
+	rows, err = pool.Query(ctx, `SELECT id, section_id, slug, title, content_md, sort_order, parent_slug, deleted, created_at, updated_at, published, draft_title, draft_content_md, publish_at, unpublish_at FROM pages WHERE section_id IN (SELECT id FROM sections WHERE space_id = $1)`+deletedFilter+` ORDER BY section_id, sort_order, id`, spaceID)
 	if err != nil {
 		return nil, fmt.Errorf("query pages: %w", err)
 	}
 	for rows.Next() {
 		var p PageExport
-		if err := rows.Scan(&p.ID, &p.SectionID, &p.Slug, &p.Title, &p.ContentMD, &p.SortOrder, &p.ParentSlug, &p.Deleted, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		if err := rows.Scan(&p.ID, &p.SectionID, &p.Slug, &p.Title, &p.ContentMD, &p.SortOrder, &p.ParentSlug, &p.Deleted, &p.CreatedAt, &p.UpdatedAt, &p.Published, &p.DraftTitle, &p.DraftContentMD, &p.PublishAt, &p.UnpublishAt); err != nil {
 			return nil, fmt.Errorf("scan page: %w", err)
 		}
 		bundle.Pages = append(bundle.Pages, p)
@@ -162,7 +322,7 @@ func Export(ctx context.Context, pool *pgxpool.Pool, includeDeleted bool) (*Expo
 	slog.Info("exported pages", "count", len(bundle.Pages))
 
 	// Export images
-	rows, err = pool.Query(ctx, `SELECT filename, content_type, data, section_id, created_at FROM images ORDER BY id`)
+	rows, err = pool.Query(ctx, `SELECT filename, content_type, data, section_id, created_at FROM images WHERE space_id = $1 ORDER BY id`, spaceID)
 	if err != nil {
 		return nil, fmt.Errorf("query images: %w", err)
 	}
@@ -178,10 +338,138 @@ func Export(ctx context.Context, pool *pgxpool.Pool, includeDeleted bool) (*Expo
 	rows.Close()
 	slog.Info("exported images", "count", len(bundle.Images))
 
+	// Export snippets (deleted snippets are never exported; pages refer to them by name)
+	rows, err = pool.Query(ctx, `SELECT name, description, content_md, created_at, updated_at FROM snippets WHERE space_id = $1 AND deleted = false ORDER BY name`, spaceID)
+	if err != nil {
+		return nil, fmt.Errorf("query snippets: %w", err)
+	}
+	for rows.Next() {
+		var sn SnippetExport
+		if err := rows.Scan(&sn.Name, &sn.Description, &sn.ContentMD, &sn.CreatedAt, &sn.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan snippet: %w", err)
+		}
+		bundle.Snippets = append(bundle.Snippets, sn)
+	}
+	rows.Close()
+	slog.Info("exported snippets", "count", len(bundle.Snippets))
+
+	// Export page_templates
+	rows, err = pool.Query(ctx, `SELECT t.name, t.description, s.name, t.content_md, t.created_at, t.updated_at
+		FROM page_templates t LEFT JOIN sections s ON s.id = t.section_id
+		WHERE t.space_id = $1 AND t.deleted = false AND (t.section_id IS NULL OR s.deleted = false) ORDER BY t.name`, spaceID)
+	if err != nil {
+		return nil, fmt.Errorf("query page_templates: %w", err)
+	}
+	for rows.Next() {
+		var t TemplateExport
+		if err := rows.Scan(&t.Name, &t.Description, &t.SectionName, &t.ContentMD, &t.CreatedAt, &t.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan page_template: %w", err)
+		}
+		bundle.Templates = append(bundle.Templates, t)
+	}
+	rows.Close()
+	slog.Info("exported page_templates", "count", len(bundle.Templates))
+
+	// Export variables
+	rows, err = pool.Query(ctx, `SELECT v.key, v.value, s.name
+		FROM site_variables v LEFT JOIN sections s ON s.id = v.section_id
+		WHERE v.space_id = $1 AND (v.section_id IS NULL OR s.deleted = false)
+		ORDER BY v.key, s.name NULLS FIRST`, spaceID)
+	if err != nil {
+		return nil, fmt.Errorf("query site_variables: %w", err)
+	}
+	for rows.Next() {
+		var v VariableExport
+		if err := rows.Scan(&v.Key, &v.Value, &v.SectionName); err != nil {
+			return nil, fmt.Errorf("scan site_variable: %w", err)
+		}
+		bundle.Variables = append(bundle.Variables, v)
+	}
+	rows.Close()
+	slog.Info("exported variables", "count", len(bundle.Variables))
+
+	// Export doc_versions with their pages
+	rows, err = pool.Query(ctx, `SELECT v.name, v.frozen, v.created_at, s.name, p.slug, p.title, p.content_md, p.sort_order, p.parent_slug, p.updated_at
+		FROM doc_versions v
+		JOIN version_pages p ON p.version_id = v.id
+		JOIN sections s ON s.id = p.section_id
+		WHERE v.space_id = $1 AND s.deleted = false
+		ORDER BY v.created_at, v.name, s.name, p.sort_order, p.slug`, spaceID)
+	if err != nil {
+		return nil, fmt.Errorf("query doc_versions: %w", err)
+	}
+	for rows.Next() {
+		var v DocVersionExport
+		var p VersionPageExport
+		if err := rows.Scan(&v.Name, &v.Frozen, &v.CreatedAt, &p.SectionName, &p.Slug, &p.Title, &p.ContentMD, &p.SortOrder, &p.ParentSlug, &p.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan doc_version: %w", err)
+		}
+		if n := len(bundle.Versions); n == 0 || bundle.Versions[n-1].Name != v.Name {
+			bundle.Versions = append(bundle.Versions, v)
+		}
+		last := &bundle.Versions[len(bundle.Versions)-1]
+		last.Pages = append(last.Pages, p)
+	}
+	rows.Close()
+	slog.Info("exported doc_versions", "count", len(bundle.Versions))
+
+	// Export page_translations of the exported pages
+	pageFilter := " AND p.deleted = false"
+	if opts.IncludeDeleted {
+		pageFilter = ""
+	}
+	rows, err = pool.Query(ctx, `SELECT t.page_id, t.locale, t.title, t.content_md, t.source_version < p.version, t.updated_at
+		FROM page_translations t
+		JOIN pages p ON p.id = t.page_id
+		JOIN sections s ON s.id = p.section_id
+		WHERE s.space_id = $1`+pageFilter+`
+		ORDER BY t.page_id, t.locale`, spaceID)
+	if err != nil {
+		return nil, fmt.Errorf("query page_translations: %w", err)
+	}
+	for rows.Next() {
+		var t TranslationExport
+		if err := rows.Scan(&t.PageID, &t.Locale, &t.Title, &t.ContentMD, &t.Outdated, &t.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan page_translation: %w", err)
+		}
+		bundle.Translations = append(bundle.Translations, t)
+	}
+	rows.Close()
+	slog.Info("exported page_translations", "count", len(bundle.Translations))
+
+	if opts.ExpandVariables {
+		expandPageVariables(bundle)
+	}
+
+	if opts.IncludeComments {
+		if err := exportComments(ctx, pool, spaceID, bundle, opts.IncludeDeleted); err != nil {
+			return nil, err
+		}
+	}
+
+	// Export custom_themes
+	rows, err = pool.Query(ctx, `SELECT name, label, vars, font, version, updated_at
+		FROM custom_themes WHERE space_id = $1 ORDER BY name`, spaceID)
+	if err != nil {
+		return nil, fmt.Errorf("query custom_themes: %w", err)
+	}
+	for rows.Next() {
+		var t CustomThemeExport
+		if err := rows.Scan(&t.Name, &t.Label, &t.Vars, &t.Font, &t.Version, &t.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan custom_theme: %w", err)
+		}
+		bundle.Themes = append(bundle.Themes, t)
+	}
+	rows.Close()
+	slog.Info("exported custom_themes", "count", len(bundle.Themes))
+
 	// Export site_settings
 	var ss SiteSettingsExport
-	err = pool.QueryRow(ctx, `SELECT site_title, badge, heading, description, footer, theme, accent_color, version, updated_at FROM site_settings WHERE singleton = TRUE`).
-		Scan(&ss.SiteTitle, &ss.Badge, &ss.Heading, &ss.Description, &ss.Footer, &ss.Theme, &ss.AccentColor, &ss.Version, &ss.UpdatedAt)
+	err = pool.QueryRow(ctx, `SELECT site_title, badge, heading, description, footer, theme, accent_color, font, default_locale, locales, ui_language, custom_css,
+	                                 nav_links, footer_columns, home_intro, home_blocks, version, updated_at
+	                          FROM site_settings WHERE space_id = $1`, spaceID).
+		Scan(&ss.SiteTitle, &ss.Badge, &ss.Heading, &ss.Description, &ss.Footer, &ss.Theme, &ss.AccentColor, &ss.Font, &ss.DefaultLocale, &ss.Locales, &ss.UILanguage, &ss.CustomCSS,
+			&ss.NavLinks, &ss.FooterColumns, &ss.HomeIntro, &ss.HomeBlocks, &ss.Version, &ss.UpdatedAt)
 	if err != nil {
 		return nil, fmt.Errorf("query site_settings: %w", err)
 	}
@@ -191,9 +479,144 @@ func Export(ctx context.Context, pool *pgxpool.Pool, includeDeleted bool) (*Expo
 	return bundle, nil
 }
 
-// Import writes the given ExportBundle into the database inside a transaction.
-// When clean is true, all existing content is deleted before importing (history is preserved).
-func Import(ctx context.Context, pool *pgxpool.Pool, bundle *ExportBundle, clean bool) error {
+// expandPageVariables replaces {{var.key}} references in the bundle's pages
+// and section landing pages, applying each section's overrides on top of
+// the global values.
+func expandPageVariables(bundle *ExportBundle) {
+	sectionNames := make(map[string]string)
+	for _, s := range bundle.Sections {
+		sectionNames[s.ID] = s.Name
+	}
+	values := func(sectionName string) map[string]string {
+		m := make(map[string]string)
+		for _, v := range bundle.Variables {
+			if v.SectionName == nil {
+				m[v.Key] = v.Value
+			}
+		}
+		for _, v := range bundle.Variables {
+			if v.SectionName != nil && *v.SectionName == sectionName {
+				m[v.Key] = v.Value
+			}
+		}
+		return m
+	}
+	cache := make(map[string]map[string]string)
+	for i, s := range bundle.Sections {
+		if s.LandingMD == "" {
+			continue
+		}
+		if _, ok := cache[s.Name]; !ok {
+			cache[s.Name] = values(s.Name)
+		}
+		bundle.Sections[i].LandingMD = markdown.ExpandVariables(s.LandingMD, cache[s.Name])
+	}
+	pageSections := make(map[string]string)
+	for i, p := range bundle.Pages {
+		name := sectionNames[p.SectionID]
+		pageSections[p.ID] = name
+		if _, ok := cache[name]; !ok {
+			cache[name] = values(name)
+		}
+		bundle.Pages[i].ContentMD = markdown.ExpandVariables(p.ContentMD, cache[name])
+		if p.DraftContentMD != nil {
+			draft := markdown.ExpandVariables(*p.DraftContentMD, cache[name])
+			bundle.Pages[i].DraftContentMD = &draft
+		}
+	}
+	for i := range bundle.Versions {
+		for j, p := range bundle.Versions[i].Pages {
+			if _, ok := cache[p.SectionName]; !ok {
+				cache[p.SectionName] = values(p.SectionName)
+			}
+			bundle.Versions[i].Pages[j].ContentMD = markdown.ExpandVariables(p.ContentMD, cache[p.SectionName])
+		}
+	}
+	for i, t := range bundle.Translations {
+		bundle.Translations[i].ContentMD = markdown.ExpandVariables(t.ContentMD, cache[pageSections[t.PageID]])
+	}
+}
+
+// exportComments adds the comment threads on the bundle's pages.
+func exportComments(ctx context.Context, pool *pgxpool.Pool, spaceID string, bundle *ExportBundle, includeDeleted bool) error {
+	deletedFilter := " AND p.deleted = false"
+	if includeDeleted {
+		deletedFilter = ""
+	}
+
+	rows, err := pool.Query(ctx, `SELECT t.id, t.page_id, t.anchor_text, t.resolved, ru.email, t.resolved_at, cu.email, t.created_at, t.updated_at
+		FROM comment_threads t
+		JOIN pages p ON p.id = t.page_id
+		JOIN sections s ON s.id = p.section_id
+		LEFT JOIN users ru ON ru.id = t.resolved_by
+		LEFT JOIN users cu ON cu.id = t.created_by
+		WHERE s.space_id = $1`+deletedFilter+`
+		ORDER BY t.created_at, t.id`, spaceID)
+	if err != nil {
+		return fmt.Errorf("query comment_threads: %w", err)
+	}
+	index := make(map[string]int)
+	for rows.Next() {
+		var t CommentThreadExport
+		if err := rows.Scan(&t.ID, &t.PageID, &t.AnchorText, &t.Resolved, &t.ResolvedBy, &t.ResolvedAt, &t.CreatedBy, &t.CreatedAt, &t.UpdatedAt); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan comment_thread: %w", err)
+		}
+		index[t.ID] = len(bundle.Comments)
+		bundle.Comments = append(bundle.Comments, t)
+	}
+	rows.Close()
+
+	rows, err = pool.Query(ctx, `SELECT c.id, c.thread_id, u.email, c.body, c.created_at
+		FROM page_comments c
+		JOIN comment_threads t ON t.id = c.thread_id
+		JOIN pages p ON p.id = t.page_id
+		JOIN sections s ON s.id = p.section_id
+		LEFT JOIN users u ON u.id = c.author_id
+		WHERE s.space_id = $1
+		ORDER BY c.created_at, c.id`, spaceID)
+	if err != nil {
+		return fmt.Errorf("query page_comments: %w", err)
+	}
+	count := 0
+	for rows.Next() {
+		var c CommentExport
+		var threadID string
+		if err := rows.Scan(&c.ID, &threadID, &c.AuthorEmail, &c.Body, &c.CreatedAt); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan page_comment: %w", err)
+		}
+		if i, ok := index[threadID]; ok {
+			bundle.Comments[i].Comments = append(bundle.Comments[i].Comments, c)
+			count++
+		}
+	}
+	rows.Close()
+	slog.Info("exported comments", "threads", len(bundle.Comments), "comments", count)
+	return nil
+}
+
+// localID returns id if no row has it yet or the row belongs to the space
+// being imported, and a new id otherwise. ownerQuery selects the space_id of
+// the row with id $1. Importing a bundle exported from another space thus
+// never takes over that space's rows.
+func localID(ctx context.Context, tx pgx.Tx, ownerQuery, id, spaceID string) (string, error) {
+	var owner string
+	err := tx.QueryRow(ctx, ownerQuery, id).Scan(&owner)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && owner == spaceID) {
+		return id, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	err = tx.QueryRow(ctx, `SELECT gen_random_uuid()::text`).Scan(&id)
+	return id, err
+}
+
+// Import writes the given ExportBundle into a space inside a transaction.
+// When clean is true, all existing content of the space is deleted before
+// importing (history is preserved).
+func Import(ctx context.Context, pool *pgxpool.Pool, spaceID string, bundle *ExportBundle, clean bool) error {
 	tx, err := pool.Begin(ctx)
 	if err != nil {
 		return fmt.Errorf("begin transaction: %w", err)
@@ -206,41 +629,52 @@ func Import(ctx context.Context, pool *pgxpool.Pool, bundle *ExportBundle, clean
 			label string
 			query string
 		}{
-			{"pages", "DELETE FROM pages"},
-			{"images", "DELETE FROM images"},
-			{"sections", "DELETE FROM sections"},
-			{"section_rows", "DELETE FROM section_rows"},
-			{"site_settings", "DELETE FROM site_settings"},
-			{"roles", "DELETE FROM roles WHERE name NOT IN ('admin', 'editor')"},
+			{"pages", "DELETE FROM pages WHERE section_id IN (SELECT id FROM sections WHERE space_id = $1)"},
+			{"images", "DELETE FROM images WHERE space_id = $1"},
+			{"snippets", "DELETE FROM snippets WHERE space_id = $1"},
+			{"page_templates", "DELETE FROM page_templates WHERE space_id = $1"},
+			{"site_variables", "DELETE FROM site_variables WHERE space_id = $1"},
+			{"doc_versions", "DELETE FROM doc_versions WHERE space_id = $1"},
+			{"sections", "DELETE FROM sections WHERE space_id = $1"},
+			{"section_rows", "DELETE FROM section_rows WHERE space_id = $1"},
+			{"custom_themes", "DELETE FROM custom_themes WHERE space_id = $1"},
+			{"site_settings", "DELETE FROM site_settings WHERE space_id = $1"},
+			{"roles", "DELETE FROM roles WHERE space_id = $1 AND name NOT IN ('admin', 'editor', 'reviewer')"},
 		}
 		for _, q := range cleanQueries {
-			if _, err := tx.Exec(ctx, q.query); err != nil {
+			if _, err := tx.Exec(ctx, q.query, spaceID); err != nil {
 				return fmt.Errorf("clean delete %s: %w", q.label, err)
 			}
 			slog.Info("clean import: deleted", "table", q.label)
 		}
 	}
 
-	// Import roles
+	// Import roles — matched by name, as their IDs are not referenced
 	for _, r := range bundle.Roles {
 		_, err := tx.Exec(ctx,
-			`INSERT INTO roles (id, name, description, created_at, updated_at)
+			`INSERT INTO roles (space_id, name, description, created_at, updated_at)
 			 VALUES ($1, $2, $3, $4, $5)
-			 ON CONFLICT (name) DO UPDATE SET description=$3, updated_at=$5`,
-			r.ID, r.Name, r.Description, r.CreatedAt, r.UpdatedAt)
+			 ON CONFLICT (space_id, name) DO UPDATE SET description=$3, updated_at=$5`,
+			spaceID, r.Name, r.Description, r.CreatedAt, r.UpdatedAt)
 		if err != nil {
 			return fmt.Errorf("upsert role %s: %w", r.Name, err)
 		}
 	}
 	slog.Info("imported roles", "count", len(bundle.Roles))
 
-	// Import section_rows
+	// Import section_rows — remapped when the ID belongs to another space
+	rowIDs := make(map[string]string) // export id -> DB id
 	for _, sr := range bundle.SectionRows {
-		_, err := tx.Exec(ctx,
-			`INSERT INTO section_rows (id, title, description, sort_order, version, deleted, created_at, updated_at)
-			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		id, err := localID(ctx, tx, `SELECT space_id FROM section_rows WHERE id = $1`, sr.ID, spaceID)
+		if err != nil {
+			return fmt.Errorf("check section_row %s: %w", sr.ID, err)
+		}
+		rowIDs[sr.ID] = id
+		_, err = tx.Exec(ctx,
+			`INSERT INTO section_rows (id, space_id, title, description, sort_order, version, deleted, created_at, updated_at)
+			 VALUES ($1, $9, $2, $3, $4, $5, $6, $7, $8)
 			 ON CONFLICT (id) DO UPDATE SET title=$2, description=$3, sort_order=$4, version=$5, deleted=$6, updated_at=$8`,
-			sr.ID, sr.Title, sr.Description, sr.SortOrder, sr.Version, sr.Deleted, sr.CreatedAt, sr.UpdatedAt)
+			id, sr.Title, sr.Description, sr.SortOrder, sr.Version, sr.Deleted, sr.CreatedAt, sr.UpdatedAt, spaceID)
 		if err != nil {
 			return fmt.Errorf("upsert section_row %s: %w", sr.ID, err)
 		}
@@ -255,13 +689,19 @@ func Import(ctx context.Context, pool *pgxpool.Pool, bundle *ExportBundle, clean
 		if name == "" {
 			name = s.ID
 		}
+		rowID := s.RowID
+		if rowID != nil {
+			if id, ok := rowIDs[*rowID]; ok {
+				rowID = &id
+			}
+		}
 		var newID string
 		err := tx.QueryRow(ctx,
-			`INSERT INTO sections (name, title, description, sort_order, icon, row_id, required_role, deleted, created_at, updated_at)
-			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-			 ON CONFLICT (name) WHERE deleted = false DO UPDATE SET title=$2, description=$3, sort_order=$4, icon=$5, row_id=$6, required_role=$7, deleted=$8, updated_at=$10
+			`INSERT INTO sections (space_id, name, title, description, sort_order, icon, row_id, required_role, deleted, created_at, updated_at, required_approvals, publish_at, unpublish_at, require_summary, landing_md, show_page_meta)
+			 VALUES ($15, $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $16, NOT $17)
+			 ON CONFLICT (space_id, name) WHERE deleted = false DO UPDATE SET title=$2, description=$3, sort_order=$4, icon=$5, row_id=$6, required_role=$7, deleted=$8, updated_at=$10, required_approvals=$11, publish_at=$12, unpublish_at=$13, require_summary=$14, landing_md=$16, show_page_meta=NOT $17
 			 RETURNING id`,
-			name, s.Title, s.Description, s.SortOrder, s.Icon, s.RowID, s.RequiredRole, s.Deleted, s.CreatedAt, s.UpdatedAt).
+			name, s.Title, s.Description, s.SortOrder, s.Icon, rowID, s.RequiredRole, s.Deleted, s.CreatedAt, s.UpdatedAt, s.RequiredApprovals, s.PublishAt, s.UnpublishAt, s.RequireSummary, spaceID, s.LandingMD, s.HidePageMeta).
 			Scan(&newID)
 		if err != nil {
 			return fmt.Errorf("upsert section %s: %w", name, err)
@@ -280,28 +720,50 @@ func Import(ctx context.Context, pool *pgxpool.Pool, bundle *ExportBundle, clean
 		exportIDToName[s.ID] = name
 	}
 
-	// Import pages — remap section_id through exportIDToName -> sectionNameToID
+	// Import pages — remap section_id through exportIDToName -> sectionNameToID,
+	// and the page's own id when it belongs to another space
+	pageIDs := make(map[string]string) // export id -> DB id
 	for _, p := range bundle.Pages {
 		name := exportIDToName[p.SectionID]
 		newSectionID := sectionNameToID[name]
 		if newSectionID == "" {
 			return fmt.Errorf("page %s references unknown section_id: %s", p.ID, p.SectionID)
 		}
+		id, err := localID(ctx, tx, `SELECT s.space_id FROM pages p JOIN sections s ON s.id = p.section_id WHERE p.id = $1`, p.ID, spaceID)
+		if err != nil {
+			return fmt.Errorf("check page %s: %w", p.ID, err)
+		}
+		pageIDs[p.ID] = id
 		// Remove any existing page with same section_id+slug but different id to avoid unique constraint violation
-		if _, err := tx.Exec(ctx, `DELETE FROM pages WHERE section_id = $1 AND slug = $2 AND id != $3`, newSectionID, p.Slug, p.ID); err != nil {
+		if _, err := tx.Exec(ctx, `DELETE FROM pages WHERE section_id = $1 AND slug = $2 AND id != $3`, newSectionID, p.Slug, id); err != nil {
 			return fmt.Errorf("clean conflicting page %s/%s: %w", newSectionID, p.Slug, err)
 		}
-		_, err := tx.Exec(ctx,
-			`INSERT INTO pages (id, section_id, slug, title, content_md, sort_order, parent_slug, deleted, created_at, updated_at)
-			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-			 ON CONFLICT (id) DO UPDATE SET section_id=$2, slug=$3, title=$4, content_md=$5, sort_order=$6, parent_slug=$7, deleted=$8, updated_at=$10`,
-			p.ID, newSectionID, p.Slug, p.Title, p.ContentMD, p.SortOrder, p.ParentSlug, p.Deleted, p.CreatedAt, p.UpdatedAt)
+		published := p.Published == nil || *p.Published
+		_, err = tx.Exec(ctx,
+			`INSERT INTO pages (id, section_id, slug, title, content_md, sort_order, parent_slug, deleted, created_at, updated_at, published, draft_title, draft_content_md, publish_at, unpublish_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+			 ON CONFLICT (id) DO UPDATE SET section_id=$2, slug=$3, title=$4, content_md=$5, sort_order=$6, parent_slug=$7, deleted=$8, updated_at=$10, published=$11, draft_title=$12, draft_content_md=$13, publish_at=$14, unpublish_at=$15`,
+			id, newSectionID, p.Slug, p.Title, p.ContentMD, p.SortOrder, p.ParentSlug, p.Deleted, p.CreatedAt, p.UpdatedAt, published, p.DraftTitle, p.DraftContentMD, p.PublishAt, p.UnpublishAt)
 		if err != nil {
 			return fmt.Errorf("upsert page %s: %w", p.ID, err)
 		}
 	}
 	slog.Info("imported pages", "count", len(bundle.Pages))
 
+	// Import page_translations — source_version is derived from the
+	// imported page's version
+	for _, t := range bundle.Translations {
+		_, err := tx.Exec(ctx,
+			`INSERT INTO page_translations (page_id, locale, title, content_md, source_version, updated_at)
+			 SELECT $1, $2, $3, $4, version - CASE WHEN $5 THEN 1 ELSE 0 END, $6 FROM pages WHERE id = $1
+			 ON CONFLICT (page_id, locale) DO UPDATE SET title=$3, content_md=$4, source_version=EXCLUDED.source_version, updated_at=$6`,
+			pageIDs[t.PageID], t.Locale, t.Title, t.ContentMD, t.Outdated, t.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("upsert page_translation %s/%s: %w", t.PageID, t.Locale, err)
+		}
+	}
+	slog.Info("imported page_translations", "count", len(bundle.Translations))
+
 	// Import images — remap section_id
 	for _, img := range bundle.Images {
 		imgData, err := base64.StdEncoding.DecodeString(img.DataBase64)
@@ -317,24 +779,203 @@ func Import(ctx context.Context, pool *pgxpool.Pool, bundle *ExportBundle, clean
 			}
 		}
 		_, err = tx.Exec(ctx,
-			`INSERT INTO images (filename, content_type, data, section_id, created_at)
-			 VALUES ($1, $2, $3, $4, $5)
-			 ON CONFLICT (filename) DO UPDATE SET content_type=$2, data=$3, section_id=$4`,
-			img.Filename, img.ContentType, imgData, sectionID, img.CreatedAt)
+			`INSERT INTO images (space_id, filename, content_type, data, section_id, created_at)
+			 VALUES ($6, $1, $2, $3, $4, $5)
+			 ON CONFLICT (space_id, filename) DO UPDATE SET content_type=$2, data=$3, section_id=$4`,
+			img.Filename, img.ContentType, imgData, sectionID, img.CreatedAt, spaceID)
 		if err != nil {
 			return fmt.Errorf("upsert image %s: %w", img.Filename, err)
 		}
 	}
 	slog.Info("imported images", "count", len(bundle.Images))
 
+	// Import snippets — matched by name
+	for _, sn := range bundle.Snippets {
+		_, err := tx.Exec(ctx,
+			`INSERT INTO snippets (space_id, name, description, content_md, created_at, updated_at)
+			 VALUES ($6, $1, $2, $3, $4, $5)
+			 ON CONFLICT (space_id, name) WHERE deleted = false DO UPDATE SET description=$2, content_md=$3, version=snippets.version+1, updated_at=$5`,
+			sn.Name, sn.Description, sn.ContentMD, sn.CreatedAt, sn.UpdatedAt, spaceID)
+		if err != nil {
+			return fmt.Errorf("upsert snippet %s: %w", sn.Name, err)
+		}
+	}
+	slog.Info("imported snippets", "count", len(bundle.Snippets))
+
+	// Import page_templates — matched by name, section remapped by name
+	for _, t := range bundle.Templates {
+		var sectionID *string
+		if t.SectionName != nil {
+			id, ok := sectionNameToID[*t.SectionName]
+			if !ok {
+				return fmt.Errorf("page template %s references unknown section: %s", t.Name, *t.SectionName)
+			}
+			sectionID = &id
+		}
+		_, err := tx.Exec(ctx,
+			`INSERT INTO page_templates (space_id, name, description, section_id, content_md, created_at, updated_at)
+			 VALUES ($7, $1, $2, $3, $4, $5, $6)
+			 ON CONFLICT (space_id, name) WHERE deleted = false DO UPDATE SET description=$2, section_id=$3, content_md=$4, version=page_templates.version+1, updated_at=$6`,
+			t.Name, t.Description, sectionID, t.ContentMD, t.CreatedAt, t.UpdatedAt, spaceID)
+		if err != nil {
+			return fmt.Errorf("upsert page_template %s: %w", t.Name, err)
+		}
+	}
+	slog.Info("imported page_templates", "count", len(bundle.Templates))
+
+	// Import variables — matched by key and section
+	for _, v := range bundle.Variables {
+		var sectionID *string
+		if v.SectionName != nil {
+			id, ok := sectionNameToID[*v.SectionName]
+			if !ok {
+				return fmt.Errorf("variable %s references unknown section: %s", v.Key, *v.SectionName)
+			}
+			sectionID = &id
+		}
+		_, err := tx.Exec(ctx,
+			`INSERT INTO site_variables (space_id, key, value, section_id)
+			 VALUES ($4, $1, $2, $3)
+			 ON CONFLICT (space_id, key, section_id) DO UPDATE SET value=$2, updated_at=now()`,
+			v.Key, v.Value, sectionID, spaceID)
+		if err != nil {
+			return fmt.Errorf("upsert variable %s: %w", v.Key, err)
+		}
+	}
+	slog.Info("imported variables", "count", len(bundle.Variables))
+
+	// Import doc_versions — matched by name, pages by section name and slug
+	for _, v := range bundle.Versions {
+		var versionID string
+		err := tx.QueryRow(ctx,
+			`INSERT INTO doc_versions (space_id, name, frozen, created_at)
+			 VALUES ($4, $1, $2, $3)
+			 ON CONFLICT (space_id, name) DO UPDATE SET frozen=$2
+			 RETURNING id`,
+			v.Name, v.Frozen, v.CreatedAt, spaceID).Scan(&versionID)
+		if err != nil {
+			return fmt.Errorf("upsert doc_version %s: %w", v.Name, err)
+		}
+		for _, p := range v.Pages {
+			sectionID, ok := sectionNameToID[p.SectionName]
+			if !ok {
+				return fmt.Errorf("version %s references unknown section: %s", v.Name, p.SectionName)
+			}
+			_, err := tx.Exec(ctx,
+				`INSERT INTO version_pages (version_id, section_id, slug, title, content_md, sort_order, parent_slug, updated_at)
+				 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+				 ON CONFLICT (version_id, section_id, slug) DO UPDATE SET title=$4, content_md=$5, sort_order=$6, parent_slug=$7, updated_at=$8`,
+				versionID, sectionID, p.Slug, p.Title, p.ContentMD, p.SortOrder, p.ParentSlug, p.UpdatedAt)
+			if err != nil {
+				return fmt.Errorf("upsert version_page %s/%s/%s: %w", v.Name, p.SectionName, p.Slug, err)
+			}
+		}
+	}
+	slog.Info("imported doc_versions", "count", len(bundle.Versions))
+
+	// Import comment threads — matched by id, users looked up by email
+	const threadOwner = `SELECT s.space_id FROM comment_threads t
+		JOIN pages p ON p.id = t.page_id JOIN sections s ON s.id = p.section_id
+		WHERE t.id = $1`
+	const commentOwner = `SELECT s.space_id FROM page_comments c
+		JOIN comment_threads t ON t.id = c.thread_id
+		JOIN pages p ON p.id = t.page_id JOIN sections s ON s.id = p.section_id
+		WHERE c.id = $1`
+	for _, t := range bundle.Comments {
+		threadID, err := localID(ctx, tx, threadOwner, t.ID, spaceID)
+		if err != nil {
+			return fmt.Errorf("check comment_thread %s: %w", t.ID, err)
+		}
+		_, err = tx.Exec(ctx,
+			`INSERT INTO comment_threads (id, page_id, anchor_text, resolved, resolved_by, resolved_at, created_by, created_at, updated_at)
+			 VALUES ($1, $2, $3, $4, (SELECT id FROM users WHERE email = $5), $6, (SELECT id FROM users WHERE email = $7), $8, $9)
+			 ON CONFLICT (id) DO UPDATE SET anchor_text=$3, resolved=$4, resolved_by=EXCLUDED.resolved_by, resolved_at=$6, updated_at=$9`,
+			threadID, pageIDs[t.PageID], t.AnchorText, t.Resolved, t.ResolvedBy, t.ResolvedAt, t.CreatedBy, t.CreatedAt, t.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("upsert comment_thread %s: %w", t.ID, err)
+		}
+		for _, c := range t.Comments {
+			commentID, err := localID(ctx, tx, commentOwner, c.ID, spaceID)
+			if err != nil {
+				return fmt.Errorf("check page_comment %s: %w", c.ID, err)
+			}
+			_, err = tx.Exec(ctx,
+				`INSERT INTO page_comments (id, thread_id, author_id, body, created_at)
+				 VALUES ($1, $2, (SELECT id FROM users WHERE email = $3), $4, $5)
+				 ON CONFLICT (id) DO UPDATE SET body=$4`,
+				commentID, threadID, c.AuthorEmail, c.Body, c.CreatedAt)
+			if err != nil {
+				return fmt.Errorf("upsert page_comment %s: %w", c.ID, err)
+			}
+		}
+	}
+	slog.Info("imported comment_threads", "count", len(bundle.Comments))
+
+	// Import custom_themes — matched by name
+	for _, t := range bundle.Themes {
+		vars := t.Vars
+		if vars == nil {
+			vars = map[string]string{}
+		}
+		font := t.Font
+		if font == "" {
+			font = "inter"
+		}
+		_, err := tx.Exec(ctx,
+			`INSERT INTO custom_themes (space_id, name, label, vars, font, version, updated_at)
+			 VALUES ($7, $1, $2, $3, $4, $5, $6)
+			 ON CONFLICT (space_id, name) DO UPDATE SET label=$2, vars=$3, font=$4, version=$5, updated_at=$6`,
+			t.Name, t.Label, vars, font, t.Version, t.UpdatedAt, spaceID)
+		if err != nil {
+			return fmt.Errorf("upsert custom_theme %s: %w", t.Name, err)
+		}
+	}
+	slog.Info("imported custom_themes", "count", len(bundle.Themes))
+
 	// Import site_settings
 	if bundle.SiteSettings != nil {
 		ss := bundle.SiteSettings
+		defaultLocale := ss.DefaultLocale
+		if defaultLocale == "" {
+			defaultLocale = "en"
+		}
+		locales := ss.Locales
+		if locales == nil {
+			locales = []string{}
+		}
+		uiLanguage := ss.UILanguage
+		if uiLanguage == "" {
+			uiLanguage = "en"
+		}
+		font := ss.Font
+		if font == "" {
+			font = "inter"
+		}
+		navLinks := ss.NavLinks
+		if navLinks == nil {
+			navLinks = []NavLinkExport{}
+		}
+		footerColumns := ss.FooterColumns
+		if footerColumns == nil {
+			footerColumns = []FooterColumnExport{}
+		}
+		for i := range footerColumns {
+			if footerColumns[i].Links == nil {
+				footerColumns[i].Links = []NavLinkExport{}
+			}
+		}
+		homeBlocks := ss.HomeBlocks
+		if homeBlocks == nil {
+			homeBlocks = []string{}
+		}
 		_, err := tx.Exec(ctx,
-			`INSERT INTO site_settings (singleton, site_title, badge, heading, description, footer, theme, accent_color, version, updated_at)
-			 VALUES (TRUE, $1, $2, $3, $4, $5, $6, $7, $8, $9)
-			 ON CONFLICT (singleton) DO UPDATE SET site_title=$1, badge=$2, heading=$3, description=$4, footer=$5, theme=$6, accent_color=$7, version=$8, updated_at=$9`,
-			ss.SiteTitle, ss.Badge, ss.Heading, ss.Description, ss.Footer, ss.Theme, ss.AccentColor, ss.Version, ss.UpdatedAt)
+			`INSERT INTO site_settings (space_id, site_title, badge, heading, description, footer, theme, accent_color, default_locale, locales, ui_language, custom_css,
+			                           nav_links, footer_columns, home_intro, home_blocks, version, updated_at, font)
+			 VALUES ($18, $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $19)
+			 ON CONFLICT (space_id) DO UPDATE SET site_title=$1, badge=$2, heading=$3, description=$4, footer=$5, theme=$6, accent_color=$7, default_locale=$8, locales=$9, ui_language=$10, custom_css=$11,
+			                                      nav_links=$12, footer_columns=$13, home_intro=$14, home_blocks=$15, version=$16, updated_at=$17, font=$19`,
+			ss.SiteTitle, ss.Badge, ss.Heading, ss.Description, ss.Footer, ss.Theme, ss.AccentColor, defaultLocale, locales, uiLanguage, ss.CustomCSS,
+			navLinks, footerColumns, ss.HomeIntro, homeBlocks, ss.Version, ss.UpdatedAt, spaceID, font)
 		if err != nil {
 			return fmt.Errorf("upsert site_settings: %w", err)
 		}
@@ -351,6 +992,13 @@ func Import(ctx context.Context, pool *pgxpool.Pool, bundle *ExportBundle, clean
 		"sections", len(bundle.Sections),
 		"pages", len(bundle.Pages),
 		"images", len(bundle.Images),
+		"snippets", len(bundle.Snippets),
+		"page_templates", len(bundle.Templates),
+		"variables", len(bundle.Variables),
+		"comment_threads", len(bundle.Comments),
+		"doc_versions", len(bundle.Versions),
+		"page_translations", len(bundle.Translations),
+		"custom_themes", len(bundle.Themes),
 	)
 
 	return nil
@@ -393,6 +1041,50 @@ func Validate(bundle *ExportBundle) error {
 		}
 	}
 
+	// Validate page templates and variables reference exported sections
+	sectionNames := map[string]bool{}
+	for _, s := range bundle.Sections {
+		sectionNames[s.Name] = true
+	}
+	for _, t := range bundle.Templates {
+		if t.SectionName != nil && !sectionNames[*t.SectionName] {
+			return fmt.Errorf("page template %s references unknown section: %s", t.Name, *t.SectionName)
+		}
+	}
+	for _, v := range bundle.Variables {
+		if v.SectionName != nil && !sectionNames[*v.SectionName] {
+			return fmt.Errorf("variable %s references unknown section: %s", v.Key, *v.SectionName)
+		}
+	}
+	for _, v := range bundle.Versions {
+		for _, p := range v.Pages {
+			if !sectionNames[p.SectionName] {
+				return fmt.Errorf("version %s references unknown section: %s", v.Name, p.SectionName)
+			}
+		}
+	}
+
+	// Validate comment threads reference exported pages
+	pageIDs := map[string]bool{}
+	for _, p := range bundle.Pages {
+		pageIDs[p.ID] = true
+	}
+	for _, t := range bundle.Comments {
+		if !pageIDs[t.PageID] {
+			return fmt.Errorf("comment thread %s references unknown page_id: %s", t.ID, t.PageID)
+		}
+	}
+	for _, t := range bundle.Translations {
+		if !pageIDs[t.PageID] {
+			return fmt.Errorf("%s translation references unknown page_id: %s", t.Locale, t.PageID)
+		}
+	}
+	for _, t := range bundle.Themes {
+		if t.Name == "" {
+			return fmt.Errorf("custom theme %q has no name", t.Label)
+		}
+	}
+
 	// Null out image section_ids that reference missing sections
 	for i := range bundle.Images {
 		if bundle.Images[i].SectionID != nil && !sectionIDs[*bundle.Images[i].SectionID] {
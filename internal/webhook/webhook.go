@@ -0,0 +1,49 @@
+// Package webhook signs and verifies webhook deliveries.
+//
+// A delivery is a JSON POST carrying three headers: the event name, the
+// Unix time it was sent and a signature. The signature is the hex-encoded
+// HMAC-SHA256, keyed with the webhook's secret, of the timestamp, a dot and
+// the body, prefixed with "sha256=". Signing the timestamp lets receivers
+// reject replayed deliveries.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+)
+
+const (
+	HeaderEvent     = "X-Simpledoc-Event"
+	HeaderDelivery  = "X-Simpledoc-Delivery"
+	HeaderTimestamp = "X-Simpledoc-Timestamp"
+	HeaderSignature = "X-Simpledoc-Signature"
+)
+
+// Sign returns the signature header value for a body sent at timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a delivery's timestamp and signature headers against its
+// body. Deliveries older or newer than tolerance are rejected.
+func Verify(secret, timestamp, signature string, body []byte, tolerance time.Duration) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("invalid timestamp")
+	}
+	if d := time.Since(time.Unix(ts, 0)); d > tolerance || d < -tolerance {
+		return errors.New("timestamp outside tolerance")
+	}
+	if !hmac.Equal([]byte(Sign(secret, ts, body)), []byte(signature)) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package webhook
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	// Computed independently with Python's hmac module.
+	want := "sha256=8f60ca6416f3ea94f701ab21a1461b3d8198decff62b4d18dd57dc30c4684415"
+	if got := Sign("whsec_test", 1700000000, []byte(`{"event":"page.updated"}`)); got != want {
+		t.Errorf("Sign = %s, want %s", got, want)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	secret := "whsec_test"
+	body := []byte(`{"event":"page.updated"}`)
+	now := time.Now().Unix()
+	ts := strconv.FormatInt(now, 10)
+	sig := Sign(secret, now, body)
+	old := now - 600
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		signature string
+		body      []byte
+		wantErr   string
+	}{
+		{"valid", secret, ts, sig, body, ""},
+		{"wrong secret", "other", ts, sig, body, "signature mismatch"},
+		{"changed body", secret, ts, sig, []byte(`{"event":"page.deleted"}`), "signature mismatch"},
+		{"signature for other timestamp", secret, strconv.FormatInt(now-1, 10), sig, body, "signature mismatch"},
+		{"missing prefix", secret, ts, sig[len("sha256="):], body, "signature mismatch"},
+		{"empty signature", secret, ts, "", body, "signature mismatch"},
+		{"invalid timestamp", secret, "yesterday", sig, body, "invalid timestamp"},
+		{"too old", secret, strconv.FormatInt(old, 10), Sign(secret, old, body), body, "timestamp outside tolerance"},
+		{"in the future", secret, strconv.FormatInt(now+600, 10), Sign(secret, now+600, body), body, "timestamp outside tolerance"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.timestamp, tt.signature, tt.body, 5*time.Minute)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Verify: %v", err)
+			case tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr):
+				t.Errorf("Verify error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS snippets_history;
+DROP TABLE IF EXISTS snippets;
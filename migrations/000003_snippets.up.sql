@@ -0,0 +1,25 @@
+CREATE TABLE snippets (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    content_md TEXT NOT NULL DEFAULT '',
+    version INT NOT NULL DEFAULT 1,
+    changed_by UUID REFERENCES users(id),
+    deleted BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX snippets_name_active ON snippets(name) WHERE deleted = false;
+
+CREATE TABLE snippets_history (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    snippet_id UUID NOT NULL REFERENCES snippets(id) ON DELETE CASCADE,
+    version INT NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    content_md TEXT NOT NULL,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    changed_by UUID REFERENCES users(id),
+    UNIQUE (snippet_id, version)
+);
@@ -0,0 +1,14 @@
+CREATE TABLE page_templates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    section_id TEXT REFERENCES sections(id) ON DELETE CASCADE,
+    content_md TEXT NOT NULL DEFAULT '',
+    version INT NOT NULL DEFAULT 1,
+    changed_by UUID REFERENCES users(id),
+    deleted BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX page_templates_name_active ON page_templates(name) WHERE deleted = false;
@@ -0,0 +1,12 @@
+CREATE TABLE site_variables (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    key TEXT NOT NULL,
+    value TEXT NOT NULL DEFAULT '',
+    section_id TEXT REFERENCES sections(id) ON DELETE CASCADE,
+    changed_by UUID REFERENCES users(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- One global value per key, plus at most one override per section.
+CREATE UNIQUE INDEX site_variables_key_section ON site_variables(key, section_id) NULLS NOT DISTINCT;
@@ -0,0 +1,7 @@
+ALTER TABLE sessions DROP COLUMN IF EXISTS preview_drafts;
+
+ALTER TABLE pages
+    DROP COLUMN IF EXISTS draft_updated_at,
+    DROP COLUMN IF EXISTS draft_content_md,
+    DROP COLUMN IF EXISTS draft_title,
+    DROP COLUMN IF EXISTS published;
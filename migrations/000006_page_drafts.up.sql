@@ -0,0 +1,9 @@
+-- A page's title/content_md are its published revision. Editors can stage
+-- changes in the draft columns; unpublished pages are hidden from readers.
+ALTER TABLE pages
+    ADD COLUMN published BOOLEAN NOT NULL DEFAULT true,
+    ADD COLUMN draft_title TEXT,
+    ADD COLUMN draft_content_md TEXT,
+    ADD COLUMN draft_updated_at TIMESTAMPTZ;
+
+ALTER TABLE sessions ADD COLUMN preview_drafts BOOLEAN NOT NULL DEFAULT false;
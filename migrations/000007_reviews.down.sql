@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS review_comments;
+DROP TABLE IF EXISTS review_votes;
+DROP TABLE IF EXISTS page_reviews;
+
+ALTER TABLE sections DROP COLUMN IF EXISTS required_approvals;
@@ -0,0 +1,44 @@
+-- Sections can require reviewer approvals before a page change is published.
+ALTER TABLE sections ADD COLUMN required_approvals INT NOT NULL DEFAULT 0;
+
+-- A review is a snapshot of a proposed page change. base_content_md is the
+-- published content the change was made against, used to render the diff.
+CREATE TABLE page_reviews (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    page_id UUID NOT NULL REFERENCES pages(id) ON DELETE CASCADE,
+    title TEXT NOT NULL,
+    content_md TEXT NOT NULL,
+    base_title TEXT NOT NULL,
+    base_content_md TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'open'
+        CHECK (status IN ('open', 'changes_requested', 'approved', 'published', 'withdrawn')),
+    submitted_by UUID REFERENCES users(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX page_reviews_page ON page_reviews(page_id);
+CREATE INDEX page_reviews_status ON page_reviews(status);
+
+CREATE TABLE review_votes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    review_id UUID NOT NULL REFERENCES page_reviews(id) ON DELETE CASCADE,
+    reviewer_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    decision TEXT NOT NULL CHECK (decision IN ('approve', 'request_changes')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (review_id, reviewer_id)
+);
+
+-- Comments with a line number are attached to that line of the diff; the
+-- old or new number identifies which side of the change it refers to.
+CREATE TABLE review_comments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    review_id UUID NOT NULL REFERENCES page_reviews(id) ON DELETE CASCADE,
+    author_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    old_line INT,
+    new_line INT,
+    body TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX review_comments_review ON review_comments(review_id);
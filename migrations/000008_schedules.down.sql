@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS pages_unpublish_at;
+DROP INDEX IF EXISTS pages_publish_at;
+
+ALTER TABLE sections
+    DROP COLUMN IF EXISTS unpublish_at,
+    DROP COLUMN IF EXISTS publish_at;
+
+ALTER TABLE pages
+    DROP COLUMN IF EXISTS unpublish_at,
+    DROP COLUMN IF EXISTS publish_at;
@@ -0,0 +1,13 @@
+-- Scheduled publishing. A page or section with publish_at is hidden from
+-- readers until that time; with unpublish_at it is hidden again afterwards.
+-- The scheduler folds passed page schedules into pages.published.
+ALTER TABLE pages
+    ADD COLUMN publish_at TIMESTAMPTZ,
+    ADD COLUMN unpublish_at TIMESTAMPTZ;
+
+ALTER TABLE sections
+    ADD COLUMN publish_at TIMESTAMPTZ,
+    ADD COLUMN unpublish_at TIMESTAMPTZ;
+
+CREATE INDEX pages_publish_at ON pages(publish_at) WHERE publish_at IS NOT NULL;
+CREATE INDEX pages_unpublish_at ON pages(unpublish_at) WHERE unpublish_at IS NOT NULL;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS page_comments;
+DROP TABLE IF EXISTS comment_threads;
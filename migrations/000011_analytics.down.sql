@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS analytics_salts;
+DROP TABLE IF EXISTS view_visitors;
+DROP TABLE IF EXISTS section_views;
+DROP TABLE IF EXISTS page_views;
@@ -0,0 +1,38 @@
+-- First-party view analytics, aggregated per day. role is the viewer's set
+-- of roles joined with commas ('' for users without roles); visitors counts
+-- distinct viewers per day.
+CREATE TABLE page_views (
+    day DATE NOT NULL,
+    page_id UUID NOT NULL REFERENCES pages(id) ON DELETE CASCADE,
+    role TEXT NOT NULL DEFAULT '',
+    views INT NOT NULL DEFAULT 0,
+    visitors INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (day, page_id, role)
+);
+
+CREATE INDEX page_views_page ON page_views(page_id);
+
+CREATE TABLE section_views (
+    day DATE NOT NULL,
+    section_id TEXT NOT NULL REFERENCES sections(id) ON DELETE CASCADE,
+    role TEXT NOT NULL DEFAULT '',
+    views INT NOT NULL DEFAULT 0,
+    visitors INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (day, section_id, role)
+);
+
+-- Salted hashes of the users seen on a page or section today, used only to
+-- count distinct visitors. Salts change daily and both tables are pruned, so
+-- a visitor cannot be followed from one day to the next.
+CREATE TABLE view_visitors (
+    day DATE NOT NULL,
+    target_id TEXT NOT NULL,
+    role TEXT NOT NULL,
+    visitor_hash TEXT NOT NULL,
+    PRIMARY KEY (day, target_id, role, visitor_hash)
+);
+
+CREATE TABLE analytics_salts (
+    day DATE PRIMARY KEY,
+    salt TEXT NOT NULL
+);
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS digest_entries;
+DROP TABLE IF EXISTS subscriptions;
+
+DROP INDEX IF EXISTS users_notify_token;
+ALTER TABLE users DROP COLUMN IF EXISTS last_digest_at;
+ALTER TABLE users DROP COLUMN IF EXISTS notify_token;
+ALTER TABLE users DROP COLUMN IF EXISTS notify_frequency;
@@ -0,0 +1,37 @@
+-- Notification preferences. notify_token authenticates the unsubscribe link
+-- in emails, which must work without logging in.
+ALTER TABLE users ADD COLUMN notify_frequency TEXT NOT NULL DEFAULT 'immediate'
+    CHECK (notify_frequency IN ('immediate', 'daily'));
+ALTER TABLE users ADD COLUMN notify_token UUID NOT NULL DEFAULT gen_random_uuid();
+ALTER TABLE users ADD COLUMN last_digest_at TIMESTAMPTZ;
+
+CREATE UNIQUE INDEX users_notify_token ON users(notify_token);
+
+-- A subscription is to a single page or to a whole section.
+CREATE TABLE subscriptions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    page_id UUID REFERENCES pages(id) ON DELETE CASCADE,
+    section_id TEXT REFERENCES sections(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK ((page_id IS NULL) <> (section_id IS NULL))
+);
+
+CREATE UNIQUE INDEX subscriptions_user_page ON subscriptions(user_id, page_id) WHERE page_id IS NOT NULL;
+CREATE UNIQUE INDEX subscriptions_user_section ON subscriptions(user_id, section_id) WHERE section_id IS NOT NULL;
+CREATE INDEX subscriptions_page ON subscriptions(page_id);
+CREATE INDEX subscriptions_section ON subscriptions(section_id);
+
+-- Page changes waiting for a subscriber's daily digest. The versions refer
+-- to pages_history and bound the change to summarise.
+CREATE TABLE digest_entries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    page_id UUID NOT NULL REFERENCES pages(id) ON DELETE CASCADE,
+    from_version INT NOT NULL,
+    to_version INT NOT NULL,
+    subscription_id UUID NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX digest_entries_user ON digest_entries(user_id);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS users_feed_token;
+ALTER TABLE users DROP COLUMN IF EXISTS feed_token;
+
+DROP INDEX IF EXISTS pages_history_changed_at;
+ALTER TABLE page_reviews DROP COLUMN IF EXISTS summary;
+ALTER TABLE pages_history DROP COLUMN IF EXISTS summary;
@@ -0,0 +1,12 @@
+-- Optional editor-supplied summary of a change, shown on the recent changes
+-- page and in its feeds. A change submitted for review carries its summary
+-- until it is published.
+ALTER TABLE pages_history ADD COLUMN summary TEXT NOT NULL DEFAULT '';
+ALTER TABLE page_reviews ADD COLUMN summary TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX pages_history_changed_at ON pages_history(changed_at DESC);
+
+-- feed_token authenticates feed readers, which cannot log in.
+ALTER TABLE users ADD COLUMN feed_token UUID NOT NULL DEFAULT gen_random_uuid();
+
+CREATE UNIQUE INDEX users_feed_token ON users(feed_token);
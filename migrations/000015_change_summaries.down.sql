@@ -0,0 +1,3 @@
+ALTER TABLE sections DROP COLUMN IF EXISTS require_summary;
+ALTER TABLE images_history DROP COLUMN IF EXISTS summary;
+ALTER TABLE sections_history DROP COLUMN IF EXISTS summary;
@@ -0,0 +1,8 @@
+-- Editor-supplied summaries of section and image changes, like
+-- pages_history.summary.
+ALTER TABLE sections_history ADD COLUMN summary TEXT NOT NULL DEFAULT '';
+ALTER TABLE images_history ADD COLUMN summary TEXT NOT NULL DEFAULT '';
+
+-- Sections in which every change to the section, its pages and its images
+-- must come with a summary. Set by admins.
+ALTER TABLE sections ADD COLUMN require_summary BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS version_pages;
+DROP TABLE IF EXISTS doc_versions;
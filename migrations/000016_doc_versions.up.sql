@@ -0,0 +1,26 @@
+-- Named documentation versions such as "v1.0". The pages of the sections
+-- branched into a version are copied to version_pages; the pages table
+-- keeps the latest documentation. A frozen version is read-only.
+CREATE TABLE doc_versions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL UNIQUE,
+    frozen BOOLEAN NOT NULL DEFAULT false,
+    created_by UUID REFERENCES users(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE version_pages (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    version_id UUID NOT NULL REFERENCES doc_versions(id) ON DELETE CASCADE,
+    section_id TEXT NOT NULL REFERENCES sections(id) ON DELETE CASCADE,
+    slug TEXT NOT NULL,
+    title TEXT NOT NULL,
+    content_md TEXT NOT NULL,
+    sort_order INT NOT NULL DEFAULT 0,
+    parent_slug TEXT,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    changed_by UUID REFERENCES users(id),
+    UNIQUE (version_id, section_id, slug)
+);
+
+CREATE INDEX version_pages_section_id ON version_pages(section_id);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS page_translations;
+ALTER TABLE site_settings_history DROP COLUMN IF EXISTS locales, DROP COLUMN IF EXISTS default_locale;
+ALTER TABLE site_settings DROP COLUMN IF EXISTS locales, DROP COLUMN IF EXISTS default_locale;
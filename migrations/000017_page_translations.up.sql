@@ -0,0 +1,23 @@
+-- The site's default language and the other languages pages may be
+-- translated into, as BCP 47 tags such as "en" or "de-CH".
+ALTER TABLE site_settings
+    ADD COLUMN default_locale TEXT NOT NULL DEFAULT 'en',
+    ADD COLUMN locales TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE site_settings_history
+    ADD COLUMN default_locale TEXT NOT NULL DEFAULT 'en',
+    ADD COLUMN locales TEXT[] NOT NULL DEFAULT '{}';
+
+-- Translations of a page's published title and content. source_version is
+-- the page version the translation was made from; once the page moves past
+-- it the translation is outdated.
+CREATE TABLE page_translations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    page_id UUID NOT NULL REFERENCES pages(id) ON DELETE CASCADE,
+    locale TEXT NOT NULL,
+    title TEXT NOT NULL,
+    content_md TEXT NOT NULL,
+    source_version INT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    changed_by UUID REFERENCES users(id),
+    UNIQUE (page_id, locale)
+);
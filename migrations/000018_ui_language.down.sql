@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN IF EXISTS ui_language;
+ALTER TABLE site_settings_history DROP COLUMN IF EXISTS ui_language;
+ALTER TABLE site_settings DROP COLUMN IF EXISTS ui_language;
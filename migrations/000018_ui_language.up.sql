@@ -0,0 +1,5 @@
+-- Language of the user interface, as the name of a message catalog such as
+-- "de". Users without a language of their own get the site's.
+ALTER TABLE site_settings ADD COLUMN ui_language TEXT NOT NULL DEFAULT 'en';
+ALTER TABLE site_settings_history ADD COLUMN ui_language TEXT NOT NULL DEFAULT 'en';
+ALTER TABLE users ADD COLUMN ui_language TEXT;
@@ -0,0 +1,48 @@
+-- Content of spaces other than the default space is deleted.
+DELETE FROM spaces WHERE id <> '00000000-0000-0000-0000-000000000001';
+
+DROP INDEX IF EXISTS images_space_filename;
+DELETE FROM images a USING images b
+WHERE a.space_id IS NULL AND b.space_id IS NOT NULL AND a.filename = b.filename;
+ALTER TABLE images ADD CONSTRAINT images_filename_key UNIQUE (filename);
+
+ALTER TABLE doc_versions DROP CONSTRAINT IF EXISTS doc_versions_space_name_key;
+ALTER TABLE doc_versions ADD CONSTRAINT doc_versions_name_key UNIQUE (name);
+
+DROP INDEX IF EXISTS webhooks_space;
+
+DROP INDEX IF EXISTS site_variables_key_section;
+CREATE UNIQUE INDEX site_variables_key_section ON site_variables(key, section_id) NULLS NOT DISTINCT;
+
+DROP INDEX IF EXISTS page_templates_name_active;
+CREATE UNIQUE INDEX page_templates_name_active ON page_templates(name) WHERE deleted = false;
+
+DROP INDEX IF EXISTS snippets_name_active;
+CREATE UNIQUE INDEX snippets_name_active ON snippets(name) WHERE deleted = false;
+
+ALTER TABLE roles DROP CONSTRAINT IF EXISTS roles_space_name_key;
+ALTER TABLE roles ADD CONSTRAINT roles_name_key UNIQUE (name);
+
+DROP INDEX IF EXISTS site_settings_history_space;
+DROP INDEX IF EXISTS site_settings_space;
+CREATE UNIQUE INDEX site_settings_singleton_unique ON site_settings(singleton);
+
+DROP INDEX IF EXISTS section_rows_space;
+DROP INDEX IF EXISTS sections_space;
+DROP INDEX IF EXISTS sections_name_active;
+CREATE UNIQUE INDEX sections_name_active ON sections(name) WHERE deleted = false;
+
+ALTER TABLE images DROP COLUMN IF EXISTS space_id;
+ALTER TABLE doc_versions DROP COLUMN IF EXISTS space_id;
+ALTER TABLE webhooks DROP COLUMN IF EXISTS space_id;
+ALTER TABLE site_variables DROP COLUMN IF EXISTS space_id;
+ALTER TABLE page_templates DROP COLUMN IF EXISTS space_id;
+ALTER TABLE snippets DROP COLUMN IF EXISTS space_id;
+ALTER TABLE roles DROP COLUMN IF EXISTS space_id;
+ALTER TABLE site_settings_history DROP COLUMN IF EXISTS space_id;
+ALTER TABLE site_settings DROP COLUMN IF EXISTS space_id;
+ALTER TABLE sections DROP COLUMN IF EXISTS space_id;
+ALTER TABLE section_rows DROP COLUMN IF EXISTS space_id;
+
+DROP TABLE IF EXISTS space_members;
+DROP TABLE IF EXISTS spaces;
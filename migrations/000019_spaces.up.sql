@@ -0,0 +1,87 @@
+-- Spaces are separate documentation sites on one deployment, each with its
+-- own sections, rows, images, settings and roles. A space is reached under
+-- /s/<name>/ or on its own host name. The default space holds the content
+-- from before spaces and is served at the root.
+CREATE TABLE spaces (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL UNIQUE,
+    title TEXT NOT NULL,
+    host TEXT UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+INSERT INTO spaces (id, name, title) VALUES ('00000000-0000-0000-0000-000000000001', 'default', 'Default');
+
+-- Users are shared by all spaces and can use the spaces they are members of.
+CREATE TABLE space_members (
+    space_id UUID NOT NULL REFERENCES spaces(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (space_id, user_id)
+);
+
+CREATE INDEX space_members_user ON space_members(user_id);
+
+INSERT INTO space_members (space_id, user_id)
+SELECT '00000000-0000-0000-0000-000000000001', id FROM users;
+
+-- Existing content moves to the default space.
+ALTER TABLE section_rows ADD COLUMN space_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES spaces(id) ON DELETE CASCADE;
+ALTER TABLE sections ADD COLUMN space_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES spaces(id) ON DELETE CASCADE;
+ALTER TABLE site_settings ADD COLUMN space_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES spaces(id) ON DELETE CASCADE;
+ALTER TABLE site_settings_history ADD COLUMN space_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES spaces(id) ON DELETE CASCADE;
+ALTER TABLE roles ADD COLUMN space_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES spaces(id) ON DELETE CASCADE;
+ALTER TABLE snippets ADD COLUMN space_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES spaces(id) ON DELETE CASCADE;
+ALTER TABLE page_templates ADD COLUMN space_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES spaces(id) ON DELETE CASCADE;
+ALTER TABLE site_variables ADD COLUMN space_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES spaces(id) ON DELETE CASCADE;
+ALTER TABLE webhooks ADD COLUMN space_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES spaces(id) ON DELETE CASCADE;
+ALTER TABLE doc_versions ADD COLUMN space_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES spaces(id) ON DELETE CASCADE;
+
+-- Rendered diagrams are a cache shared by all spaces and have no space.
+ALTER TABLE images ADD COLUMN space_id UUID DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES spaces(id) ON DELETE CASCADE;
+UPDATE images SET space_id = NULL
+WHERE filename LIKE 'diagram-%.svg' AND section_id IS NULL AND changed_by IS NULL;
+
+ALTER TABLE section_rows ALTER COLUMN space_id DROP DEFAULT;
+ALTER TABLE sections ALTER COLUMN space_id DROP DEFAULT;
+ALTER TABLE site_settings ALTER COLUMN space_id DROP DEFAULT;
+ALTER TABLE site_settings_history ALTER COLUMN space_id DROP DEFAULT;
+ALTER TABLE roles ALTER COLUMN space_id DROP DEFAULT;
+ALTER TABLE snippets ALTER COLUMN space_id DROP DEFAULT;
+ALTER TABLE page_templates ALTER COLUMN space_id DROP DEFAULT;
+ALTER TABLE site_variables ALTER COLUMN space_id DROP DEFAULT;
+ALTER TABLE webhooks ALTER COLUMN space_id DROP DEFAULT;
+ALTER TABLE doc_versions ALTER COLUMN space_id DROP DEFAULT;
+ALTER TABLE images ALTER COLUMN space_id DROP DEFAULT;
+
+-- Names are unique per space. Roles belong to a space, so a user's roles
+-- in user_roles differ from space to space.
+DROP INDEX sections_name_active;
+CREATE UNIQUE INDEX sections_name_active ON sections(space_id, name) WHERE deleted = false;
+CREATE INDEX sections_space ON sections(space_id);
+CREATE INDEX section_rows_space ON section_rows(space_id);
+
+DROP INDEX site_settings_singleton_unique;
+CREATE UNIQUE INDEX site_settings_space ON site_settings(space_id);
+CREATE INDEX site_settings_history_space ON site_settings_history(space_id);
+
+ALTER TABLE roles DROP CONSTRAINT roles_name_key;
+ALTER TABLE roles ADD CONSTRAINT roles_space_name_key UNIQUE (space_id, name);
+
+DROP INDEX snippets_name_active;
+CREATE UNIQUE INDEX snippets_name_active ON snippets(space_id, name) WHERE deleted = false;
+
+DROP INDEX page_templates_name_active;
+CREATE UNIQUE INDEX page_templates_name_active ON page_templates(space_id, name) WHERE deleted = false;
+
+DROP INDEX site_variables_key_section;
+CREATE UNIQUE INDEX site_variables_key_section ON site_variables(space_id, key, section_id) NULLS NOT DISTINCT;
+
+CREATE INDEX webhooks_space ON webhooks(space_id);
+
+ALTER TABLE doc_versions DROP CONSTRAINT doc_versions_name_key;
+ALTER TABLE doc_versions ADD CONSTRAINT doc_versions_space_name_key UNIQUE (space_id, name);
+
+ALTER TABLE images DROP CONSTRAINT images_filename_key;
+CREATE UNIQUE INDEX images_space_filename ON images(space_id, filename) NULLS NOT DISTINCT;
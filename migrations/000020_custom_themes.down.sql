@@ -0,0 +1,3 @@
+ALTER TABLE site_settings_history DROP COLUMN IF EXISTS custom_css;
+ALTER TABLE site_settings DROP COLUMN IF EXISTS custom_css;
+DROP TABLE IF EXISTS custom_themes;
@@ -0,0 +1,20 @@
+-- Themes defined by admins. vars maps CSS variable names without the leading
+-- dashes (such as "bg-body") to their values; variables left out fall back
+-- to the midnight theme with the blue accent. site_settings.theme holds
+-- either a built-in theme or the name of one of these.
+CREATE TABLE custom_themes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    space_id UUID NOT NULL REFERENCES spaces(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    label TEXT NOT NULL,
+    vars JSONB NOT NULL DEFAULT '{}',
+    version INT NOT NULL DEFAULT 1,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    changed_by UUID REFERENCES users(id),
+    UNIQUE (space_id, name)
+);
+
+-- Extra CSS appended after the theme variables on every page.
+ALTER TABLE site_settings ADD COLUMN custom_css TEXT NOT NULL DEFAULT '';
+ALTER TABLE site_settings_history ADD COLUMN custom_css TEXT NOT NULL DEFAULT '';
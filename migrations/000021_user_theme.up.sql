@@ -0,0 +1,5 @@
+-- Theme and accent color a user picked for themselves: a built-in theme,
+-- "auto" to follow the device's light or dark mode, or the name of a
+-- custom theme. NULL means the site's.
+ALTER TABLE users ADD COLUMN theme TEXT;
+ALTER TABLE users ADD COLUMN accent_color TEXT;
@@ -0,0 +1,3 @@
+ALTER TABLE site_settings_history DROP COLUMN IF EXISTS font;
+ALTER TABLE site_settings DROP COLUMN IF EXISTS font;
+ALTER TABLE custom_themes DROP COLUMN IF EXISTS font;
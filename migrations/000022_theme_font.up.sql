@@ -0,0 +1,7 @@
+-- Body font of a custom theme: "inter" (self-hosted), "system" or "serif".
+ALTER TABLE custom_themes ADD COLUMN font TEXT NOT NULL DEFAULT 'inter';
+
+-- Body font of the built-in themes, set for the whole site. Custom themes
+-- choose their own.
+ALTER TABLE site_settings ADD COLUMN font TEXT NOT NULL DEFAULT 'inter';
+ALTER TABLE site_settings_history ADD COLUMN font TEXT NOT NULL DEFAULT 'inter';
@@ -0,0 +1,10 @@
+ALTER TABLE site_settings_history
+    DROP COLUMN IF EXISTS home_blocks,
+    DROP COLUMN IF EXISTS home_intro,
+    DROP COLUMN IF EXISTS footer_columns,
+    DROP COLUMN IF EXISTS nav_links;
+ALTER TABLE site_settings
+    DROP COLUMN IF EXISTS home_blocks,
+    DROP COLUMN IF EXISTS home_intro,
+    DROP COLUMN IF EXISTS footer_columns,
+    DROP COLUMN IF EXISTS nav_links;
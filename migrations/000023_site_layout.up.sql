@@ -0,0 +1,14 @@
+-- Links admins add to the header and footer, and the optional home page
+-- blocks. nav_links is a list of {"label", "url"} objects; footer_columns a
+-- list of {"title", "links"} objects with links in the same form.
+-- home_blocks lists the enabled generated blocks ("popular", "recent").
+ALTER TABLE site_settings
+    ADD COLUMN nav_links JSONB NOT NULL DEFAULT '[]',
+    ADD COLUMN footer_columns JSONB NOT NULL DEFAULT '[]',
+    ADD COLUMN home_intro TEXT NOT NULL DEFAULT '',
+    ADD COLUMN home_blocks TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE site_settings_history
+    ADD COLUMN nav_links JSONB NOT NULL DEFAULT '[]',
+    ADD COLUMN footer_columns JSONB NOT NULL DEFAULT '[]',
+    ADD COLUMN home_intro TEXT NOT NULL DEFAULT '',
+    ADD COLUMN home_blocks TEXT[] NOT NULL DEFAULT '{}';
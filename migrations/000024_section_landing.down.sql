@@ -0,0 +1,2 @@
+ALTER TABLE sections_history DROP COLUMN IF EXISTS landing_md;
+ALTER TABLE sections DROP COLUMN IF EXISTS landing_md;
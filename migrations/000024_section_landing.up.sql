@@ -0,0 +1,4 @@
+-- Markdown shown at /{section}/ above cards for the section's pages. Empty
+-- sends readers to the first page instead.
+ALTER TABLE sections ADD COLUMN landing_md TEXT NOT NULL DEFAULT '';
+ALTER TABLE sections_history ADD COLUMN landing_md TEXT NOT NULL DEFAULT '';
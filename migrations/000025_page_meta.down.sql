@@ -0,0 +1 @@
+ALTER TABLE sections DROP COLUMN IF EXISTS show_page_meta;
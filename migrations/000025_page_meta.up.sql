@@ -0,0 +1,2 @@
+-- Whether pages in the section end with "Last updated <date> by <name>".
+ALTER TABLE sections ADD COLUMN show_page_meta BOOLEAN NOT NULL DEFAULT true;
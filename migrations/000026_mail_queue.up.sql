@@ -0,0 +1,15 @@
+-- Notification emails waiting to be sent. Requests only queue them, so a
+-- slow or unreachable SMTP server does not hold up saving a page. Rows are
+-- deleted once sent or given up on.
+CREATE TABLE mail_queue (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    recipient TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    body TEXT NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX mail_queue_due ON mail_queue(next_attempt_at);
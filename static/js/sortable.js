@@ -0,0 +1,181 @@
+// Drag-and-drop reordering of the children of a container, built on the
+// browser's native drag and drop. It implements the part of the SortableJS
+// API the templates use:
+//
+//   new Sortable(el, {
+//     draggable: '.item',   // which children can be dragged (default: all)
+//     handle: '.grip',      // where a drag must start (default: anywhere)
+//     group: 'name',        // lists with the same group exchange items
+//     ghostClass, chosenClass,
+//     onMove(evt),          // return false to refuse, -1/1 to force before/after
+//     onAdd(evt), onUpdate(evt), onEnd(evt)
+//   });
+//
+// onAdd is called on the list an item was dragged into, onUpdate on a list
+// whose order changed, and onEnd on the list the drag started in. The event
+// has item, from, to, oldIndex and newIndex; onMove's has dragged, related,
+// from, to and willInsertAfter.
+(function() {
+  'use strict';
+
+  // The drag in progress: the item, the Sortable it started in and its
+  // index there.
+  var dragging = null;
+
+  function Sortable(el, options) {
+    this.el = el;
+    this.options = options || {};
+    this.armed = null;
+    el._sortable = this;
+    el.addEventListener('mousedown', this._onMouseDown.bind(this));
+    el.addEventListener('dragstart', this._onDragStart.bind(this));
+    el.addEventListener('dragover', this._onDragOver.bind(this));
+  }
+
+  // item returns the draggable child of the list that contains node, if any.
+  Sortable.prototype.item = function(node) {
+    while (node && node.parentElement !== this.el) {
+      node = node.parentElement;
+    }
+    if (!node || node.nodeType !== 1) return null;
+    if (this.options.draggable && !node.matches(this.options.draggable)) return null;
+    return node;
+  };
+
+  Sortable.prototype.index = function(item) {
+    var i = 0;
+    for (var n = this.el.firstElementChild; n; n = n.nextElementSibling) {
+      if (n === item) return i;
+      if (this.item(n)) i++;
+    }
+    return -1;
+  };
+
+  // accepts reports whether items dragged from another list may be dropped
+  // into this one.
+  Sortable.prototype.accepts = function(from) {
+    return from === this || (!!this.options.group && this.options.group === from.options.group);
+  };
+
+  // A drag may only start from the handle; the item is made draggable for
+  // the duration of the press.
+  Sortable.prototype._onMouseDown = function(e) {
+    this.armed = null;
+    var item = this.item(e.target);
+    if (!item) return;
+    var handle = this.options.handle;
+    if (handle) {
+      var h = e.target.closest(handle);
+      if (!h || !item.contains(h)) return;
+    }
+    this.armed = item;
+    if (!item.draggable) {
+      item.draggable = true;
+      item._sortableDraggable = true;
+    }
+  };
+
+  Sortable.prototype._onDragStart = function(e) {
+    var item = this.item(e.target);
+    if (!item || item !== this.armed) return;
+    e.stopPropagation();
+    dragging = { item: item, from: this, oldIndex: this.index(item) };
+    e.dataTransfer.effectAllowed = 'move';
+    try { e.dataTransfer.setData('text/plain', ''); } catch (err) {}
+    if (this.options.chosenClass) item.classList.add(this.options.chosenClass);
+    // The drag image is taken after this handler returns; style the item
+    // left in the list only then.
+    var ghost = this.options.ghostClass;
+    if (ghost) setTimeout(function() { item.classList.add(ghost); }, 0);
+  };
+
+  Sortable.prototype._onDragOver = function(e) {
+    if (!dragging || !this.accepts(dragging.from) || dragging.item.contains(this.el)) return;
+    e.preventDefault();
+    e.stopPropagation();
+    e.dataTransfer.dropEffect = 'move';
+
+    var item = dragging.item;
+    var related = this.item(e.target);
+    if (related === item) return;
+    if (!related) {
+      // Over the list itself, e.g. an empty one: add the item at the end.
+      if (item.parentElement !== this.el) this.el.appendChild(item);
+      return;
+    }
+
+    var rect = related.getBoundingClientRect();
+    var after = horizontal(related, rect)
+      ? e.clientX > rect.left + rect.width / 2
+      : e.clientY > rect.top + rect.height / 2;
+    if (this.options.onMove) {
+      var res = this.options.onMove({
+        dragged: item, related: related, from: dragging.from.el, to: this.el, willInsertAfter: after
+      });
+      if (res === false) return;
+      if (res === -1) after = false;
+      if (res === 1) after = true;
+    }
+    var ref = after ? related.nextSibling : related;
+    if (ref === item || (item.parentElement === this.el && item.nextSibling === ref)) return;
+    this.el.insertBefore(item, ref);
+  };
+
+  // horizontal reports whether the item sits in a row with its neighbours,
+  // as in a grid, rather than above or below them.
+  function horizontal(item, rect) {
+    var sib = item.previousElementSibling || item.nextElementSibling;
+    if (!sib) return false;
+    return Math.abs(sib.getBoundingClientRect().top - rect.top) < rect.height / 2;
+  }
+
+  function call(sortable, name, evt) {
+    var fn = sortable && sortable.options[name];
+    if (fn) fn(evt);
+  }
+
+  document.addEventListener('dragend', function() {
+    if (!dragging) return;
+    var d = dragging;
+    dragging = null;
+
+    var item = d.item;
+    var opts = d.from.options;
+    if (opts.ghostClass) item.classList.remove(opts.ghostClass);
+    if (opts.chosenClass) item.classList.remove(opts.chosenClass);
+    if (item._sortableDraggable) {
+      item.removeAttribute('draggable');
+      delete item._sortableDraggable;
+    }
+    d.from.armed = null;
+
+    var to = item.parentElement && item.parentElement._sortable;
+    var evt = {
+      item: item, from: d.from.el, to: to ? to.el : null,
+      oldIndex: d.oldIndex, newIndex: to ? to.index(item) : -1
+    };
+    if (to && to !== d.from) {
+      call(to, 'onAdd', evt);
+    } else if (evt.newIndex !== evt.oldIndex) {
+      call(d.from, 'onUpdate', evt);
+    }
+    call(d.from, 'onEnd', evt);
+  });
+
+  // Dropping must not navigate to the (empty) dragged data.
+  document.addEventListener('drop', function(e) {
+    if (dragging) e.preventDefault();
+  });
+
+  document.addEventListener('mouseup', function() {
+    if (dragging) return;
+    document.querySelectorAll('[draggable]').forEach(function(n) {
+      if (n._sortableDraggable) {
+        n.removeAttribute('draggable');
+        delete n._sortableDraggable;
+      }
+    });
+  });
+
+  window.Sortable = Sortable;
+})();